@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	ptp "github.com/subutai-io/p2p/lib"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultInstancesDir is a directory with declarative instance files
+const DefaultInstancesDir = "/etc/p2p/instances.d"
+
+// Actions of apply plan
+const (
+	ApplyCreate    = "create"
+	ApplyRecreate  = "recreate"
+	ApplyUpdate    = "update"
+	ApplyDelete    = "delete"
+	ApplyUnchanged = "unchanged"
+)
+
+// KeySpec declares a crypto key of an instance
+type KeySpec struct {
+	Key string `yaml:"key" json:"key"`
+	TTL string `yaml:"ttl" json:"ttl"` // Unix timestamp
+}
+
+// InstanceSpec declares desired state of an instance. Empty MAC, device
+// and port mean that any value is acceptable
+type InstanceSpec struct {
+	Hash    string    `yaml:"hash" json:"hash"`
+	IP      string    `yaml:"ip" json:"ip"` // Static address or dhcp
+	Mac     string    `yaml:"mac" json:"mac"`
+	Dev     string    `yaml:"dev" json:"dev"`
+	Keyfile string    `yaml:"keyfile" json:"keyfile"`
+	Keys    []KeySpec `yaml:"keys" json:"keys"`
+	Port    int       `yaml:"port" json:"port"`
+	Fwd     bool      `yaml:"fwd" json:"fwd"`
+	MTU     int       `yaml:"mtu" json:"mtu"` // Daemon MTU is used when 0
+}
+
+// InstancesFile is a content of a single declarative file
+type InstancesFile struct {
+	Instances []InstanceSpec `yaml:"instances"`
+}
+
+// ApplyRequest is a body of apply request
+type ApplyRequest struct {
+	Instances []InstanceSpec `json:"instances"`
+	DryRun    bool           `json:"dry_run"`
+}
+
+// ApplyAction is a single step of apply plan
+type ApplyAction struct {
+	Action  string   `json:"action" yaml:"action"`
+	Hash    string   `json:"hash" yaml:"hash"`
+	Changes []string `json:"changes,omitempty" yaml:"changes,omitempty"`
+	Error   string   `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ApplyResponse is a versioned response of `apply` command
+type ApplyResponse struct {
+	Version int           `json:"version" yaml:"version"`
+	Code    int           `json:"code" yaml:"code"`
+	Error   string        `json:"error,omitempty" yaml:"error,omitempty"`
+	DryRun  bool          `json:"dry_run" yaml:"dry_run"`
+	Actions []ApplyAction `json:"actions" yaml:"actions"`
+}
+
+func normalizeIP(ip string) string {
+	if ip == "" || ip == "auto" {
+		return "dhcp"
+	}
+	return ip
+}
+
+func (s *InstanceSpec) validate() error {
+	if s.Hash == "" {
+		return fmt.Errorf("Hash cannot be empty")
+	}
+	if strings.Contains(s.Hash, "~") {
+		return fmt.Errorf("Hash %s contains ~", s.Hash)
+	}
+	if s.Mac != "" {
+		if _, err := net.ParseMAC(s.Mac); err != nil {
+			return fmt.Errorf("Instance %s: invalid MAC address: %s", s.Hash, err)
+		}
+	}
+	ip := normalizeIP(s.IP)
+	if ip != "dhcp" && net.ParseIP(ip) == nil {
+		if _, _, err := net.ParseCIDR(ip); err != nil {
+			return fmt.Errorf("Instance %s: invalid IP address %s", s.Hash, s.IP)
+		}
+	}
+	if s.MTU < 0 || s.MTU > 65535 {
+		return fmt.Errorf("Instance %s: invalid MTU %d", s.Hash, s.MTU)
+	}
+	for _, k := range s.Keys {
+		if k.Key == "" {
+			return fmt.Errorf("Instance %s: key cannot be empty", s.Hash)
+		}
+	}
+	return nil
+}
+
+// loadInstanceSpecs reads declarative files. A directory path means every
+// *.yaml and *.yml file in it
+func loadInstanceSpecs(paths ...string) ([]InstanceSpec, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		for _, ext := range []string{"*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(path, ext))
+			sort.Strings(matches)
+			files = append(files, matches...)
+		}
+	}
+
+	specs := []InstanceSpec{}
+	seen := make(map[string]string)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		content := InstancesFile{}
+		err = yaml.UnmarshalStrict(data, &content)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse %s: %s", file, err)
+		}
+		for _, spec := range content.Instances {
+			if err := spec.validate(); err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+			if prev, exists := seen[spec.Hash]; exists {
+				return nil, fmt.Errorf("Instance %s is declared in both %s and %s", spec.Hash, prev, file)
+			}
+			seen[spec.Hash] = file
+			specs = append(specs, spec)
+		}
+	}
+	return specs, nil
+}
+
+// CommandApply sends declared instances to daemon, which converges
+// running instances to them
+func CommandApply(restPort int, paths []string, dryRun bool, format string) {
+	validateFormat(format)
+	if len(paths) == 0 {
+		paths = []string{DefaultInstancesDir}
+	}
+	specs, err := loadInstanceSpecs(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	data, err := json.Marshal(&ApplyRequest{Instances: specs, DryRun: dryRun})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal request: %s\n", err)
+		os.Exit(1)
+	}
+	client, req, err := controlRequest(restPort, "POST", "/rest/v1/apply", bytes.NewBuffer(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create request: %s\n", err)
+		os.Exit(1)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Couldn't execute command. Check if p2p daemon is running.")
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	out := &ApplyResponse{}
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to unmarshal response: %s\n", err)
+		os.Exit(1)
+	}
+	if isStructured(format) {
+		printStructured(format, out)
+		os.Exit(out.Code)
+	}
+	if len(out.Actions) > 0 {
+		fmt.Print(formatPlan(out))
+	}
+	if out.Error != "" {
+		fmt.Fprintln(os.Stderr, out.Error)
+	}
+	os.Exit(out.Code)
+}
+
+func formatPlan(out *ApplyResponse) string {
+	marks := map[string]string{
+		ApplyCreate:    "+",
+		ApplyRecreate:  "~",
+		ApplyUpdate:    "~",
+		ApplyDelete:    "-",
+		ApplyUnchanged: " ",
+	}
+	counts := make(map[string]int)
+	result := ""
+	for _, a := range out.Actions {
+		counts[a.Action]++
+		result += fmt.Sprintf("%s %s %s\n", marks[a.Action], a.Action, a.Hash)
+		for _, c := range a.Changes {
+			result += fmt.Sprintf("    %s\n", c)
+		}
+		if a.Error != "" {
+			result += fmt.Sprintf("    failed: %s\n", a.Error)
+		}
+	}
+	result += fmt.Sprintf("Plan: %d to create, %d to recreate, %d to update, %d to delete\n",
+		counts[ApplyCreate], counts[ApplyRecreate], counts[ApplyUpdate], counts[ApplyDelete])
+	if out.DryRun {
+		result += "Dry run, no changes were applied\n"
+	}
+	return result
+}
+
+// hasKey checks whether instance already uses the key
+func hasKey(keys []ptp.CryptoKey, key string) bool {
+	for _, k := range keys {
+		if string(k.Key) == key || string(k.Key) == normalizeKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// planInstance compares declared instance with the running one
+func planInstance(spec InstanceSpec, inst *P2PInstance) ApplyAction {
+	action := ApplyAction{Hash: spec.Hash, Changes: []string{}}
+	if inst == nil {
+		action.Action = ApplyCreate
+		return action
+	}
+	args := inst.Args
+	if normalizeIP(spec.IP) != normalizeIP(args.IP) {
+		action.Changes = append(action.Changes, fmt.Sprintf("ip: %s -> %s", normalizeIP(args.IP), normalizeIP(spec.IP)))
+	}
+	if spec.Mac != "" && !strings.EqualFold(spec.Mac, args.Mac) {
+		action.Changes = append(action.Changes, fmt.Sprintf("mac: %s -> %s", args.Mac, spec.Mac))
+	}
+	if spec.Dev != "" && spec.Dev != args.Dev {
+		action.Changes = append(action.Changes, fmt.Sprintf("dev: %s -> %s", args.Dev, spec.Dev))
+	}
+	if spec.Port != 0 && spec.Port != args.Port {
+		action.Changes = append(action.Changes, fmt.Sprintf("port: %d -> %d", args.Port, spec.Port))
+	}
+	if spec.Fwd != args.Fwd {
+		action.Changes = append(action.Changes, fmt.Sprintf("fwd: %t -> %t", args.Fwd, spec.Fwd))
+	}
+	if spec.MTU != args.MTU {
+		action.Changes = append(action.Changes, fmt.Sprintf("mtu: %d -> %d", args.MTU, spec.MTU))
+	}
+	if spec.Keyfile != args.Keyfile {
+		action.Changes = append(action.Changes, fmt.Sprintf("keyfile: %s -> %s", args.Keyfile, spec.Keyfile))
+	}
+	if len(action.Changes) > 0 {
+		action.Action = ApplyRecreate
+		return action
+	}
+	if inst.PTP != nil {
+		for _, k := range spec.Keys {
+			if !hasKey(inst.PTP.Crypter.Keys, k.Key) {
+				action.Changes = append(action.Changes, fmt.Sprintf("add key with ttl %s", k.TTL))
+			}
+		}
+	}
+	if len(action.Changes) > 0 {
+		action.Action = ApplyUpdate
+		return action
+	}
+	action.Action = ApplyUnchanged
+	return action
+}
+
+// planApply returns actions required to converge running instances to
+// declared ones. Instances that are not declared are deleted
+func (d *Daemon) planApply(specs []InstanceSpec) []ApplyAction {
+	actions := []ApplyAction{}
+	declared := make(map[string]bool)
+	for _, spec := range specs {
+		declared[spec.Hash] = true
+		actions = append(actions, planInstance(spec, d.Instances.getInstance(spec.Hash)))
+	}
+	for hash := range d.Instances.get() {
+		if !declared[hash] {
+			actions = append(actions, ApplyAction{Action: ApplyDelete, Hash: hash, Changes: []string{}})
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].Hash < actions[j].Hash })
+	return actions
+}
+
+// createFromSpec starts declared instance and adds remaining keys
+func (d *Daemon) createFromSpec(spec InstanceSpec) error {
+	args := &RunArgs{
+		IP:      normalizeIP(spec.IP),
+		Mac:     spec.Mac,
+		Dev:     spec.Dev,
+		Hash:    spec.Hash,
+		Keyfile: spec.Keyfile,
+		Fwd:     spec.Fwd,
+		Port:    spec.Port,
+		MTU:     spec.MTU,
+	}
+	if len(spec.Keys) > 0 {
+		args.Key = spec.Keys[0].Key
+		args.TTL = spec.Keys[0].TTL
+	}
+	response := new(Response)
+	err := d.run(args, response)
+	if err == nil && response.ExitCode != 0 {
+		err = fmt.Errorf("%s", strings.TrimSpace(response.Output))
+	}
+	if err != nil {
+		return err
+	}
+	d.saveInstance(args)
+	return d.addSpecKeys(spec)
+}
+
+func (d *Daemon) addSpecKeys(spec InstanceSpec) error {
+	inst := d.Instances.getInstance(spec.Hash)
+	if inst == nil || inst.PTP == nil {
+		return fmt.Errorf("Instance %s is not running", spec.Hash)
+	}
+	for _, k := range spec.Keys {
+		if !hasKey(inst.PTP.Crypter.Keys, k.Key) {
+			inst.PTP.AddKey(normalizeKey(k.Key), k.TTL)
+		}
+	}
+	return nil
+}
+
+func (d *Daemon) stopForApply(hash string) error {
+	response := new(Response)
+	d.Stop(&DaemonArgs{Hash: hash}, response)
+	if response.ExitCode != 0 {
+		return fmt.Errorf("%s", response.Output)
+	}
+	return nil
+}
+
+// apply executes plan. Failed actions are reported and don't prevent
+// remaining actions from execution
+func (d *Daemon) apply(specs []InstanceSpec, actions []ApplyAction) bool {
+	bySpec := make(map[string]InstanceSpec)
+	for _, spec := range specs {
+		bySpec[spec.Hash] = spec
+	}
+	success := true
+	for i, a := range actions {
+		var err error
+		ptp.Log(ptp.Info, "Apply: %s %s", a.Action, a.Hash)
+		switch a.Action {
+		case ApplyCreate:
+			err = d.createFromSpec(bySpec[a.Hash])
+		case ApplyRecreate:
+			err = d.stopForApply(a.Hash)
+			if err == nil {
+				err = d.createFromSpec(bySpec[a.Hash])
+			}
+		case ApplyUpdate:
+			err = d.addSpecKeys(bySpec[a.Hash])
+		case ApplyDelete:
+			err = d.stopForApply(a.Hash)
+		}
+		if err != nil {
+			ptp.Log(ptp.Error, "Apply: failed to %s %s: %s", a.Action, a.Hash, err)
+			actions[i].Error = err.Error()
+			success = false
+		}
+	}
+	return success
+}
+
+func (d *Daemon) execRESTApply(w http.ResponseWriter, r *http.Request) {
+	response := &ApplyResponse{Version: OutputVersion, Actions: []ApplyAction{}}
+	defer func() {
+		output, err := json.Marshal(response)
+		if err != nil {
+			ptp.Log(ptp.Error, "Failed to marshal apply response: %s", err)
+			return
+		}
+		w.Write(output)
+	}()
+
+	req := new(ApplyRequest)
+	data, err := ioutil.ReadAll(r.Body)
+	if err == nil {
+		err = json.Unmarshal(data, req)
+	}
+	if err != nil {
+		response.Code = 1
+		response.Error = fmt.Sprintf("Failed to read request body: %s", err)
+		return
+	}
+	response.DryRun = req.DryRun
+	for _, spec := range req.Instances {
+		if err := spec.validate(); err != nil {
+			response.Code = 1
+			response.Error = err.Error()
+			return
+		}
+	}
+	if !req.DryRun && requestRole(r) < RoleAdmin {
+		response.Code = http.StatusForbidden
+		response.Error = "Applying changes requires admin role"
+		return
+	}
+	response.Code, response.Error = daemonState()
+	if response.Code != 0 {
+		return
+	}
+
+	response.Actions = d.planApply(req.Instances)
+	if req.DryRun {
+		return
+	}
+	if !d.apply(req.Instances, response.Actions) {
+		response.Code = 1
+		response.Error = "Some actions failed"
+	}
+}
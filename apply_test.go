@@ -0,0 +1,104 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+func TestLoadInstanceSpecs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-instances")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to write %s: %s", name, err)
+		}
+		return path
+	}
+	write("a.yaml", "instances:\n  - hash: swarm-a\n    ip: 10.10.10.1\n")
+	write("b.yml", "instances:\n  - hash: swarm-b\n    keys:\n      - key: secret\n        ttl: \"1700000000\"\n")
+	write("ignored.txt", "garbage")
+	dup := write("dup.conf", "instances:\n  - hash: swarm-a\n")
+	bad := write("bad.conf", "instances:\n  - hash: swarm-c\n    ip: 10.10.10\n")
+	unknown := write("unknown.conf", "instances:\n  - hash: swarm-c\n    address: dhcp\n")
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    int
+		wantErr bool
+	}{
+		{"directory", []string{dir}, 2, false},
+		{"duplicate hash", []string{dir, dup}, 0, true},
+		{"bad ip", []string{bad}, 0, true},
+		{"unknown field", []string{unknown}, 0, true},
+		{"missing", []string{filepath.Join(dir, "missing")}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadInstanceSpecs(tt.paths...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadInstanceSpecs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("loadInstanceSpecs() returned %d specs, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_planApply(t *testing.T) {
+	d := new(Daemon)
+	d.Instances = new(InstanceList)
+	d.Instances.init()
+	newInst := func(args RunArgs, keys ...string) *P2PInstance {
+		p := &ptp.PeerToPeer{}
+		for _, k := range keys {
+			p.Crypter.Keys = append(p.Crypter.Keys, ptp.CryptoKey{Key: []byte(normalizeKey(k))})
+		}
+		return &P2PInstance{ID: args.Hash, Args: args, PTP: p}
+	}
+	d.Instances.update("same", newInst(RunArgs{Hash: "same", IP: "dhcp"}, "key"))
+	d.Instances.update("newkey", newInst(RunArgs{Hash: "newkey", IP: "10.0.0.1"}, "key"))
+	d.Instances.update("moved", newInst(RunArgs{Hash: "moved", IP: "10.0.0.1", Port: 5000}))
+	d.Instances.update("orphan", newInst(RunArgs{Hash: "orphan"}))
+	d.Instances.update("mtu", newInst(RunArgs{Hash: "mtu", IP: "dhcp"}))
+
+	specs := []InstanceSpec{
+		{Hash: "same", Keys: []KeySpec{{Key: "key"}}},
+		{Hash: "newkey", IP: "10.0.0.1", Keys: []KeySpec{{Key: "key"}, {Key: "other"}}},
+		{Hash: "moved", IP: "10.0.0.2", Port: 5000},
+		{Hash: "fresh"},
+		{Hash: "mtu", MTU: 1400},
+	}
+	want := map[string]string{
+		"same":   ApplyUnchanged,
+		"newkey": ApplyUpdate,
+		"moved":  ApplyRecreate,
+		"fresh":  ApplyCreate,
+		"orphan": ApplyDelete,
+		"mtu":    ApplyRecreate,
+	}
+	actions := d.planApply(specs)
+	if len(actions) != len(want) {
+		t.Fatalf("Daemon.planApply() returned %d actions, want %d", len(actions), len(want))
+	}
+	for _, a := range actions {
+		if want[a.Hash] != a.Action {
+			t.Errorf("Daemon.planApply() %s = %s, want %s", a.Hash, a.Action, want[a.Hash])
+		}
+	}
+	for i := 1; i < len(actions); i++ {
+		if actions[i-1].Hash > actions[i].Hash {
+			t.Errorf("Daemon.planApply() actions are not sorted")
+		}
+	}
+}
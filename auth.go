@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+// Role is an access level of control API client
+type Role int
+
+// Roles of control API clients
+const (
+	RoleNone  Role = iota // Not authenticated
+	RoleRead              // Allowed to query state
+	RoleAdmin             // Allowed to modify state
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+func parseRole(role string) (Role, error) {
+	switch role {
+	case "read", "readonly", "read-only":
+		return RoleRead, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("Unknown role %q", role)
+}
+
+// DefaultSocketMode is a permission of control socket when not configured
+const DefaultSocketMode = 0660
+
+type roleKey struct{}
+
+// apiAuth authenticates control API requests and assigns roles to them
+type apiAuth struct {
+	tokens     map[string]Role
+	certRoles  map[string]Role
+	socketRole Role
+	// enabled is false when neither tokens nor client certificates
+	// are configured. TCP clients are trusted in that case
+	enabled bool
+}
+
+func newAPIAuth(conf ptp.APIConf) (*apiAuth, error) {
+	a := &apiAuth{
+		tokens:     make(map[string]Role),
+		certRoles:  make(map[string]Role),
+		socketRole: RoleAdmin,
+	}
+	if conf.SocketRole != "" {
+		role, err := parseRole(conf.SocketRole)
+		if err != nil {
+			return nil, fmt.Errorf("Bad socket role: %s", err)
+		}
+		a.socketRole = role
+	}
+	for _, t := range conf.Tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("Token %s is empty", t.Name)
+		}
+		role, err := parseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("Bad role of token %s: %s", t.Name, err)
+		}
+		a.tokens[t.Token] = role
+	}
+	for _, c := range conf.CertRoles {
+		role, err := parseRole(c.Role)
+		if err != nil {
+			return nil, fmt.Errorf("Bad role of certificate %s: %s", c.CommonName, err)
+		}
+		a.certRoles[c.CommonName] = role
+	}
+	a.enabled = len(a.tokens) > 0 || conf.ClientCA != ""
+	return a, nil
+}
+
+// connContext marks requests received over Unix socket
+func (a *apiAuth) connContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*net.UnixConn); ok {
+		return context.WithValue(ctx, roleKey{}, a.socketRole)
+	}
+	return ctx
+}
+
+// role returns role of the request's client
+func (a *apiAuth) role(r *http.Request) Role {
+	if role, ok := r.Context().Value(roleKey{}).(Role); ok {
+		return role
+	}
+	if !a.enabled {
+		return RoleAdmin
+	}
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		token := []byte(strings.TrimPrefix(auth, "Bearer "))
+		for t, role := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(t), token) == 1 {
+				return role
+			}
+		}
+		return RoleNone
+	}
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		// Certificates without a listed common name get no access
+		return a.certRoles[cn]
+	}
+	return RoleNone
+}
+
+// require wraps handler to reject clients with a role lower than
+// specified. Role of the client is stored in request context
+func (a *apiAuth) require(role Role, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got := a.role(r)
+		if got == RoleNone {
+			writeAuthError(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, "Authentication required")
+			return
+		}
+		if got < role {
+			writeAuthError(w, r, http.StatusForbidden, ErrCodeForbidden, fmt.Sprintf("Role %s is not allowed to perform this request", got))
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, got)))
+	}
+}
+
+// requestRole returns role assigned to the request by apiAuth.require
+func requestRole(r *http.Request) Role {
+	role, _ := r.Context().Value(roleKey{}).(Role)
+	return role
+}
+
+// writeAuthError responds with v2 error body on v2 endpoints and with
+// v1 response elsewhere, so existing clients can display the message
+func writeAuthError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	if strings.HasPrefix(r.URL.Path, RESTv2Prefix) {
+		writeAPIError(w, newAPIError(status, code, "%s", message))
+		return
+	}
+	data, _ := json.Marshal(&RESTResponse{Code: status, Message: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// apiTLSConfig returns TLS configuration of TCP listener or nil when
+// HTTPS is not configured
+func apiTLSConfig(conf ptp.APIConf) (*tls.Config, error) {
+	if conf.TLSCert == "" && conf.TLSKey == "" {
+		if conf.ClientCA != "" {
+			return nil, fmt.Errorf("Client CA requires tls_cert and tls_key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(conf.TLSCert, conf.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load server certificate: %s", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if conf.ClientCA != "" {
+		pem, err := ioutil.ReadFile(conf.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read client CA: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", conf.ClientCA)
+		}
+		config.ClientCAs = pool
+		// Clients may authenticate with bearer token instead
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// listenSocket creates control Unix socket with configured permissions
+func listenSocket(conf ptp.APIConf) (net.Listener, error) {
+	mode := os.FileMode(DefaultSocketMode)
+	if conf.SocketMode != "" {
+		m, err := strconv.ParseUint(conf.SocketMode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("Bad socket mode %s: %s", conf.SocketMode, err)
+		}
+		mode = os.FileMode(m)
+	}
+	// Remove socket left from previous run
+	if _, err := os.Stat(conf.Socket); err == nil {
+		os.Remove(conf.Socket)
+	}
+	l, err := net.Listen("unix", conf.Socket)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(conf.Socket, mode)
+	if err != nil {
+		l.Close()
+		return nil, fmt.Errorf("Failed to set socket permissions: %s", err)
+	}
+	return l, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+func TestNewAPIAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		conf    ptp.APIConf
+		enabled bool
+		wantErr bool
+	}{
+		{"empty", ptp.APIConf{}, false, false},
+		{"tokens", ptp.APIConf{Tokens: []ptp.APIToken{{Name: "ro", Token: "t", Role: "read"}}}, true, false},
+		{"client ca", ptp.APIConf{ClientCA: "/etc/p2p/ca.pem"}, true, false},
+		{"empty token", ptp.APIConf{Tokens: []ptp.APIToken{{Name: "ro", Role: "read"}}}, false, true},
+		{"bad role", ptp.APIConf{Tokens: []ptp.APIToken{{Name: "ro", Token: "t", Role: "root"}}}, false, true},
+		{"bad socket role", ptp.APIConf{SocketRole: "root"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAPIAuth(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newAPIAuth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && a.enabled != tt.enabled {
+				t.Errorf("newAPIAuth() enabled = %v, want %v", a.enabled, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestAPIAuth_require(t *testing.T) {
+	a, err := newAPIAuth(ptp.APIConf{
+		SocketRole: "read",
+		Tokens: []ptp.APIToken{
+			{Name: "ro", Token: "read-token", Role: "read"},
+			{Name: "rw", Token: "admin-token", Role: "admin"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newAPIAuth() failed: %s", err)
+	}
+	open, _ := newAPIAuth(ptp.APIConf{})
+
+	tests := []struct {
+		name   string
+		auth   *apiAuth
+		role   Role
+		token  string
+		socket bool
+		status int
+	}{
+		{"no token", a, RoleRead, "", false, http.StatusUnauthorized},
+		{"wrong token", a, RoleRead, "wrong", false, http.StatusUnauthorized},
+		{"read token on read", a, RoleRead, "read-token", false, http.StatusOK},
+		{"read token on admin", a, RoleAdmin, "read-token", false, http.StatusForbidden},
+		{"admin token on admin", a, RoleAdmin, "admin-token", false, http.StatusOK},
+		{"socket role", a, RoleAdmin, "", true, http.StatusForbidden},
+		{"no auth configured", open, RoleAdmin, "", false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.auth.require(tt.role, func(w http.ResponseWriter, r *http.Request) {
+				if requestRole(r) < tt.role {
+					t.Errorf("Handler received role %s", requestRole(r))
+				}
+			})
+			r := httptest.NewRequest(http.MethodPost, "/rest/v1/stop", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			if tt.socket {
+				r = r.WithContext(context.WithValue(r.Context(), roleKey{}, tt.auth.socketRole))
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			if w.Code != tt.status {
+				t.Errorf("apiAuth.require() status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestAPIAuth_certRole(t *testing.T) {
+	a, err := newAPIAuth(ptp.APIConf{
+		ClientCA:  "/etc/p2p/ca.pem",
+		CertRoles: []ptp.APIRole{{CommonName: "operator", Role: "admin"}},
+	})
+	if err != nil {
+		t.Fatalf("newAPIAuth() failed: %s", err)
+	}
+	tests := []struct {
+		name string
+		cn   string
+		want Role
+	}{
+		{"listed", "operator", RoleAdmin},
+		{"not listed", "someone", RoleNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/rest/v1/show", nil)
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: tt.cn}}
+			r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			if got := a.role(r); got != tt.want {
+				t.Errorf("apiAuth.role() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
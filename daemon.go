@@ -3,11 +3,14 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"os"
 	"os/signal"
 	"runtime/pprof"
 	"strings"
+	"syscall"
 	"time"
 
 	ptp "github.com/subutai-io/p2p/lib"
@@ -21,23 +24,25 @@ var (
 // DaemonArgs arguments used by daemon to manipulate
 // p2p behaviour
 type DaemonArgs struct {
-	IP         string `json:"ip"`
-	Mac        string `json:"mac"`
-	Dev        string `json:"dev"`
-	Hash       string `json:"hash"`
-	Dht        string `json:"dht"`
-	Keyfile    string `json:"keyfile"`
-	Key        string `json:"key"`
-	TTL        string `json:"ttl"`
-	Fwd        bool   `json:"fwd"`
-	Port       int    `json:"port"`
-	Interfaces bool   `json:"interfaces"` // show only
-	All        bool   `json:"all"`        // show only
-	Command    string `json:"command"`
-	Args       string `json:"args"`
-	Log        string `json:"log"`
-	Bind       bool   `json:"bind"`
-	MTU        bool   `json:"mtu"`
+	IP           string `json:"ip"`
+	Mac          string `json:"mac"`
+	Dev          string `json:"dev"`
+	Hash         string `json:"hash"`
+	Dht          string `json:"dht"`
+	Keyfile      string `json:"keyfile"`
+	Key          string `json:"key"`
+	TTL          string `json:"ttl"`
+	Fwd          bool   `json:"fwd"`
+	Port         int    `json:"port"`
+	Interfaces   bool   `json:"interfaces"` // show only
+	All          bool   `json:"all"`        // show only
+	Command      string `json:"command"`
+	Args         string `json:"args"`
+	Log          string `json:"log"`
+	Bind         bool   `json:"bind"`
+	MTU          bool   `json:"mtu"`
+	ResetTraffic bool   `json:"reset_traffic"`
+	Format       string `json:"format"`
 }
 
 var bootstrap DHTConnection
@@ -74,13 +79,126 @@ func configureMTU(conf *ptp.Conf, mtu int, pmtu bool) {
 	}
 }
 
+func configureHooks(conf *ptp.Conf) *ptp.HookRunner {
+	if conf == nil || conf.GetHooks().IsEmpty() {
+		return nil
+	}
+	ptp.Log(ptp.Info, "Event hooks enabled")
+	return ptp.RunHooks(ptp.GlobalEvents, conf.GetHooks())
+}
+
+func configureLogging(conf *ptp.Conf, logLevel, syslog string) {
+	if conf != nil {
+		logLevel = conf.GetLogLevel(logLevel)
+		syslog = conf.GetSyslog(syslog)
+	}
+	if logLevel == "" {
+		logLevel = DefaultLog
+	}
+	ptp.ClearSubsystemLogLevels()
+	if err := ptp.SetLogLevels(logLevel); err != nil {
+		ptp.Log(ptp.Error, "Failed to set log level: %s", err)
+	}
+	if conf != nil {
+		if err := ptp.SetLogFormat(conf.GetLogFormat()); err != nil {
+			ptp.Log(ptp.Error, "%s", err)
+		}
+	}
+	if syslog != "" {
+		ptp.SetSyslogSocket(syslog)
+	}
+}
+
+// configureLogOutput opens log destinations selected in configuration.
+// Opened log file is returned, so it can be closed when replaced
+func configureLogOutput(conf *ptp.Conf) (io.Closer, error) {
+	out := ptp.LogOutputConf{}
+	if conf != nil {
+		out = conf.GetLogOutput()
+	}
+	writers := []io.Writer{}
+	if out.StdoutEnabled() {
+		writers = append(writers, os.Stdout)
+	}
+	var file *ptp.RotatingFile
+	if out.File.Path != "" {
+		var err error
+		file, err = ptp.OpenLogFile(out.File)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, file)
+	}
+	err := ptp.SetJournal(out.Journald)
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+	switch len(writers) {
+	case 0:
+		ptp.SetLogOutput(ioutil.Discard)
+	case 1:
+		ptp.SetLogOutput(writers[0])
+	default:
+		ptp.SetLogOutput(io.MultiWriter(writers...))
+	}
+	if file == nil {
+		return nil, nil
+	}
+	return file, nil
+}
+
+func bootstrapNodes(conf *ptp.Conf) []string {
+	if conf == nil {
+		return nil
+	}
+	return conf.GetBootstrap()
+}
+
+func apiConf(conf *ptp.Conf) ptp.APIConf {
+	if conf == nil {
+		return ptp.APIConf{}
+	}
+	return conf.GetAPI()
+}
+
+func masterKeyFile(conf *ptp.Conf) string {
+	if conf == nil {
+		return ""
+	}
+	return conf.GetMasterKeyFile()
+}
+
+// configureMasterKey loads a key used to seal secrets in the save file
+func configureMasterKey(conf *ptp.Conf, r *Restore) {
+	if !r.isActive() {
+		// Key is not used, but still must not reach hooks
+		os.Unsetenv(MasterKeyEnv)
+		return
+	}
+	key, source, err := loadMasterKey(masterKeyFile(conf))
+	if err != nil {
+		ptp.Log(ptp.Error, "%s. Save file will not be loaded or overwritten", err)
+		r.locked = true
+		return
+	}
+	if key == nil {
+		ptp.Log(ptp.Warning, "Master key is not configured. Keys will be kept in save file in plain text")
+		return
+	}
+	ptp.Log(ptp.Info, "Using master key from %s", source)
+	r.setMasterKey(key)
+}
+
 // ExecDaemon starts P2P daemon
 func ExecDaemon(port int, targetURL, sFile, profiling, syslog, logLevel, configFile string, mtu int, pmtu bool) {
 	ptp.Log(ptp.Info, "Initializing P2P Daemon")
 	if logLevel == "" {
 		ptp.SetMinLogLevelString(DefaultLog)
 	} else {
-		ptp.SetMinLogLevelString(logLevel)
+		ptp.SetLogLevels(logLevel)
 	}
 
 	var err error
@@ -97,14 +215,27 @@ func ExecDaemon(port int, targetURL, sFile, profiling, syslog, logLevel, configF
 	if targetURL == "" {
 		targetURL = "subutai.io"
 	}
-	if syslog != "" {
-		ptp.SetSyslogSocket(syslog)
+	configureLogging(config, logLevel, syslog)
+	logFile, err := configureLogOutput(config)
+	if err != nil {
+		ptp.Log(ptp.Error, "Failed to configure log output: %s", err)
 	}
 	StartProfiling(profiling)
 	ptp.InitPlatform()
 	ptp.InitErrors()
 
 	configureMTU(config, mtu, pmtu)
+	ptp.TAPQueues = config.GetTAPQueues()
+	ptp.UseOffload = config.GetOffload()
+	ptp.UnderlayMTU = config.GetUnderlayMTU()
+	ptp.UseCompression = config.GetCompression()
+	if err := ptp.SetMultipath(config.GetMultipath()); err != nil {
+		ptp.Log(ptp.Error, "%s", err)
+	}
+	if err := ptp.SetDuplicatePorts(config.GetDuplicatePorts()); err != nil {
+		ptp.Log(ptp.Error, "%s", err)
+	}
+	hooks := configureHooks(config)
 
 	if !ptp.HavePrivileges(ptp.GetPrivilegesLevel()) {
 		os.Exit(1)
@@ -119,7 +250,7 @@ func ExecDaemon(port int, targetURL, sFile, profiling, syslog, logLevel, configF
 	for !bootstrapConnected {
 		if time.Since(bootstrapLastConnection) > time.Duration(time.Second*5) {
 			bootstrapLastConnection = time.Now()
-			err := bootstrap.init(targetURL)
+			err := bootstrap.init(targetURL, bootstrapNodes(config))
 			if err == nil {
 				bootstrapConnected = true
 			} else {
@@ -134,19 +265,39 @@ func ExecDaemon(port int, targetURL, sFile, profiling, syslog, logLevel, configF
 
 	proc := new(Daemon)
 	proc.init(sFile)
-	setupRESTHandlers(port, proc)
+	configureMasterKey(config, proc.Restore)
+	proc.settings = &daemonSettings{
+		configFile: configFile,
+		targetURL:  targetURL,
+		logLevel:   logLevel,
+		syslog:     syslog,
+		mtu:        mtu,
+		pmtu:       pmtu,
+		conf:       config,
+		hooks:      hooks,
+		logFile:    logFile,
+	}
+	setupRESTHandlers(port, proc, apiConf(config))
 
 	go restoreInstances(proc)
 
 	ReadyToServe = true
 
 	SignalChannel = make(chan os.Signal, 1)
-	signal.Notify(SignalChannel, os.Interrupt)
+	signal.Notify(SignalChannel, os.Interrupt, syscall.SIGHUP)
 
 	go waitActiveBootstrap()
 
 	go func() {
 		for sig := range SignalChannel {
+			if sig == syscall.SIGHUP {
+				ptp.Log(ptp.Info, "Received SIGHUP, reloading configuration")
+				_, err := proc.reload()
+				if err != nil {
+					ptp.Log(ptp.Error, "Failed to reload configuration: %s", err)
+				}
+				continue
+			}
 			fmt.Println("Received signal: ", sig)
 			pprof.StopCPUProfile()
 			os.Exit(0)
@@ -171,14 +322,14 @@ func ExecDaemon(port int, targetURL, sFile, profiling, syslog, logLevel, configF
 }
 
 func waitOutboundIP() {
-	for _, r := range bootstrap.routers {
+	for _, r := range bootstrap.getRouters() {
 		if r != nil {
 			go r.run()
 			go r.keepAlive()
 		}
 	}
 	for !bootstrap.isActive {
-		for _, r := range bootstrap.routers {
+		for _, r := range bootstrap.getRouters() {
 			if r.running && r.handshaked {
 				bootstrap.isActive = true
 				break
@@ -195,7 +346,7 @@ func waitOutboundIP() {
 func waitActiveBootstrap() {
 	for {
 		active := 0
-		for _, r := range bootstrap.routers {
+		for _, r := range bootstrap.getRouters() {
 			if !r.stop {
 				active++
 			}
@@ -240,6 +391,9 @@ func restoreInstances(daemon *Daemon) {
 				Keyfile: e.Keyfile,
 				Key:     e.Key,
 				TTL:     e.TTL,
+				Fwd:     e.Fwd,
+				Port:    e.Port,
+				MTU:     e.MTU,
 			}, new(Response))
 			if err != nil {
 				ptp.Log(ptp.Error, "Failed to start instance %s during restore: %s", e.Hash, err.Error())
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -12,7 +13,24 @@ import (
 )
 
 // CommandDebug prints debug information
-func CommandDebug(restPort int) {
+func CommandDebug(restPort int, format string) {
+	validateFormat(format)
+	if isStructured(format) {
+		out, err := sendRequestRaw(restPort, "debug", &request{Format: format})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		response := new(DebugResponse)
+		err = json.Unmarshal(out, response)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to unmarshal debug response: %s\n", err)
+			os.Exit(125)
+		}
+		printStructured(format, response)
+		os.Exit(response.Code)
+	}
+
 	out, err := sendRequest(restPort, "debug", &DaemonArgs{})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
@@ -24,6 +42,20 @@ func CommandDebug(restPort int) {
 }
 
 func (d *Daemon) execRESTDebug(w http.ResponseWriter, r *http.Request) {
+	args := new(DaemonArgs)
+	err := getJSON(r.Body, args)
+	if handleMarshalError(err, w) != nil {
+		return
+	}
+	if isStructured(args.Format) {
+		output, err := json.Marshal(d.debugStructured())
+		if err != nil {
+			ptp.Log(ptp.Error, "Failed to marshal debug response: %s", err)
+			return
+		}
+		w.Write(output)
+		return
+	}
 	if !ReadyToServe {
 		resp, _ := getResponse(105, "P2P Daemon is in initialization state")
 		w.Write(resp)
@@ -39,11 +71,6 @@ func (d *Daemon) execRESTDebug(w http.ResponseWriter, r *http.Request) {
 		w.Write(resp)
 		return
 	}
-	args := new(DaemonArgs)
-	err := getJSON(r.Body, args)
-	if handleMarshalError(err, w) != nil {
-		return
-	}
 	response := new(Response)
 	d.Debug(&Args{
 		Command: args.Command,
@@ -62,13 +89,13 @@ func (p *Daemon) Debug(args *Args, resp *Response) error {
 	resp.Output = fmt.Sprintf("Version: %s Build: %s\n", AppVersion, BuildID)
 	resp.Output += fmt.Sprintf("Uptime: %d h %d m %d s\n", int(time.Since(StartTime).Hours()), int(time.Since(StartTime).Minutes())%60, int(time.Since(StartTime).Seconds())%60)
 	resp.Output += fmt.Sprintf("Number of gouroutines: %d\n", runtime.NumGoroutine())
-	if ptp.UsePMTU {
+	if p.settings.instanceConfig(ptp.Config{}).PMTU {
 		resp.Output += fmt.Sprintf("PMTU: Enabled\n")
 	} else {
 		resp.Output += fmt.Sprintf("PMTU: Disabled\n")
 	}
 	resp.Output += fmt.Sprintf("Bootstrap nodes information:\n")
-	for _, node := range bootstrap.routers {
+	for _, node := range bootstrap.getRouters() {
 		if node != nil {
 			resp.Output += fmt.Sprintf("  %s Rx: %d Tx: %d Version: %s Packet version: %s\n", node.addr.String(), node.rx, node.tx, node.version, node.packetVersion)
 		}
@@ -121,6 +148,14 @@ func (p *Daemon) Debug(args *Args, resp *Response) error {
 				cdelta := peer.Stat.GetConnectionTimeDelta()
 				rdelta := peer.Stat.GetReconnectionTimeDelta()
 				resp.Output += fmt.Sprintf("Stats: [C: %d] [R: %d] [HP: %d] [CDelta: %d] [RDelta: %d]\n", c, r, hp, cdelta, rdelta)
+				resp.Output += fmt.Sprintf("\tTraffic: ")
+				for _, path := range ptp.PathTypes {
+					resp.Output += fmt.Sprintf("[%s %s] ", path, formatTraffic(peer.Traffic.Path(path)))
+				}
+				resp.Output += "\n"
+				for ep, counters := range peer.Traffic.Endpoints() {
+					resp.Output += fmt.Sprintf("\t\t%s %s\n", ep, formatTraffic(counters))
+				}
 			}
 			resp.Output += fmt.Sprintf("\tEndpoints pool: ")
 			pool := []*net.UDPAddr{}
@@ -135,3 +170,36 @@ func (p *Daemon) Debug(args *Args, resp *Response) error {
 	}
 	return nil
 }
+
+// debugStructured returns versioned debug information
+func (d *Daemon) debugStructured() *DebugResponse {
+	response := &DebugResponse{
+		Version:    OutputVersion,
+		AppVersion: AppVersion,
+		Build:      BuildID,
+		Uptime:     int64(time.Since(StartTime).Seconds()),
+		Goroutines: runtime.NumGoroutine(),
+		PMTU:       d.settings.instanceConfig(ptp.Config{}).PMTU,
+		Bootstrap:  []BootstrapOutput{},
+		Instances:  []InstanceOutput{},
+	}
+	response.Code, response.Error = daemonState()
+	if response.Code != 0 {
+		return response
+	}
+	for _, node := range bootstrap.getRouters() {
+		if node == nil {
+			continue
+		}
+		response.Bootstrap = append(response.Bootstrap, BootstrapOutput{
+			Addr:          node.router,
+			Connected:     node.running && node.handshaked,
+			Rx:            node.rx,
+			Tx:            node.tx,
+			Version:       node.version,
+			PacketVersion: node.packetVersion,
+		})
+	}
+	response.Instances = d.newInstancesOutput("", true)
+	return response
+}
@@ -21,6 +21,7 @@ var (
 type DHTConnection struct {
 	routers     []*DHTRouter             // Bootstrap nodes
 	routersList map[int]string           // List of bootstrap nodes received from SRV lookup
+	routersLock sync.RWMutex             // Mutex for routers
 	lock        sync.Mutex               // Mutex for register/unregister
 	instances   map[string]*P2PInstance  // Instances
 	registered  []string                 // List of registered swarm IDs
@@ -29,11 +30,11 @@ type DHTConnection struct {
 	isActive    bool                     // Whether DHT connection is active or not
 }
 
-func (dht *DHTConnection) init(target string) error {
+func (dht *DHTConnection) init(target string, nodes []string) error {
 	ptp.Log(ptp.Debug, "Initializing connection to a bootstrap nodes")
 	dht.incoming = make(chan *protocol.DHTPacket)
 	var err error
-	dht.routersList, err = ptp.SrvLookup(target, "tcp", "subutai.io")
+	dht.routersList, err = lookupRouters(target, nodes)
 	if err != nil {
 		ptp.Log(ptp.Debug, "Failed to get bootstrap nodes: %s", err.Error())
 		dht.routersList = make(map[int]string)
@@ -45,21 +46,107 @@ func (dht *DHTConnection) init(target string) error {
 		if r == "" {
 			continue
 		}
-		addr, err := net.ResolveTCPAddr("tcp4", r)
+		router, err := dht.newRouter(r)
 		if err != nil {
-			ptp.Log(ptp.Error, "Bad router address provided [%s]: %s", r, err)
-			return ErrorBadRouterAddress
+			return err
 		}
-		router := new(DHTRouter)
-		router.addr = addr
-		router.router = r
-		router.data = dht.incoming
 		dht.routers = append(dht.routers, router)
 	}
 	dht.instances = make(map[string]*P2PInstance)
 	return nil
 }
 
+// lookupRouters returns bootstrap nodes specified in configuration or
+// received from SRV lookup when none were specified
+func lookupRouters(target string, nodes []string) (map[int]string, error) {
+	if len(nodes) == 0 {
+		return ptp.SrvLookup(target, "tcp", "subutai.io")
+	}
+	list := make(map[int]string)
+	for i, node := range nodes {
+		list[i] = node
+	}
+	return list, nil
+}
+
+func (dht *DHTConnection) newRouter(r string) (*DHTRouter, error) {
+	addr, err := net.ResolveTCPAddr("tcp4", r)
+	if err != nil {
+		ptp.Log(ptp.Error, "Bad router address provided [%s]: %s", r, err)
+		return nil, ErrorBadRouterAddress
+	}
+	router := new(DHTRouter)
+	router.addr = addr
+	router.router = r
+	router.data = dht.incoming
+	return router, nil
+}
+
+// getRouters returns a copy of bootstrap nodes list, so it can be
+// iterated while reload replaces the list
+func (dht *DHTConnection) getRouters() []*DHTRouter {
+	dht.routersLock.RLock()
+	defer dht.routersLock.RUnlock()
+	routers := make([]*DHTRouter, len(dht.routers))
+	copy(routers, dht.routers)
+	return routers
+}
+
+// updateRouters connects to bootstrap nodes that are not in use yet and
+// disconnects from nodes missing in the list
+func (dht *DHTConnection) updateRouters(nodes []string) (added, removed []string, err error) {
+	wanted := make(map[string]bool)
+	newRouters := []*DHTRouter{}
+	for _, r := range nodes {
+		if r == "" || wanted[r] {
+			continue
+		}
+		wanted[r] = true
+		exists := false
+		for _, router := range dht.getRouters() {
+			if router.router == r && !router.stop {
+				exists = true
+			}
+		}
+		if exists {
+			continue
+		}
+		router, err := dht.newRouter(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", r, err)
+		}
+		newRouters = append(newRouters, router)
+	}
+	if len(wanted) == 0 {
+		return nil, nil, ErrorNoRouters
+	}
+
+	dht.routersLock.Lock()
+	defer dht.routersLock.Unlock()
+	routers := []*DHTRouter{}
+	for _, router := range dht.routers {
+		if wanted[router.router] {
+			routers = append(routers, router)
+			continue
+		}
+		ptp.Log(ptp.Info, "Disconnecting from bootstrap node %s", router.router)
+		router.stop = true
+		if router.conn != nil {
+			router.conn.Close()
+		}
+		removed = append(removed, router.router)
+	}
+	for _, router := range newRouters {
+		ptp.Log(ptp.Info, "Connecting to bootstrap node %s", router.router)
+		go router.run()
+		go router.keepAlive()
+		routers = append(routers, router)
+		added = append(added, router.router)
+	}
+	dht.routers = routers
+	return added, removed, nil
+}
+
 func (dht *DHTConnection) registerInstance(hash string, inst *P2PInstance) error {
 	dht.lock.Lock()
 	defer dht.lock.Unlock()
@@ -108,7 +195,7 @@ func (dht *DHTConnection) send(packet *protocol.DHTPacket) {
 		ptp.Log(ptp.Error, "Failed to marshal DHT Packet: %s", err)
 	}
 	ptp.Log(ptp.Trace, "Sending marshaled DHT Packet of size [%d]", len(data))
-	for i, router := range dht.routers {
+	for _, router := range dht.getRouters() {
 		if router.running && router.handshaked {
 			n, err := router.sendRaw(data)
 			if err != nil {
@@ -116,7 +203,7 @@ func (dht *DHTConnection) send(packet *protocol.DHTPacket) {
 				continue
 			}
 			if n >= 0 {
-				dht.routers[i].tx += uint64(n)
+				router.tx += uint64(n)
 			}
 		}
 	}
@@ -150,7 +237,7 @@ func (dht *DHTConnection) run() {
 			continue
 		}
 		i, e := dht.instances[packet.Infohash]
-		if e && i != nil && i.PTP != nil && !i.PTP.Shutdown && i.PTP.Dht != nil && i.PTP.Dht.IncomingData != nil {
+		if e && i != nil && i.PTP != nil && !i.PTP.IsShutdown() && i.PTP.Dht != nil && i.PTP.Dht.IncomingData != nil {
 			i.PTP.Dht.IncomingData <- packet
 		} else {
 			ptp.Log(ptp.Debug, "DHT received data for unknown instance %s: %+v", packet.Infohash, packet)
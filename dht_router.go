@@ -159,7 +159,7 @@ func (dht *DHTRouter) sleep() {
 func (dht *DHTRouter) keepAlive() {
 	lastPing := time.Now()
 	dht.lastContact = time.Now()
-	for {
+	for !dht.stop {
 		if time.Since(lastPing) > time.Duration(time.Millisecond*30000) && time.Since(dht.lastContact) > time.Duration(time.Millisecond*40) {
 			lastPing = time.Now()
 			if dht.ping() != nil {
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+// CommandEvents will subscribe to daemon's event stream and print
+// received events until interrupted
+func CommandEvents(rpcPort int, hash, types, format string) {
+	validateFormat(format)
+	if useGRPC() {
+		list := []string{}
+		for _, t := range strings.Split(types, ",") {
+			if t != "" {
+				list = append(list, t)
+			}
+		}
+		err := watchGRPCEvents(rpcPort, hash, list, func(event ptp.Event) {
+			printEvent(format, event)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to receive events: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	query := url.Values{}
+	if hash != "" {
+		query.Set("hash", hash)
+	}
+	if types != "" {
+		query.Set("type", types)
+	}
+	client, req, err := controlRequest(rpcPort, "GET", "/rest/v1/events?"+query.Encode(), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create request: %s\n", err)
+		os.Exit(1)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to execute request: %s\n", err)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "Daemon responded with %s\n", resp.Status)
+		os.Exit(1)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		event := ptp.Event{}
+		err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to parse event: %s\n", err)
+			continue
+		}
+		printEvent(format, event)
+	}
+}
+
+// printEvent prints event in requested format
+func printEvent(format string, event ptp.Event) {
+	switch format {
+	case FormatJSON:
+		// One event per line
+		data, _ := json.Marshal(event)
+		fmt.Println(string(data))
+	case FormatYAML:
+		fmt.Println("---")
+		printStructured(format, &event)
+	default:
+		fmt.Println(formatEvent(event))
+	}
+}
+
+func formatEvent(e ptp.Event) string {
+	out := fmt.Sprintf("%s %s %s", e.Time.Format("2006-01-02 15:04:05"), e.Hash, e.Type)
+	if e.PeerID != "" {
+		out += " " + e.PeerID
+	}
+	keys := []string{"from", "to", "state", "action", "proxy", "endpoint", "ip", "mac", "device", "until", "id"}
+	for _, k := range keys {
+		if v, exists := e.Data[k]; exists {
+			out += fmt.Sprintf(" %s=%s", k, v)
+		}
+	}
+	return out
+}
+
+// execRESTEvents streams events published by instances as
+// server-sent events
+func (d *Daemon) execRESTEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	types := []ptp.EventType{}
+	for _, t := range strings.Split(r.URL.Query().Get("type"), ",") {
+		if t != "" {
+			types = append(types, ptp.EventType(t))
+		}
+	}
+	sub := ptp.GlobalEvents.Subscribe(r.URL.Query().Get("hash"), 0, types...)
+	defer ptp.GlobalEvents.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ptp.Log(ptp.Debug, "Events subscriber connected from %s", r.RemoteAddr)
+	for {
+		select {
+		case <-r.Context().Done():
+			ptp.Log(ptp.Debug, "Events subscriber %s disconnected", r.RemoteAddr)
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				ptp.Log(ptp.Error, "Failed to marshal event: %s", err)
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
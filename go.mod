@@ -0,0 +1,27 @@
+module github.com/subutai-io/p2p
+
+go 1.24.0
+
+require (
+	github.com/NebulousLabs/go-upnp v0.0.0-20180202185039-29b680b06c82
+	github.com/golang/protobuf v1.5.4
+	github.com/google/uuid v1.6.0
+	github.com/mdlayher/ethernet v0.0.0-20190606142754-0394541c37b7
+	github.com/pierrec/lz4/v4 v4.1.22
+	github.com/urfave/cli/v2 v2.27.7
+	golang.org/x/net v0.50.0
+	golang.org/x/sys v0.41.0
+	google.golang.org/grpc v1.80.0
+	google.golang.org/protobuf v1.36.12
+	gopkg.in/yaml.v2 v2.4.0
+)
+
+require (
+	github.com/NebulousLabs/fastrand v0.0.0-20181203155948-6fb6489aac4e // indirect
+	github.com/cpuguy83/go-md2man/v2 v2.0.7 // indirect
+	github.com/russross/blackfriday/v2 v2.1.0 // indirect
+	github.com/xrash/smetrics v0.0.0-20240521201337-686a1a2994c1 // indirect
+	golang.org/x/crypto v0.48.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260120221211-b8f7ae30c516 // indirect
+)
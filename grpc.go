@@ -0,0 +1,660 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	ptp "github.com/subutai-io/p2p/lib"
+	"github.com/subutai-io/p2p/protocol/control"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC control service is served on the same listeners as REST API, so
+// the same access control applies. RPCs call the same logic as REST v2
+// handlers
+
+// grpcPolicy describes access requirements of an RPC
+type grpcPolicy struct {
+	role  Role
+	ready bool // RPC requires daemon to be connected to bootstrap
+}
+
+var grpcPolicies = map[string]grpcPolicy{
+	control.Control_GetDaemon_FullMethodName:      {RoleRead, false},
+	control.Control_Reload_FullMethodName:         {RoleAdmin, false},
+	control.Control_ListBootstrap_FullMethodName:  {RoleRead, true},
+	control.Control_ListInstances_FullMethodName:  {RoleRead, true},
+	control.Control_GetInstance_FullMethodName:    {RoleRead, true},
+	control.Control_CreateInstance_FullMethodName: {RoleAdmin, true},
+	control.Control_DeleteInstance_FullMethodName: {RoleAdmin, true},
+	control.Control_ListPeers_FullMethodName:      {RoleRead, true},
+	control.Control_GetPeer_FullMethodName:        {RoleRead, true},
+	control.Control_ListKeys_FullMethodName:       {RoleRead, true},
+	control.Control_AddKey_FullMethodName:         {RoleAdmin, true},
+	control.Control_WatchEvents_FullMethodName:    {RoleRead, false},
+}
+
+// grpcAuthorize checks role of the client stored in context by
+// apiAuth.grpc and readiness of the daemon
+func grpcAuthorize(ctx context.Context, method string) error {
+	policy, exists := grpcPolicies[method]
+	if !exists {
+		return status.Errorf(codes.Unimplemented, "Unknown method %s", method)
+	}
+	role, _ := ctx.Value(roleKey{}).(Role)
+	if role == RoleNone {
+		return status.Error(codes.Unauthenticated, "Authentication required")
+	}
+	if role < policy.role {
+		return status.Errorf(codes.PermissionDenied, "Role %s is not allowed to perform this request", role)
+	}
+	if policy.ready {
+		if apiErr := apiReady(); apiErr != nil {
+			return grpcError(apiErr)
+		}
+	}
+	return nil
+}
+
+// grpcError converts v2 API error to gRPC status
+func grpcError(e *APIError) error {
+	code := codes.Internal
+	switch e.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	}
+	return status.Error(code, e.Message)
+}
+
+// newGRPCServer creates gRPC server of control service
+func newGRPCServer(d *Daemon) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := grpcAuthorize(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := grpcAuthorize(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	control.RegisterControlServer(server, &controlServer{d: d})
+	return server
+}
+
+// grpc passes gRPC requests to server. Role of the client is stored in
+// request context and checked by interceptors, so clients receive gRPC
+// statuses instead of HTTP errors
+func (a *apiAuth) grpc(server *grpc.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, a.role(r))))
+	}
+}
+
+// controlServer implements gRPC control service
+type controlServer struct {
+	control.UnimplementedControlServer
+	d *Daemon
+}
+
+func (s *controlServer) GetDaemon(ctx context.Context, req *control.GetDaemonRequest) (*control.Daemon, error) {
+	out := newDaemonOutput()
+	return &control.Daemon{
+		AppVersion: out.AppVersion,
+		Build:      out.Build,
+		Uptime:     out.Uptime,
+		Goroutines: int32(out.Goroutines),
+		Ready:      out.Ready,
+		State:      int32(out.State),
+		Message:    out.Message,
+	}, nil
+}
+
+func (s *controlServer) Reload(ctx context.Context, req *control.ReloadRequest) (*control.ReloadResponse, error) {
+	out, err := s.d.reload()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &control.ReloadResponse{
+		Applied:         out.Applied,
+		RestartRequired: out.RestartRequired,
+		Failed:          out.Failed,
+	}, nil
+}
+
+func (s *controlServer) ListBootstrap(ctx context.Context, req *control.ListBootstrapRequest) (*control.ListBootstrapResponse, error) {
+	resp := &control.ListBootstrapResponse{}
+	for _, node := range newBootstrapOutput() {
+		resp.Bootstrap = append(resp.Bootstrap, &control.Bootstrap{
+			Addr:          node.Addr,
+			Connected:     node.Connected,
+			Rx:            node.Rx,
+			Tx:            node.Tx,
+			Version:       node.Version,
+			PacketVersion: node.PacketVersion,
+		})
+	}
+	return resp, nil
+}
+
+func (s *controlServer) ListInstances(ctx context.Context, req *control.ListInstancesRequest) (*control.ListInstancesResponse, error) {
+	resp := &control.ListInstancesResponse{}
+	for _, inst := range s.d.newInstancesOutput("", false) {
+		resp.Instances = append(resp.Instances, instanceToProto(inst))
+	}
+	return resp, nil
+}
+
+func (s *controlServer) GetInstance(ctx context.Context, req *control.GetInstanceRequest) (*control.Instance, error) {
+	inst, apiErr := s.d.apiInstance(req.Hash)
+	if apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	return instanceToProto(newInstanceOutput(inst, true)), nil
+}
+
+func (s *controlServer) CreateInstance(ctx context.Context, req *control.CreateInstanceRequest) (*control.Instance, error) {
+	out, apiErr := s.d.createInstance(&InstanceRequest{
+		Hash:    req.Hash,
+		IP:      req.Ip,
+		Mac:     req.Mac,
+		Dev:     req.Dev,
+		Keyfile: req.Keyfile,
+		Key:     req.Key,
+		TTL:     req.Ttl,
+		Fwd:     req.Fwd,
+		Port:    int(req.Port),
+	})
+	if apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	return instanceToProto(*out), nil
+}
+
+func (s *controlServer) DeleteInstance(ctx context.Context, req *control.DeleteInstanceRequest) (*control.DeleteInstanceResponse, error) {
+	if apiErr := s.d.deleteInstance(req.Hash); apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	return &control.DeleteInstanceResponse{}, nil
+}
+
+func (s *controlServer) ListPeers(ctx context.Context, req *control.ListPeersRequest) (*control.ListPeersResponse, error) {
+	peers, apiErr := s.d.listPeers(req.Hash)
+	if apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	resp := &control.ListPeersResponse{}
+	for _, peer := range peers {
+		resp.Peers = append(resp.Peers, peerToProto(peer))
+	}
+	return resp, nil
+}
+
+func (s *controlServer) GetPeer(ctx context.Context, req *control.GetPeerRequest) (*control.Peer, error) {
+	peer, apiErr := s.d.getPeer(req.Hash, req.Id)
+	if apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	return peerToProto(*peer), nil
+}
+
+func (s *controlServer) ListKeys(ctx context.Context, req *control.ListKeysRequest) (*control.ListKeysResponse, error) {
+	keys, apiErr := s.d.listKeys(req.Hash)
+	if apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	resp := &control.ListKeysResponse{}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, &control.Key{Until: key.Until, Active: key.Active})
+	}
+	return resp, nil
+}
+
+func (s *controlServer) AddKey(ctx context.Context, req *control.AddKeyRequest) (*control.Key, error) {
+	key, apiErr := s.d.addKey(req.Hash, &KeyRequest{Key: req.Key, TTL: req.Ttl})
+	if apiErr != nil {
+		return nil, grpcError(apiErr)
+	}
+	return &control.Key{Until: key.Until, Active: key.Active}, nil
+}
+
+// WatchEvents streams events published by instances until client
+// disconnects
+func (s *controlServer) WatchEvents(req *control.WatchEventsRequest, stream grpc.ServerStreamingServer[control.Event]) error {
+	types := []ptp.EventType{}
+	for _, t := range req.Types {
+		types = append(types, ptp.EventType(t))
+	}
+	sub := ptp.GlobalEvents.Subscribe(req.Hash, 0, types...)
+	defer ptp.GlobalEvents.Unsubscribe(sub)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(eventToProto(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func eventToProto(e ptp.Event) *control.Event {
+	return &control.Event{
+		Type:   string(e.Type),
+		Time:   e.Time.Format(time.RFC3339Nano),
+		Hash:   e.Hash,
+		PeerId: e.PeerID,
+		Data:   e.Data,
+	}
+}
+
+func instanceToProto(inst InstanceOutput) *control.Instance {
+	out := &control.Instance{
+		Hash:         inst.Hash,
+		Id:           inst.ID,
+		Ip:           inst.IP,
+		Mac:          inst.Mac,
+		Interface:    inst.Interface,
+		Port:         int32(inst.Port),
+		DhtConnected: inst.DHTConnected,
+		LocalIps:     inst.LocalIPs,
+	}
+	for _, proxy := range inst.Proxies {
+		out.Proxies = append(out.Proxies, &control.Proxy{
+			Addr:      proxy.Addr,
+			Endpoint:  proxy.Endpoint,
+			Active:    proxy.Active,
+			LatencyMs: proxy.Latency,
+			Rtt:       rttToProto(proxy.RTT),
+		})
+	}
+	for _, peer := range inst.Peers {
+		out.Peers = append(out.Peers, peerToProto(peer))
+	}
+	return out
+}
+
+func peerToProto(peer PeerOutput) *control.Peer {
+	out := &control.Peer{
+		Id:          peer.ID,
+		Ip:          peer.IP,
+		Mac:         peer.Mac,
+		State:       peer.State,
+		RemoteState: peer.RemoteState,
+		LastError:   peer.LastError,
+		Endpoint:    peer.Endpoint,
+		Path:        peer.Path,
+		LastContact: peer.LastContact,
+		KnownIps:    peer.KnownIPs,
+		Proxies:     peer.Proxies,
+		Paths:       peer.Paths,
+		Stats: &control.PeerStats{
+			ConnectionAttempts: int32(peer.Stats.ConnectionAttempts),
+			Reconnects:         int32(peer.Stats.Reconnects),
+			HolePunches:        int32(peer.Stats.HolePunches),
+			StartedAt:          peer.Stats.StartedAt,
+			ConnectedAt:        peer.Stats.ConnectedAt,
+			ConnectionLostAt:   peer.Stats.ConnectionLostAt,
+			ReconnectedAt:      peer.Stats.ReconnectedAt,
+		},
+		Traffic: &control.Traffic{
+			Total:     countersToProto(peer.Traffic.Total),
+			Paths:     make(map[string]*control.TrafficCounters),
+			Endpoints: make(map[string]*control.TrafficCounters),
+			Since:     peer.Traffic.Since,
+		},
+	}
+	for _, ep := range peer.Endpoints {
+		out.Endpoints = append(out.Endpoints, &control.Endpoint{
+			Addr:        ep.Addr,
+			LatencyMs:   ep.Latency,
+			LastContact: ep.LastContact,
+			ScoreMs:     ep.Score,
+			Mtu:         int32(ep.MTU),
+			Rtt:         rttToProto(ep.RTT),
+		})
+	}
+	for path, c := range peer.Traffic.Paths {
+		out.Traffic.Paths[path] = countersToProto(c)
+	}
+	for ep, c := range peer.Traffic.Endpoints {
+		out.Traffic.Endpoints[ep] = countersToProto(c)
+	}
+	if peer.Compression != nil {
+		out.Compression = &control.Compression{
+			Frames:          peer.Compression.Frames,
+			Skipped:         peer.Compression.Skipped,
+			Bytes:           peer.Compression.Bytes,
+			CompressedBytes: peer.Compression.Compressed,
+			Ratio:           peer.Compression.Ratio,
+		}
+	}
+	return out
+}
+
+func countersToProto(c ptp.TrafficCounters) *control.TrafficCounters {
+	return &control.TrafficCounters{
+		TxBytes:   c.TxBytes,
+		RxBytes:   c.RxBytes,
+		TxPackets: c.TxPackets,
+		RxPackets: c.RxPackets,
+	}
+}
+
+func rttToProto(rtt *RTTOutput) *control.RTT {
+	if rtt == nil {
+		return nil
+	}
+	return &control.RTT{
+		Samples:     int32(rtt.Samples),
+		Lost:        int32(rtt.Lost),
+		LossPercent: rtt.Loss,
+		MinMs:       rtt.Min,
+		AvgMs:       rtt.Avg,
+		MaxMs:       rtt.Max,
+		P95Ms:       rtt.P95,
+		JitterMs:    rtt.Jitter,
+	}
+}
+
+// useGRPC returns true when client commands should use gRPC control
+// service instead of REST API
+func useGRPC() bool {
+	return os.Getenv(EnvAPIGRPC) != ""
+}
+
+// bearerToken attaches API token to every RPC
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity returns false, because tokens are also
+// accepted on Unix socket and plain TCP port
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// dialControl creates a client of gRPC control service. Transport and
+// credentials are taken from the same environment variables as REST
+func dialControl(port int) (control.ControlClient, *grpc.ClientConn, error) {
+	target := fmt.Sprintf("localhost:%d", port)
+	if socket := os.Getenv(EnvAPISocket); socket != "" {
+		target = "unix:" + socket
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if os.Getenv(EnvAPISocket) == "" {
+		config, err := clientTLSConfig()
+		if err != nil {
+			return nil, nil, err
+		}
+		if config != nil {
+			opts[0] = grpc.WithTransportCredentials(credentials.NewTLS(config))
+		}
+	}
+	if token := os.Getenv(EnvAPIToken); token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return control.NewControlClient(conn), conn, nil
+}
+
+// sendGRPCRequest executes start or stop command over gRPC and returns
+// response in the same form as REST API
+func sendGRPCRequest(port int, command string, args *DaemonArgs) (*RESTResponse, error) {
+	client, conn, err := dialControl(port)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create gRPC client: %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out := &RESTResponse{}
+	switch command {
+	case "start":
+		_, err = client.CreateInstance(ctx, &control.CreateInstanceRequest{
+			Hash:    args.Hash,
+			Ip:      args.IP,
+			Mac:     args.Mac,
+			Dev:     args.Dev,
+			Keyfile: args.Keyfile,
+			Key:     args.Key,
+			Ttl:     args.TTL,
+			Fwd:     args.Fwd,
+			Port:    int32(args.Port),
+		})
+		out.Message = "Instance created: " + args.Hash
+	case "stop":
+		_, err = client.DeleteInstance(ctx, &control.DeleteInstanceRequest{Hash: args.Hash})
+		out.Message = "Shutting down " + args.Hash
+	default:
+		return nil, fmt.Errorf("Command %s is not supported over gRPC", command)
+	}
+	if err != nil {
+		out.Code = 1
+		out.Message = status.Convert(err).Message()
+	}
+	return out, nil
+}
+
+// grpcStatus requests description of instances and their peers over
+// gRPC. Peers are returned only by GetInstance
+func grpcStatus(port int, hash string) ([]InstanceOutput, error) {
+	client, conn, err := dialControl(port)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create gRPC client: %s", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	hashes := []string{hash}
+	if hash == "" {
+		list, err := client.ListInstances(ctx, &control.ListInstancesRequest{})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list instances: %s", status.Convert(err).Message())
+		}
+		hashes = []string{}
+		for _, inst := range list.Instances {
+			hashes = append(hashes, inst.Hash)
+		}
+	}
+	instances := []InstanceOutput{}
+	for _, h := range hashes {
+		inst, err := client.GetInstance(ctx, &control.GetInstanceRequest{Hash: h})
+		if status.Code(err) == codes.NotFound && hash == "" {
+			// Instance was stopped after it was listed
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get instance %s: %s", h, status.Convert(err).Message())
+		}
+		instances = append(instances, instanceFromProto(inst))
+	}
+	return instances, nil
+}
+
+// watchGRPCEvents calls handler for every event streamed by daemon
+func watchGRPCEvents(port int, hash string, types []string, handler func(ptp.Event)) error {
+	client, conn, err := dialControl(port)
+	if err != nil {
+		return fmt.Errorf("Failed to create gRPC client: %s", err)
+	}
+	defer conn.Close()
+
+	stream, err := client.WatchEvents(context.Background(), &control.WatchEventsRequest{Hash: hash, Types: types})
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s", status.Convert(err).Message())
+		}
+		handler(eventFromProto(event))
+	}
+}
+
+func eventFromProto(e *control.Event) ptp.Event {
+	t, _ := time.Parse(time.RFC3339Nano, e.Time)
+	return ptp.Event{
+		Type:   ptp.EventType(e.Type),
+		Time:   t,
+		Hash:   e.Hash,
+		PeerID: e.PeerId,
+		Data:   e.Data,
+	}
+}
+
+func instanceFromProto(inst *control.Instance) InstanceOutput {
+	out := InstanceOutput{
+		Hash:         inst.Hash,
+		ID:           inst.Id,
+		IP:           inst.Ip,
+		Mac:          inst.Mac,
+		Interface:    inst.Interface,
+		Port:         int(inst.Port),
+		DHTConnected: inst.DhtConnected,
+		LocalIPs:     inst.LocalIps,
+		Proxies:      []ProxyOutput{},
+	}
+	for _, proxy := range inst.Proxies {
+		out.Proxies = append(out.Proxies, ProxyOutput{
+			Addr:     proxy.Addr,
+			Endpoint: proxy.Endpoint,
+			Active:   proxy.Active,
+			Latency:  proxy.LatencyMs,
+			RTT:      rttFromProto(proxy.Rtt),
+		})
+	}
+	for _, peer := range inst.Peers {
+		out.Peers = append(out.Peers, peerFromProto(peer))
+	}
+	return out
+}
+
+func peerFromProto(peer *control.Peer) PeerOutput {
+	out := PeerOutput{
+		ID:          peer.Id,
+		IP:          peer.Ip,
+		Mac:         peer.Mac,
+		State:       peer.State,
+		RemoteState: peer.RemoteState,
+		LastError:   peer.LastError,
+		Endpoint:    peer.Endpoint,
+		Path:        peer.Path,
+		Paths:       peer.Paths,
+		LastContact: peer.LastContact,
+		Endpoints:   []EndpointOutput{},
+		KnownIPs:    peer.KnownIps,
+		Proxies:     peer.Proxies,
+		Traffic: TrafficOutput{
+			Paths:     make(map[string]ptp.TrafficCounters),
+			Endpoints: make(map[string]ptp.TrafficCounters),
+		},
+	}
+	for _, ep := range peer.Endpoints {
+		out.Endpoints = append(out.Endpoints, EndpointOutput{
+			Addr:        ep.Addr,
+			Latency:     ep.LatencyMs,
+			Score:       ep.ScoreMs,
+			MTU:         int(ep.Mtu),
+			LastContact: ep.LastContact,
+			RTT:         rttFromProto(ep.Rtt),
+		})
+	}
+	if stats := peer.Stats; stats != nil {
+		out.Stats = PeerStatsOutput{
+			ConnectionAttempts: int(stats.ConnectionAttempts),
+			Reconnects:         int(stats.Reconnects),
+			HolePunches:        int(stats.HolePunches),
+			StartedAt:          stats.StartedAt,
+			ConnectedAt:        stats.ConnectedAt,
+			ConnectionLostAt:   stats.ConnectionLostAt,
+			ReconnectedAt:      stats.ReconnectedAt,
+		}
+	}
+	if traffic := peer.Traffic; traffic != nil {
+		out.Traffic.Total = countersFromProto(traffic.Total)
+		out.Traffic.Since = traffic.Since
+		for path, c := range traffic.Paths {
+			out.Traffic.Paths[path] = countersFromProto(c)
+		}
+		for ep, c := range traffic.Endpoints {
+			out.Traffic.Endpoints[ep] = countersFromProto(c)
+		}
+	}
+	if c := peer.Compression; c != nil {
+		out.Compression = &CompressionOutput{
+			Frames:     c.Frames,
+			Skipped:    c.Skipped,
+			Bytes:      c.Bytes,
+			Compressed: c.CompressedBytes,
+			Ratio:      c.Ratio,
+		}
+	}
+	return out
+}
+
+func countersFromProto(c *control.TrafficCounters) ptp.TrafficCounters {
+	if c == nil {
+		return ptp.TrafficCounters{}
+	}
+	return ptp.TrafficCounters{
+		TxBytes:   c.TxBytes,
+		RxBytes:   c.RxBytes,
+		TxPackets: c.TxPackets,
+		RxPackets: c.RxPackets,
+	}
+}
+
+func rttFromProto(rtt *control.RTT) *RTTOutput {
+	if rtt == nil {
+		return nil
+	}
+	return &RTTOutput{
+		Samples: int(rtt.Samples),
+		Lost:    int(rtt.Lost),
+		Loss:    rtt.LossPercent,
+		Min:     rtt.MinMs,
+		Avg:     rtt.AvgMs,
+		Max:     rtt.MaxMs,
+		P95:     rtt.P95Ms,
+		Jitter:  rtt.JitterMs,
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	ptp "github.com/subutai-io/p2p/lib"
+	"github.com/subutai-io/p2p/protocol/control"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   codes.Code
+	}{
+		{"bad request", http.StatusBadRequest, codes.InvalidArgument},
+		{"unauthorized", http.StatusUnauthorized, codes.Unauthenticated},
+		{"forbidden", http.StatusForbidden, codes.PermissionDenied},
+		{"not found", http.StatusNotFound, codes.NotFound},
+		{"conflict", http.StatusConflict, codes.AlreadyExists},
+		{"unavailable", http.StatusServiceUnavailable, codes.Unavailable},
+		{"internal", http.StatusInternalServerError, codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := grpcError(newAPIError(tt.status, ErrCodeInternal, "message"))
+			st := status.Convert(err)
+			if st.Code() != tt.code || st.Message() != "message" {
+				t.Errorf("grpcError() = %v, want %s", st, tt.code)
+			}
+		})
+	}
+}
+
+func TestGRPCPolicies(t *testing.T) {
+	for _, method := range control.Control_ServiceDesc.Methods {
+		name := "/" + control.Control_ServiceDesc.ServiceName + "/" + method.MethodName
+		if _, exists := grpcPolicies[name]; !exists {
+			t.Errorf("No policy for %s", name)
+		}
+	}
+	for _, stream := range control.Control_ServiceDesc.Streams {
+		name := "/" + control.Control_ServiceDesc.ServiceName + "/" + stream.StreamName
+		if _, exists := grpcPolicies[name]; !exists {
+			t.Errorf("No policy for %s", name)
+		}
+	}
+}
+
+// startGRPCServer serves control service the same way daemon does and
+// returns port of the server
+func startGRPCServer(t *testing.T, d *Daemon, conf ptp.APIConf) int {
+	auth, err := newAPIAuth(conf)
+	if err != nil {
+		t.Fatalf("newAPIAuth() failed: %s", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/"+control.Control_ServiceDesc.ServiceName+"/", auth.grpc(newGRPCServer(d)))
+	server := httptest.NewUnstartedServer(mux)
+	server.Config.Protocols = apiProtocols()
+	server.Start()
+	t.Cleanup(server.Close)
+
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+	return port
+}
+
+func TestControlServer(t *testing.T) {
+	ReadyToServe = false
+	d := new(Daemon)
+	d.Instances = new(InstanceList)
+	d.Instances.init()
+	port := startGRPCServer(t, d, ptp.APIConf{
+		Tokens: []ptp.APIToken{{Name: "ro", Token: "read-token", Role: "read"}},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Setenv(EnvAPIToken, "")
+	client, conn, err := dialControl(port)
+	if err != nil {
+		t.Fatalf("dialControl() failed: %s", err)
+	}
+	_, err = client.GetDaemon(ctx, &control.GetDaemonRequest{})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetDaemon() without token error = %v, want %s", err, codes.Unauthenticated)
+	}
+	conn.Close()
+
+	t.Setenv(EnvAPIToken, "read-token")
+	client, conn, err = dialControl(port)
+	if err != nil {
+		t.Fatalf("dialControl() failed: %s", err)
+	}
+	defer conn.Close()
+
+	out, err := client.GetDaemon(ctx, &control.GetDaemonRequest{})
+	if err != nil {
+		t.Fatalf("GetDaemon() failed: %s", err)
+	}
+	if out.Ready || out.State != 105 {
+		t.Errorf("GetDaemon() = %v, want state 105", out)
+	}
+	_, err = client.ListInstances(ctx, &control.ListInstancesRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("ListInstances() error = %v, want %s", err, codes.Unavailable)
+	}
+	_, err = client.DeleteInstance(ctx, &control.DeleteInstanceRequest{Hash: "hash"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("DeleteInstance() error = %v, want %s", err, codes.PermissionDenied)
+	}
+
+	stream, err := client.WatchEvents(ctx, &control.WatchEventsRequest{Hash: "grpc-test"})
+	if err != nil {
+		t.Fatalf("WatchEvents() failed: %s", err)
+	}
+	received := make(chan *control.Event)
+	go func() {
+		event, err := stream.Recv()
+		if err != nil {
+			close(received)
+			return
+		}
+		received <- event
+	}()
+	// Subscription is created when stream reaches the server, so event
+	// is published until received
+	for {
+		ptp.GlobalEvents.Publish(ptp.Event{Type: ptp.EventIfaceUp, Hash: "grpc-test", Data: map[string]string{"device": "vptp1"}})
+		select {
+		case event, ok := <-received:
+			if !ok {
+				t.Fatalf("WatchEvents() stream failed")
+			}
+			if event.Type != string(ptp.EventIfaceUp) || event.Hash != "grpc-test" || event.Data["device"] != "vptp1" {
+				t.Errorf("WatchEvents() = %v", event)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatalf("WatchEvents() didn't receive event")
+		}
+	}
+}
@@ -22,6 +22,7 @@ type RunArgs struct {
 	TTL         string `json:"ttl"`
 	Fwd         bool   `json:"fwd"`
 	Port        int    `json:"port"`
+	MTU         int    `json:"mtu"` // MTU of the interface. Daemon MTU is used when 0
 	LastSuccess time.Time
 }
 
@@ -329,6 +330,7 @@ type Daemon struct {
 	Instances  *InstanceList
 	Restore    *Restore
 	OutboundIP net.IP
+	settings   *daemonSettings
 }
 
 // init will initialize daemon, instnaces and restore subsystems
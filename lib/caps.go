@@ -0,0 +1,146 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// PeerCaps is a set of optional features supported by a peer. Peers
+// exchange capabilities after connection is established. Features are
+// used only when both sides support them, so older peers keep receiving
+// ordinary frames
+type PeerCaps uint32
+
+// Capabilities
+const (
+	CapOffload  PeerCaps = 1 << iota // Peer accepts TCP super-packets
+	CapFragment                      // Peer reassembles fragmented messages
+	CapPMTU                          // Peer answers path MTU probes
+	CapCompress                      // Peer accepts compressed frames
+	CapDedup                         // Peer drops duplicated frames
+	CapPingSeq                       // Peer echoes sequence numbers of pings
+)
+
+// capsKnown marks that capabilities were received from the peer
+const capsKnown uint32 = 1 << 31
+
+// Capability negotiation timings
+const (
+	capsRequestInterval = time.Second * 10
+	capsMaxRequests     = 3 // Older peers never reply
+)
+
+// Caps returns capabilities received from the peer
+func (np *NetworkPeer) Caps() PeerCaps {
+	return PeerCaps(atomic.LoadUint32(&np.caps) &^ capsKnown)
+}
+
+func (np *NetworkPeer) setCaps(caps PeerCaps) {
+	atomic.StoreUint32(&np.caps, uint32(caps)|capsKnown)
+}
+
+// Codec returns compression codec accepted by the peer
+func (np *NetworkPeer) Codec() Codec {
+	return Codec(atomic.LoadUint32(&np.codec))
+}
+
+func (np *NetworkPeer) setCodec(codec Codec) {
+	atomic.StoreUint32(&np.codec, uint32(codec))
+}
+
+// negotiateCaps sends our capabilities to the peer until it replies with
+// its own. Nothing is sent when we have no capabilities: such peer will
+// be asked by the other side
+func (np *NetworkPeer) negotiateCaps(ptpc *PeerToPeer) error {
+	if atomic.LoadUint32(&np.caps)&capsKnown != 0 || np.capsRequests >= capsMaxRequests {
+		return nil
+	}
+	if time.Since(np.capsRequested) < capsRequestInterval || np.Endpoint == nil || ptpc.Dht == nil {
+		return nil
+	}
+	if ptpc.localCaps() == 0 {
+		return nil
+	}
+	np.capsRequests++
+	np.capsRequested = time.Now()
+	msg, err := ptpc.CreateMessage(MsgTypeComm, ptpc.capsPayload(false), 0, true)
+	if err != nil {
+		return err
+	}
+	_, err = ptpc.UDPSocket.SendMessage(msg, np.Endpoint)
+	return err
+}
+
+// localCaps returns capabilities of this instance
+func (p *PeerToPeer) localCaps() PeerCaps {
+	caps := CapFragment | CapPMTU | CapDedup | CapPingSeq
+	if ot, ok := p.Interface.(OffloadTAP); ok && ot.OffloadEnabled() {
+		caps |= CapOffload
+	}
+	if p.compression {
+		caps |= CapCompress
+	}
+	return caps
+}
+
+// localCodec returns compression codec accepted by this instance
+func (p *PeerToPeer) localCodec() Codec {
+	if p.compression {
+		return CodecLZ4
+	}
+	return CodecNone
+}
+
+// capsPayload creates capabilities communication packet
+func (p *PeerToPeer) capsPayload(reply bool) []byte {
+	payload := make([]byte, 44)
+	binary.BigEndian.PutUint16(payload[0:2], CommCapabilities)
+	copy(payload[2:38], p.Dht.ID)
+	binary.BigEndian.PutUint32(payload[38:42], uint32(p.localCaps()))
+	if reply {
+		payload[42] = 1
+	}
+	payload[43] = byte(p.localCodec())
+	return payload
+}
+
+// commCapabilitiesHandler stores capabilities of the peer and replies with
+// our own ones. Data format is as follows:
+// id[36] caps[4] reply[1] codec[1]
+// reply is 0 for requests and 1 for responses. codec is a compression
+// codec accepted by the peer. It's missing in packets of peers that
+// don't support compression
+func commCapabilitiesHandler(data []byte, p *PeerToPeer) ([]byte, error) {
+	err := commPacketCheck(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.Swarm == nil {
+		return nil, fmt.Errorf("nil peer list")
+	}
+	if p.Dht == nil {
+		return nil, fmt.Errorf("nil dht")
+	}
+	if len(data) < 41 {
+		return nil, fmt.Errorf("wrong payload size: %d", len(data))
+	}
+	id := string(data[0:36])
+	peer := p.Swarm.GetPeer(id)
+	if peer == nil {
+		return nil, fmt.Errorf("capabilities of unknown peer %s", id)
+	}
+	caps := PeerCaps(binary.BigEndian.Uint32(data[36:40]))
+	codec := CodecNone
+	if len(data) > 41 {
+		codec = Codec(data[41])
+	}
+	peer.setCodec(codec)
+	peer.setCaps(caps)
+	Log(Debug, "Peer %s capabilities: %#x, codec: %d", id, uint32(caps), codec)
+	if data[40] == 1 {
+		return nil, nil
+	}
+	return p.capsPayload(true), nil
+}
@@ -0,0 +1,62 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestCommCapabilitiesHandler(t *testing.T) {
+	remote := "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb"
+	p := new(PeerToPeer)
+	p.Swarm = new(Swarm)
+	p.Swarm.Init()
+	p.Dht = &DHTClient{ID: "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"}
+	peer := new(NetworkPeer)
+	p.Swarm.Update(remote, peer)
+
+	packet := func(id string, caps PeerCaps, reply byte) []byte {
+		data := make([]byte, 41)
+		copy(data, id)
+		binary.BigEndian.PutUint32(data[36:40], uint32(caps))
+		data[40] = reply
+		return data
+	}
+
+	response, err := commCapabilitiesHandler(packet(remote, CapOffload, 0), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.Caps() != CapOffload || peer.Codec() != CodecNone {
+		t.Errorf("Peer capabilities: %#x, codec: %d", peer.Caps(), peer.Codec())
+	}
+	if len(response) != 44 || binary.BigEndian.Uint16(response[0:2]) != CommCapabilities || string(response[2:38]) != p.Dht.ID || response[42] != 1 || response[43] != byte(CodecNone) {
+		t.Errorf("Wrong response: %v", response)
+	}
+
+	p.compression = true
+	response, err = commCapabilitiesHandler(append(packet(remote, CapCompress, 0), byte(CodecLZ4)), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.Caps() != CapCompress || peer.Codec() != CodecLZ4 {
+		t.Errorf("Peer capabilities: %#x, codec: %d", peer.Caps(), peer.Codec())
+	}
+	if len(response) != 44 || response[43] != byte(CodecLZ4) {
+		t.Errorf("Wrong response: %v", response)
+	}
+
+	response, err = commCapabilitiesHandler(packet(remote, 0, 1), p)
+	if err != nil || response != nil {
+		t.Errorf("Reply was answered: %v, %v", response, err)
+	}
+	if peer.Caps() != 0 || peer.caps&capsKnown == 0 {
+		t.Errorf("Capabilities weren't updated")
+	}
+
+	if _, err := commCapabilitiesHandler(packet("cccccccc-cccc-cccc-cccc-cccccccccccc", 0, 0), p); err == nil {
+		t.Errorf("Unknown peer was accepted")
+	}
+	if _, err := commCapabilitiesHandler(packet(remote, 0, 0)[:40], p); err == nil {
+		t.Errorf("Short packet was accepted")
+	}
+}
@@ -101,7 +101,8 @@ func commIPInfoHandler(data []byte, p *PeerToPeer) ([]byte, error) {
 			p.Interface.SetIP(ip)
 			p.Interface.Configure(false)
 			p.Interface.MarkConfigured()
-			go p.notifyIP()
+			p.interfaceConfigured()
+			p.spawn(func() { p.notifyIP() })
 			return nil, nil
 		}
 		Log(Info, "IP %s is already known to this swarm. Ignoring it", ip.String())
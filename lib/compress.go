@@ -0,0 +1,155 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pierrec/lz4/v4"
+)
+
+// Frames sent to peers that accept compression are compressed with LZ4
+// before encryption and sent as MsgTypeCompressed messages. Frames that
+// don't shrink are sent as is. Peers announce the codec they accept in
+// CommCapabilities exchange after connection is established. Message data
+// format is as follows:
+// codec[1] size[4] block
+// size is a length of the frame before compression
+
+// Codec identifies a compression algorithm
+type Codec uint8
+
+// Compression codecs
+const (
+	CodecNone Codec = iota // Compression is not accepted
+	CodecLZ4               // LZ4 block
+)
+
+// Compression limits
+const (
+	compressMinSize    = 128                          // Smaller frames are never compressed
+	compressMaxFrame   = ethernetHeaderLength + 65535 // Largest frame accepted from a peer
+	compressHeaderSize = 5                            // Codec and size of the frame
+)
+
+// UseCompression is a daemon-wide compression switch. Used only by
+// instances created after a change
+var UseCompression = false
+
+var compressors = sync.Pool{
+	New: func() interface{} {
+		return new(lz4.Compressor)
+	},
+}
+
+// CompressionStats holds compression counters of frames sent to a peer
+type CompressionStats struct {
+	Frames     uint64 `json:"frames" yaml:"frames"`                     // Frames sent compressed
+	Skipped    uint64 `json:"skipped" yaml:"skipped"`                   // Frames sent as is, since they didn't shrink
+	Bytes      uint64 `json:"bytes" yaml:"bytes"`                       // Size of compressed frames before compression
+	Compressed uint64 `json:"compressed_bytes" yaml:"compressed_bytes"` // Size of compressed frames after compression
+}
+
+// Ratio returns compression ratio of frames sent compressed
+func (s CompressionStats) Ratio() float64 {
+	if s.Compressed == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.Compressed)
+}
+
+func (s *CompressionStats) add(size, compressed int) {
+	atomic.AddUint64(&s.Frames, 1)
+	atomic.AddUint64(&s.Bytes, uint64(size))
+	atomic.AddUint64(&s.Compressed, uint64(compressed))
+}
+
+// CompressionStats returns compression counters of the peer
+func (np *NetworkPeer) CompressionStats() CompressionStats {
+	return CompressionStats{
+		Frames:     atomic.LoadUint64(&np.compression.Frames),
+		Skipped:    atomic.LoadUint64(&np.compression.Skipped),
+		Bytes:      atomic.LoadUint64(&np.compression.Bytes),
+		Compressed: atomic.LoadUint64(&np.compression.Compressed),
+	}
+}
+
+// compressFrame compresses frame with LZ4. Returns nil when frame
+// doesn't shrink
+func compressFrame(frame []byte) []byte {
+	limit := len(frame) - 1
+	if limit <= compressHeaderSize {
+		return nil
+	}
+	data := make([]byte, limit)
+	data[0] = byte(CodecLZ4)
+	binary.BigEndian.PutUint32(data[1:compressHeaderSize], uint32(len(frame)))
+	c := compressors.Get().(*lz4.Compressor)
+	defer compressors.Put(c)
+	// Block that doesn't fit is reported as zero length or an error
+	n, err := c.CompressBlock(frame, data[compressHeaderSize:])
+	if err != nil || n == 0 {
+		return nil
+	}
+	return data[:compressHeaderSize+n]
+}
+
+// decompressFrame decompresses frame compressed by a peer
+func decompressFrame(data []byte) ([]byte, error) {
+	if len(data) < compressHeaderSize {
+		return nil, fmt.Errorf("Compressed frame is too short")
+	}
+	if Codec(data[0]) != CodecLZ4 {
+		return nil, fmt.Errorf("Unsupported compression codec %d", data[0])
+	}
+	size := binary.BigEndian.Uint32(data[1:compressHeaderSize])
+	if size > compressMaxFrame {
+		return nil, fmt.Errorf("Decompressed frame is too large")
+	}
+	frame := make([]byte, size)
+	n, err := lz4.UncompressBlock(data[compressHeaderSize:], frame)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decompress frame: %s", err)
+	}
+	if n != len(frame) {
+		return nil, fmt.Errorf("Decompressed frame is %d bytes, expected %d", n, size)
+	}
+	return frame, nil
+}
+
+// createFrameMessage creates a message carrying frame to the peer. Frame
+// is compressed before encryption, if both sides accept compression
+func (p *PeerToPeer) createFrameMessage(peer *NetworkPeer, frame []byte, proto uint16) (*P2PMessage, error) {
+	if p.compression && peer != nil && peer.Caps()&CapCompress != 0 && peer.Codec() == CodecLZ4 && len(frame) >= compressMinSize {
+		data := compressFrame(frame)
+		if data != nil {
+			peer.compression.add(len(frame), len(data))
+			return p.CreateMessage(MsgTypeCompressed, data, proto, true)
+		}
+		atomic.AddUint64(&peer.compression.Skipped, 1)
+	}
+	return p.CreateMessage(MsgTypeNenc, frame, proto, true)
+}
+
+// HandleCompressedMessage writes frame compressed by a peer to the
+// interface
+func (p *PeerToPeer) HandleCompressedMessage(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+	if msg == nil {
+		return fmt.Errorf("nil message")
+	}
+	if msg.Header == nil {
+		return fmt.Errorf("nil header")
+	}
+	if srcAddr == nil {
+		return fmt.Errorf("nil source addr")
+	}
+	frame, err := decompressFrame(msg.Data)
+	if err != nil {
+		return err
+	}
+	p.countRx(&P2PMessage{Header: msg.Header, Data: frame}, srcAddr)
+	p.WriteToDevice(frame, msg.Header.NetProto, false)
+	return nil
+}
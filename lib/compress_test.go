@@ -0,0 +1,87 @@
+package ptp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCompressFrame(t *testing.T) {
+	text := bytes.Repeat([]byte(`{"level":"info","message":"request served"}`), 30)
+	random := make([]byte, 1200)
+	rand.Read(random)
+
+	data := compressFrame(text)
+	if data == nil || len(data) >= len(text) {
+		t.Fatalf("Compressible frame wasn't compressed")
+	}
+	frame, err := decompressFrame(data)
+	if err != nil || !bytes.Equal(frame, text) {
+		t.Errorf("Decompressed frame differs: %v", err)
+	}
+	if compressFrame(random) != nil {
+		t.Errorf("Incompressible frame was compressed")
+	}
+	if _, err := decompressFrame(data[:len(data)-1]); err == nil {
+		t.Errorf("Broken frame was decompressed")
+	}
+	unknown := append([]byte{}, data...)
+	unknown[0] = byte(CodecLZ4 + 1)
+	if _, err := decompressFrame(unknown); err == nil {
+		t.Errorf("Frame of unknown codec was decompressed")
+	}
+	if _, err := decompressFrame(data[:compressHeaderSize-1]); err == nil {
+		t.Errorf("Short frame was decompressed")
+	}
+	if _, err := decompressFrame(compressFrame(make([]byte, compressMaxFrame+1))); err == nil {
+		t.Errorf("Too large frame was decompressed")
+	}
+}
+
+func TestPeerToPeer_createFrameMessage(t *testing.T) {
+	text := bytes.Repeat([]byte("compressible "), 100)
+	random := make([]byte, 1200)
+	rand.Read(random)
+
+	tests := []struct {
+		name        string
+		compression bool
+		caps        PeerCaps
+		codec       Codec
+		frame       []byte
+		want        uint16
+	}{
+		{"compressed", true, CapCompress, CodecLZ4, text, MsgTypeCompressed},
+		{"disabled", false, CapCompress, CodecLZ4, text, MsgTypeNenc},
+		{"peer without compression", true, CapFragment, CodecNone, text, MsgTypeNenc},
+		{"unknown codec", true, CapCompress, CodecLZ4 + 1, text, MsgTypeNenc},
+		{"small frame", true, CapCompress, CodecLZ4, text[:64], MsgTypeNenc},
+		{"incompressible", true, CapCompress, CodecLZ4, random, MsgTypeNenc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &PeerToPeer{compression: tt.compression}
+			peer := new(NetworkPeer)
+			peer.setCaps(tt.caps)
+			peer.setCodec(tt.codec)
+			msg, err := p.createFrameMessage(peer, tt.frame, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Header.Type != tt.want {
+				t.Errorf("Message type = %d, want %d", msg.Header.Type, tt.want)
+			}
+		})
+	}
+
+	p := &PeerToPeer{compression: true}
+	peer := new(NetworkPeer)
+	peer.setCaps(CapCompress)
+	peer.setCodec(CodecLZ4)
+	msg, _ := p.createFrameMessage(peer, text, 0)
+	p.createFrameMessage(peer, random, 0)
+	s := peer.CompressionStats()
+	if s.Frames != 1 || s.Skipped != 1 || s.Bytes != uint64(len(text)) || s.Compressed != uint64(len(msg.Data)) || s.Ratio() <= 1 {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+}
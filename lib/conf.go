@@ -8,11 +8,73 @@ import (
 )
 
 type Conf struct {
-	IPTool  string `yaml:"iptool"`
-	TAPTool string `yaml:"taptool"`
-	INFFile string `yaml:"inf_file"`
-	MTU     int    `yaml:"mtu"`
-	PMTU    bool   `yaml:"pmtu"`
+	IPTool      string        `yaml:"iptool"`
+	TAPTool     string        `yaml:"taptool"`
+	INFFile     string        `yaml:"inf_file"`
+	MTU         int           `yaml:"mtu"`
+	PMTU        bool          `yaml:"pmtu"`
+	Hooks       Hooks         `yaml:"hooks"`
+	API         APIConf       `yaml:"api"`
+	LogLevel    string        `yaml:"log_level"`  // Minimal level and subsystem levels, e.g. info,dht=debug
+	LogFormat   string        `yaml:"log_format"` // text, json or logfmt
+	LogOutput   LogOutputConf `yaml:"log_output"`
+	Syslog      string        `yaml:"syslog"`
+	Bootstrap   []string      `yaml:"bootstrap"`       // Bootstrap nodes in host:port format. SRV lookup is used when empty
+	MasterKey   string        `yaml:"master_key_file"` // File with a key used to seal secrets in the save file
+	TAPQueues   int           `yaml:"tap_queues"`      // Number of TAP queues read in parallel. Linux only
+	Offload     bool          `yaml:"offload"`         // Exchange TCP super-packets with TAP and peers. Linux only
+	UnderlayMTU int           `yaml:"underlay_mtu"`    // MTU of the network between peers. Larger messages are fragmented
+	Compression bool          `yaml:"compression"`     // Compress frames for peers that accept it
+	Multipath   string        `yaml:"multipath"`       // off, hash, roundrobin or duplicate
+	Duplicate   []int         `yaml:"duplicate_ports"` // TCP and UDP ports of flows duplicated in duplicate mode
+}
+
+// LogOutputConf selects destinations of log messages
+type LogOutputConf struct {
+	Stdout   *bool       `yaml:"stdout"`   // Write to stdout. Enabled by default
+	File     LogFileConf `yaml:"file"`     // Write to a file when path is set
+	Journald bool        `yaml:"journald"` // Send to systemd journal with structured fields
+}
+
+// LogFileConf is a configuration of log file and its rotation
+type LogFileConf struct {
+	Path       string `yaml:"path"`
+	MaxSize    int    `yaml:"max_size"`    // Size in megabytes to rotate at. 100 when 0
+	MaxAge     string `yaml:"max_age"`     // Rotate when file is older, e.g. 24h. Disabled when empty
+	MaxBackups int    `yaml:"max_backups"` // Number of rotated files to keep. 7 when 0
+	Compress   bool   `yaml:"compress"`    // Compress rotated files with gzip
+}
+
+// StdoutEnabled returns whether logs should be written to stdout
+func (c LogOutputConf) StdoutEnabled() bool {
+	return c.Stdout == nil || *c.Stdout
+}
+
+// APIConf configures listeners and access control of the daemon
+// control API
+type APIConf struct {
+	Socket     string     `yaml:"socket"`      // Path to Unix domain socket
+	SocketMode string     `yaml:"socket_mode"` // Octal permissions of the socket, 0660 by default
+	SocketRole string     `yaml:"socket_role"` // Role granted to socket clients, admin by default
+	DisableTCP bool       `yaml:"disable_tcp"` // Serve API on Unix socket only
+	TLSCert    string     `yaml:"tls_cert"`    // Server certificate. Enables HTTPS on TCP listener
+	TLSKey     string     `yaml:"tls_key"`     // Server private key
+	ClientCA   string     `yaml:"client_ca"`   // CA used to verify client certificates
+	CertRoles  []APIRole  `yaml:"cert_roles"`  // Roles of client certificates by common name
+	Tokens     []APIToken `yaml:"tokens"`      // Bearer tokens accepted on TCP listener
+}
+
+// APIToken is a bearer token with the role it grants
+type APIToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  string `yaml:"role"`
+}
+
+// APIRole maps a client certificate common name to a role
+type APIRole struct {
+	CommonName string `yaml:"cn"`
+	Role       string `yaml:"role"`
 }
 
 func (c *Conf) Load(filepath string) error {
@@ -70,3 +132,87 @@ func (c *Conf) GetMTU(preset int) int {
 func (c *Conf) GetPMTU() bool {
 	return c.PMTU
 }
+
+// GetTAPQueues returns number of TAP queues. Single queue is used by
+// default
+func (c *Conf) GetTAPQueues() int {
+	if c.TAPQueues < 1 {
+		return 1
+	}
+	if c.TAPQueues > MaxTAPQueues {
+		return MaxTAPQueues
+	}
+	return c.TAPQueues
+}
+
+// GetUnderlayMTU returns MTU of the network between peers
+func (c *Conf) GetUnderlayMTU() int {
+	if c.UnderlayMTU == 0 {
+		return DefaultUnderlayMTU
+	}
+	if c.UnderlayMTU < MinUnderlayMTU {
+		return MinUnderlayMTU
+	}
+	if c.UnderlayMTU > 65535 {
+		return 65535
+	}
+	return c.UnderlayMTU
+}
+
+// GetOffload returns whether TAP offload is enabled
+func (c *Conf) GetOffload() bool {
+	return c.Offload
+}
+
+// GetCompression returns whether frames are compressed
+func (c *Conf) GetCompression() bool {
+	return c.Compression
+}
+
+// GetMultipath returns name of multipath mode
+func (c *Conf) GetMultipath() string {
+	return c.Multipath
+}
+
+// GetDuplicatePorts returns ports of flows duplicated in duplicate mode
+func (c *Conf) GetDuplicatePorts() []int {
+	return c.Duplicate
+}
+
+func (c *Conf) GetHooks() Hooks {
+	return c.Hooks
+}
+
+func (c *Conf) GetAPI() APIConf {
+	return c.API
+}
+
+func (c *Conf) GetLogLevel(preset string) string {
+	if preset != "" {
+		return preset
+	}
+	return c.LogLevel
+}
+
+func (c *Conf) GetSyslog(preset string) string {
+	if preset != "" {
+		return preset
+	}
+	return c.Syslog
+}
+
+func (c *Conf) GetBootstrap() []string {
+	return c.Bootstrap
+}
+
+func (c *Conf) GetMasterKeyFile() string {
+	return c.MasterKey
+}
+
+func (c *Conf) GetLogFormat() string {
+	return c.LogFormat
+}
+
+func (c *Conf) GetLogOutput() LogOutputConf {
+	return c.LogOutput
+}
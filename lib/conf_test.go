@@ -281,3 +281,45 @@ func Test_Conf_getPMTU(t *testing.T) {
 		})
 	}
 }
+
+func TestConf_GetTAPQueues(t *testing.T) {
+	tests := []struct {
+		name   string
+		queues int
+		want   int
+	}{
+		{"default", 0, 1},
+		{"negative", -2, 1},
+		{"set", 4, 4},
+		{"too many", 1000, MaxTAPQueues},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conf{TAPQueues: tt.queues}
+			if got := c.GetTAPQueues(); got != tt.want {
+				t.Errorf("Conf.GetTAPQueues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConf_GetUnderlayMTU(t *testing.T) {
+	tests := []struct {
+		name string
+		mtu  int
+		want int
+	}{
+		{"default", 0, DefaultUnderlayMTU},
+		{"too small", 100, MinUnderlayMTU},
+		{"set", 1280, 1280},
+		{"too large", 100000, 65535},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Conf{UnderlayMTU: tt.mtu}
+			if got := c.GetUnderlayMTU(); got != tt.want {
+				t.Errorf("Conf.GetUnderlayMTU() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
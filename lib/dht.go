@@ -1,6 +1,7 @@
 package ptp
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"time"
@@ -44,6 +45,7 @@ type DHTClient struct {
 	ListenerIsRunning bool                                   // True if listener is runnning
 	IncomingData      chan *protocol.DHTPacket
 	OutgoingData      chan *protocol.DHTPacket
+	reserved          *IPRegistry // IPs of p2p interfaces that must not be reported
 }
 
 // Forwarder structure represents a Proxy received from DHT server
@@ -73,6 +75,12 @@ func (dht *DHTClient) Init(hash string) error {
 
 // Connect sends `conn` packet to a DHT
 func (dht *DHTClient) Connect(ipList []net.IP, proxyList []*proxyServer) error {
+	return dht.ConnectContext(context.Background(), ipList, proxyList)
+}
+
+// ConnectContext works like Connect but stops waiting for handshake
+// when ctx is done
+func (dht *DHTClient) ConnectContext(ctx context.Context, ipList []net.IP, proxyList []*proxyServer) error {
 	dht.Connected = false
 	if dht.RemotePort == 0 {
 		dht.RemotePort = dht.LocalPort
@@ -84,13 +92,7 @@ func (dht *DHTClient) Connect(ipList []net.IP, proxyList []*proxyServer) error {
 		if ip == nil {
 			continue
 		}
-		skip := false
-		for _, a := range ActiveInterfaces {
-			if a.Equal(ip) {
-				skip = true
-			}
-		}
-		if skip {
+		if dht.registry().Contains(ip) {
 			continue
 		}
 		ips = append(ips, ip.String())
@@ -109,20 +111,32 @@ func (dht *DHTClient) Connect(ipList []net.IP, proxyList []*proxyServer) error {
 		Arguments: ips,
 		Proxies:   proxies,
 	}
-	err := dht.send(packet)
+	err := dht.sendContext(ctx, packet)
 	if err != nil {
 		return fmt.Errorf("Failed to handshake with bootstrap node: %s", err)
 	}
-	// Waiting for 3 seconds to get connection confirmation
-	sent := time.Now()
-	for time.Since(sent) < time.Duration(5000*time.Millisecond) {
-		if dht.Connected {
-			return nil
+	// Waiting for 5 seconds to get connection confirmation
+	timeout := time.After(5000 * time.Millisecond)
+	for !dht.Connected {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			Log(Error, "DHT handshake didn't finish")
+			return fmt.Errorf("Couldn't handshake with bootstrap node")
+		case <-time.After(time.Millisecond * 100):
 		}
-		time.Sleep(time.Millisecond * 100)
 	}
-	Log(Error, "DHT handshake didn't finish")
-	return fmt.Errorf("Couldn't handshake with bootstrap node")
+	return nil
+}
+
+// registry returns IPs reserved by instances. Falls back to daemon-wide
+// ActiveInterfaces when client wasn't created with a registry
+func (dht *DHTClient) registry() *IPRegistry {
+	if dht.reserved == nil {
+		return ActiveInterfaces
+	}
+	return dht.reserved
 }
 
 func (dht *DHTClient) read() (*protocol.DHTPacket, error) {
@@ -138,11 +152,20 @@ func (dht *DHTClient) read() (*protocol.DHTPacket, error) {
 
 // send sends bytes to all connected bootstrap nodes
 func (dht *DHTClient) send(packet *protocol.DHTPacket) error {
+	return dht.sendContext(context.Background(), packet)
+}
+
+// sendContext works like send but gives up when ctx is done before
+// packet is taken from OutgoingData
+func (dht *DHTClient) sendContext(ctx context.Context, packet *protocol.DHTPacket) error {
 	// if dht.OutgoingData != nil && !dht.isShutdown {
 	if dht.OutgoingData != nil {
 		// dht.OutgoingData <- packet
 		if len(packet.Arguments) == 0 && len(packet.Proxies) == 0 {
-			dht.OutgoingData <- packet
+			err := dht.push(ctx, packet)
+			if err != nil {
+				return err
+			}
 		} else {
 			for len(packet.Arguments) != 0 || len(packet.Proxies) != 0 {
 				blockLengthArgs := min(10, len(packet.Arguments))
@@ -161,7 +184,10 @@ func (dht *DHTClient) send(packet *protocol.DHTPacket) error {
 					Payload:   packet.Payload,
 					Version:   packet.Version,
 				}
-				dht.OutgoingData <- currentPacket
+				err := dht.push(ctx, currentPacket)
+				if err != nil {
+					return err
+				}
 				packet.Arguments = packet.Arguments[blockLengthArgs:]
 				packet.Proxies = packet.Proxies[blockLengthProxies:]
 			}
@@ -173,6 +199,16 @@ func (dht *DHTClient) send(packet *protocol.DHTPacket) error {
 	return nil
 }
 
+// push writes a packet to OutgoingData unless ctx is done first
+func (dht *DHTClient) push(ctx context.Context, packet *protocol.DHTPacket) error {
+	select {
+	case dht.OutgoingData <- packet:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // sendFind will send request for network peers known to BSN. As a response BSN will send array of IDs of peers in this swarm
 func (dht *DHTClient) sendFind() error {
 	dht.LastUpdate = time.Now()
@@ -237,7 +273,7 @@ func (dht *DHTClient) sendState(id, state string) error {
 	return dht.send(packet)
 }
 
-func (dht *DHTClient) sendDHCP(ip net.IP, network *net.IPNet) error {
+func (dht *DHTClient) sendDHCP(ctx context.Context, ip net.IP, network *net.IPNet) error {
 	subnet := "0"
 	if ip == nil {
 		ip = net.ParseIP("127.0.0.1")
@@ -254,7 +290,7 @@ func (dht *DHTClient) sendDHCP(ip net.IP, network *net.IPNet) error {
 		Extra:    subnet,
 		Version:  PacketVersion,
 	}
-	return dht.send(packet)
+	return dht.sendContext(ctx, packet)
 }
 
 func (dht *DHTClient) sendProxy() error {
@@ -13,7 +13,7 @@ type dhtCallback func(*protocol.DHTPacket) error
 
 func (p *PeerToPeer) setupTCPCallbacks() {
 	if p.Dht == nil {
-		Log(Error, "Can't setup TCP callbacks: DHT is nil")
+		p.logger(SubsystemDHT).Log(Error, "Can't setup TCP callbacks: DHT is nil")
 		return
 	}
 	p.Dht.TCPCallbacks = make(map[protocol.DHTPacketType]dhtCallback)
@@ -53,8 +53,9 @@ func (p *PeerToPeer) packetConnect(packet *protocol.DHTPacket) error {
 		return fmt.Errorf("Received malformed ID")
 	}
 	p.Dht.ID = packet.Id
-	Log(Info, "Received personal ID for this session: %s", p.Dht.ID)
+	p.logger(SubsystemDHT).Log(Info, "Received personal ID for this session: %s", p.Dht.ID)
 	p.Dht.Connected = true
+	p.publish(EventDHT, "", map[string]string{"state": "connected", "id": p.Dht.ID})
 	return nil
 }
 
@@ -68,12 +69,12 @@ func (p *PeerToPeer) packetDHCP(packet *protocol.DHTPacket) error {
 	if packet.Data != "" && packet.Extra != "" {
 		ip, network, err := net.ParseCIDR(fmt.Sprintf("%s/%s", packet.Data, packet.Extra))
 		if err != nil {
-			Log(Error, "Failed to parse DHCP packet: %s", err)
+			p.logger(SubsystemDHT).Log(Error, "Failed to parse DHCP packet: %s", err)
 			return err
 		}
 		p.Dht.IP = ip
 		p.Dht.Network = network
-		Log(Info, "Received network information: %s", network.String())
+		p.logger(SubsystemDHT).Log(Info, "Received network information: %s", network.String())
 	}
 	return nil
 }
@@ -90,7 +91,7 @@ func (p *PeerToPeer) packetError(packet *protocol.DHTPacket) error {
 	} else if packet.Data == "Error" {
 		lvl = Error
 	}
-	Log(lvl, "Bootstrap node returns: %s", packet.Extra)
+	p.logger(SubsystemDHT).Log(lvl, "Bootstrap node returns: %s", packet.Extra)
 	return nil
 }
 
@@ -102,11 +103,11 @@ func (p *PeerToPeer) packetFind(packet *protocol.DHTPacket) error {
 		return fmt.Errorf("nil dht")
 	}
 	if len(packet.Arguments) == 0 {
-		Log(Warning, "Received empty peer list")
+		p.logger(SubsystemDHT).Log(Warning, "Received empty peer list")
 		return nil
 	}
 	if packet.Data == p.Dht.ID {
-		Log(Debug, "Skipping self [%s = %s]", packet.Data, p.Dht.ID)
+		p.logger(SubsystemDHT).Log(Debug, "Skipping self [%s = %s]", packet.Data, p.Dht.ID)
 		return nil
 	}
 	if p.Swarm == nil {
@@ -116,12 +117,12 @@ func (p *PeerToPeer) packetFind(packet *protocol.DHTPacket) error {
 		return fmt.Errorf("nil proxy manager")
 	}
 
-	Log(Debug, "Received `find`: %+v", packet)
+	p.logger(SubsystemDHT).Log(Debug, "Received `find`: %+v", packet)
 	peer := p.Swarm.GetPeer(packet.Data)
 
 	if peer == nil {
 		peer := new(NetworkPeer)
-		Log(Debug, "Received new peer %s", packet.Data)
+		p.logger(SubsystemDHT).Log(Debug, "Received new peer %s", packet.Data)
 		peer.ID = packet.Data
 		for _, ip := range packet.Arguments {
 			addr, err := net.ResolveUDPAddr("udp4", ip)
@@ -144,7 +145,7 @@ func (p *PeerToPeer) packetFind(packet *protocol.DHTPacket) error {
 
 			if isNew {
 				peer.KnownIPs = append(peer.KnownIPs, addr)
-				Log(Debug, "Adding endpoint: %s", addr.String())
+				p.logger(SubsystemDHT).Log(Debug, "Adding endpoint: %s", addr.String())
 			}
 		}
 		for _, proxy := range packet.Proxies {
@@ -168,7 +169,7 @@ func (p *PeerToPeer) packetFind(packet *protocol.DHTPacket) error {
 
 			if isNew {
 				peer.Proxies = append(peer.Proxies, addr)
-				Log(Debug, "Adding proxy: %s", addr.String())
+				p.logger(SubsystemDHT).Log(Debug, "Adding proxy: %s", addr.String())
 			}
 		}
 		if packet.GetExtra() != "skip" {
@@ -200,7 +201,7 @@ func (p *PeerToPeer) packetFind(packet *protocol.DHTPacket) error {
 			}
 			if isNew {
 				ips = append(ips, addr)
-				Log(Debug, "Updating endpoint: %s", addr.String())
+				p.logger(SubsystemDHT).Log(Debug, "Updating endpoint: %s", addr.String())
 			}
 		}
 		peer.KnownIPs = ips
@@ -220,7 +221,7 @@ func (p *PeerToPeer) packetFind(packet *protocol.DHTPacket) error {
 			}
 			if isNew {
 				proxies = append(proxies, addr)
-				Log(Debug, "Updating proxy: %s", addr.String())
+				p.logger(SubsystemDHT).Log(Debug, "Updating proxy: %s", addr.String())
 			}
 		}
 		peer.Proxies = proxies
@@ -251,7 +252,7 @@ func (p *PeerToPeer) packetNode(packet *protocol.DHTPacket) error {
 		return fmt.Errorf("Peer %s not found", packet.Data)
 	}
 
-	Log(Debug, "Received peer %s IPs", packet.Data)
+	p.logger(SubsystemDHT).Log(Debug, "Received peer %s IPs", packet.Data)
 	list := []*net.UDPAddr{}
 	for _, addr := range packet.Arguments {
 		if addr == "" {
@@ -259,7 +260,7 @@ func (p *PeerToPeer) packetNode(packet *protocol.DHTPacket) error {
 		}
 		ip, err := net.ResolveUDPAddr("udp4", addr)
 		if err != nil {
-			Log(Error, "Failed to resolve one of peer addresses: %s", err)
+			p.logger(SubsystemDHT).Log(Error, "Failed to resolve one of peer addresses: %s", err)
 			continue
 		}
 		list = append(list, ip)
@@ -291,19 +292,19 @@ func (p *PeerToPeer) packetProxy(packet *protocol.DHTPacket) error {
 	if p.Dht == nil {
 		return fmt.Errorf("nil dht")
 	}
-	Log(Debug, "Received list of proxies")
+	p.logger(SubsystemDHT).Log(Debug, "Received list of proxies")
 	for _, proxy := range packet.Proxies {
 		proxyAddr, err := net.ResolveUDPAddr("udp4", proxy)
 		if err != nil {
 			continue
 		}
 		if p.ProxyManager.new(proxyAddr) == nil {
-			go func() {
+			p.spawn(func() {
 				msg, err := p.CreateMessage(MsgTypeProxy, []byte(p.Dht.ID), 0, false)
 				if err == nil {
 					p.UDPSocket.SendMessage(msg, proxyAddr)
 				}
-			}()
+			})
 		}
 	}
 	return nil
@@ -318,7 +319,7 @@ func (p *PeerToPeer) packetRequestProxy(packet *protocol.DHTPacket) error {
 	for _, proxy := range packet.Proxies {
 		addr, err := net.ResolveUDPAddr("udp4", proxy)
 		if err != nil {
-			Log(Error, "Can't parse proxy %s for peer %s", proxy, packet.Data)
+			p.logger(SubsystemDHT).Log(Error, "Can't parse proxy %s for peer %s", proxy, packet.Data)
 			continue
 		}
 		list = append(list, addr)
@@ -332,7 +333,7 @@ func (p *PeerToPeer) packetRequestProxy(packet *protocol.DHTPacket) error {
 }
 
 func (p *PeerToPeer) packetReportProxy(packet *protocol.DHTPacket) error {
-	Log(Info, "DHT confirmed proxy registration")
+	p.logger(SubsystemDHT).Log(Info, "DHT confirmed proxy registration")
 	return nil
 }
 
@@ -341,7 +342,7 @@ func (p *PeerToPeer) packetRegisterProxy(packet *protocol.DHTPacket) error {
 		return fmt.Errorf("nil packet")
 	}
 	if packet.Data == "OK" {
-		Log(Info, "Proxy registration confirmed")
+		p.logger(SubsystemDHT).Log(Info, "Proxy registration confirmed")
 	}
 	return nil
 }
@@ -372,9 +373,9 @@ func (p *PeerToPeer) packetState(packet *protocol.DHTPacket) error {
 	if peer != nil {
 		peer.RemoteState = PeerState(numericState)
 		p.Swarm.Update(packet.Data, peer)
-		Log(Debug, "Peer %s reported state '%s'", peer.ID, StringifyState(peer.RemoteState))
+		p.logger(SubsystemDHT).Log(Debug, "Peer %s reported state '%s'", peer.ID, StringifyState(peer.RemoteState))
 	} else {
-		Log(Trace, "Received state of unknown peer. Updating peers")
+		p.logger(SubsystemDHT).Log(Trace, "Received state of unknown peer. Updating peers")
 		//p.Dht.sendFind()
 	}
 	return nil
@@ -397,14 +398,15 @@ func (p *PeerToPeer) packetUnknown(packet *protocol.DHTPacket) error {
 	if p.Interface == nil {
 		return fmt.Errorf("nil interface")
 	}
-	Log(Debug, "Received unknown packet")
+	p.logger(SubsystemDHT).Log(Debug, "Received unknown packet")
 	p.FindNetworkAddresses()
 	if len(packet.Data) > 0 && packet.Data == "DHCP" {
-		Log(Warning, "Network information was requested")
+		p.logger(SubsystemDHT).Log(Warning, "Network information was requested")
 		p.ReportIP(p.Interface.GetIP().String(), p.Interface.GetHardwareAddress().String(), p.Interface.GetName())
 		return nil
 	}
-	Log(Warning, "Bootstap node refuses our identity. Reconnecting")
+	p.logger(SubsystemDHT).Log(Warning, "Bootstap node refuses our identity. Reconnecting")
+	p.publish(EventDHT, "", map[string]string{"state": "reconnecting"})
 	return p.Dht.Connect(p.LocalIPs, p.ProxyManager.GetList())
 }
 
@@ -415,6 +417,20 @@ func (p *PeerToPeer) packetUnsupported(packet *protocol.DHTPacket) error {
 	if p.Dht == nil {
 		return fmt.Errorf("nil dht")
 	}
-	Log(Error, "Bootstap node doesn't support our version. Shutting down")
+	p.logger(SubsystemDHT).Log(Error, "Bootstap node doesn't support our version. Shutting down")
+	p.publish(EventDHT, "", map[string]string{"state": "disconnected"})
 	return p.Dht.Close()
 }
+
+// handleDHTPacket runs a callback for packet received from bootstrap node
+func (p *PeerToPeer) handleDHTPacket(packet *protocol.DHTPacket) {
+	cb, e := p.Dht.TCPCallbacks[packet.Type]
+	if !e {
+		Log(Error, "Unsupported packet from DHT")
+		return
+	}
+	err := cb(packet)
+	if err != nil {
+		Log(Error, "DHT: %s", err)
+	}
+}
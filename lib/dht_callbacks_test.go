@@ -14,7 +14,6 @@ func TestPeerToPeer_setupTCPCallbacks(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -43,7 +42,6 @@ func TestPeerToPeer_setupTCPCallbacks(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -67,7 +65,6 @@ func TestPeerToPeer_packetBadProxy(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -100,7 +97,6 @@ func TestPeerToPeer_packetBadProxy(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -126,7 +122,6 @@ func TestPeerToPeer_packetConnect(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -162,7 +157,6 @@ func TestPeerToPeer_packetConnect(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -188,7 +182,6 @@ func TestPeerToPeer_packetDHCP(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -224,7 +217,6 @@ func TestPeerToPeer_packetDHCP(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -250,7 +242,6 @@ func TestPeerToPeer_packetError(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -287,7 +278,6 @@ func TestPeerToPeer_packetError(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -313,7 +303,6 @@ func TestPeerToPeer_packetFind(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -409,7 +398,6 @@ func TestPeerToPeer_packetFind(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -435,7 +423,6 @@ func TestPeerToPeer_packetForward(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -468,7 +455,6 @@ func TestPeerToPeer_packetForward(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -494,7 +480,6 @@ func TestPeerToPeer_packetNode(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -556,7 +541,6 @@ func TestPeerToPeer_packetNode(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -582,7 +566,6 @@ func TestPeerToPeer_packetNotify(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -615,7 +598,6 @@ func TestPeerToPeer_packetNotify(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -641,7 +623,6 @@ func TestPeerToPeer_packetPing(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -674,7 +655,6 @@ func TestPeerToPeer_packetPing(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -700,7 +680,6 @@ func TestPeerToPeer_packetProxy(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -769,7 +748,6 @@ func TestPeerToPeer_packetProxy(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -795,7 +773,6 @@ func TestPeerToPeer_packetRequestProxy(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -853,7 +830,6 @@ func TestPeerToPeer_packetRequestProxy(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -879,7 +855,6 @@ func TestPeerToPeer_packetReportProxy(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -912,7 +887,6 @@ func TestPeerToPeer_packetReportProxy(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -938,7 +912,6 @@ func TestPeerToPeer_packetRegisterProxy(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -973,7 +946,6 @@ func TestPeerToPeer_packetRegisterProxy(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -999,7 +971,6 @@ func TestPeerToPeer_packetReportLoad(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1032,7 +1003,6 @@ func TestPeerToPeer_packetReportLoad(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1058,7 +1028,6 @@ func TestPeerToPeer_packetState(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1132,7 +1101,6 @@ func TestPeerToPeer_packetState(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1158,7 +1126,6 @@ func TestPeerToPeer_packetStop(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1191,7 +1158,6 @@ func TestPeerToPeer_packetStop(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1217,7 +1183,6 @@ func TestPeerToPeer_packetUnknown(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1272,7 +1237,6 @@ func TestPeerToPeer_packetUnknown(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1298,7 +1262,6 @@ func TestPeerToPeer_packetUnsupported(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1333,7 +1296,6 @@ func TestPeerToPeer_packetUnsupported(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1,6 +1,7 @@
 package ptp
 
 import (
+	"context"
 	"net"
 	"testing"
 	"time"
@@ -25,7 +26,8 @@ func TestConnect(t *testing.T) {
 	finish := make(chan bool)
 	defer close(finish)
 	dht := new(DHTClient)
-	ActiveInterfaces = []net.IP{net.IP("127.0.0.1")}
+	ActiveInterfaces = NewIPRegistry()
+	ActiveInterfaces.Reserve(net.IP("127.0.0.1"))
 	go func() {
 		errChan := make(chan error)
 		go func() {
@@ -490,19 +492,19 @@ func TestSendState(t *testing.T) {
 
 func TestSendDHCP(t *testing.T) {
 	dht := new(DHTClient)
-	err := dht.sendDHCP(nil, nil)
+	err := dht.sendDHCP(context.Background(), nil, nil)
 	if err == nil {
 		t.Fatalf("Failed to sendDHCP (1): must have returned non-nil but returned nil")
 	}
 	dht.OutgoingData = make(chan *protocol.DHTPacket, 1)
-	err = dht.sendDHCP(nil, nil)
+	err = dht.sendDHCP(context.Background(), nil, nil)
 	close(dht.OutgoingData)
 	if err != nil {
 		t.Fatalf("Failed to sendDHCP (2): %v", err)
 	}
 	dht.OutgoingData = make(chan *protocol.DHTPacket, 1)
 	defer close(dht.OutgoingData)
-	err = dht.sendDHCP(nil, new(net.IPNet))
+	err = dht.sendDHCP(context.Background(), nil, new(net.IPNet))
 	if err != nil {
 		t.Fatalf("Failed to sendDHCP (3): %v", err)
 	}
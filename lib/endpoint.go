@@ -14,8 +14,13 @@ type Endpoint struct {
 	LastContact      time.Time
 	LastPing         time.Time
 	broken           bool
+	failedAt         time.Time     // Time when endpoint stopped answering fast pings
+	quality          latencyWindow // Results of recent pings and latency requests
 	Latency          time.Duration
 	LastLatencyQuery time.Time
+	mtu              uint32    // Discovered path MTU
+	probeAck         uint32    // Token of the last answered path MTU probe
+	search           mtuSearch // Path MTU search
 }
 
 // Measure will prepare and send latency packet to the endpoint
@@ -39,7 +44,7 @@ func (e *Endpoint) Measure(n *Network, id string) {
 
 	e.LastLatencyQuery = time.Now()
 
-	ts, _ := time.Now().MarshalBinary()
+	ts, _ := e.LastLatencyQuery.MarshalBinary()
 	ba := e.addrToBytes()
 
 	if ba == nil {
@@ -51,6 +56,7 @@ func (e *Endpoint) Measure(n *Network, id string) {
 	payload = append(payload, ba...)
 	payload = append(payload, []byte(id)...)
 	payload = append(payload, ts...)
+	payload = appendSeq(payload, e.quality.sent(e.LastLatencyQuery))
 
 	msg, err := CreateMessageStatic(MsgTypeLatency, payload)
 	if err != nil {
@@ -85,7 +91,9 @@ func (e *Endpoint) addrToBytes() []byte {
 	return ipfield
 }
 
-func (e *Endpoint) ping(ptpc *PeerToPeer, id string) error {
+// ping sends cross-peer ping to the endpoint. Peers that echo sequence
+// numbers receive one, so reply is used to measure the path
+func (e *Endpoint) ping(ptpc *PeerToPeer, id string, seq bool) error {
 	if ptpc == nil {
 		return fmt.Errorf("nil ptp")
 	}
@@ -97,6 +105,9 @@ func (e *Endpoint) ping(ptpc *PeerToPeer, id string) error {
 		return fmt.Errorf("nil addr")
 	}
 	payload := append([]byte("q"+id), []byte(e.Addr.String())...)
+	if seq {
+		payload = pingPayload(payload, e.quality.sent(e.LastPing))
+	}
 	msg, err := ptpc.CreateMessage(MsgTypeXpeerPing, payload, 0, true)
 	if err != nil {
 		return err
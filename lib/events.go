@@ -0,0 +1,242 @@
+package ptp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies a kind of event published on the event bus
+type EventType string
+
+// Types of events
+const (
+	EventPeerState   EventType = "peer.state"    // Peer changed local state
+	EventPeerRoute   EventType = "peer.endpoint" // Peer switched active endpoint
+	EventProxy       EventType = "proxy"         // Proxy server was activated or removed
+	EventIPAssigned  EventType = "ip.assigned"   // IP was assigned to p2p interface
+	EventIfaceUp     EventType = "iface.up"      // p2p interface was created
+	EventIfaceDown   EventType = "iface.down"    // p2p interface was closed
+	EventKeyChanged  EventType = "key"           // Crypto key was added
+	EventDHT         EventType = "dht"           // Connectivity with bootstrap node changed
+	EventInstanceEnd EventType = "instance.stop" // Instance has been stopped
+)
+
+// DefaultEventBuffer is a size of subscriber's channel used when
+// buffer is not specified
+const DefaultEventBuffer = 64
+
+// MaxEventQueue is a number of events kept in memory for a queued
+// subscriber. Events published when queue is full are dropped
+const MaxEventQueue = 4096
+
+// Event is a single notification published on the event bus
+type Event struct {
+	Type   EventType         `json:"type" yaml:"type"`
+	Time   time.Time         `json:"time" yaml:"time"`
+	Hash   string            `json:"hash" yaml:"hash"`
+	PeerID string            `json:"peer,omitempty" yaml:"peer,omitempty"`
+	Data   map[string]string `json:"data,omitempty" yaml:"data,omitempty"`
+}
+
+// Subscription receives events from the bus through C. Events that
+// doesn't fit into subscriber's buffer or queue are dropped
+type Subscription struct {
+	dropped uint64 // Must be first to keep 64-bit alignment
+	C       <-chan Event
+	ch      chan Event
+	types   map[EventType]bool
+	hash    string
+	closed  bool
+	queued  bool          // Events are kept in queue until subscriber reads them
+	queue   []Event       // Events waiting for delivery to queued subscriber
+	sending int           // Events taken from queue by forwarder
+	wake    chan struct{} // Signals new events in queue
+}
+
+// Dropped returns number of events that were not delivered because
+// subscriber's buffer or queue was full
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+func (s *Subscription) match(e Event) bool {
+	if s.hash != "" && s.hash != e.Hash {
+		return false
+	}
+	if len(s.types) == 0 {
+		return true
+	}
+	return s.types[e.Type]
+}
+
+// EventBus delivers events to subscribers. Publishing never blocks.
+// All methods are safe to call on a nil bus
+type EventBus struct {
+	subscribers map[*Subscription]bool
+	lock        sync.Mutex
+}
+
+// NewEventBus returns an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*Subscription]bool)}
+}
+
+// Subscribe registers new subscriber. Empty hash matches events from all
+// instances and empty types matches events of all types. Buffer of 0
+// means DefaultEventBuffer
+func (b *EventBus) Subscribe(hash string, buffer int, types ...EventType) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+	s := newSubscription(hash, buffer, types)
+	b.add(s)
+	return s
+}
+
+// SubscribeQueued registers subscriber that doesn't lose events while
+// it's busy. Up to MaxEventQueue events are queued in memory. Remaining
+// events are delivered after Unsubscribe, then C is closed
+func (b *EventBus) SubscribeQueued(hash string, types ...EventType) *Subscription {
+	s := newSubscription(hash, 0, types)
+	s.queued = true
+	s.wake = make(chan struct{}, 1)
+	if b.add(s) {
+		go b.forward(s)
+	}
+	return s
+}
+
+func newSubscription(hash string, buffer int, types []EventType) *Subscription {
+	s := &Subscription{
+		ch:    make(chan Event, buffer),
+		types: make(map[EventType]bool),
+		hash:  hash,
+	}
+	s.C = s.ch
+	for _, t := range types {
+		s.types[t] = true
+	}
+	return s
+}
+
+// add registers subscription. Subscription on nil bus is closed right away
+// and false is returned
+func (b *EventBus) add(s *Subscription) bool {
+	if b == nil {
+		close(s.ch)
+		s.closed = true
+		return false
+	}
+	b.lock.Lock()
+	if b.subscribers == nil {
+		b.subscribers = make(map[*Subscription]bool)
+	}
+	b.subscribers[s] = true
+	b.lock.Unlock()
+	return true
+}
+
+// forward moves events from the queue to the channel of queued
+// subscription. Blocks while subscriber is busy
+func (b *EventBus) forward(s *Subscription) {
+	for {
+		b.lock.Lock()
+		queue, closed := s.queue, s.closed
+		s.queue = nil
+		s.sending = len(queue)
+		b.lock.Unlock()
+		for _, e := range queue {
+			s.ch <- e
+		}
+		if closed && len(queue) == 0 {
+			close(s.ch)
+			return
+		}
+		if len(queue) == 0 {
+			<-s.wake
+		}
+	}
+}
+
+// notify wakes up forwarder of queued subscription
+func (s *Subscription) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Unsubscribe removes subscriber from the bus and closes its channel
+func (b *EventBus) Unsubscribe(s *Subscription) {
+	if b == nil || s == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	delete(b.subscribers, s)
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.queued {
+		s.notify()
+		return
+	}
+	close(s.ch)
+}
+
+// Publish sends event to every matching subscriber
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for s := range b.subscribers {
+		if !s.match(e) {
+			continue
+		}
+		if s.queued {
+			if len(s.queue)+s.sending >= MaxEventQueue {
+				atomic.AddUint64(&s.dropped, 1)
+				continue
+			}
+			s.queue = append(s.queue, e)
+			s.notify()
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
+
+// publish sends event of this instance to the bus
+func (p *PeerToPeer) publish(t EventType, peerID string, data map[string]string) {
+	p.Events.Publish(Event{Type: t, Hash: p.Hash, PeerID: peerID, Data: data})
+}
+
+// addrToString returns string representation of address or an empty
+// string for nil address
+func addrToString(addr *net.UDPAddr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// ipToString returns string representation of IP or an empty string
+// for nil IP
+func ipToString(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
@@ -0,0 +1,134 @@
+package ptp
+
+import (
+	"testing"
+)
+
+func TestEventBus_Publish(t *testing.T) {
+	type args struct {
+		hash  string
+		types []EventType
+	}
+	tests := []struct {
+		name   string
+		args   args
+		events []Event
+		want   int
+	}{
+		{"all events", args{}, []Event{{Type: EventPeerState, Hash: "a"}, {Type: EventDHT, Hash: "b"}}, 2},
+		{"by hash", args{hash: "a"}, []Event{{Type: EventPeerState, Hash: "a"}, {Type: EventDHT, Hash: "b"}}, 1},
+		{"by type", args{types: []EventType{EventDHT, EventProxy}}, []Event{{Type: EventPeerState, Hash: "a"}, {Type: EventDHT, Hash: "b"}, {Type: EventProxy, Hash: "a"}}, 2},
+		{"by hash and type", args{hash: "a", types: []EventType{EventDHT}}, []Event{{Type: EventDHT, Hash: "a"}, {Type: EventDHT, Hash: "b"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewEventBus()
+			s := b.Subscribe(tt.args.hash, 0, tt.args.types...)
+			for _, e := range tt.events {
+				b.Publish(e)
+			}
+			b.Unsubscribe(s)
+			got := 0
+			for e := range s.C {
+				if e.Time.IsZero() {
+					t.Errorf("EventBus.Publish() didn't set event time")
+				}
+				got++
+			}
+			if got != tt.want {
+				t.Errorf("EventBus.Publish() delivered %d events, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventBus_Dropped(t *testing.T) {
+	b := NewEventBus()
+	s := b.Subscribe("", 2)
+	for i := 0; i < 5; i++ {
+		b.Publish(Event{Type: EventKeyChanged})
+	}
+	if s.Dropped() != 3 {
+		t.Errorf("Subscription.Dropped() = %d, want 3", s.Dropped())
+	}
+	b.Unsubscribe(s)
+	b.Unsubscribe(s)
+}
+
+func TestEventBus_SubscribeQueued(t *testing.T) {
+	b := NewEventBus()
+	s := b.SubscribeQueued("", EventKeyChanged)
+	for i := 0; i < DefaultEventBuffer*3; i++ {
+		b.Publish(Event{Type: EventKeyChanged})
+		b.Publish(Event{Type: EventDHT})
+	}
+	if e := <-s.C; e.Type != EventKeyChanged {
+		t.Errorf("Received %s event", e.Type)
+	}
+	b.Unsubscribe(s)
+	b.Publish(Event{Type: EventKeyChanged})
+	got := 1
+	for range s.C {
+		got++
+	}
+	if got != DefaultEventBuffer*3 || s.Dropped() != 0 {
+		t.Errorf("Delivered %d events, dropped %d", got, s.Dropped())
+	}
+
+	var nilBus *EventBus
+	if _, ok := <-nilBus.SubscribeQueued("").C; ok {
+		t.Errorf("Subscription on nil bus must be closed")
+	}
+}
+
+func TestEventBus_SubscribeQueuedOverflow(t *testing.T) {
+	b := NewEventBus()
+	s := b.SubscribeQueued("")
+	total := MaxEventQueue + 10
+	for i := 0; i < total; i++ {
+		b.Publish(Event{Type: EventKeyChanged})
+	}
+	b.Unsubscribe(s)
+	got := 0
+	for range s.C {
+		got++
+	}
+	if uint64(got)+s.Dropped() != uint64(total) || s.Dropped() == 0 {
+		t.Errorf("Delivered %d events, dropped %d", got, s.Dropped())
+	}
+	if got > MaxEventQueue {
+		t.Errorf("Delivered %d events, queue is limited to %d", got, MaxEventQueue)
+	}
+}
+
+func TestEventBus_nil(t *testing.T) {
+	var b *EventBus
+	b.Publish(Event{Type: EventDHT})
+	s := b.Subscribe("", 0)
+	if _, ok := <-s.C; ok {
+		t.Errorf("Subscription on nil bus must be closed")
+	}
+	b.Unsubscribe(s)
+
+	p := new(PeerToPeer)
+	p.publish(EventDHT, "", nil)
+}
+
+func TestNetworkPeer_SetState_event(t *testing.T) {
+	p := &PeerToPeer{Dht: new(DHTClient), Hash: "hash", Events: NewEventBus()}
+	s := p.Events.Subscribe("hash", 0, EventPeerState)
+	np := &NetworkPeer{ID: "peer"}
+	np.SetState(PeerStateConnecting, p)
+	np.SetState(PeerStateConnecting, p)
+	p.Events.Unsubscribe(s)
+	events := []Event{}
+	for e := range s.C {
+		events = append(events, e)
+	}
+	if len(events) != 1 {
+		t.Fatalf("SetState() published %d events, want 1", len(events))
+	}
+	if events[0].PeerID != "peer" || events[0].Data["to"] != StringifyState(PeerStateConnecting) {
+		t.Errorf("SetState() published wrong event: %+v", events[0])
+	}
+}
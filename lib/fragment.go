@@ -0,0 +1,250 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Messages that don't fit into underlay MTU are split into fragments and
+// reassembled by receiving peer, so they're never fragmented by IP.
+// Fragment is a MsgTypeFragment message with the following payload:
+// id[4] index[2] count[2] data[?]
+// Data of all fragments put together is a serialized message
+
+// Fragmentation limits
+const (
+	fragmentHeaderSize  = 8
+	underlayOverhead    = 28              // IPv4 and UDP headers
+	messageOverhead     = HeaderSize + 32 // Header, IV and padding of encrypted message
+	MaxFragments        = 64
+	FragmentTimeout     = time.Second * 5 // Incomplete messages are dropped after this time
+	FragmentMemoryLimit = 4 << 20         // Bytes held by incomplete messages of an instance
+	DefaultUnderlayMTU  = 1500
+	MinUnderlayMTU      = 576
+)
+
+// UnderlayMTU is a daemon-wide MTU of the network between peers. Used only
+// by instances created after a change
+var UnderlayMTU = DefaultUnderlayMTU
+
+// FragmentStats holds fragmentation counters of an instance
+type FragmentStats struct {
+	Sent        uint64 `json:"sent"`        // Fragments sent
+	Reassembled uint64 `json:"reassembled"` // Messages put together from fragments
+	Dropped     uint64 `json:"dropped"`     // Fragments that were invalid, expired or evicted
+}
+
+// fragmentKey identifies fragments of a single message
+type fragmentKey struct {
+	ip   [16]byte
+	port int
+	id   uint32
+}
+
+func newFragmentKey(addr *net.UDPAddr, id uint32) fragmentKey {
+	key := fragmentKey{port: addr.Port, id: id}
+	copy(key.ip[:], addr.IP.To16())
+	return key
+}
+
+// reassembly is a message waiting for the rest of its fragments
+type reassembly struct {
+	parts   [][]byte
+	missing int
+	size    int
+	started time.Time
+}
+
+// fragmentAssembler puts fragments back together. Memory held by
+// incomplete messages is limited: oldest ones are evicted first
+type fragmentAssembler struct {
+	stats     FragmentStats // Must be first to keep 64-bit alignment
+	lock      sync.Mutex
+	pending   map[fragmentKey]*reassembly
+	memory    int
+	limit     int
+	timeout   time.Duration
+	lastPurge time.Time
+}
+
+func newFragmentAssembler(limit int, timeout time.Duration) *fragmentAssembler {
+	return &fragmentAssembler{
+		pending: make(map[fragmentKey]*reassembly),
+		limit:   limit,
+		timeout: timeout,
+	}
+}
+
+// add stores a fragment. Returns the message when it's complete
+func (a *fragmentAssembler) add(key fragmentKey, index, count int, data []byte, now time.Time) ([]byte, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if now.Sub(a.lastPurge) >= time.Second {
+		a.purge(now)
+		a.lastPurge = now
+	}
+	if count < 1 || count > MaxFragments || index >= count || len(data) > a.limit {
+		atomic.AddUint64(&a.stats.Dropped, 1)
+		return nil, fmt.Errorf("Bad fragment %d of %d", index, count)
+	}
+	r, exists := a.pending[key]
+	if exists && len(r.parts) != count {
+		a.remove(key, r)
+		atomic.AddUint64(&a.stats.Dropped, 1)
+		return nil, fmt.Errorf("Fragment count changed from %d to %d", len(r.parts), count)
+	}
+	if exists && r.parts[index] != nil {
+		// Duplicate
+		return nil, nil
+	}
+	for a.memory+len(data) > a.limit {
+		a.evictOldest()
+	}
+	if _, exists = a.pending[key]; !exists {
+		r = &reassembly{parts: make([][]byte, count), missing: count, started: now}
+		a.pending[key] = r
+	}
+	r.parts[index] = append([]byte(nil), data...)
+	r.missing--
+	r.size += len(data)
+	a.memory += len(data)
+	if r.missing > 0 {
+		return nil, nil
+	}
+	a.remove(key, r)
+	message := make([]byte, 0, r.size)
+	for _, part := range r.parts {
+		message = append(message, part...)
+	}
+	atomic.AddUint64(&a.stats.Reassembled, 1)
+	return message, nil
+}
+
+func (a *fragmentAssembler) remove(key fragmentKey, r *reassembly) {
+	delete(a.pending, key)
+	a.memory -= r.size
+}
+
+// drop removes incomplete message and counts its fragments as dropped
+func (a *fragmentAssembler) drop(key fragmentKey, r *reassembly) {
+	a.remove(key, r)
+	atomic.AddUint64(&a.stats.Dropped, uint64(len(r.parts)-r.missing))
+}
+
+// purge drops messages that weren't completed in time
+func (a *fragmentAssembler) purge(now time.Time) {
+	for key, r := range a.pending {
+		if now.Sub(r.started) > a.timeout {
+			a.drop(key, r)
+		}
+	}
+}
+
+func (a *fragmentAssembler) evictOldest() {
+	var oldest *reassembly
+	var oldestKey fragmentKey
+	for key, r := range a.pending {
+		if oldest == nil || r.started.Before(oldest.started) {
+			oldest, oldestKey = r, key
+		}
+	}
+	if oldest != nil {
+		a.drop(oldestKey, oldest)
+	}
+}
+
+// Stats returns a snapshot of fragmentation counters
+func (a *fragmentAssembler) Stats() FragmentStats {
+	if a == nil {
+		return FragmentStats{}
+	}
+	return FragmentStats{
+		Sent:        atomic.LoadUint64(&a.stats.Sent),
+		Reassembled: atomic.LoadUint64(&a.stats.Reassembled),
+		Dropped:     atomic.LoadUint64(&a.stats.Dropped),
+	}
+}
+
+// maxDatagram returns size of the largest datagram that can be sent to
+// the peer without IP fragmentation
+func (p *PeerToPeer) maxDatagram(peer *NetworkPeer) int {
+	return p.pathMTU(peer) - underlayOverhead
+}
+
+// sendToPeer queues message to the endpoint of the peer. Message that
+// doesn't fit into a datagram is sent in fragments, if peer can
+// reassemble them
+func (p *PeerToPeer) sendToPeer(peer *NetworkPeer, msg *P2PMessage, endpoint *net.UDPAddr) (int, error) {
+	limit := p.maxDatagram(peer)
+	if HeaderSize+len(msg.Data) <= limit || peer.Caps()&CapFragment == 0 || p.fragments == nil {
+		return p.UDPSocket.QueueMessage(msg, endpoint)
+	}
+	data := msg.Serialize()
+	chunk := limit - HeaderSize - fragmentHeaderSize
+	count := (len(data) + chunk - 1) / chunk
+	if count > MaxFragments {
+		return 0, fmt.Errorf("Message of %d bytes needs too many fragments", len(data))
+	}
+	id := atomic.AddUint32(&p.fragmentID, 1)
+	sent := 0
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunk
+		if end > len(data) {
+			end = len(data)
+		}
+		payload := make([]byte, fragmentHeaderSize+end-i*chunk)
+		binary.BigEndian.PutUint32(payload[0:4], id)
+		binary.BigEndian.PutUint16(payload[4:6], uint16(i))
+		binary.BigEndian.PutUint16(payload[6:8], uint16(count))
+		copy(payload[fragmentHeaderSize:], data[i*chunk:end])
+		// Message is encrypted already, if needed
+		fragment, err := p.CreateMessage(MsgTypeFragment, payload, 0, false)
+		if err != nil {
+			return sent, err
+		}
+		n, err := p.UDPSocket.QueueMessage(fragment, endpoint)
+		if err != nil {
+			return sent, err
+		}
+		sent += n
+		atomic.AddUint64(&p.fragments.stats.Sent, 1)
+	}
+	return sent, nil
+}
+
+// HandleFragmentMessage collects fragments of a message and handles the
+// message once it's complete
+func (p *PeerToPeer) HandleFragmentMessage(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+	if msg == nil {
+		return fmt.Errorf("nil message")
+	}
+	if srcAddr == nil {
+		return fmt.Errorf("nil source addr")
+	}
+	if p.fragments == nil {
+		return fmt.Errorf("nil fragment assembler")
+	}
+	if len(msg.Data) < fragmentHeaderSize {
+		return fmt.Errorf("payload is too small")
+	}
+	id := binary.BigEndian.Uint32(msg.Data[0:4])
+	index := int(binary.BigEndian.Uint16(msg.Data[4:6]))
+	count := int(binary.BigEndian.Uint16(msg.Data[6:8]))
+	data, err := p.fragments.add(newFragmentKey(srcAddr, id), index, count, msg.Data[fragmentHeaderSize:], time.Now())
+	if err != nil || data == nil {
+		return err
+	}
+	if len(data) >= HeaderSize && binary.BigEndian.Uint16(data[2:4]) == MsgTypeFragment {
+		return fmt.Errorf("Fragmented fragment from %s", srcAddr)
+	}
+	return p.HandleP2PMessage(len(data), srcAddr, nil, data)
+}
+
+// FragmentStats returns fragmentation counters of the instance
+func (p *PeerToPeer) FragmentStats() FragmentStats {
+	return p.fragments.Stats()
+}
@@ -0,0 +1,182 @@
+package ptp
+
+import (
+	"bytes"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFragmentAssembler(t *testing.T) {
+	src, _ := net.ResolveUDPAddr("udp4", "192.168.0.1:1234")
+	now := time.Now()
+
+	a := newFragmentAssembler(1024, FragmentTimeout)
+	key := newFragmentKey(src, 1)
+	steps := []struct {
+		name    string
+		index   int
+		count   int
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{"last first", 2, 3, "ghi", "", false},
+		{"first", 0, 3, "abc", "", false},
+		{"duplicate", 0, 3, "xxx", "", false},
+		{"bad index", 3, 3, "jkl", "", true},
+		{"bad count", 0, MaxFragments + 1, "jkl", "", true},
+		{"complete", 1, 3, "def", "abcdefghi", false},
+	}
+	for _, tt := range steps {
+		got, err := a.add(key, tt.index, tt.count, []byte(tt.data), now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: message = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if len(a.pending) != 0 || a.memory != 0 {
+		t.Errorf("Complete message is still held: %d messages, %d bytes", len(a.pending), a.memory)
+	}
+	if s := a.Stats(); s.Reassembled != 1 || s.Dropped != 2 {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+
+	// Count of fragments can't change
+	key = newFragmentKey(src, 2)
+	a.add(key, 0, 2, []byte("abc"), now)
+	if _, err := a.add(key, 1, 3, []byte("def"), now); err == nil {
+		t.Errorf("Changed fragment count was accepted")
+	}
+	if len(a.pending) != 0 {
+		t.Errorf("Message with changed count is still held")
+	}
+}
+
+func TestFragmentAssembler_expire(t *testing.T) {
+	src, _ := net.ResolveUDPAddr("udp4", "192.168.0.1:1234")
+	now := time.Now()
+
+	a := newFragmentAssembler(1024, FragmentTimeout)
+	a.add(newFragmentKey(src, 1), 0, 2, []byte("abc"), now)
+	a.add(newFragmentKey(src, 2), 0, 2, []byte("abc"), now.Add(FragmentTimeout))
+
+	later := now.Add(FragmentTimeout + time.Second*2)
+	got, _ := a.add(newFragmentKey(src, 1), 1, 2, []byte("def"), later)
+	if got != nil {
+		t.Errorf("Expired message was completed: %q", got)
+	}
+	if _, exists := a.pending[newFragmentKey(src, 2)]; !exists {
+		t.Errorf("Message that didn't expire was dropped")
+	}
+	if s := a.Stats(); s.Dropped != 1 || s.Reassembled != 0 {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+}
+
+func TestFragmentAssembler_memoryLimit(t *testing.T) {
+	src, _ := net.ResolveUDPAddr("udp4", "192.168.0.1:1234")
+	other, _ := net.ResolveUDPAddr("udp4", "192.168.0.2:1234")
+	now := time.Now()
+	data := make([]byte, 40)
+
+	a := newFragmentAssembler(100, FragmentTimeout)
+	a.add(newFragmentKey(src, 1), 0, 3, data, now)
+	a.add(newFragmentKey(src, 1), 1, 3, data, now)
+	a.add(newFragmentKey(other, 1), 0, 2, data, now.Add(time.Millisecond))
+
+	if _, exists := a.pending[newFragmentKey(src, 1)]; exists {
+		t.Errorf("Oldest message wasn't evicted")
+	}
+	if _, exists := a.pending[newFragmentKey(other, 1)]; !exists {
+		t.Errorf("Newest message was evicted")
+	}
+	if a.memory != len(data) {
+		t.Errorf("Held memory: %d", a.memory)
+	}
+	if s := a.Stats(); s.Dropped != 2 {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+	if _, err := a.add(newFragmentKey(src, 2), 0, 1, make([]byte, 101), now); err == nil {
+		t.Errorf("Fragment larger than limit was accepted")
+	}
+}
+
+func TestPeerToPeer_fragmentLoopback(t *testing.T) {
+	sender, receiver := loopbackPair(t)
+	defer sender.Close()
+
+	crypter := Crypto{Active: true, ActiveKey: CryptoKey{Key: []byte("0123456789abcdef")}}
+	mac, _ := net.ParseMAC("06:00:00:00:00:01")
+
+	p := new(PeerToPeer)
+	p.Crypter = crypter
+	p.UDPSocket = sender
+	p.underlayMTU = MinUnderlayMTU
+	p.fragments = newFragmentAssembler(FragmentMemoryLimit, FragmentTimeout)
+	p.Swarm = new(Swarm)
+	p.Swarm.Init()
+	peer := &NetworkPeer{ID: "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb", PeerHW: mac, Endpoint: loopbackAddr(receiver)}
+	peer.setCaps(CapFragment)
+	p.Swarm.Update(peer.ID, peer)
+
+	var lock sync.Mutex
+	var got []byte
+	r := new(PeerToPeer)
+	r.Crypter = crypter
+	r.fragments = newFragmentAssembler(FragmentMemoryLimit, FragmentTimeout)
+	r.MessageHandlers = map[uint16]MessageHandler{
+		MsgTypeFragment: r.HandleFragmentMessage,
+		MsgTypeNenc: func(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+			lock.Lock()
+			got = msg.Data
+			lock.Unlock()
+			return nil
+		},
+	}
+	done := make(chan struct{})
+	go func() {
+		receiver.ListenBatch(func(batch []UDPDatagram) {
+			for _, d := range batch {
+				r.HandleP2PMessage(len(d.Data), d.Addr, nil, d.Data)
+			}
+		})
+		close(done)
+	}()
+
+	frame := make([]byte, 3000)
+	for i := range frame {
+		frame[i] = byte(i)
+	}
+	msg, err := p.CreateMessage(MsgTypeNenc, frame, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.SendTo(mac, msg); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		lock.Lock()
+		n := len(got)
+		lock.Unlock()
+		if n != 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	receiver.Close()
+	<-done
+
+	if !bytes.Equal(got, frame) {
+		t.Fatalf("Received %d bytes, want %d", len(got), len(frame))
+	}
+	if s := p.FragmentStats(); s.Sent < 6 {
+		t.Errorf("Message was sent in %d fragments", s.Sent)
+	}
+	if s := r.FragmentStats(); s.Reassembled != 1 || s.Dropped != 0 {
+		t.Errorf("Wrong receiver stats: %+v", s)
+	}
+}
@@ -0,0 +1,129 @@
+package ptp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// HookTimeout is a maximum time a single hook command is allowed to run
+var HookTimeout = time.Duration(30 * time.Second)
+
+// Hooks is a set of commands executed on instance events. Details of
+// the event are passed through P2P_* environment variables
+type Hooks struct {
+	InterfaceUp      string `yaml:"interface-up"`      // p2p interface was created
+	InterfaceDown    string `yaml:"interface-down"`    // p2p interface was closed
+	PeerConnected    string `yaml:"peer-connected"`    // Peer switched to connected state
+	PeerDisconnected string `yaml:"peer-disconnected"` // Peer left connected state
+	IPAssigned       string `yaml:"ip-assigned"`       // IP was set on p2p interface
+}
+
+// IsEmpty returns true when no hooks were configured
+func (h Hooks) IsEmpty() bool {
+	return h.InterfaceUp == "" && h.InterfaceDown == "" && h.PeerConnected == "" && h.PeerDisconnected == "" && h.IPAssigned == ""
+}
+
+// command returns hook name and command that should be executed for
+// the event. Empty command means event has no hook
+func (h Hooks) command(e Event) (string, string) {
+	connected := StringifyState(PeerStateConnected)
+	switch e.Type {
+	case EventIfaceUp:
+		return "interface-up", h.InterfaceUp
+	case EventIfaceDown:
+		return "interface-down", h.InterfaceDown
+	case EventIPAssigned:
+		return "ip-assigned", h.IPAssigned
+	case EventPeerState:
+		if e.Data["to"] == connected {
+			return "peer-connected", h.PeerConnected
+		}
+		if e.Data["from"] == connected {
+			return "peer-disconnected", h.PeerDisconnected
+		}
+	}
+	return "", ""
+}
+
+// hookEnv builds environment variables for the hook. Every value of
+// event data is passed as P2P_<KEY>
+func hookEnv(name string, e Event) []string {
+	env := []string{
+		"P2P_HOOK=" + name,
+		"P2P_EVENT=" + string(e.Type),
+		"P2P_HASH=" + e.Hash,
+		"P2P_PEER_ID=" + e.PeerID,
+	}
+	for k, v := range e.Data {
+		env = append(env, fmt.Sprintf("P2P_%s=%s", strings.ToUpper(k), v))
+	}
+	return env
+}
+
+// inheritedEnv returns environment of the daemon without P2P_* variables,
+// so hooks see only variables describing the event and daemon secrets,
+// like P2P_MASTER_KEY, don't leak
+func inheritedEnv() []string {
+	env := []string{}
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "P2P_") {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// HookRunner executes hooks for events received from the bus
+type HookRunner struct {
+	hooks Hooks
+	bus   *EventBus
+	sub   *Subscription
+	done  chan struct{}
+}
+
+// RunHooks subscribes to the bus and executes configured hooks one by
+// one in a separate goroutine. Events are queued while a hook runs, so
+// slow hooks don't lose them unless MaxEventQueue events pile up
+func RunHooks(bus *EventBus, hooks Hooks) *HookRunner {
+	r := &HookRunner{
+		hooks: hooks,
+		bus:   bus,
+		sub:   bus.SubscribeQueued("", EventIfaceUp, EventIfaceDown, EventIPAssigned, EventPeerState),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		for e := range r.sub.C {
+			r.execute(e)
+		}
+		close(r.done)
+	}()
+	return r
+}
+
+// Stop unsubscribes runner from the bus and waits for running and queued
+// hooks
+func (r *HookRunner) Stop() {
+	r.bus.Unsubscribe(r.sub)
+	<-r.done
+}
+
+func (r *HookRunner) execute(e Event) error {
+	name, command := r.hooks.command(e)
+	if command == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), HookTimeout)
+	defer cancel()
+	cmd := hookCommand(ctx, command)
+	cmd.Env = append(inheritedEnv(), hookEnv(name, e)...)
+	Log(Debug, "Executing %s hook: %s", name, command)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		Log(Error, "Hook %s failed: %s: %s", name, err, strings.TrimSpace(string(output)))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package ptp
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHooks_command(t *testing.T) {
+	hooks := Hooks{
+		InterfaceUp:      "up",
+		InterfaceDown:    "down",
+		PeerConnected:    "connected",
+		PeerDisconnected: "disconnected",
+		IPAssigned:       "ip",
+	}
+	connected := StringifyState(PeerStateConnected)
+	connecting := StringifyState(PeerStateConnecting)
+	disconnect := StringifyState(PeerStateDisconnect)
+	tests := []struct {
+		name  string
+		event Event
+		want  string
+	}{
+		{"interface up", Event{Type: EventIfaceUp}, "up"},
+		{"interface down", Event{Type: EventIfaceDown}, "down"},
+		{"ip assigned", Event{Type: EventIPAssigned}, "ip"},
+		{"peer connected", Event{Type: EventPeerState, Data: map[string]string{"from": connecting, "to": connected}}, "connected"},
+		{"peer disconnected", Event{Type: EventPeerState, Data: map[string]string{"from": connected, "to": disconnect}}, "disconnected"},
+		{"peer connecting", Event{Type: EventPeerState, Data: map[string]string{"from": disconnect, "to": connecting}}, ""},
+		{"no hook", Event{Type: EventDHT}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := hooks.command(tt.event); got != tt.want {
+				t.Errorf("Hooks.command() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Hook command uses POSIX shell")
+	}
+	out, err := ioutil.TempFile("", "p2p-hook")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %s", err)
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	// Variables of the daemon must not reach hooks
+	os.Setenv("P2P_MASTER_KEY", "secret")
+	defer os.Unsetenv("P2P_MASTER_KEY")
+
+	bus := NewEventBus()
+	r := RunHooks(bus, Hooks{IPAssigned: "echo $P2P_HOOK $P2P_HASH $P2P_IP $P2P_MASTER_KEY > " + out.Name()})
+	bus.Publish(Event{Type: EventIPAssigned, Hash: "hash", Data: map[string]string{"ip": "10.10.10.1"}})
+	r.Stop()
+
+	data, _ := ioutil.ReadFile(out.Name())
+	if strings.TrimSpace(string(data)) != "ip-assigned hash 10.10.10.1" {
+		t.Errorf("Hook produced wrong output: %s", data)
+	}
+}
@@ -0,0 +1,211 @@
+package ptp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/subutai-io/p2p/protocol"
+)
+
+// Callbacks is a set of typed callbacks executed by instance on lifecycle
+// events. Any of them can be nil. Callbacks are executed synchronously, so
+// they must not block
+type Callbacks struct {
+	PeerStateChanged    func(peerID string, from, to PeerState)            // Peer changed local state
+	InterfaceConfigured func(name string, ip net.IP, mac net.HardwareAddr) // Network interface was configured
+	Stopped             func(hash string)                                  // Instance has been stopped
+}
+
+func (c *Callbacks) peerStateChanged(peerID string, from, to PeerState) {
+	if c.PeerStateChanged != nil {
+		c.PeerStateChanged(peerID, from, to)
+	}
+}
+
+func (c *Callbacks) interfaceConfigured(tap TAP) {
+	if c.InterfaceConfigured != nil && tap != nil {
+		c.InterfaceConfigured(tap.GetName(), tap.GetIP(), tap.GetHardwareAddress())
+	}
+}
+
+func (c *Callbacks) stopped(hash string) {
+	if c.Stopped != nil {
+		c.Stopped(hash)
+	}
+}
+
+// Config is a set of parameters used to create new instance
+type Config struct {
+	Hash           string        // Infohash of the swarm
+	IP             string        // IP of p2p interface. Accepts IP, CIDR, "dhcp" or "discover"
+	Mac            string        // Hardware address of p2p interface. Generated when empty
+	Device         string        // Name of p2p interface. Generated when empty
+	Keyfile        string        // Path to a file with crypto keys
+	Key            string        // AES crypto key
+	TTL            string        // Time until crypto key will be active
+	Target         string        // SRV entry used for UDP keep alive
+	Forward        bool          // Force proxy servers usage
+	Port           int           // UDP port. Random port is used when 0
+	OutboundIP     net.IP        // Outbound IP address
+	MTU            int           // MTU of p2p interface. DefaultMTU is used when 0
+	PMTU           bool          // Whether PMTU capabilities are enabled or not
+	UnderlayMTU    int           // MTU of the network between peers. DefaultUnderlayMTU is used when 0
+	Queues         int           // Number of TAP queues. Single queue is used when 0
+	Offload        bool          // Whether TAP is opened with virtio-net header. Linux only
+	Compression    bool          // Whether frames are compressed for peers that accept it
+	Multipath      MultipathMode // How frames are spread over endpoints of a peer
+	DuplicatePorts []int         // TCP and UDP ports of flows sent over two paths in duplicate mode
+	Reserved       *IPRegistry   // Registry shared between instances. New registry is created when nil
+	Callbacks      Callbacks     // Lifecycle callbacks
+	Events         *EventBus     // Bus for lifecycle events. Events are not published when nil
+}
+
+func (c *Config) validate() error {
+	if c.Hash == "" {
+		return fmt.Errorf("empty hash")
+	}
+	if c.Mac != "" {
+		_, err := net.ParseMAC(c.Mac)
+		if err != nil {
+			return fmt.Errorf("bad mac: %s", err)
+		}
+	}
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("bad port: %d", c.Port)
+	}
+	if c.MTU < 0 {
+		return fmt.Errorf("bad mtu: %d", c.MTU)
+	}
+	if c.Multipath > MultipathDuplicate {
+		return fmt.Errorf("bad multipath mode: %d", c.Multipath)
+	}
+	if _, err := portSet(c.DuplicatePorts); err != nil {
+		return fmt.Errorf("bad duplicate ports: %v", c.DuplicatePorts)
+	}
+	return nil
+}
+
+// Instance is an embeddable p2p instance with context-aware lifecycle.
+// DHT packets are exchanged with bootstrap nodes by the caller through
+// channels returned by DHT()
+type Instance struct {
+	PTP     *PeerToPeer
+	config  Config
+	lock    sync.Mutex
+	started bool
+	stopped bool
+}
+
+// NewInstance creates new instance from provided configuration. UDP socket
+// is opened immediately, while interface and peers are handled after Start
+func NewInstance(ctx context.Context, cfg Config) (*Instance, error) {
+	err := cfg.validate()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid configuration: %s", err)
+	}
+	if cfg.MTU == 0 {
+		cfg.MTU = DefaultMTU
+	}
+	if cfg.Reserved == nil {
+		cfg.Reserved = NewIPRegistry()
+	}
+	p, err := newPeerToPeer(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		p.stopSocket()
+		return nil, ctx.Err()
+	}
+	p.Dht.IncomingData = make(chan *protocol.DHTPacket)
+	p.Dht.OutgoingData = make(chan *protocol.DHTPacket)
+	return &Instance{PTP: p, config: cfg}, nil
+}
+
+// DHT returns channels that must be connected to bootstrap nodes.
+// Packets received from bootstrap nodes should be written to in, packets
+// read from out should be sent to bootstrap nodes. Out is closed on Stop
+func (i *Instance) DHT() (in chan<- *protocol.DHTPacket, out <-chan *protocol.DHTPacket) {
+	return i.PTP.Dht.IncomingData, i.PTP.Dht.OutgoingData
+}
+
+// Start connects instance to the swarm and configures network interface.
+// Start returns when interface is configured or ctx is done
+func (i *Instance) Start(ctx context.Context) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	if i.started {
+		return fmt.Errorf("Instance already started")
+	}
+	if i.stopped {
+		return fmt.Errorf("Instance has been stopped")
+	}
+	p := i.PTP
+	p.spawn(func() { p.ReadDHT() })
+
+	p.Dht.LocalPort = p.UDPSocket.GetPort()
+	p.FindNetworkAddresses()
+	err := p.Dht.ConnectContext(ctx, p.LocalIPs, p.ProxyManager.GetList())
+	if ctx.Err() != nil {
+		i.rollback(ctx)
+		return ctx.Err()
+	}
+	if err != nil {
+		i.rollback(ctx)
+		return fmt.Errorf("Failed to connect to bootstrap nodes: %s", err)
+	}
+
+	err = p.prepareInterfaces(ctx, i.config.IP, i.config.Device)
+	if ctx.Err() != nil {
+		i.rollback(ctx)
+		return ctx.Err()
+	}
+	if err != nil {
+		i.rollback(ctx)
+		return fmt.Errorf("Failed to configure network interface: %s", err)
+	}
+
+	p.spawn(func() { p.ListenInterface() })
+	p.spawn(func() { p.Run() })
+	i.started = true
+	return nil
+}
+
+// rollback releases socket, bootstrap connection and goroutines of the
+// instance that failed to start. Such instance can't be started again.
+// Goroutines are awaited until ctx is done
+func (i *Instance) rollback(ctx context.Context) {
+	i.stopped = true
+	i.PTP.Close()
+	err := i.PTP.wait(ctx)
+	if err != nil {
+		Log(Warning, "Instance %s didn't roll back in time: %s", i.config.Hash, err)
+	}
+}
+
+// Stop shuts down the instance and waits for all of its goroutines
+// to finish or ctx to be done
+func (i *Instance) Stop(ctx context.Context) error {
+	i.lock.Lock()
+	if i.stopped {
+		i.lock.Unlock()
+		return nil
+	}
+	i.stopped = true
+	i.lock.Unlock()
+
+	i.PTP.Close()
+
+	err := i.PTP.wait(ctx)
+	if err != nil {
+		return fmt.Errorf("Instance %s didn't stop in time: %s", i.config.Hash, err)
+	}
+	return nil
+}
+
+// Config returns configuration used to create the instance
+func (i *Instance) Config() Config {
+	return i.config
+}
@@ -0,0 +1,114 @@
+package ptp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"empty config", Config{}, true},
+		{"bad mac", Config{Hash: "hash", Mac: "badmac"}, true},
+		{"bad port", Config{Hash: "hash", Port: 70000}, true},
+		{"negative port", Config{Hash: "hash", Port: -1}, true},
+		{"bad mtu", Config{Hash: "hash", MTU: -1}, true},
+		{"bad multipath", Config{Hash: "hash", Multipath: MultipathDuplicate + 1}, true},
+		{"bad duplicate port", Config{Hash: "hash", DuplicatePorts: []int{0}}, true},
+		{"passing", Config{Hash: "hash", Mac: "00:11:22:33:44:55", Port: 6882, MTU: 1400, Multipath: MultipathDuplicate, DuplicatePorts: []int{5060}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Config.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInstance(t *testing.T) {
+	inst, err := NewInstance(context.Background(), Config{})
+	if err == nil || inst != nil {
+		t.Fatalf("NewInstance() must fail on empty config")
+	}
+}
+
+func TestCallbacks(t *testing.T) {
+	c := Callbacks{}
+	c.peerStateChanged("id", PeerStateInit, PeerStateConnected)
+	c.interfaceConfigured(nil)
+	c.stopped("hash")
+
+	var from, to PeerState
+	stopped := ""
+	c = Callbacks{
+		PeerStateChanged: func(id string, f, t PeerState) {
+			from = f
+			to = t
+		},
+		Stopped: func(hash string) {
+			stopped = hash
+		},
+	}
+	c.peerStateChanged("id", PeerStateInit, PeerStateConnected)
+	c.stopped("hash")
+	if from != PeerStateInit || to != PeerStateConnected {
+		t.Errorf("PeerStateChanged received %d -> %d", from, to)
+	}
+	if stopped != "hash" {
+		t.Errorf("Stopped received %s", stopped)
+	}
+}
+
+func TestInstance_StartRollback(t *testing.T) {
+	inst, err := NewInstance(context.Background(), Config{Hash: "hash", Mac: "00:11:22:33:44:55"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, out := inst.DHT()
+	go func() {
+		for range out {
+		}
+	}()
+	if inst.Start(context.Background()) == nil {
+		t.Fatalf("Start() succeeded without bootstrap nodes")
+	}
+	done := make(chan struct{})
+	go func() {
+		inst.PTP.routines.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Goroutines are running after failed Start()")
+	}
+	if inst.Start(context.Background()) == nil {
+		t.Errorf("Instance started after rollback")
+	}
+}
+
+func TestInstance_StartCancel(t *testing.T) {
+	inst, err := NewInstance(context.Background(), Config{Hash: "hash", Mac: "00:11:22:33:44:55"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Nobody reads DHT packets, so Start blocks until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*200)
+	defer cancel()
+	started := time.Now()
+	err = inst.Start(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Start() returned %v, expected %v", err, context.DeadlineExceeded)
+	}
+	if time.Since(started) > time.Second {
+		t.Errorf("Start() ignored cancellation for %s", time.Since(started))
+	}
+	if !inst.PTP.IsShutdown() {
+		t.Errorf("Instance isn't shut down after cancelled Start()")
+	}
+}
@@ -0,0 +1,73 @@
+package ptp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// IPRegistry keeps a list of IP addresses reserved by p2p interfaces.
+// Instances that share a registry will not use each other's interfaces
+// as endpoints. All methods are safe to call on a nil registry
+type IPRegistry struct {
+	ips  []net.IP
+	lock sync.RWMutex
+}
+
+// NewIPRegistry returns an empty registry
+func NewIPRegistry() *IPRegistry {
+	return &IPRegistry{}
+}
+
+// Reserve adds IP to the registry
+func (r *IPRegistry) Reserve(ip net.IP) {
+	if r == nil || ip == nil {
+		return
+	}
+	r.lock.Lock()
+	r.ips = append(r.ips, ip)
+	r.lock.Unlock()
+}
+
+// Release removes IP from the registry
+func (r *IPRegistry) Release(ip net.IP) error {
+	if r == nil {
+		return fmt.Errorf("nil registry")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, rip := range r.ips {
+		if rip.Equal(ip) {
+			r.ips = append(r.ips[:i], r.ips[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("IP %s is not reserved", ip)
+}
+
+// Contains returns true if IP was reserved
+func (r *IPRegistry) Contains(ip net.IP) bool {
+	if r == nil {
+		return false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	for _, rip := range r.ips {
+		if rip.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns a copy of reserved IPs
+func (r *IPRegistry) Get() []net.IP {
+	if r == nil {
+		return nil
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	result := make([]net.IP, len(r.ips))
+	copy(result, r.ips)
+	return result
+}
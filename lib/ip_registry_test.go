@@ -0,0 +1,51 @@
+package ptp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIPRegistry(t *testing.T) {
+	var nilRegistry *IPRegistry
+	nilRegistry.Reserve(net.ParseIP("10.0.0.1"))
+	if nilRegistry.Contains(net.ParseIP("10.0.0.1")) {
+		t.Errorf("nil registry must not contain IPs")
+	}
+	if nilRegistry.Release(net.ParseIP("10.0.0.1")) == nil {
+		t.Errorf("nil registry release must fail")
+	}
+
+	r := NewIPRegistry()
+	r.Reserve(net.ParseIP("10.0.0.1"))
+	r.Reserve(net.ParseIP("10.0.0.2"))
+	r.Reserve(nil)
+
+	tests := []struct {
+		name string
+		ip   net.IP
+		want bool
+	}{
+		{"reserved 1", net.ParseIP("10.0.0.1"), true},
+		{"reserved 2", net.ParseIP("10.0.0.2"), true},
+		{"not reserved", net.ParseIP("10.0.0.3"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.Contains(tt.ip); got != tt.want {
+				t.Errorf("IPRegistry.Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(r.Get()) != 2 {
+		t.Errorf("IPRegistry.Get() returned %d IPs, want 2", len(r.Get()))
+	}
+	if r.Release(net.ParseIP("10.0.0.1")) != nil {
+		t.Errorf("Failed to release reserved IP")
+	}
+	if r.Release(net.ParseIP("10.0.0.1")) == nil {
+		t.Errorf("Released IP twice")
+	}
+	if r.Contains(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Released IP is still reserved")
+	}
+}
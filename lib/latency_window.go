@@ -0,0 +1,167 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Latency requests and cross-peer pings carry sequence numbers. Results of
+// recent ones are kept in a sliding window per endpoint and per proxy, so
+// loss and jitter can be calculated along with round-trip time
+
+// Latency window
+const (
+	latencyWindowSize  = 64              // Number of recent requests kept
+	latencyLossTimeout = time.Second * 2 // Request without response for this long is lost
+)
+
+// LatencyStats describes round-trip times of recent requests
+type LatencyStats struct {
+	Samples int           // Answered requests
+	Lost    int           // Requests without response
+	SRTT    time.Duration // Smoothed round-trip time
+	Min     time.Duration
+	Avg     time.Duration
+	Max     time.Duration
+	P95     time.Duration
+	Jitter  time.Duration // Smoothed mean deviation of round-trip time
+}
+
+// Loss returns share of lost requests in percents
+func (s LatencyStats) Loss() float64 {
+	if s.Samples+s.Lost == 0 {
+		return 0
+	}
+	return float64(s.Lost) * 100 / float64(s.Samples+s.Lost)
+}
+
+// latencyWindow is a sliding window of recent requests. Request with
+// sequence number N is kept in slot N % latencyWindowSize. Smoothed values
+// are kept for ranking of paths and reported as SRTT and jitter
+type latencyWindow struct {
+	lock    sync.Mutex
+	seq     uint32        // Sequence number of the last request
+	srtt    time.Duration // Smoothed the same way as in TCP (RFC 6298)
+	rttvar  time.Duration // Mean deviation of RTT, smoothed the same way
+	loss    float64       // Smoothed share of lost requests
+	samples int           // Answered requests since creation
+	records [latencyWindowSize]latencyRecord
+}
+
+type latencyRecord struct {
+	seq  uint32
+	sent time.Time
+	rtt  time.Duration // Zero until answered
+	lost bool          // Counted in smoothed loss
+}
+
+// sent registers a new request and returns its sequence number. Requests
+// that weren't answered in time are counted as lost
+func (w *latencyWindow) sent(now time.Time) uint32 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for i := range w.records {
+		r := &w.records[i]
+		if r.rtt == 0 && !r.lost && !r.sent.IsZero() && now.Sub(r.sent) > latencyLossTimeout {
+			r.lost = true
+			w.loss += (1 - w.loss) * pingLossWeight
+		}
+	}
+	w.seq++
+	w.records[w.seq%latencyWindowSize] = latencyRecord{seq: w.seq, sent: now}
+	return w.seq
+}
+
+// received accounts response to the request. Returns round-trip time or
+// false for unknown, repeated and late responses
+func (w *latencyWindow) received(seq uint32, now time.Time) (time.Duration, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	r := &w.records[seq%latencyWindowSize]
+	if r.seq != seq || r.sent.IsZero() || r.rtt != 0 || r.lost || now.Sub(r.sent) > latencyLossTimeout {
+		return 0, false
+	}
+	r.rtt = now.Sub(r.sent)
+	if r.rtt <= 0 {
+		r.rtt = 1
+	}
+	if w.samples == 0 {
+		w.srtt, w.rttvar = r.rtt, r.rtt/2
+	} else {
+		diff := w.srtt - r.rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		w.rttvar = (3*w.rttvar + diff) / 4
+		w.srtt = (7*w.srtt + r.rtt) / 8
+	}
+	w.samples++
+	w.loss -= w.loss * pingLossWeight
+	return r.rtt, true
+}
+
+// quality returns smoothed values of the window
+func (w *latencyWindow) quality() PathQuality {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return PathQuality{RTT: w.srtt, Jitter: w.rttvar, Loss: w.loss, Samples: w.samples}
+}
+
+// stats calculates statistics of the window. Requests that may still be
+// answered are not counted
+func (w *latencyWindow) stats(now time.Time) LatencyStats {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	s := LatencyStats{SRTT: w.srtt, Jitter: w.rttvar}
+	rtts := make([]time.Duration, 0, latencyWindowSize)
+	for i := uint32(1); i <= latencyWindowSize; i++ {
+		seq := w.seq - latencyWindowSize + i
+		r := w.records[seq%latencyWindowSize]
+		if r.seq != seq || r.sent.IsZero() {
+			continue
+		}
+		if r.rtt == 0 {
+			if now.Sub(r.sent) > latencyLossTimeout {
+				s.Lost++
+			}
+			continue
+		}
+		rtts = append(rtts, r.rtt)
+	}
+	s.Samples = len(rtts)
+	if s.Samples == 0 {
+		return s
+	}
+	var sum time.Duration
+	for _, rtt := range rtts {
+		sum += rtt
+	}
+	s.Avg = sum / time.Duration(s.Samples)
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	s.Min, s.Max = rtts[0], rtts[s.Samples-1]
+	s.P95 = rtts[(s.Samples*95+99)/100-1]
+	return s
+}
+
+// latencyTimestamp parses timestamp of latency request. Newer peers put a
+// sequence number after it
+func latencyTimestamp(data []byte) (time.Time, uint32, bool, error) {
+	ts := time.Time{}
+	err := ts.UnmarshalBinary(data)
+	if err == nil || len(data) <= 4 {
+		return ts, 0, false, err
+	}
+	if ts.UnmarshalBinary(data[:len(data)-4]) != nil {
+		return ts, 0, false, err
+	}
+	return ts, binary.BigEndian.Uint32(data[len(data)-4:]), true, nil
+}
+
+// appendSeq appends sequence number to latency request
+func appendSeq(payload []byte, seq uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, seq)
+	return append(payload, b...)
+}
@@ -0,0 +1,133 @@
+package ptp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyWindow(t *testing.T) {
+	w := new(latencyWindow)
+	now := time.Now()
+
+	if got := w.stats(now); got.Samples != 0 || got.Lost != 0 || got.Loss() != 0 {
+		t.Errorf("Stats of empty window: %+v", got)
+	}
+
+	rtts := []time.Duration{10, 30, 20, 40, 20}
+	for i, rtt := range rtts {
+		sent := now.Add(time.Duration(i) * time.Second)
+		seq := w.sent(sent)
+		if got, ok := w.received(seq, sent.Add(rtt*time.Millisecond)); !ok || got != rtt*time.Millisecond {
+			t.Fatalf("received() = %v, %v", got, ok)
+		}
+		if _, ok := w.received(seq, sent.Add(rtt*time.Millisecond)); ok {
+			t.Errorf("Repeated response was accounted")
+		}
+	}
+	late := w.sent(now.Add(5 * time.Second))
+	w.sent(now.Add(6 * time.Second))
+	if _, ok := w.received(late, now.Add(5*time.Second+latencyLossTimeout+time.Millisecond)); ok {
+		t.Errorf("Late response was accounted")
+	}
+	if _, ok := w.received(1000, now); ok {
+		t.Errorf("Unknown response was accounted")
+	}
+
+	got := w.stats(now.Add(8 * time.Second))
+	want := LatencyStats{
+		Samples: 5,
+		Lost:    1,
+		Min:     10 * time.Millisecond,
+		Avg:     24 * time.Millisecond,
+		Max:     40 * time.Millisecond,
+		P95:     40 * time.Millisecond,
+	}
+	want.SRTT, want.Jitter = got.SRTT, got.Jitter
+	if got != want {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+	if got.SRTT < 10*time.Millisecond || got.SRTT > 40*time.Millisecond {
+		t.Errorf("SRTT = %v", got.SRTT)
+	}
+	// Status shows the same jitter that ranks paths
+	if q := w.quality(); got.Jitter != q.Jitter || got.SRTT != q.RTT {
+		t.Errorf("stats() jitter = %v, quality() jitter = %v", got.Jitter, q.Jitter)
+	}
+	if got.Loss() < 16.6 || got.Loss() > 16.7 {
+		t.Errorf("Loss() = %v", got.Loss())
+	}
+
+	for i := 0; i < latencyWindowSize; i++ {
+		w.sent(now.Add(10 * time.Second))
+	}
+	if got := w.stats(now.Add(20 * time.Second)); got.Samples != 0 || got.Lost != latencyWindowSize {
+		t.Errorf("Old requests weren't pushed out of window: %+v", got)
+	}
+}
+
+func TestLatencyWindow_quality(t *testing.T) {
+	q := new(latencyWindow)
+	now := time.Now()
+
+	s1 := q.sent(now)
+	if _, ok := q.received(s1, now.Add(100*time.Millisecond)); !ok {
+		t.Fatalf("Reply wasn't accounted")
+	}
+	if _, ok := q.received(s1, now.Add(200*time.Millisecond)); ok {
+		t.Errorf("Repeated reply was accounted")
+	}
+	if got := q.quality(); got.RTT != 100*time.Millisecond || got.Jitter != 50*time.Millisecond || got.Samples != 1 {
+		t.Errorf("After first reply: %+v", got)
+	}
+
+	s2 := q.sent(now.Add(time.Second))
+	q.received(s2, now.Add(time.Second+20*time.Millisecond))
+	if got := q.quality(); got.RTT != 90*time.Millisecond || got.Jitter != 57500*time.Microsecond {
+		t.Errorf("After second reply: %+v", got)
+	}
+
+	lost := q.sent(now.Add(2 * time.Second))
+	q.sent(now.Add(2*time.Second + latencyLossTimeout + time.Millisecond))
+	if got := q.quality(); got.Loss != pingLossWeight {
+		t.Errorf("Loss = %v, want %v", got.Loss, pingLossWeight)
+	}
+	if _, ok := q.received(lost, now.Add(5*time.Second)); ok {
+		t.Errorf("Reply to lost ping was accounted")
+	}
+	// Window keeps results of the same pings
+	if got := q.stats(now.Add(5 * time.Second)); got.Samples != 2 || got.Lost != 1 {
+		t.Errorf("Window stats: %+v", got)
+	}
+}
+
+func TestLatencyTimestamp(t *testing.T) {
+	now := time.Now().Round(0)
+	ts, _ := now.MarshalBinary()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		seq     uint32
+		hasSeq  bool
+		wantErr bool
+	}{
+		{"old format", ts, 0, false, false},
+		{"with sequence", appendSeq(append([]byte{}, ts...), 42), 42, true, false},
+		{"garbage", []byte{1, 2, 3, 4, 5, 6}, 0, false, true},
+		{"empty", nil, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, seq, hasSeq, err := latencyTimestamp(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("latencyTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if seq != tt.seq || hasSeq != tt.hasSeq {
+				t.Errorf("latencyTimestamp() seq = %d, %v, want %d, %v", seq, hasSeq, tt.seq, tt.hasSeq)
+			}
+			if err == nil && !got.Equal(now) {
+				t.Errorf("latencyTimestamp() = %v, want %v", got, now)
+			}
+		})
+	}
+}
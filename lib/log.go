@@ -1,10 +1,11 @@
 package ptp
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
-	"fmt"
 )
 
 // LogLevel is a level of the log message
@@ -20,6 +21,7 @@ const (
 )
 
 var logPrefixes = [...]string{"[TRACE] ", "[DEBUG] ", "[INFO] ", "[WARNING] ", "[ERROR] "}
+var logLevelNames = [...]string{"trace", "debug", "info", "warning", "error"}
 var logFlags = [...]int{log.Ldate | log.Ltime,
 	log.Ldate | log.Ltime,
 	log.Ldate | log.Ltime,
@@ -42,24 +44,33 @@ func SetMinLogLevel(level LogLevel) {
 // SetMinLogLevel sets a minimal logging level. Accepts a string for setting
 func SetMinLogLevelString(level string) error {
 	level = strings.ToLower(level)
-	if level == "trace" {
-		SetMinLogLevel(Trace)
-	} else if level == "debug" {
-		SetMinLogLevel(Debug)
-	} else if level == "info" {
-		SetMinLogLevel(Info)
-	} else if level == "warning" {
-		SetMinLogLevel(Warning)
-	} else if level == "error" {
-		SetMinLogLevel(Error)
-	} else {
+	l, err := parseLogLevel(level)
+	if err != nil {
 		Log(Warning, "Unknown log level %s was provided. Supported log levels are:\ntrace\ndebug\ninfo\nwarning\nerror\n", level)
 		return fmt.Errorf("Could not set provided log level")
 	}
+	SetMinLogLevel(l)
 	Log(Info, "Logging level has switched to %s level", level)
 	return nil
 }
 
+func parseLogLevel(level string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.ToLower(level) == name {
+			return LogLevel(i), nil
+		}
+	}
+	return Info, fmt.Errorf("Unknown log level %s", level)
+}
+
+// String returns name of the log level
+func (l LogLevel) String() string {
+	if l < Trace || l > Error {
+		return strconv.Itoa(int(l))
+	}
+	return logLevelNames[l]
+}
+
 // MinLogLevel returns minimal log level
 func MinLogLevel() LogLevel { return logLevelMin }
 
@@ -68,10 +79,13 @@ func Log(level LogLevel, format string, v ...interface{}) {
 	if level < logLevelMin {
 		return
 	}
-	stdLoggers[level].Printf(format, v...)
-	if level != Trace && len(syslogSocket) != 0 {
-		go Syslog(level, format, v...)
-	}
+	rootLogger.Log(level, format, v...)
+}
+
+// LogLimited writes a log message unless the same message was logged too
+// often recently. Use it for messages caused by remote peers or traffic
+func LogLimited(level LogLevel, format string, v ...interface{}) {
+	rootLogger.Limited(level, format, v...)
 }
 
 // SetSyslogSocket sets an adders of the syslog server
@@ -0,0 +1,210 @@
+package ptp
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of log file rotation
+const (
+	DefaultLogFileMaxSize    = 100 // Megabytes
+	DefaultLogFileMaxBackups = 7
+)
+
+const logFileTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is a log file that is rotated when it grows over a size
+// limit or gets older than maximum age. Rotated files are named after
+// rotation time and may be compressed
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+	file       *os.File
+	size       int64
+	opened     time.Time
+	lock       sync.Mutex
+	background sync.WaitGroup
+	tasks      sync.Mutex // Serializes compression and removal of rotated files
+	now        func() time.Time
+}
+
+// OpenLogFile opens log file for appending, creating it and its directory
+// if needed
+func OpenLogFile(conf LogFileConf) (*RotatingFile, error) {
+	if conf.Path == "" {
+		return nil, fmt.Errorf("Log file path is empty")
+	}
+	if conf.MaxSize < 0 || conf.MaxBackups < 0 {
+		return nil, fmt.Errorf("Log file size and backups can't be negative")
+	}
+	f := &RotatingFile{
+		path:       conf.Path,
+		maxSize:    int64(conf.MaxSize) * 1024 * 1024,
+		maxBackups: conf.MaxBackups,
+		compress:   conf.Compress,
+		now:        time.Now,
+	}
+	if f.maxSize == 0 {
+		f.maxSize = DefaultLogFileMaxSize * 1024 * 1024
+	}
+	if f.maxBackups == 0 {
+		f.maxBackups = DefaultLogFileMaxBackups
+	}
+	if conf.MaxAge != "" {
+		age, err := time.ParseDuration(conf.MaxAge)
+		if err != nil || age <= 0 {
+			return nil, fmt.Errorf("Bad log file max_age %s", conf.MaxAge)
+		}
+		f.maxAge = age
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return nil, fmt.Errorf("Failed to create log directory: %s", err)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("Failed to open log file: %s", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("Failed to open log file: %s", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = f.now()
+	return nil
+}
+
+// Write appends p to the file, rotating it first when needed
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return 0, fmt.Errorf("Log file %s is closed", f.path)
+	}
+	if f.size > 0 && (f.size+int64(len(p)) > f.maxSize || f.maxAge > 0 && f.now().Sub(f.opened) >= f.maxAge) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to rotate log file: %s\n", err)
+			if f.file == nil {
+				return 0, err
+			}
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate renames current file and opens a new one. Compression and
+// removal of old files are done in background
+func (f *RotatingFile) rotate() error {
+	f.file.Close()
+	f.file = nil
+	backup := f.path + "." + f.now().Format(logFileTimeFormat)
+	err := os.Rename(f.path, backup)
+	if oerr := f.open(); oerr != nil {
+		return oerr
+	}
+	if err != nil {
+		return err
+	}
+	f.background.Add(1)
+	go func() {
+		defer f.background.Done()
+		f.tasks.Lock()
+		defer f.tasks.Unlock()
+		if f.compress {
+			if err := compressLogFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to compress log file: %s\n", err)
+			}
+		}
+		f.removeBackups()
+	}()
+	return nil
+}
+
+func compressLogFile(path string) error {
+	src, err := os.Open(path)
+	if os.IsNotExist(err) {
+		// Already removed as an old backup
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// removeBackups deletes the oldest rotated files over maxBackups
+func (f *RotatingFile) removeBackups() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	backups := f.backups()
+	for len(backups) > f.maxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// backups returns rotated files from oldest to newest
+func (f *RotatingFile) backups() []string {
+	matches, _ := filepath.Glob(f.path + ".*")
+	backups := []string{}
+	prefix := f.path + "."
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, prefix), ".gz")
+		if _, err := time.Parse(logFileTimeFormat, stamp); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// Close waits for background compression and closes the file
+func (f *RotatingFile) Close() error {
+	f.background.Wait()
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
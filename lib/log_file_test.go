@@ -0,0 +1,103 @@
+package ptp
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenLogFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-log")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		conf    LogFileConf
+		wantErr bool
+	}{
+		{"defaults", LogFileConf{Path: filepath.Join(dir, "a", "p2p.log")}, false},
+		{"max age", LogFileConf{Path: filepath.Join(dir, "b.log"), MaxAge: "24h"}, false},
+		{"empty path", LogFileConf{}, true},
+		{"bad max age", LogFileConf{Path: filepath.Join(dir, "c.log"), MaxAge: "daily"}, true},
+		{"negative size", LogFileConf{Path: filepath.Join(dir, "d.log"), MaxSize: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := OpenLogFile(tt.conf)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OpenLogFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if f != nil {
+				f.Close()
+			}
+		})
+	}
+}
+
+func TestRotatingFile_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-log")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "p2p.log")
+
+	f, err := OpenLogFile(LogFileConf{Path: path, MaxAge: "1h", MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatalf("OpenLogFile() failed: %s", err)
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+	f.opened = now
+	f.maxSize = 10
+
+	write := func(s string) {
+		if _, err := f.Write([]byte(s)); err != nil {
+			t.Fatalf("Write() failed: %s", err)
+		}
+	}
+	write("0123456789")
+	write("first\n") // Over size limit
+	now = now.Add(time.Minute)
+	write("second\n") // Over size limit
+	now = now.Add(time.Minute)
+	write("x")
+	now = now.Add(time.Hour)
+	write("third\n") // Too old
+	f.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "third\n" {
+		t.Errorf("Current log file contains %q", data)
+	}
+	backups := f.backups()
+	if len(backups) != 2 {
+		t.Fatalf("Found %d backups, want 2: %v", len(backups), backups)
+	}
+	for i, want := range []string{"first\n", "second\nx"} {
+		if !strings.HasSuffix(backups[i], ".gz") {
+			t.Errorf("Backup %s is not compressed", backups[i])
+			continue
+		}
+		file, _ := os.Open(backups[i])
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %s", backups[i], err)
+		}
+		content, _ := ioutil.ReadAll(gz)
+		file.Close()
+		if string(content) != want {
+			t.Errorf("Backup %s contains %q, want %q", backups[i], content, want)
+		}
+	}
+	if _, err := f.Write([]byte("closed")); err == nil {
+		t.Errorf("Write() to closed file didn't fail")
+	}
+}
@@ -0,0 +1,56 @@
+package ptp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// journalPriorities maps log levels to syslog priorities used by journald
+var journalPriorities = [...]int{7, 7, 6, 4, 3}
+
+// journalMessage encodes a record in journald native protocol. Fields are
+// uppercased, so `instance` field can be matched with INSTANCE=
+func journalMessage(level LogLevel, msg string, fields []interface{}) []byte {
+	b := new(bytes.Buffer)
+	writeJournalField(b, "MESSAGE", msg)
+	writeJournalField(b, "PRIORITY", fmt.Sprint(journalPriorities[level]))
+	writeJournalField(b, "SYSLOG_IDENTIFIER", "p2p")
+	for i := 0; i+1 < len(fields); i += 2 {
+		name := journalFieldName(fmt.Sprint(fields[i]))
+		if name == "" {
+			continue
+		}
+		writeJournalField(b, name, fmt.Sprint(fields[i+1]))
+	}
+	return b.Bytes()
+}
+
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	// Multiline values are written with explicit length
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName converts field name to a valid journald field: upper
+// case letters, digits and underscores, not starting with underscore
+func journalFieldName(name string) string {
+	result := []byte{}
+	for _, c := range []byte(strings.ToUpper(name)) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= '0' && c <= '9' && len(result) > 0:
+			result = append(result, c)
+		case c == '_' || c == '-' || c == '.':
+			if len(result) > 0 {
+				result = append(result, '_')
+			}
+		}
+	}
+	return string(result)
+}
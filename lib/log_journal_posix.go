@@ -0,0 +1,46 @@
+// +build !windows
+
+package ptp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+var journalSocket = "/run/systemd/journal/socket"
+
+var journal struct {
+	sync.RWMutex
+	conn *net.UnixConn
+}
+
+// SetJournal enables or disables sending log messages to systemd journal.
+// New connection is established before the old one is closed, so failed
+// reconnect keeps logging to journal
+func SetJournal(enabled bool) error {
+	var conn *net.UnixConn
+	if enabled {
+		var err error
+		conn, err = net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+		if err != nil {
+			return fmt.Errorf("Failed to connect to journald: %s", err)
+		}
+	}
+	journal.Lock()
+	defer journal.Unlock()
+	if journal.conn != nil {
+		journal.conn.Close()
+	}
+	journal.conn = conn
+	return nil
+}
+
+func writeJournal(level LogLevel, msg string, fields []interface{}) {
+	journal.RLock()
+	defer journal.RUnlock()
+	if journal.conn == nil {
+		return
+	}
+	journal.conn.Write(journalMessage(level, msg, fields))
+}
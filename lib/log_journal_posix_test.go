@@ -0,0 +1,53 @@
+// +build !windows
+
+package ptp
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-journal")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "socket")
+	l, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %s", socket, err)
+	}
+	defer l.Close()
+
+	defer func(path string) {
+		journalSocket = path
+		SetJournal(false)
+	}(journalSocket)
+	journalSocket = socket
+	if err := SetJournal(true); err != nil {
+		t.Fatalf("SetJournal() failed: %s", err)
+	}
+	conn := journal.conn
+
+	journalSocket = filepath.Join(dir, "missing")
+	if err := SetJournal(true); err == nil {
+		t.Fatalf("SetJournal() with missing socket didn't fail")
+	}
+	if journal.conn != conn {
+		t.Fatalf("SetJournal() dropped connection after failed reconnect")
+	}
+	writeJournal(Info, "still connected", nil)
+	buf := make([]byte, 1024)
+	n, err := l.Read(buf)
+	if err != nil || n == 0 {
+		t.Errorf("Journal didn't receive message: %v", err)
+	}
+
+	if err := SetJournal(false); err != nil || journal.conn != nil {
+		t.Errorf("SetJournal(false) = %v, connection %v", err, journal.conn)
+	}
+}
@@ -0,0 +1,19 @@
+package ptp
+
+import (
+	"testing"
+)
+
+func TestJournalMessage(t *testing.T) {
+	got := string(journalMessage(Warning, "Peer lost", []interface{}{FieldInstance, "swarm-1", "_private", 1, "peer.id", "abc", "---", "skipped"}))
+	want := "MESSAGE=Peer lost\nPRIORITY=4\nSYSLOG_IDENTIFIER=p2p\nINSTANCE=swarm-1\nPRIVATE=1\nPEER_ID=abc\n"
+	if got != want {
+		t.Errorf("journalMessage() = %q, want %q", got, want)
+	}
+
+	got = string(journalMessage(Error, "two\nlines", nil))
+	want = "MESSAGE\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\nPRIORITY=3\nSYSLOG_IDENTIFIER=p2p\n"
+	if got != want {
+		t.Errorf("journalMessage() of multiline message = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,16 @@
+// +build windows
+
+package ptp
+
+import "fmt"
+
+// SetJournal enables or disables sending log messages to systemd journal
+func SetJournal(enabled bool) error {
+	if enabled {
+		return fmt.Errorf("journald is not supported on this platform")
+	}
+	return nil
+}
+
+func writeJournal(level LogLevel, msg string, fields []interface{}) {
+}
@@ -0,0 +1,347 @@
+package ptp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Common fields of log messages
+const (
+	FieldInstance  = "instance"
+	FieldPeer      = "peer"
+	FieldEndpoint  = "endpoint"
+	FieldSubsystem = "subsystem"
+)
+
+// Subsystems used as values of FieldSubsystem
+const (
+	SubsystemDHT    = "dht"
+	SubsystemPeer   = "peer"
+	SubsystemPacket = "packet"
+)
+
+// Formats of log output
+const (
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+var logFormat = LogFormatText
+var structuredLogger = log.New(os.Stdout, "", 0)
+
+var subsystemLevels = struct {
+	sync.RWMutex
+	levels map[string]LogLevel
+}{levels: make(map[string]LogLevel)}
+
+// Logger writes log messages with attached fields. Nil Logger writes
+// messages without fields
+type Logger struct {
+	fields    []interface{} // Key-value pairs
+	subsystem string
+}
+
+var rootLogger *Logger
+
+// With returns a logger that attaches provided key-value pairs to every
+// message. Value of FieldSubsystem also selects subsystem log level
+func With(kv ...interface{}) *Logger {
+	return rootLogger.With(kv...)
+}
+
+// With returns a copy of the logger with additional key-value pairs
+func (l *Logger) With(kv ...interface{}) *Logger {
+	n := new(Logger)
+	if l != nil {
+		n.fields = make([]interface{}, 0, len(l.fields)+len(kv))
+		n.fields = append(n.fields, l.fields...)
+		n.subsystem = l.subsystem
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i] == FieldSubsystem {
+			n.subsystem = fmt.Sprint(kv[i+1])
+		}
+		n.fields = append(n.fields, kv[i], kv[i+1])
+	}
+	return n
+}
+
+// Log writes a log message
+func (l *Logger) Log(level LogLevel, format string, v ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	l.write(level, fmt.Sprintf(format, v...), nil)
+}
+
+// Limited writes a log message unless the same message was logged by
+// this subsystem too often recently. Number of suppressed messages is
+// reported with the next message that passes
+func (l *Logger) Limited(level LogLevel, format string, v ...interface{}) {
+	if !l.enabled(level) {
+		return
+	}
+	subsystem := ""
+	if l != nil {
+		subsystem = l.subsystem
+	}
+	allowed, suppressed := logLimiter.allow(subsystem+"|"+format, time.Now())
+	if !allowed {
+		return
+	}
+	var extra []interface{}
+	if suppressed > 0 {
+		extra = []interface{}{"suppressed", suppressed}
+	}
+	l.write(level, fmt.Sprintf(format, v...), extra)
+}
+
+func (l *Logger) enabled(level LogLevel) bool {
+	if l != nil && l.subsystem != "" && haveSubsystemLevels() {
+		subsystemLevels.RLock()
+		min, ok := subsystemLevels.levels[l.subsystem]
+		subsystemLevels.RUnlock()
+		if ok {
+			return level >= min
+		}
+	}
+	return level >= logLevelMin
+}
+
+func (l *Logger) write(level LogLevel, msg string, extra []interface{}) {
+	if level < Trace || level > Error {
+		return
+	}
+	var fields []interface{}
+	if l != nil {
+		fields = l.fields
+	}
+	if len(extra) > 0 {
+		fields = append(append([]interface{}{}, fields...), extra...)
+	}
+	switch logFormat {
+	case LogFormatJSON:
+		structuredLogger.Print(formatJSON(time.Now(), level, msg, fields))
+	case LogFormatLogfmt:
+		structuredLogger.Print(formatLogfmt(time.Now(), level, msg, fields))
+	default:
+		stdLoggers[level].Print(msg + formatFields(fields))
+	}
+	writeJournal(level, msg, fields)
+	if level != Trace && len(syslogSocket) != 0 {
+		go Syslog(level, "%s", msg+formatFields(fields))
+	}
+}
+
+// SetLogOutput sets writer for log messages. Stdout is used by default
+func SetLogOutput(w io.Writer) {
+	for _, l := range stdLoggers {
+		l.SetOutput(w)
+	}
+	structuredLogger.SetOutput(w)
+}
+
+// SetLogFormat sets format of log output: text, json or logfmt
+func SetLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", LogFormatText:
+		logFormat = LogFormatText
+	case LogFormatJSON:
+		logFormat = LogFormatJSON
+	case LogFormatLogfmt:
+		logFormat = LogFormatLogfmt
+	default:
+		return fmt.Errorf("Unknown log format %s. Supported formats are: text, json, logfmt", format)
+	}
+	return nil
+}
+
+// LogFormat returns current format of log output
+func LogFormat() string { return logFormat }
+
+// SetLogLevels changes log levels according to spec. Spec is a comma
+// separated list of levels. Level without a name sets minimal level,
+// `name=level` overrides level of a subsystem and `name=default`
+// removes the override. Example: `info,dht=trace,peer=debug`
+func SetLogLevels(spec string) error {
+	type change struct {
+		subsystem string
+		level     LogLevel
+		reset     bool
+	}
+	changes := []change{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(strings.ToLower(item))
+		if item == "" {
+			continue
+		}
+		c := change{}
+		level := item
+		if i := strings.Index(item, "="); i >= 0 {
+			c.subsystem = strings.TrimSpace(item[:i])
+			level = strings.TrimSpace(item[i+1:])
+			if c.subsystem == "" {
+				return fmt.Errorf("Empty subsystem name in %s", item)
+			}
+		}
+		if c.subsystem != "" && level == "default" {
+			c.reset = true
+		} else {
+			l, err := parseLogLevel(level)
+			if err != nil {
+				return err
+			}
+			c.level = l
+		}
+		changes = append(changes, c)
+	}
+	if len(changes) == 0 {
+		return fmt.Errorf("Empty log level")
+	}
+	subsystemLevels.Lock()
+	for _, c := range changes {
+		switch {
+		case c.subsystem == "":
+			SetMinLogLevel(c.level)
+		case c.reset:
+			delete(subsystemLevels.levels, c.subsystem)
+		default:
+			subsystemLevels.levels[c.subsystem] = c.level
+		}
+	}
+	subsystemLevels.Unlock()
+	Log(Info, "Log levels changed to %s", LogLevels())
+	return nil
+}
+
+// ClearSubsystemLogLevels removes all subsystem overrides
+func ClearSubsystemLogLevels() {
+	subsystemLevels.Lock()
+	subsystemLevels.levels = make(map[string]LogLevel)
+	subsystemLevels.Unlock()
+}
+
+// LogLevels returns current levels in a format accepted by SetLogLevels
+func LogLevels() string {
+	subsystemLevels.RLock()
+	defer subsystemLevels.RUnlock()
+	names := make([]string, 0, len(subsystemLevels.levels))
+	for name := range subsystemLevels.levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := logLevelMin.String()
+	for _, name := range names {
+		result += "," + name + "=" + subsystemLevels.levels[name].String()
+	}
+	return result
+}
+
+func haveSubsystemLevels() bool {
+	subsystemLevels.RLock()
+	defer subsystemLevels.RUnlock()
+	return len(subsystemLevels.levels) > 0
+}
+
+// formatFields renders fields as ` key=value` pairs appended to text messages
+func formatFields(fields []interface{}) string {
+	result := ""
+	for i := 0; i+1 < len(fields); i += 2 {
+		result += " " + fmt.Sprint(fields[i]) + "=" + logfmtValue(fields[i+1])
+	}
+	return result
+}
+
+func formatLogfmt(t time.Time, level LogLevel, msg string, fields []interface{}) string {
+	return "time=" + t.Format(time.RFC3339Nano) + " level=" + level.String() + " msg=" + logfmtValue(msg) + formatFields(fields)
+}
+
+func logfmtValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func formatJSON(t time.Time, level LogLevel, msg string, fields []interface{}) string {
+	b := strings.Builder{}
+	b.WriteString(`{"time":`)
+	b.WriteString(jsonValue(t.Format(time.RFC3339Nano)))
+	b.WriteString(`,"level":`)
+	b.WriteString(jsonValue(level.String()))
+	b.WriteString(`,"msg":`)
+	b.WriteString(jsonValue(msg))
+	for i := 0; i+1 < len(fields); i += 2 {
+		b.WriteString(",")
+		b.WriteString(jsonValue(fmt.Sprint(fields[i])))
+		b.WriteString(":")
+		b.WriteString(jsonValue(fields[i+1]))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func jsonValue(v interface{}) string {
+	switch v.(type) {
+	case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+	default:
+		v = fmt.Sprint(v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	return string(data)
+}
+
+// rateLimiter allows up to burst messages with the same key per interval
+type rateLimiter struct {
+	lock     sync.Mutex
+	burst    int
+	interval time.Duration
+	entries  map[string]*rateEntry
+}
+
+type rateEntry struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+var logLimiter = newRateLimiter(5, time.Minute)
+
+func newRateLimiter(burst int, interval time.Duration) *rateLimiter {
+	return &rateLimiter{burst: burst, interval: interval, entries: make(map[string]*rateEntry)}
+}
+
+// allow reports whether message with the key may be written and how many
+// messages were suppressed since the last written one
+func (r *rateLimiter) allow(key string, now time.Time) (bool, int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	e, ok := r.entries[key]
+	if !ok || now.Sub(e.start) >= r.interval {
+		suppressed := 0
+		if ok {
+			suppressed = e.suppressed
+		}
+		r.entries[key] = &rateEntry{start: now, count: 1}
+		return true, suppressed
+	}
+	if e.count >= r.burst {
+		e.suppressed++
+		return false, 0
+	}
+	e.count++
+	return true, 0
+}
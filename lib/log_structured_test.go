@@ -0,0 +1,130 @@
+package ptp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSetLogLevels(t *testing.T) {
+	defer func() {
+		ClearSubsystemLogLevels()
+		SetMinLogLevel(Info)
+	}()
+	tests := []struct {
+		name    string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{"minimal level", "debug", "debug", false},
+		{"subsystem", "DHT=trace", "debug,dht=trace", false},
+		{"mixed", "warning, peer=error", "warning,dht=trace,peer=error", false},
+		{"reset subsystem", "dht=default", "warning,peer=error", false},
+		{"unknown level", "info,dht=loud", "warning,peer=error", true},
+		{"empty subsystem", "=debug", "warning,peer=error", true},
+		{"empty", " , ", "warning,peer=error", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetLogLevels(tt.spec); (err != nil) != tt.wantErr {
+				t.Errorf("SetLogLevels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := LogLevels(); got != tt.want {
+				t.Errorf("LogLevels() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogger_enabled(t *testing.T) {
+	defer func() {
+		ClearSubsystemLogLevels()
+		SetMinLogLevel(Info)
+	}()
+	SetLogLevels("info,dht=trace,peer=error")
+
+	tests := []struct {
+		name   string
+		logger *Logger
+		level  LogLevel
+		want   bool
+	}{
+		{"nil logger", nil, Info, true},
+		{"nil logger below minimum", nil, Debug, false},
+		{"fields only", With(FieldInstance, "hash"), Debug, false},
+		{"subsystem below minimum", With(FieldSubsystem, SubsystemDHT), Trace, true},
+		{"subsystem above minimum", With(FieldSubsystem, SubsystemPeer), Warning, false},
+		{"inherited subsystem", With(FieldSubsystem, SubsystemDHT).With(FieldPeer, "id"), Debug, true},
+		{"subsystem without override", With(FieldSubsystem, SubsystemPacket), Debug, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.logger.enabled(tt.level); got != tt.want {
+				t.Errorf("Logger.enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogFormats(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	fields := []interface{}{FieldInstance, "swarm-1", FieldPeer, "peer id", "count", 3}
+
+	if got, want := formatLogfmt(now, Warning, "Peer \"x\" lost", fields),
+		`time=2020-01-02T03:04:05Z level=warning msg="Peer \"x\" lost" instance=swarm-1 peer="peer id" count=3`; got != want {
+		t.Errorf("formatLogfmt() = %s, want %s", got, want)
+	}
+	if got, want := formatFields(nil), ""; got != want {
+		t.Errorf("formatFields() = %q, want %q", got, want)
+	}
+
+	line := formatJSON(now, Error, "failed", append(fields, FieldEndpoint, time.Second))
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+		t.Fatalf("formatJSON() produced invalid JSON %s: %s", line, err)
+	}
+	want := map[string]interface{}{
+		"time": "2020-01-02T03:04:05Z", "level": "error", "msg": "failed",
+		"instance": "swarm-1", "peer": "peer id", "count": float64(3), "endpoint": "1s",
+	}
+	for k, v := range want {
+		if decoded[k] != v {
+			t.Errorf("formatJSON() %s = %v, want %v", k, decoded[k], v)
+		}
+	}
+
+	for _, f := range []string{"json", "LOGFMT", "text", ""} {
+		if err := SetLogFormat(f); err != nil {
+			t.Errorf("SetLogFormat(%s) failed: %s", f, err)
+		}
+	}
+	if err := SetLogFormat("xml"); err == nil || LogFormat() != LogFormatText {
+		t.Errorf("SetLogFormat() accepted unknown format")
+	}
+}
+
+func TestRateLimiter_allow(t *testing.T) {
+	r := newRateLimiter(2, time.Minute)
+	start := time.Unix(1000, 0)
+	steps := []struct {
+		key            string
+		at             time.Duration
+		wantAllowed    bool
+		wantSuppressed int
+	}{
+		{"a", 0, true, 0},
+		{"a", time.Second, true, 0},
+		{"a", 2 * time.Second, false, 0},
+		{"b", 2 * time.Second, true, 0},
+		{"a", 3 * time.Second, false, 0},
+		{"a", time.Minute, true, 2},
+		{"a", time.Minute + time.Second, true, 0},
+	}
+	for i, s := range steps {
+		allowed, suppressed := r.allow(s.key, start.Add(s.at))
+		if allowed != s.wantAllowed || suppressed != s.wantSuppressed {
+			t.Errorf("step %d: allow() = %v, %d, want %v, %d", i, allowed, suppressed, s.wantAllowed, s.wantSuppressed)
+		}
+	}
+}
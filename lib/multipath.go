@@ -0,0 +1,424 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MultipathMode selects how frames are spread over endpoints of a peer
+type MultipathMode uint32
+
+// Multipath modes
+const (
+	MultipathOff        MultipathMode = iota // Single endpoint selected by routing
+	MultipathHash                            // Flows are spread over endpoints by hash
+	MultipathRoundRobin                      // Frames are spread over endpoints one by one
+	MultipathDuplicate                       // Frames are sent over two fastest endpoints
+)
+
+// Multipath limits
+const (
+	multipathMaxPaths      = 8
+	multipathMaxWeight     = 1000
+	multipathLatencyFactor = 4    // Endpoints slower than the fastest one by this factor are not used
+	multipathStride        = 8009 // Prime larger than the largest total weight, multipathMaxPaths * multipathMaxWeight
+	duplicateWindow        = 64   // Number of recent sequence numbers remembered
+	duplicateRestart       = 1024 // Sequence numbers that far behind mean that sender was restarted
+	duplicateMaxSenders    = 4096
+	dscpEF                 = 46 // Expedited Forwarding (RFC 3246)
+)
+
+// Daemon-wide multipath settings. Used only by instances created with New()
+var (
+	multipathMode  uint32
+	duplicatePorts atomic.Value // []int
+)
+
+// SetMultipath changes daemon-wide multipath mode
+func SetMultipath(name string) error {
+	mode, err := ParseMultipathMode(name)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&multipathMode, uint32(mode))
+	return nil
+}
+
+// GetMultipath returns daemon-wide multipath mode
+func GetMultipath() MultipathMode {
+	return MultipathMode(atomic.LoadUint32(&multipathMode))
+}
+
+// SetDuplicatePorts changes daemon-wide TCP and UDP ports of flows sent
+// over two paths in duplicate mode
+func SetDuplicatePorts(ports []int) error {
+	if _, err := portSet(ports); err != nil {
+		return err
+	}
+	duplicatePorts.Store(append([]int(nil), ports...))
+	return nil
+}
+
+// GetDuplicatePorts returns daemon-wide ports of flows sent over two paths
+// in duplicate mode
+func GetDuplicatePorts() []int {
+	ports, _ := duplicatePorts.Load().([]int)
+	return ports
+}
+
+func portSet(ports []int) (map[uint16]bool, error) {
+	set := make(map[uint16]bool, len(ports))
+	for _, port := range ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("Bad duplicate port: %d", port)
+		}
+		set[uint16(port)] = true
+	}
+	return set, nil
+}
+
+// SetMultipath changes multipath mode of the instance. Paths of peers are
+// dropped when multipath is turned off
+func (p *PeerToPeer) SetMultipath(mode MultipathMode) {
+	atomic.StoreUint32(&p.multipath, uint32(mode))
+	if mode != MultipathOff || p.Swarm == nil {
+		return
+	}
+	for _, peer := range p.Swarm.Get() {
+		peer.paths.Store((*pathSet)(nil))
+	}
+}
+
+// Multipath returns multipath mode of the instance
+func (p *PeerToPeer) Multipath() MultipathMode {
+	return MultipathMode(atomic.LoadUint32(&p.multipath))
+}
+
+// SetDuplicatePorts changes TCP and UDP ports of flows sent over two paths
+// by the instance in duplicate mode
+func (p *PeerToPeer) SetDuplicatePorts(ports []int) error {
+	set, err := portSet(ports)
+	if err != nil {
+		return err
+	}
+	p.duplicatePorts.Store(set)
+	return nil
+}
+
+// latencyCritical returns true for IPv4 frames duplicated in duplicate
+// mode: frames marked with DSCP EF and TCP or UDP frames from or to one
+// of duplicate ports
+func (p *PeerToPeer) latencyCritical(frame []byte) bool {
+	if len(frame) < 34 || PacketType(binary.BigEndian.Uint16(frame[12:14])) != PacketIPv4 {
+		return false
+	}
+	ip := frame[14:]
+	if ip[1]>>2 == dscpEF {
+		return true
+	}
+	ports, _ := p.duplicatePorts.Load().(map[uint16]bool)
+	if len(ports) == 0 {
+		return false
+	}
+	ihl := int(ip[0]&0x0f) * 4
+	proto := ip[9]
+	if (proto != 6 && proto != 17) || len(ip) < ihl+4 || binary.BigEndian.Uint16(ip[6:8])&0x1fff != 0 {
+		return false
+	}
+	return ports[binary.BigEndian.Uint16(ip[ihl:ihl+2])] || ports[binary.BigEndian.Uint16(ip[ihl+2:ihl+4])]
+}
+
+// ParseMultipathMode parses mode name. Empty name means off
+func ParseMultipathMode(name string) (MultipathMode, error) {
+	switch name {
+	case "", "off":
+		return MultipathOff, nil
+	case "hash":
+		return MultipathHash, nil
+	case "roundrobin":
+		return MultipathRoundRobin, nil
+	case "duplicate":
+		return MultipathDuplicate, nil
+	}
+	return MultipathOff, fmt.Errorf("Unknown multipath mode: %s", name)
+}
+
+func (m MultipathMode) String() string {
+	switch m {
+	case MultipathHash:
+		return "hash"
+	case MultipathRoundRobin:
+		return "roundrobin"
+	case MultipathDuplicate:
+		return "duplicate"
+	}
+	return "off"
+}
+
+// pathSet lists endpoints of a peer used in multipath mode, fastest first.
+// Weights are inversely proportional to latency
+type pathSet struct {
+	endpoints []*net.UDPAddr
+	weights   []uint32
+	keys      []uint32 // Hashes of endpoint addresses
+	total     uint32
+	next      uint32 // Round-robin counter
+}
+
+// rendezvous selects endpoint for a flow with weighted rendezvous hashing.
+// When a weight changes, only a part of flows moves to another endpoint
+func (s *pathSet) rendezvous(flow uint32) int {
+	best, bestScore := 0, 0.0
+	for i, key := range s.keys {
+		h := (flow ^ key) * 2654435761
+		h ^= h >> 16
+		u := (float64(h) + 0.5) / (1 << 32)
+		score := float64(s.weights[i]) / -math.Log(u)
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// roundRobin selects endpoints in turn according to their weights. Stride
+// interleaves endpoints instead of sending bursts to each of them. It's
+// coprime with the total weight, so every position is visited
+func (s *pathSet) roundRobin() int {
+	pos := (atomic.AddUint32(&s.next, 1) % s.total) * multipathStride % s.total
+	for i, w := range s.weights {
+		if pos < w {
+			return i
+		}
+		pos -= w
+	}
+	return 0
+}
+
+// updatePaths selects endpoints used in multipath mode. Endpoints must be
+// direct, alive and have measured latency comparable to the fastest one
+func (np *NetworkPeer) updatePaths(mode MultipathMode) {
+	if mode == MultipathOff {
+		np.paths.Store((*pathSet)(nil))
+		return
+	}
+	atomic.CompareAndSwapUint32(&np.duplicateSeq, 0, rand.Uint32())
+	candidates := []*Endpoint{}
+	np.Lock.RLock()
+	for _, ep := range np.EndpointsHeap {
+		if ep == nil || ep.Addr == nil || ep.broken || ep.Latency <= 0 || !ep.alive(time.Now()) {
+			continue
+		}
+		if np.pathType(ep.Addr) == PathProxy {
+			continue
+		}
+		candidates = append(candidates, ep)
+	}
+	np.Lock.RUnlock()
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Latency < candidates[j].Latency
+	})
+
+	set := &pathSet{}
+	for _, ep := range candidates {
+		if ep.Latency > candidates[0].Latency*multipathLatencyFactor || len(set.endpoints) == multipathMaxPaths {
+			break
+		}
+		weight := uint32(multipathMaxWeight)
+		if ep.Latency > time.Second/multipathMaxWeight {
+			weight = uint32(time.Second / ep.Latency)
+		}
+		if weight == 0 {
+			// Endpoints slower than a second still get their share
+			weight = 1
+		}
+		set.endpoints = append(set.endpoints, ep.Addr)
+		set.weights = append(set.weights, weight)
+		set.keys = append(set.keys, hashKey([]byte(ep.Addr.String())))
+		set.total += weight
+	}
+	if len(set.endpoints) < 2 {
+		set = nil
+	}
+	np.paths.Store(set)
+}
+
+// sendPaths returns endpoints a message of flow is sent to. Active
+// endpoint is used, unless multipath mode is on and connected peer has
+// several healthy endpoints. In duplicate mode only latency critical
+// messages are sent over two paths
+func (np *NetworkPeer) sendPaths(mode MultipathMode, flow uint32, critical bool) []*net.UDPAddr {
+	set, _ := np.paths.Load().(*pathSet)
+	if set == nil || mode == MultipathOff || np.State != PeerStateConnected {
+		if np.Endpoint == nil {
+			return nil
+		}
+		return []*net.UDPAddr{np.Endpoint}
+	}
+	switch mode {
+	case MultipathHash:
+		i := set.rendezvous(flow)
+		return set.endpoints[i : i+1]
+	case MultipathRoundRobin:
+		i := set.roundRobin()
+		return set.endpoints[i : i+1]
+	case MultipathDuplicate:
+		if critical && np.Caps()&CapDedup != 0 {
+			return set.endpoints[:2]
+		}
+	}
+	return set.endpoints[:1]
+}
+
+// Paths returns endpoints currently used in multipath mode
+func (np *NetworkPeer) Paths() []string {
+	set, _ := np.paths.Load().(*pathSet)
+	if set == nil {
+		return nil
+	}
+	paths := []string{}
+	for _, addr := range set.endpoints {
+		paths = append(paths, addr.String())
+	}
+	return paths
+}
+
+// duplicateMessage wraps message sent over two paths. Receiving peer
+// handles the copy that comes first. Payload has the following format:
+// mac[6] seq[4] message[?]
+// mac is hardware address of the sender and seq is a sequence number of
+// messages sent to this peer. Receiving peer accepts the message only from
+// a known endpoint of the peer with this hardware address
+func (p *PeerToPeer) duplicateMessage(peer *NetworkPeer, msg *P2PMessage) (*P2PMessage, error) {
+	if p.Interface == nil {
+		return nil, fmt.Errorf("nil interface")
+	}
+	mac := p.Interface.GetHardwareAddress()
+	if len(mac) != 6 {
+		return nil, fmt.Errorf("bad hardware address")
+	}
+	payload := make([]byte, 10, 10+HeaderSize+len(msg.Data))
+	copy(payload[0:6], mac)
+	binary.BigEndian.PutUint32(payload[6:10], atomic.AddUint32(&peer.duplicateSeq, 1))
+	payload = append(payload, msg.Serialize()...)
+	// Wrapped message is encrypted already, if needed
+	return p.CreateMessage(MsgTypeDuplicate, payload, msg.Header.NetProto, false)
+}
+
+// duplicateFilter remembers recent sequence numbers of every peer
+type duplicateFilter struct {
+	dropped uint64 // Must be first to keep 64-bit alignment
+	lock    sync.Mutex
+	senders map[string]*seqWindow // Keyed by peer ID
+}
+
+func newDuplicateFilter() *duplicateFilter {
+	return &duplicateFilter{senders: make(map[string]*seqWindow)}
+}
+
+// check returns true when sequence number of the peer is seen first time
+func (f *duplicateFilter) check(id string, seq uint32) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	w, exists := f.senders[id]
+	if !exists {
+		if len(f.senders) >= duplicateMaxSenders {
+			f.senders = make(map[string]*seqWindow)
+		}
+		w = &seqWindow{top: seq - 1}
+		f.senders[id] = w
+	}
+	if w.check(seq) {
+		return true
+	}
+	atomic.AddUint64(&f.dropped, 1)
+	return false
+}
+
+// Dropped returns number of dropped duplicates
+func (f *duplicateFilter) Dropped() uint64 {
+	if f == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&f.dropped)
+}
+
+// seqWindow is a sliding window of recent sequence numbers. Bit N of seen
+// is set when top-N was received
+type seqWindow struct {
+	top  uint32
+	seen uint64
+}
+
+func (w *seqWindow) check(seq uint32) bool {
+	diff := int32(seq - w.top)
+	switch {
+	case diff > 0:
+		if diff >= duplicateWindow {
+			w.seen = 0
+		} else {
+			w.seen <<= uint(diff)
+		}
+		w.seen |= 1
+		w.top = seq
+		return true
+	case diff <= -duplicateRestart:
+		w.top, w.seen = seq, 1
+		return true
+	case diff <= -duplicateWindow:
+		return false
+	}
+	bit := uint64(1) << uint(-diff)
+	if w.seen&bit != 0 {
+		return false
+	}
+	w.seen |= bit
+	return true
+}
+
+// HandleDuplicateMessage handles the first copy of a message sent over
+// several paths. Other copies are dropped. Hardware address in the message
+// isn't trusted until the message comes from an endpoint of its peer, so
+// nobody else can move sequence window of the peer
+func (p *PeerToPeer) HandleDuplicateMessage(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+	if msg == nil {
+		return fmt.Errorf("nil message")
+	}
+	if srcAddr == nil {
+		return fmt.Errorf("nil source addr")
+	}
+	if p.duplicates == nil {
+		return fmt.Errorf("nil duplicate filter")
+	}
+	if p.Swarm == nil {
+		return fmt.Errorf("nil peer list")
+	}
+	if len(msg.Data) < 10+HeaderSize {
+		return fmt.Errorf("payload is too small")
+	}
+	peer := p.Swarm.GetPeerByMac(net.HardwareAddr(msg.Data[0:6]).String())
+	if peer == nil || peer.getEndpoint(srcAddr) == nil {
+		return fmt.Errorf("Duplicate from unknown endpoint %s", srcAddr)
+	}
+	if !p.duplicates.check(peer.ID, binary.BigEndian.Uint32(msg.Data[6:10])) {
+		return nil
+	}
+	data := msg.Data[10:]
+	if binary.BigEndian.Uint16(data[2:4]) == MsgTypeDuplicate {
+		return fmt.Errorf("Duplicated duplicate from %s", srcAddr)
+	}
+	return p.HandleP2PMessage(len(data), srcAddr, nil, data)
+}
+
+// DuplicatesDropped returns number of dropped copies of messages sent over
+// several paths
+func (p *PeerToPeer) DuplicatesDropped() uint64 {
+	return p.duplicates.Dropped()
+}
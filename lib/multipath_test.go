@@ -0,0 +1,277 @@
+package ptp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseMultipathMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    MultipathMode
+		wantErr bool
+	}{
+		{"", MultipathOff, false},
+		{"off", MultipathOff, false},
+		{"hash", MultipathHash, false},
+		{"roundrobin", MultipathRoundRobin, false},
+		{"duplicate", MultipathDuplicate, false},
+		{"random", MultipathOff, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMultipathMode(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseMultipathMode() = %v, %v, want %v", got, err, tt.want)
+			}
+			if err == nil && tt.name != "" && got.String() != tt.name {
+				t.Errorf("String() = %s", got)
+			}
+		})
+	}
+}
+
+func TestLatencyCritical(t *testing.T) {
+	p := new(PeerToPeer)
+	if err := p.SetDuplicatePorts([]int{5060, 3478}); err != nil {
+		t.Fatal(err)
+	}
+	a, b := [4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}
+	ef := ipv4Frame(17, a, b, 40000, 443)
+	ef[15] = dscpEF << 2
+	af41 := ipv4Frame(17, a, b, 40000, 443)
+	af41[15] = 34 << 2
+	fragment := ipv4Frame(17, a, b, 40000, 5060)
+	fragment[21] = 1
+
+	tests := []struct {
+		name  string
+		frame []byte
+		want  bool
+	}{
+		{"expedited forwarding", ef, true},
+		{"other DSCP", af41, false},
+		{"UDP to duplicate port", ipv4Frame(17, a, b, 40000, 5060), true},
+		{"TCP from duplicate port", ipv4Frame(6, b, a, 3478, 40000), true},
+		{"other port", ipv4Frame(6, a, b, 40000, 443), false},
+		{"ICMP", ipv4Frame(1, a, b, 5060, 5060), false},
+		{"fragment", fragment, false},
+		{"short", ef[:20], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.latencyCritical(tt.frame); got != tt.want {
+				t.Errorf("latencyCritical() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if err := p.SetDuplicatePorts([]int{70000}); err == nil {
+		t.Errorf("Bad port was accepted")
+	}
+	if err := SetDuplicatePorts([]int{0}); err == nil {
+		t.Errorf("Bad daemon-wide port was accepted")
+	}
+}
+
+func TestSeqWindow(t *testing.T) {
+	w := &seqWindow{top: 99}
+	steps := []struct {
+		seq  uint32
+		want bool
+	}{
+		{100, true},
+		{100, false},
+		{102, true},
+		{101, true},
+		{101, false},
+		{200, true},
+		{150, true},
+		{136, false}, // Older than window
+		{150, false},
+		{4000, true},
+		{5, true}, // Sender restarted
+		{6, true},
+		{5, false},
+	}
+	for i, s := range steps {
+		if got := w.check(s.seq); got != s.want {
+			t.Errorf("Step %d: check(%d) = %v, want %v", i, s.seq, got, s.want)
+		}
+	}
+}
+
+func multipathPeer(latencies ...time.Duration) *NetworkPeer {
+	np := &NetworkPeer{State: PeerStateConnected, Endpoint: &net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 1}}
+	for i, latency := range latencies {
+		np.EndpointsHeap = append(np.EndpointsHeap, &Endpoint{
+			Addr:        &net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: i + 1},
+			Latency:     latency,
+			LastContact: time.Now(),
+		})
+	}
+	return np
+}
+
+func TestNetworkPeer_updatePaths(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      MultipathMode
+		latencies []time.Duration
+		want      []uint32
+	}{
+		{"off", MultipathOff, []time.Duration{time.Millisecond, time.Millisecond}, nil},
+		{"two paths", MultipathHash, []time.Duration{20 * time.Millisecond, 10 * time.Millisecond}, []uint32{100, 50}},
+		{"slow path", MultipathHash, []time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 20 * time.Millisecond}, []uint32{100, 50}},
+		{"unmeasured path", MultipathHash, []time.Duration{10 * time.Millisecond, 0}, nil},
+		{"fast paths", MultipathRoundRobin, []time.Duration{100 * time.Microsecond, 200 * time.Microsecond}, []uint32{1000, 1000}},
+		{"slow paths", MultipathRoundRobin, []time.Duration{1500 * time.Millisecond, 3 * time.Second}, []uint32{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := multipathPeer(tt.latencies...)
+			np.updatePaths(tt.mode)
+			set, _ := np.paths.Load().(*pathSet)
+			if tt.want == nil {
+				if set != nil || len(np.sendPaths(tt.mode, 0, true)) != 1 {
+					t.Errorf("Multipath used: %v", np.Paths())
+				}
+				return
+			}
+			if set == nil || len(set.weights) != len(tt.want) {
+				t.Fatalf("Paths = %v, want %d", np.Paths(), len(tt.want))
+			}
+			for i, w := range tt.want {
+				if set.weights[i] != w {
+					t.Errorf("Weights = %v, want %v", set.weights, tt.want)
+				}
+			}
+			np.sendPaths(tt.mode, 0, false)
+		})
+	}
+
+	np := multipathPeer(time.Millisecond, time.Millisecond)
+	np.EndpointsHeap[1].LastContact = time.Now().Add(-EndpointTimeout * 2)
+	np.updatePaths(MultipathHash)
+	if np.Paths() != nil {
+		t.Errorf("Stale endpoint used: %v", np.Paths())
+	}
+}
+
+func TestNetworkPeer_sendPaths(t *testing.T) {
+	np := multipathPeer(10*time.Millisecond, 20*time.Millisecond, 40*time.Millisecond)
+	np.updatePaths(MultipathRoundRobin)
+	counts := map[int]int{}
+	for i := 0; i < 175*10; i++ {
+		counts[np.sendPaths(MultipathRoundRobin, 0, false)[0].Port]++
+	}
+	if counts[1] != 1000 || counts[2] != 500 || counts[3] != 250 {
+		t.Errorf("Round-robin counts = %v", counts)
+	}
+
+	counts = map[int]int{}
+	for flow := uint32(0); flow < 7000; flow++ {
+		port := np.sendPaths(MultipathHash, flow*2654435761, false)[0].Port
+		if np.sendPaths(MultipathHash, flow*2654435761, false)[0].Port != port {
+			t.Fatalf("Flow %d moved between paths", flow)
+		}
+		counts[port]++
+	}
+	if counts[1] < 3500 || counts[2] < 1500 || counts[3] < 500 || counts[3] > 1500 {
+		t.Errorf("Hash counts = %v", counts)
+	}
+
+	if paths := np.sendPaths(MultipathDuplicate, 0, true); len(paths) != 1 {
+		t.Errorf("Duplicates sent to peer without multipath: %v", paths)
+	}
+	np.setCaps(CapDedup)
+	if paths := np.sendPaths(MultipathDuplicate, 0, true); len(paths) != 2 || paths[0].Port != 1 || paths[1].Port != 2 {
+		t.Errorf("Duplicates sent to %v", paths)
+	}
+	if paths := np.sendPaths(MultipathDuplicate, 0, false); len(paths) != 1 || paths[0].Port != 1 {
+		t.Errorf("Frame that isn't latency critical sent to %v", paths)
+	}
+	np.State = PeerStateDisconnect
+	if paths := np.sendPaths(MultipathDuplicate, 0, true); len(paths) != 1 || paths[0] != np.Endpoint {
+		t.Errorf("Multipath used for disconnected peer: %v", paths)
+	}
+	if paths := np.sendPaths(MultipathOff, 0, true); len(paths) != 1 || paths[0] != np.Endpoint {
+		t.Errorf("Multipath used when it's off: %v", paths)
+	}
+}
+
+func TestPeerToPeer_SetMultipath(t *testing.T) {
+	p := &PeerToPeer{Swarm: new(Swarm)}
+	p.Swarm.Init()
+	np := multipathPeer(10*time.Millisecond, 20*time.Millisecond)
+	p.Swarm.Update("peer", np)
+
+	p.SetMultipath(MultipathHash)
+	np.updatePaths(p.Multipath())
+	if len(np.Paths()) != 2 {
+		t.Fatalf("Paths = %v, want 2", np.Paths())
+	}
+	p.SetMultipath(MultipathOff)
+	if p.Multipath() != MultipathOff || np.Paths() != nil {
+		t.Errorf("Paths after multipath was turned off = %v", np.Paths())
+	}
+}
+
+func TestPeerToPeer_duplicateMessage(t *testing.T) {
+	tap, err := newTAP("", "10.0.0.1", "00:00:00:00:00:00", "255.255.255.0", 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tap.SetHardwareAddress(net.HardwareAddr{0x06, 1, 2, 3, 4, 5})
+	p := &PeerToPeer{Interface: tap}
+	peer := new(NetworkPeer)
+
+	delivered := 0
+	src := &net.UDPAddr{IP: net.ParseIP("192.168.0.1"), Port: 1}
+	r := &PeerToPeer{duplicates: newDuplicateFilter(), Swarm: new(Swarm)}
+	r.Swarm.Init()
+	r.Swarm.Update("sender", &NetworkPeer{ID: "sender", PeerHW: tap.GetHardwareAddress(), EndpointsHeap: []*Endpoint{{Addr: src}}})
+	r.MessageHandlers = map[uint16]MessageHandler{
+		MsgTypeDuplicate: r.HandleDuplicateMessage,
+		MsgTypeNenc: func(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+			if string(msg.Data) != "frame" {
+				t.Errorf("Wrong frame delivered: %q", msg.Data)
+			}
+			delivered++
+			return nil
+		},
+	}
+
+	// Spoofed copy far ahead must not move window of the peer
+	spoofer := &net.UDPAddr{IP: net.ParseIP("192.168.0.2"), Port: 1}
+	msg, err := p.CreateMessage(MsgTypeNenc, []byte("frame"), 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spoofed := &NetworkPeer{duplicateSeq: 500}
+	dup, err := p.duplicateMessage(spoofed, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.HandleDuplicateMessage(dup, spoofer); err == nil {
+		t.Errorf("Copy from unknown endpoint was accepted")
+	}
+
+	for i := 0; i < 3; i++ {
+		msg, err := p.CreateMessage(MsgTypeNenc, []byte("frame"), 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dup, err := p.duplicateMessage(peer, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := dup.Serialize()
+		r.HandleP2PMessage(len(data), src, nil, data)
+		r.HandleP2PMessage(len(data), src, nil, data)
+	}
+	if delivered != 3 || r.DuplicatesDropped() != 3 {
+		t.Errorf("Delivered %d frames, dropped %d", delivered, r.DuplicatesDropped())
+	}
+}
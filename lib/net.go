@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -107,33 +109,90 @@ func CreateMessageStatic(msgType MsgType, payload []byte) (*P2PMessage, error) {
 
 ///////////////////////////////////////////////////////////////////////////////////////////
 
+// NetworkStats holds traffic counters of the UDP socket
+type NetworkStats struct {
+	TxBytes   uint64 // Bytes sent
+	RxBytes   uint64 // Bytes received
+	TxPackets uint64 // Datagrams sent
+	RxPackets uint64 // Datagrams received
+}
+
 // Network is a network subsystem
 type Network struct {
-	host       string
-	port       int
-	remotePort int
-	addr       *net.UDPAddr
-	conn       *net.UDPConn
-	inBuffer   [4096]byte
-	disposed   bool
+	stats       NetworkStats // Must be first to keep 64-bit alignment for atomic operations
+	host        string
+	port        int
+	remotePort  int
+	addr        *net.UDPAddr
+	conn        *net.UDPConn
+	inBuffer    [4096]byte
+	disposed    bool
+	disposeLock sync.RWMutex     // Guards disposed, which is changed by Close while listeners run
+	sendQueue   chan outDatagram // Datagrams waiting for batch writer
+	writer      sync.Once        // Starts batch writer
+	quit        chan struct{}    // Closed with connection to stop batch writer
+	maxDatagram int              // Size of batch read buffers. Size of inBuffer when 0
+	sendLock    sync.RWMutex     // Taken for writing while a probe with Don't Fragment flag is sent
+	pmtuMode    int              // IP_MTU_DISCOVER of the socket before the first probe
+	pmtuSaved   bool             // Whether pmtuMode was read
 }
 
-// Close will terminate packet reader
+// Close will terminate packet reader. Connection is kept, so goroutines
+// that still use it receive an error from the closed socket
 func (uc *Network) Close() error {
+	uc.disposeLock.Lock()
+	closed := uc.disposed && uc.conn != nil
 	uc.disposed = true
-	if uc.conn != nil {
-		err := uc.conn.Close()
-		uc.conn = nil
-		return err
+	uc.disposeLock.Unlock()
+	if uc.conn == nil {
+		return fmt.Errorf("Nil Connection")
+	}
+	if closed {
+		return fmt.Errorf("Connection already closed")
+	}
+	if uc.quit != nil {
+		close(uc.quit)
 	}
-	return fmt.Errorf("Nil Connection")
+	return uc.conn.Close()
+}
+
+// Stats returns a snapshot of traffic counters
+func (uc *Network) Stats() NetworkStats {
+	if uc == nil {
+		return NetworkStats{}
+	}
+	return NetworkStats{
+		TxBytes:   atomic.LoadUint64(&uc.stats.TxBytes),
+		RxBytes:   atomic.LoadUint64(&uc.stats.RxBytes),
+		TxPackets: atomic.LoadUint64(&uc.stats.TxPackets),
+		RxPackets: atomic.LoadUint64(&uc.stats.RxPackets),
+	}
+}
+
+func (uc *Network) countTx(n int) {
+	atomic.AddUint64(&uc.stats.TxBytes, uint64(n))
+	atomic.AddUint64(&uc.stats.TxPackets, 1)
+}
+
+// SetMaxDatagram sets size of buffers used by ListenBatch. Must be called
+// before listener is started
+func (uc *Network) SetMaxDatagram(size int) {
+	uc.maxDatagram = size
 }
 
 // Disposed returns whether service is willing to stop or not
 func (uc *Network) Disposed() bool {
+	uc.disposeLock.RLock()
+	defer uc.disposeLock.RUnlock()
 	return uc.disposed
 }
 
+func (uc *Network) setDisposed(disposed bool) {
+	uc.disposeLock.Lock()
+	uc.disposed = disposed
+	uc.disposeLock.Unlock()
+}
+
 // Addr returns assigned address
 func (uc *Network) Addr() *net.UDPAddr {
 	if uc.addr != nil {
@@ -147,7 +206,7 @@ func (uc *Network) Init(host string, port int) error {
 	var err error
 	uc.host = host
 	uc.port = port
-	uc.disposed = true
+	uc.setDisposed(true)
 
 	//todo check if we need Host and Port
 	uc.addr, err = net.ResolveUDPAddr("udp4", fmt.Sprintf(":%d", port))
@@ -158,7 +217,8 @@ func (uc *Network) Init(host string, port int) error {
 	if err != nil {
 		return err
 	}
-	uc.disposed = false
+	uc.quit = make(chan struct{})
+	uc.setDisposed(false)
 	return nil
 }
 
@@ -191,12 +251,12 @@ func (uc *Network) KeepAlive(target string) error {
 	keepAlive := time.Now()
 	Log(Debug, "Started keep alive session with %s", addr)
 	i := 0
-	for i < 20 {
+	for i < 20 && !uc.Disposed() {
 		uc.SendRawBytes(data, addr)
 		i++
 		time.Sleep(time.Millisecond * 500)
 	}
-	for !uc.disposed {
+	for !uc.Disposed() {
 		if time.Duration(time.Second*3) < time.Since(keepAlive) {
 			keepAlive = time.Now()
 			uc.SendRawBytes(data, addr)
@@ -226,6 +286,10 @@ func (uc *Network) Listen(receivedCallback UDPReceivedCallback) error {
 	}
 	for !uc.Disposed() {
 		n, src, err := uc.conn.ReadFromUDP(uc.inBuffer[:])
+		if err == nil {
+			atomic.AddUint64(&uc.stats.RxBytes, uint64(n))
+			atomic.AddUint64(&uc.stats.RxPackets, 1)
+		}
 		receivedCallback(n, src, err, uc.inBuffer[:])
 	}
 	Log(Info, "Stopping UDP Listener")
@@ -240,11 +304,9 @@ func (uc *Network) SendMessage(msg *P2PMessage, dstAddr *net.UDPAddr) (int, erro
 	if msg == nil {
 		return 0, fmt.Errorf("Nil message")
 	}
-	n, err := uc.conn.WriteToUDP(msg.Serialize(), dstAddr)
-	if err != nil {
-		return 0, err
-	}
-	return n, nil
+	uc.sendLock.RLock()
+	defer uc.sendLock.RUnlock()
+	return uc.writeTo(msg.Serialize(), dstAddr)
 }
 
 // SendRawBytes sends bytes over network
@@ -252,9 +314,17 @@ func (uc *Network) SendRawBytes(bytes []byte, dstAddr *net.UDPAddr) (int, error)
 	if uc.conn == nil {
 		return -1, fmt.Errorf("Nil connection")
 	}
-	n, err := uc.conn.WriteToUDP(bytes, dstAddr)
+	uc.sendLock.RLock()
+	defer uc.sendLock.RUnlock()
+	return uc.writeTo(bytes, dstAddr)
+}
+
+// writeTo writes a single datagram. Caller holds sendLock
+func (uc *Network) writeTo(data []byte, dstAddr *net.UDPAddr) (int, error) {
+	n, err := uc.conn.WriteToUDP(data, dstAddr)
 	if err != nil {
 		return 0, err
 	}
+	uc.countTx(n)
 	return n, nil
 }
@@ -0,0 +1,211 @@
+package ptp
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	"golang.org/x/net/ipv4"
+)
+
+// Sizes of batched UDP I/O
+const (
+	UDPBatchSize     = 32  // Datagrams read or written by a single system call
+	udpSendQueueSize = 512 // Datagrams waiting for batch writer
+)
+
+// UDPDatagram is a datagram received by batch listener. Data is valid
+// until the callback returns
+type UDPDatagram struct {
+	Data []byte
+	Addr *net.UDPAddr
+}
+
+// UDPBatchReceivedCallback is executed for every batch of received
+// datagrams
+type UDPBatchReceivedCallback func(batch []UDPDatagram)
+
+// ListenBatch is like Listen, but delivers datagrams in batches. On Linux
+// up to UDPBatchSize datagrams are read with a single recvmmsg call.
+// Other platforms read one datagram at a time
+func (uc *Network) ListenBatch(receivedCallback UDPBatchReceivedCallback) error {
+	Log(Info, "Started UDP listener")
+	conn := uc.conn
+	if conn == nil {
+		return fmt.Errorf("Nil connection")
+	}
+	size := UDPBatchSize
+	if !batchIO {
+		size = 1
+	}
+	// Buffers are reused for every batch, so callback must copy data it
+	// wants to keep
+	pc := ipv4.NewPacketConn(conn)
+	bufSize := uc.maxDatagram
+	if bufSize == 0 {
+		bufSize = len(uc.inBuffer)
+	}
+	msgs := make([]ipv4.Message, size)
+	for i := range msgs {
+		msgs[i].Buffers = [][]byte{make([]byte, bufSize)}
+	}
+	batch := make([]UDPDatagram, size)
+	for !uc.Disposed() {
+		n, err := uc.readBatch(conn, pc, msgs)
+		if err != nil {
+			if !uc.Disposed() {
+				Log(Error, "Failed to read from UDP socket: %s", err)
+			}
+			continue
+		}
+		received := batch[:0]
+		for _, m := range msgs[:n] {
+			addr, _ := m.Addr.(*net.UDPAddr)
+			received = append(received, UDPDatagram{Data: m.Buffers[0][:m.N], Addr: addr})
+			atomic.AddUint64(&uc.stats.RxBytes, uint64(m.N))
+			atomic.AddUint64(&uc.stats.RxPackets, 1)
+		}
+		if len(received) > 0 {
+			receivedCallback(received)
+		}
+	}
+	Log(Info, "Stopping UDP Listener")
+	return nil
+}
+
+func (uc *Network) readBatch(conn *net.UDPConn, pc *ipv4.PacketConn, msgs []ipv4.Message) (int, error) {
+	if len(msgs) > 1 {
+		return pc.ReadBatch(msgs, 0)
+	}
+	n, addr, err := conn.ReadFromUDP(msgs[0].Buffers[0])
+	if err != nil {
+		return 0, err
+	}
+	msgs[0].N = n
+	msgs[0].Addr = addr
+	return 1, nil
+}
+
+// outDatagram is a datagram waiting for batch writer
+type outDatagram struct {
+	data []byte
+	addr *net.UDPAddr
+}
+
+// QueueMessage sends message through batch writer. Messages queued at the
+// same time are written with a single sendmmsg call, grouped by
+// destination. Messages to the same destination keep their order. Write
+// errors are logged, not returned. Without batched I/O message is sent
+// immediately, like with SendMessage
+func (uc *Network) QueueMessage(msg *P2PMessage, dstAddr *net.UDPAddr) (int, error) {
+	if !batchIO {
+		return uc.SendMessage(msg, dstAddr)
+	}
+	if uc.conn == nil {
+		return -1, fmt.Errorf("Nil connection")
+	}
+	if msg == nil {
+		return 0, fmt.Errorf("Nil message")
+	}
+	uc.startWriter()
+	data := msg.Serialize()
+	select {
+	case uc.sendQueue <- outDatagram{data: data, addr: dstAddr}:
+		return len(data), nil
+	case <-uc.quit:
+		return 0, fmt.Errorf("Connection closed")
+	}
+}
+
+func (uc *Network) startWriter() {
+	uc.writer.Do(func() {
+		uc.sendQueue = make(chan outDatagram, udpSendQueueSize)
+		go uc.writeBatches(ipv4.NewPacketConn(uc.conn))
+	})
+}
+
+// writeBatches takes everything queued at the moment, up to UDPBatchSize
+// datagrams, and writes it at once
+func (uc *Network) writeBatches(pc *ipv4.PacketConn) {
+	pending := make([]outDatagram, 0, UDPBatchSize)
+	grouped := make([]outDatagram, 0, UDPBatchSize)
+	msgs := make([]ipv4.Message, UDPBatchSize)
+	for i := range msgs {
+		msgs[i].Buffers = make([][]byte, 1)
+	}
+	for {
+		select {
+		case d := <-uc.sendQueue:
+			pending = append(pending[:0], d)
+		case <-uc.quit:
+			return
+		}
+	collect:
+		for len(pending) < UDPBatchSize {
+			select {
+			case d := <-uc.sendQueue:
+				pending = append(pending, d)
+			default:
+				break collect
+			}
+		}
+		grouped = groupByDestination(pending, grouped)
+		for i, d := range grouped {
+			msgs[i].Buffers[0] = d.data
+			msgs[i].Addr = d.addr
+		}
+		uc.writeBatch(pc, msgs[:len(grouped)])
+	}
+}
+
+// writeBatch writes all messages, retrying after partial writes
+func (uc *Network) writeBatch(pc *ipv4.PacketConn, msgs []ipv4.Message) {
+	for len(msgs) > 0 {
+		uc.sendLock.RLock()
+		n, err := pc.WriteBatch(msgs, 0)
+		uc.sendLock.RUnlock()
+		for _, m := range msgs[:n] {
+			uc.countTx(m.N)
+		}
+		if err != nil || n == 0 {
+			if err != nil && !uc.Disposed() {
+				Log(Error, "Failed to write to UDP socket: %s", err)
+			}
+			// Skip datagram that failed
+			n++
+		}
+		if n > len(msgs) {
+			n = len(msgs)
+		}
+		msgs = msgs[n:]
+	}
+}
+
+// groupByDestination puts datagrams to the same destination next to each
+// other, keeping their order. Result is written to out
+func groupByDestination(batch, out []outDatagram) []outDatagram {
+	out = out[:0]
+	var taken [UDPBatchSize]bool
+	for i := range batch {
+		if taken[i] {
+			continue
+		}
+		for j := i; j < len(batch); j++ {
+			if !taken[j] && sameUDPAddr(batch[i].addr, batch[j].addr) {
+				taken[j] = true
+				out = append(out, batch[j])
+			}
+		}
+	}
+	return out
+}
+
+func sameUDPAddr(a, b *net.UDPAddr) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return a.Port == b.Port && a.IP.Equal(b.IP)
+}
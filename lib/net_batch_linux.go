@@ -0,0 +1,4 @@
+package ptp
+
+// batchIO enables recvmmsg and sendmmsg
+const batchIO = true
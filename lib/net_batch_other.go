@@ -0,0 +1,7 @@
+// +build !linux
+
+package ptp
+
+// batchIO is disabled: golang.org/x/net/ipv4 reads and writes a single
+// datagram per call on this platform
+const batchIO = false
@@ -0,0 +1,170 @@
+package ptp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupByDestination(t *testing.T) {
+	a := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1}
+	a2 := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1}
+	b := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1}
+	c := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 2}
+	batch := []outDatagram{
+		{[]byte{1}, a}, {[]byte{2}, b}, {[]byte{3}, a2}, {[]byte{4}, c}, {[]byte{5}, b}, {[]byte{6}, a},
+	}
+	got := groupByDestination(batch, nil)
+	want := []byte{1, 3, 6, 2, 5, 4}
+	if len(got) != len(want) {
+		t.Fatalf("Got %d datagrams, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].data[0] != want[i] {
+			t.Errorf("Datagram %d: got %d, want %d", i, got[i].data[0], want[i])
+		}
+	}
+}
+
+// loopbackPair returns sockets listening on loopback
+func loopbackPair(t testing.TB) (*Network, *Network) {
+	sender, receiver := new(Network), new(Network)
+	if err := sender.Init("127.0.0.1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := receiver.Init("127.0.0.1", 0); err != nil {
+		sender.Close()
+		t.Fatal(err)
+	}
+	return sender, receiver
+}
+
+func loopbackAddr(uc *Network) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: uc.GetPort()}
+}
+
+func TestNetwork_batchLoopback(t *testing.T) {
+	sender, receiver := loopbackPair(t)
+	defer sender.Close()
+
+	var lock sync.Mutex
+	got := []uint16{}
+	done := make(chan struct{})
+	go func() {
+		receiver.ListenBatch(func(batch []UDPDatagram) {
+			lock.Lock()
+			defer lock.Unlock()
+			for _, d := range batch {
+				msg, err := P2PMessageFromBytes(d.Data)
+				if err != nil {
+					t.Errorf("Bad datagram: %s", err)
+					continue
+				}
+				got = append(got, msg.Header.NetProto)
+			}
+		})
+		close(done)
+	}()
+
+	dst := loopbackAddr(receiver)
+	for i := 0; i < 100; i++ {
+		msg, _ := CreateMessageStatic(MsgTypeNenc, []byte("data"))
+		msg.Header.NetProto = uint16(i)
+		if _, err := sender.QueueMessage(msg, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		lock.Lock()
+		n := len(got)
+		lock.Unlock()
+		if n == 100 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	receiver.Close()
+	<-done
+
+	if len(got) != 100 {
+		t.Fatalf("Received %d datagrams, want 100", len(got))
+	}
+	for i, v := range got {
+		if int(v) != i {
+			t.Fatalf("Datagram %d received at position %d", v, i)
+		}
+	}
+	if s := receiver.Stats(); s.RxPackets != 100 {
+		t.Errorf("Counted %d received datagrams", s.RxPackets)
+	}
+}
+
+// benchmarkLoopback sends b.N messages over loopback and waits until they
+// are received. Messages are sent in bursts of UDPBatchSize, so socket
+// buffer of the receiver doesn't overflow. Lost datagrams are reported
+func benchmarkLoopback(b *testing.B, batch bool) {
+	sender, receiver := loopbackPair(b)
+	defer sender.Close()
+	defer receiver.Close()
+
+	var received uint64
+	notify := make(chan struct{}, 1)
+	count := func(n int) {
+		atomic.AddUint64(&received, uint64(n))
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	if batch {
+		go receiver.ListenBatch(func(batch []UDPDatagram) {
+			count(len(batch))
+		})
+	} else {
+		go receiver.Listen(func(n int, src *net.UDPAddr, err error, buf []byte) error {
+			if err == nil {
+				count(1)
+			}
+			return nil
+		})
+	}
+	msg, _ := CreateMessageStatic(MsgTypeNenc, make([]byte, 1400))
+	dst := loopbackAddr(receiver)
+	lost := uint64(0)
+	// wait blocks until all sent datagrams are received. Datagrams that
+	// don't arrive in time are counted as lost
+	wait := func(sent uint64) {
+		for atomic.LoadUint64(&received)+lost < sent {
+			select {
+			case <-notify:
+			case <-time.After(10 * time.Millisecond):
+				lost = sent - atomic.LoadUint64(&received)
+			}
+		}
+	}
+	b.SetBytes(int64(HeaderSize + 1400))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%UDPBatchSize == 0 {
+			wait(uint64(i))
+		}
+		if batch {
+			sender.QueueMessage(msg, dst)
+		} else {
+			sender.SendMessage(msg, dst)
+		}
+	}
+	wait(uint64(b.N))
+	b.StopTimer()
+	b.ReportMetric(float64(lost)/float64(b.N), "lost/op")
+}
+
+func BenchmarkUDPLoopback_single(b *testing.B) {
+	benchmarkLoopback(b, false)
+}
+
+func BenchmarkUDPLoopback_batch(b *testing.B) {
+	benchmarkLoopback(b, true)
+}
@@ -0,0 +1,117 @@
+package ptp
+
+import (
+	"fmt"
+	"net"
+)
+
+// UseOffload enables virtio-net header offload on TAP interfaces of new
+// instances. Linux only
+var UseOffload = false
+
+// handleOffloadFrame sends super-packet read from TAP interface. Peers that
+// accept super-packets receive it in pieces. Other peers receive ordinary
+// segments
+func (p *PeerToPeer) handleOffloadFrame(frame []byte, proto int, h VNetHeader) error {
+	if p.Swarm == nil {
+		return fmt.Errorf("nil peer list")
+	}
+	dst := net.HardwareAddr(frame[0:6])
+	peer := p.Swarm.GetPeerByMac(dst.String())
+	if peer == nil || peer.Caps()&CapOffload == 0 {
+		return segmentTCP(frame, h, int(h.GSOSize), func(seg []byte, _ VNetHeader) error {
+			return p.handlePacket(seg, proto)
+		})
+	}
+	chunk, err := p.offloadChunk(peer, frame)
+	if err != nil {
+		return err
+	}
+	flow, critical := flowHash(frame), p.latencyCritical(frame)
+	return segmentTCP(frame, h, chunk, func(seg []byte, sh VNetHeader) error {
+		payload := make([]byte, VNetHeaderSize+len(seg))
+		sh.Encode(payload)
+		copy(payload[VNetHeaderSize:], seg)
+		msg, err := p.CreateMessage(MsgTypeGSO, payload, uint16(proto), true)
+		if err != nil {
+			return err
+		}
+		_, err = p.sendFlow(dst, flow, critical, msg)
+		return err
+	})
+}
+
+// offloadChunk returns TCP payload size of super-packet pieces sent to
+// the peer. Peers reassembling fragments receive pieces of up to
+// offloadMaxPayload bytes, which are split to path MTU by sendToPeer.
+// Other peers receive pieces that fit into a single datagram, so IP
+// fragmentation is never needed
+func (p *PeerToPeer) offloadChunk(peer *NetworkPeer, frame []byte) (int, error) {
+	if peer.Caps()&CapFragment != 0 {
+		return offloadMaxPayload, nil
+	}
+	f, err := parseTCPFrame(frame)
+	if err != nil {
+		return 0, err
+	}
+	return p.maxDatagram(peer) - messageOverhead - VNetHeaderSize - f.hdrLen(), nil
+}
+
+// HandleGSOMessage is a super-packet sent by a peer with offload. Data
+// is virtio-net header followed by the frame
+func (p *PeerToPeer) HandleGSOMessage(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+	if msg == nil {
+		return fmt.Errorf("nil message")
+	}
+	if msg.Header == nil {
+		return fmt.Errorf("nil header")
+	}
+	if srcAddr == nil {
+		return fmt.Errorf("nil source addr")
+	}
+	if len(msg.Data) < VNetHeaderSize+14 {
+		return fmt.Errorf("payload is too small")
+	}
+	h, _ := ParseVNetHeader(msg.Data)
+	if h.IsGSO() && h.GSOType != VNetGSOTCPv4 {
+		return fmt.Errorf("Unsupported GSO type %d", h.GSOType)
+	}
+	frame := msg.Data[VNetHeaderSize:]
+	p.countRx(&P2PMessage{Header: msg.Header, Data: frame}, srcAddr)
+	return p.writeOffload(h, frame, msg.Header.NetProto)
+}
+
+// writeOffload writes frame with virtio-net header to TAP interface.
+// Interfaces without offload receive ordinary segments with checksums
+func (p *PeerToPeer) writeOffload(h VNetHeader, frame []byte, proto uint16) error {
+	if p.Interface == nil {
+		return fmt.Errorf("nil interface")
+	}
+	if ot, ok := p.Interface.(OffloadTAP); ok && ot.OffloadEnabled() {
+		err := ot.WriteOffload(h, frame)
+		if err != nil {
+			Log(Error, "Failed to write to TAP Interface: %v", err)
+		}
+		return err
+	}
+	if !h.IsGSO() {
+		err := completeChecksum(frame, h)
+		if err != nil {
+			return err
+		}
+		return p.WriteToDevice(frame, proto, false)
+	}
+	return segmentTCP(frame, h, int(h.GSOSize), func(seg []byte, _ VNetHeader) error {
+		return p.WriteToDevice(seg, proto, false)
+	})
+}
+
+// flushInterface writes frames coalesced by TAP interface
+func (p *PeerToPeer) flushInterface() {
+	if ot, ok := p.Interface.(OffloadTAP); ok {
+		err := ot.Flush()
+		if err != nil {
+			Log(Error, "Failed to write to TAP Interface: %v", err)
+		}
+	}
+}
@@ -1,27 +1,40 @@
 package ptp
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	upnp "github.com/NebulousLabs/go-upnp"
 )
 
-// GlobalMTU value specified on daemon start
+// GlobalMTU value specified on daemon start. Used only by instances
+// created with New()
 var GlobalMTU = DefaultMTU
 
+// UsePMTU is a daemon-wide PMTU switch. Used only by instances created
+// with New()
 var UsePMTU = false
 
+// TAPQueues is a daemon-wide number of TAP queues. Used only by instances
+// created with New()
+var TAPQueues = 1
+
+// GlobalEvents is a daemon-wide event bus. Used only by instances created
+// with New()
+var GlobalEvents = NewEventBus()
+
 // PeerToPeer - Main structure
 type PeerToPeer struct {
+	decryptFailures uint64                               // Number of messages failed to decrypt. Must be first to keep 64-bit alignment
 	UDPSocket       *Network                             // Peer-to-peer interconnection socket
 	LocalIPs        []net.IP                             // List of IPs available in the system
 	Dht             *DHTClient                           // DHT Client
 	Crypter         Crypto                               // Cryptography subsystem
-	Shutdown        bool                                 // Set to true when instance in shutdown mode
 	ForwardMode     bool                                 // Skip local peer discovery
 	ReadyToStop     bool                                 // Set to true when instance is ready to stop
 	MessageHandlers map[uint16]MessageHandler            // Callbacks for network packets
@@ -35,6 +48,25 @@ type PeerToPeer struct {
 	UsePMTU         bool                                 // Whether PMTU capabilities are enabled or not
 	StartedAt       time.Time                            // Timestamp of instance creation time
 	ConfiguredAt    time.Time                            // Time when configuration of the instance was finished
+	MTU             int                                  // MTU of the p2p interface
+	Callbacks       Callbacks                            // Lifecycle callbacks
+	Events          *EventBus                            // Lifecycle events bus
+	reserved        *IPRegistry                          // IPs reserved by p2p interfaces
+	routines        sync.WaitGroup                       // Goroutines started by this instance
+	shutdown        chan struct{}                        // Closed when instance enters shutdown mode
+	shutdownInit    sync.Once                            // Creates shutdown channel
+	closed          uint32                               // Set by the first Close
+	packets         *pipeline                            // Workers processing frames and datagrams
+	dhtPackets      *pipeline                            // Workers processing packets from bootstrap node
+	messageBuffers  *bufferPool                          // Pool of buffers for datagrams larger than packetBufferSize
+	fragments       *fragmentAssembler                   // Reassembles messages fragmented by peers
+	fragmentID      uint32                               // ID of the last fragmented message
+	probeToken      uint32                               // Token of the last path MTU probe
+	underlayMTU     int                                  // MTU of the network between peers
+	compression     bool                                 // Whether frames are compressed for peers that accept it
+	duplicates      *duplicateFilter                     // Drops copies of messages sent over several paths
+	multipath       uint32                               // MultipathMode of the instance
+	duplicatePorts  atomic.Value                         // Set of TCP and UDP ports duplicated in duplicate mode
 }
 
 // PeerHandshake holds handshake information received from peer
@@ -46,8 +78,17 @@ type PeerHandshake struct {
 	AutoIP       bool // Whether or not peer have automatic IP
 }
 
-// ActiveInterfaces is a global (daemon-wise) list of reserved IP addresses
-var ActiveInterfaces []net.IP
+// logger returns a logger with instance hash and subsystem fields
+func (p *PeerToPeer) logger(subsystem string) *Logger {
+	if p == nil {
+		return With(FieldSubsystem, subsystem)
+	}
+	return With(FieldInstance, p.Hash, FieldSubsystem, subsystem)
+}
+
+// ActiveInterfaces is a global (daemon-wise) list of reserved IP addresses.
+// Used only by instances created with New()
+var ActiveInterfaces = NewIPRegistry()
 
 // AssignInterface - Creates TUN/TAP Interface and configures it with provided IP tool
 func (p *PeerToPeer) AssignInterface(interfaceName string) error {
@@ -93,14 +134,34 @@ func (p *PeerToPeer) AssignInterface(interfaceName string) error {
 	if err != nil {
 		return err
 	}
-	ActiveInterfaces = append(ActiveInterfaces, p.Interface.GetIP())
+	p.publish(EventIfaceUp, "", p.interfaceDetails())
+	p.registry().Reserve(p.Interface.GetIP())
 	if !p.Interface.IsAuto() {
 		Log(Debug, "Interface has been configured")
 		p.Interface.MarkConfigured()
+		p.interfaceConfigured()
 	}
 	return err
 }
 
+// interfaceConfigured notifies subscribers about configured interface
+func (p *PeerToPeer) interfaceConfigured() {
+	if p.Interface == nil {
+		return
+	}
+	p.Callbacks.interfaceConfigured(p.Interface)
+	p.publish(EventIPAssigned, "", p.interfaceDetails())
+}
+
+// interfaceDetails returns event data describing p2p interface
+func (p *PeerToPeer) interfaceDetails() map[string]string {
+	return map[string]string{
+		"ip":     ipToString(p.Interface.GetIP()),
+		"mac":    p.Interface.GetHardwareAddress().String(),
+		"device": p.Interface.GetName(),
+	}
+}
+
 // ListenInterface - Listens TAP interface for incoming packets
 // Read packets received by TAP interface and send them to a handlePacket goroutine
 // This goroutine will execute a callback method based on packet type
@@ -110,35 +171,85 @@ func (p *PeerToPeer) ListenInterface() error {
 		return fmt.Errorf("nil interface")
 	}
 	p.Interface.Run()
+	mq, _ := p.Interface.(MultiQueueTAP)
+	if mq != nil && p.waitInterface() && mq.QueueCount() > 1 {
+		// Every queue gets its own reader. Kernel steers a single flow
+		// to a single queue, so flows stay ordered
+		for q := 1; q < mq.QueueCount(); q++ {
+			queue := q
+			p.spawn(func() { p.readFrames(mq, queue) })
+		}
+	}
+	p.readFrames(mq, 0)
+	Log(Debug, "Shutting down interface listener")
+
+	if p.Interface != nil {
+		return p.Interface.Close()
+	}
+	return fmt.Errorf("Interface already closed")
+}
+
+// waitInterface blocks until interface is configured. Returns false when
+// instance was shut down before that
+func (p *PeerToPeer) waitInterface() bool {
+	for {
+		if p.Interface.GetIP() != nil && p.Interface.IsConfigured() {
+			return true
+		}
+		select {
+		case <-p.done():
+			return false
+		case <-time.After(time.Millisecond * 100):
+		}
+	}
+}
+
+// readFrames reads frames from a queue of TAP interface until instance is
+// shut down. Interfaces without queues are read with ReadPacket
+func (p *PeerToPeer) readFrames(mq MultiQueueTAP, queue int) {
+	size := frameBufferSize(p.Interface.GetMTU())
+	if ot, ok := p.Interface.(OffloadTAP); ok && ot.OffloadEnabled() {
+		size = offloadBufferSize
+	}
 	for {
-		if p.Shutdown {
+		if p.IsShutdown() {
 			break
 		}
 		if p.Interface.GetIP() == nil || p.Interface.IsConfigured() == false {
-			time.Sleep(time.Millisecond * 100)
+			if !p.waitInterface() {
+				break
+			}
 			continue
 		}
-		packet, err := p.Interface.ReadPacket()
+		var packet *Packet
+		var buf *packetBuffer
+		var err error
+		if mq != nil {
+			buf = getPacketBuffer(size)
+			packet, err = mq.ReadQueue(queue, buf.data)
+		} else {
+			packet, err = p.Interface.ReadPacket()
+		}
+		if packet == nil && buf != nil {
+			putPacketBuffer(buf)
+		}
+		if err != nil && p.IsShutdown() {
+			break
+		}
 		if err != nil && err != errPacketTooBig {
 			Log(Error, "Reading packet: %s", err)
 			p.Close()
 			break
 		}
 		if packet != nil {
-			go p.handlePacket(packet.Packet, packet.Protocol)
+			p.receiveFrame(packet, buf)
 		}
 	}
-	Log(Debug, "Shutting down interface listener")
-
-	if p.Interface != nil {
-		return p.Interface.Close()
-	}
-	return fmt.Errorf("Interface already closed")
 }
 
 // GenerateDeviceName method will generate device name if none were specified at startup
 func (p *PeerToPeer) GenerateDeviceName(i int) string {
-	tap, _ := newTAP("", "127.0.0.1", "00:00:00:00:00:00", "", 0, p.UsePMTU)
+	tap, _ := newTAP("", "127.0.0.1", "00:00:00:00:00:00", "", p.MTU, p.UsePMTU)
 	var devName = tap.GetBasename() + fmt.Sprintf("%d", i)
 	if isDeviceExists(devName) {
 		return p.GenerateDeviceName(i + 1)
@@ -161,36 +272,101 @@ func (p *PeerToPeer) IsIPv4(ip string) bool {
 
 // New is an entry point of a P2P library.
 // This function will return new PeerToPeer object which later
-// should be configured and started using Run() method
+// should be configured and started using Run() method.
+// New uses daemon-wide GlobalMTU, UsePMTU, UnderlayMTU, TAPQueues,
+// UseOffload, UseCompression and ActiveInterfaces and returns nil on
+// failure. Use NewInstance when embedding p2p into another application
 func New(mac, hash, keyfile, key, ttl, target string, fwd bool, port int, outboundIP net.IP) *PeerToPeer {
-	Log(Debug, "Starting new P2P Instance: %s", hash)
-	Log(Debug, "Mac: %s", mac)
+	return NewFromConfig(Config{
+		Hash:           hash,
+		Mac:            mac,
+		Keyfile:        keyfile,
+		Key:            key,
+		TTL:            ttl,
+		Target:         target,
+		Forward:        fwd,
+		Port:           port,
+		OutboundIP:     outboundIP,
+		MTU:            GlobalMTU,
+		PMTU:           UsePMTU,
+		UnderlayMTU:    UnderlayMTU,
+		Queues:         TAPQueues,
+		Offload:        UseOffload,
+		Compression:    UseCompression,
+		Multipath:      GetMultipath(),
+		DuplicatePorts: GetDuplicatePorts(),
+	})
+}
+
+// NewFromConfig is like New, but accepts instance configuration.
+// DefaultMTU is used when MTU is 0. Reserved and Events are always
+// daemon-wide
+func NewFromConfig(cfg Config) *PeerToPeer {
+	if cfg.MTU == 0 {
+		cfg.MTU = DefaultMTU
+	}
+	cfg.Reserved = ActiveInterfaces
+	cfg.Events = GlobalEvents
+	p, err := newPeerToPeer(&cfg)
+	if err != nil {
+		Log(Error, "%s", err)
+		return nil
+	}
+	return p
+}
+
+// newPeerToPeer creates PeerToPeer object from provided configuration,
+// opens UDP socket and initializes DHT client
+func newPeerToPeer(cfg *Config) (*PeerToPeer, error) {
+	Log(Debug, "Starting new P2P Instance: %s", cfg.Hash)
+	Log(Debug, "Mac: %s", cfg.Mac)
 	p := new(PeerToPeer)
-	p.outboundIP = outboundIP
+	p.outboundIP = cfg.OutboundIP
+	p.MTU = cfg.MTU
+	p.UsePMTU = cfg.PMTU
+	p.underlayMTU = cfg.UnderlayMTU
+	p.compression = cfg.Compression
+	p.fragments = newFragmentAssembler(FragmentMemoryLimit, FragmentTimeout)
+	p.duplicates = newDuplicateFilter()
+	p.SetMultipath(cfg.Multipath)
+	if err := p.SetDuplicatePorts(cfg.DuplicatePorts); err != nil {
+		return nil, err
+	}
+	p.reserved = cfg.Reserved
+	p.Callbacks = cfg.Callbacks
+	p.Events = cfg.Events
 	p.Init()
 	var err error
-	p.Interface, err = newTAP(GetConfigurationTool(), "127.0.0.1", "00:00:00:00:00:00", "", DefaultMTU, UsePMTU)
+	p.Interface, err = newTAP(GetConfigurationTool(), "127.0.0.1", "00:00:00:00:00:00", "", p.MTU, p.UsePMTU)
 	if err != nil {
-		Log(Error, "Failed to create TAP object: %s", err)
-		return nil
+		return nil, fmt.Errorf("Failed to create TAP object: %s", err)
+	}
+	if mq, ok := p.Interface.(MultiQueueTAP); ok {
+		mq.SetQueues(cfg.Queues)
 	}
-	p.Interface.SetHardwareAddress(p.validateMac(mac))
+	offload := false
+	if ot, ok := p.Interface.(OffloadTAP); ok {
+		ot.SetOffload(cfg.Offload)
+		offload = cfg.Offload
+	}
+	p.Interface.SetHardwareAddress(p.validateMac(cfg.Mac))
 	p.FindNetworkAddresses()
 
-	if fwd {
+	if cfg.Forward {
 		p.ForwardMode = true
 	}
 
-	if keyfile != "" {
-		p.Crypter.ReadKeysFromFile(keyfile)
+	if cfg.Keyfile != "" {
+		p.Crypter.ReadKeysFromFile(cfg.Keyfile)
 	}
-	if key != "" {
+	if cfg.Key != "" {
 		// Override key from file
+		ttl := cfg.TTL
 		if ttl == "" {
 			ttl = "default"
 		}
 		var newKey CryptoKey
-		newKey = p.Crypter.EnrichKeyValues(newKey, key, ttl)
+		newKey = p.Crypter.EnrichKeyValues(newKey, cfg.Key, ttl)
 		p.Crypter.Keys = append(p.Crypter.Keys, newKey)
 		p.Crypter.ActiveKey = p.Crypter.Keys[0]
 		p.Crypter.Active = true
@@ -202,14 +378,28 @@ func New(mac, hash, keyfile, key, ttl, target string, fwd bool, port int, outbou
 		Log(Debug, "No AES key were provided. Traffic encryption is disabled")
 	}
 
-	p.Hash = hash
+	p.Hash = cfg.Hash
 
 	p.setupHandlers()
 
 	p.UDPSocket = new(Network)
-	p.UDPSocket.Init("", port)
-	go p.UDPSocket.Listen(p.HandleP2PMessage)
-	go p.UDPSocket.KeepAlive(target)
+	err = p.UDPSocket.Init("", cfg.Port)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open UDP socket: %s", err)
+	}
+	switch {
+	case offload:
+		// Peers with offload send super-packets in datagrams up to 64KB
+		p.UDPSocket.SetMaxDatagram(offloadBufferSize)
+		p.messageBuffers = offloadBuffers
+	case frameBufferSize(p.MTU) > packetBufferSize:
+		// Peers without fragmentation send large frames in a single datagram
+		p.UDPSocket.SetMaxDatagram(frameBufferSize(p.MTU) + messageOverhead)
+		p.messageBuffers = bufferPoolOf(frameBufferSize(p.MTU) + messageOverhead)
+	}
+	p.startPipelines()
+	p.spawn(func() { p.UDPSocket.ListenBatch(p.receiveBatch) })
+	p.spawn(func() { p.UDPSocket.KeepAlive(cfg.Target) })
 	p.waitForRemotePort()
 
 	// Create new DHT Client, configure it and initialize
@@ -222,14 +412,76 @@ func New(mac, hash, keyfile, key, ttl, target string, fwd bool, port int, outbou
 	p.Dht = new(DHTClient)
 	err = p.Dht.Init(p.Hash)
 	if err != nil {
-		Log(Error, "Failed to initialize DHT: %s", err)
-		return nil
+		p.UDPSocket.Close()
+		p.stopPipelines()
+		return nil, fmt.Errorf("Failed to initialize DHT: %s", err)
 	}
+	p.Dht.reserved = p.reserved
 
 	p.setupTCPCallbacks()
 	p.ProxyManager = new(ProxyManager)
 	p.ProxyManager.init()
-	return p
+	return p, nil
+}
+
+// registry returns IPs reserved by instances. Falls back to daemon-wide
+// ActiveInterfaces when instance wasn't created with a registry
+func (p *PeerToPeer) registry() *IPRegistry {
+	if p.reserved == nil {
+		return ActiveInterfaces
+	}
+	return p.reserved
+}
+
+// spawn runs fn in a goroutine tracked by the instance
+func (p *PeerToPeer) spawn(fn func()) {
+	if p == nil {
+		go fn()
+		return
+	}
+	p.routines.Add(1)
+	go func() {
+		defer p.routines.Done()
+		fn()
+	}()
+}
+
+// wait blocks until goroutines started with spawn return or ctx is done
+func (p *PeerToPeer) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.routines.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// done returns channel closed when instance enters shutdown mode
+func (p *PeerToPeer) done() chan struct{} {
+	p.shutdownInit.Do(func() {
+		p.shutdown = make(chan struct{})
+	})
+	return p.shutdown
+}
+
+// Done returns a channel that is closed when instance enters shutdown mode
+func (p *PeerToPeer) Done() <-chan struct{} {
+	return p.done()
+}
+
+// IsShutdown returns true when instance is in shutdown mode
+func (p *PeerToPeer) IsShutdown() bool {
+	select {
+	case <-p.done():
+		return true
+	default:
+		return false
+	}
 }
 
 // ReadDHT will read packets from bootstrap node
@@ -237,24 +489,27 @@ func (p *PeerToPeer) ReadDHT() error {
 	if p.Dht == nil {
 		return fmt.Errorf("ReadDHT: nil DHT")
 	}
-	for !p.Shutdown {
-		packet, err := p.Dht.read()
-		if err != nil {
-			break
-		}
-		go func() {
-			cb, e := p.Dht.TCPCallbacks[packet.Type]
-			if !e {
-				Log(Error, "Unsupported packet from DHT")
-				return
+	in := p.Dht.IncomingData
+	if in == nil {
+		return fmt.Errorf("ReadDHT: closed DHT")
+	}
+	for {
+		select {
+		case <-p.done():
+			return nil
+		case packet := <-in:
+			if packet == nil {
+				return nil
 			}
-			err = cb(packet)
-			if err != nil {
-				Log(Error, "DHT: %s", err)
+			if p.dhtPackets == nil {
+				p.handleDHTPacket(packet)
+				continue
 			}
-		}()
+			// Wait for a free space instead of dropping: DHT packets are rare
+			// and losing them breaks peer discovery
+			p.dhtPackets.submit(dhtKey(packet), pipelineJob{kind: jobDHT, dht: packet}, true)
+		}
 	}
-	return nil
 }
 
 // This method will block for seconds or unless we receive remote port
@@ -281,6 +536,12 @@ func (p *PeerToPeer) waitForRemotePort() error {
 
 // PrepareInterfaces will assign IPs to interfaces
 func (p *PeerToPeer) PrepareInterfaces(ip, interfaceName string) error {
+	return p.prepareInterfaces(context.Background(), ip, interfaceName)
+}
+
+// prepareInterfaces works like PrepareInterfaces but stops waiting for
+// bootstrap node when ctx is done
+func (p *PeerToPeer) prepareInterfaces(ctx context.Context, ip, interfaceName string) error {
 	if p.Interface == nil {
 		return fmt.Errorf("PrepareInterfaces: nil interface")
 	}
@@ -298,7 +559,7 @@ func (p *PeerToPeer) PrepareInterfaces(ip, interfaceName string) error {
 
 	if ip == "dhcp" || ip == "auto" {
 		ip = "dhcp"
-		ipn, maskn, err := p.RequestIP(p.Interface.GetHardwareAddress().String(), iface)
+		ipn, maskn, err := p.requestIP(ctx, p.Interface.GetHardwareAddress().String(), iface)
 		if err != nil {
 			return err
 		}
@@ -318,7 +579,7 @@ func (p *PeerToPeer) PrepareInterfaces(ip, interfaceName string) error {
 		return fmt.Errorf("Failed to parse specified IP: %s", ip)
 	}
 	p.Interface.SetIP(staticIP)
-	ipn, maskn, err := p.ReportIP(ip, p.Interface.GetHardwareAddress().String(), iface)
+	ipn, maskn, err := p.reportIP(ctx, ip, p.Interface.GetHardwareAddress().String(), iface)
 	if err != nil {
 		return err
 	}
@@ -387,6 +648,11 @@ func (p *PeerToPeer) setupHandlers() error {
 	p.MessageHandlers[MsgTypeProxy] = p.HandleProxyMessage
 	p.MessageHandlers[MsgTypeLatency] = p.HandleLatency
 	p.MessageHandlers[MsgTypeComm] = p.HandleComm
+	p.MessageHandlers[MsgTypeGSO] = p.HandleGSOMessage
+	p.MessageHandlers[MsgTypeFragment] = p.HandleFragmentMessage
+	p.MessageHandlers[MsgTypeTest] = p.HandleTestMessage
+	p.MessageHandlers[MsgTypeCompressed] = p.HandleCompressedMessage
+	p.MessageHandlers[MsgTypeDuplicate] = p.HandleDuplicateMessage
 
 	// Register packet handlers
 	p.PacketHandlers = make(map[PacketType]PacketHandlerCallback)
@@ -405,6 +671,10 @@ func (p *PeerToPeer) setupHandlers() error {
 
 // RequestIP asks DHT to get IP from DHCP-like service
 func (p *PeerToPeer) RequestIP(mac, device string) (net.IP, net.IPMask, error) {
+	return p.requestIP(context.Background(), mac, device)
+}
+
+func (p *PeerToPeer) requestIP(ctx context.Context, mac, device string) (net.IP, net.IPMask, error) {
 	if p.Dht == nil {
 		return nil, nil, fmt.Errorf("RequestIP: nil dht")
 	}
@@ -413,7 +683,7 @@ func (p *PeerToPeer) RequestIP(mac, device string) (net.IP, net.IPMask, error) {
 	requestedAt := time.Now()
 	interval := time.Duration(2 * time.Second)
 	attempt := 0
-	p.Dht.sendDHCP(nil, nil)
+	p.Dht.sendDHCP(ctx, nil, nil)
 	for p.Dht.IP == nil && p.Dht.Network == nil {
 		if time.Since(requestedAt) > interval {
 			if attempt >= 3 {
@@ -421,10 +691,14 @@ func (p *PeerToPeer) RequestIP(mac, device string) (net.IP, net.IPMask, error) {
 			}
 			Log(Info, "IP wasn't received. Requesting again: attempt %d/3", (attempt + 1))
 			attempt++
-			p.Dht.sendDHCP(nil, nil)
+			p.Dht.sendDHCP(ctx, nil, nil)
 			requestedAt = time.Now()
 		}
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
 	}
 	p.Interface.SetIP(p.Dht.IP)
 	p.Interface.SetMask(p.Dht.Network.Mask)
@@ -437,6 +711,10 @@ func (p *PeerToPeer) RequestIP(mac, device string) (net.IP, net.IPMask, error) {
 
 // ReportIP will send IP specified at service start to DHCP-like service
 func (p *PeerToPeer) ReportIP(ipAddress, mac, device string) (net.IP, net.IPMask, error) {
+	return p.reportIP(context.Background(), ipAddress, mac, device)
+}
+
+func (p *PeerToPeer) reportIP(ctx context.Context, ipAddress, mac, device string) (net.IP, net.IPMask, error) {
 	if p.Dht == nil {
 		return nil, nil, fmt.Errorf("nil dht")
 	}
@@ -461,7 +739,7 @@ func (p *PeerToPeer) ReportIP(ipAddress, mac, device string) (net.IP, net.IPMask
 	p.Dht.IP = ip
 	p.Dht.Network = ipnet
 
-	p.Dht.sendDHCP(ip, ipnet)
+	p.Dht.sendDHCP(ctx, ip, ipnet)
 	err = p.AssignInterface(device)
 	if err != nil {
 		return nil, nil, fmt.Errorf("Failed to configure interface: %s", err)
@@ -483,20 +761,16 @@ func (p *PeerToPeer) Run() error {
 	initialRequestSent := false
 	started := time.Now()
 	p.Dht.LastUpdate = time.Now()
-	for {
-		if p.Shutdown {
-			// TODO: Do it more safely
-			if p.ReadyToStop {
-				break
-			}
-			time.Sleep(1 * time.Second)
-			continue
-		}
+	for !p.IsShutdown() {
 		p.removeStoppedPeers()
 		p.checkLastDHTUpdate()
 		p.checkProxies()
 		p.checkPeers()
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-p.done():
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
 		if !initialRequestSent && time.Since(started) > time.Duration(time.Millisecond*5000) {
 			initialRequestSent = true
 			p.Dht.sendFind()
@@ -560,7 +834,9 @@ func (p *PeerToPeer) checkProxies() error {
 	if p.UDPSocket == nil {
 		return fmt.Errorf("checkProxies: nil socket")
 	}
-	p.ProxyManager.check()
+	for _, id := range p.ProxyManager.check() {
+		p.publish(EventProxy, "", map[string]string{"action": "removed", "proxy": id})
+	}
 	// Unlink dead proxies
 	proxies := p.ProxyManager.get()
 	list := []*net.UDPAddr{}
@@ -604,6 +880,7 @@ func (p *PeerToPeer) checkPeers() error {
 			}
 			e.Measure(p.UDPSocket, p.Dht.ID)
 		}
+		peer.checkActivePath(p, time.Now())
 	}
 	return nil
 }
@@ -742,8 +1019,28 @@ func (p *PeerToPeer) WriteToDevice(b []byte, proto uint16, truncated bool) error
 	return nil
 }
 
+// DecryptFailures returns number of received messages that failed to decrypt
+func (p *PeerToPeer) DecryptFailures() uint64 {
+	return atomic.LoadUint64(&p.decryptFailures)
+}
+
+// AddKey adds new crypto key to the instance
+func (p *PeerToPeer) AddKey(key, ttl string) {
+	var newKey CryptoKey
+	newKey = p.Crypter.EnrichKeyValues(newKey, key, ttl)
+	p.Crypter.Keys = append(p.Crypter.Keys, newKey)
+	p.publish(EventKeyChanged, "", map[string]string{"until": newKey.Until.String()})
+}
+
 // SendTo sends a p2p packet by MAC address
 func (p *PeerToPeer) SendTo(dst net.HardwareAddr, msg *P2PMessage) (int, error) {
+	return p.sendFlow(dst, 0, false, msg)
+}
+
+// sendFlow sends message of a flow to the peer. In multipath mode flow
+// selects one of the endpoints. Latency critical messages are sent over
+// two endpoints in duplicate mode
+func (p *PeerToPeer) sendFlow(dst net.HardwareAddr, flow uint32, critical bool, msg *P2PMessage) (int, error) {
 	if p.Swarm == nil {
 		return -1, fmt.Errorf("SendTo: nil peer list")
 	}
@@ -756,16 +1053,44 @@ func (p *PeerToPeer) SendTo(dst net.HardwareAddr, msg *P2PMessage) (int, error)
 	if dst == nil {
 		return -1, fmt.Errorf("SendTo: nil dst")
 	}
-	endpoint, err := p.Swarm.GetEndpoint(dst.String())
-	if err == nil && endpoint != nil {
-		size, err := p.UDPSocket.SendMessage(msg, endpoint)
-		return size, err
+	peer := p.Swarm.GetPeerByMac(dst.String())
+	if peer == nil {
+		return 0, nil
+	}
+	atomic.StoreInt64(&peer.lastSent, time.Now().UnixNano())
+	endpoints := peer.sendPaths(p.Multipath(), flow, critical)
+	out := msg
+	if len(endpoints) > 1 {
+		var err error
+		out, err = p.duplicateMessage(peer, msg)
+		if err != nil {
+			return 0, err
+		}
 	}
-	return 0, nil
+	// Copy sent over another path may still reach the peer, so error is
+	// returned only when every send failed
+	size, failed := 0, 0
+	for _, endpoint := range endpoints {
+		n, err := p.sendToPeer(peer, out, endpoint)
+		if err != nil {
+			if failed++; failed == len(endpoints) {
+				return n, err
+			}
+			continue
+		}
+		size += n
+		peer.Traffic.countTx(peer.pathType(endpoint), endpoint, int(msg.Header.Length))
+	}
+	return size, nil
 }
 
 // Close stops current instance
 func (p *PeerToPeer) Close() error {
+	// Close is called by every TAP reader on error, by Run and by owner of
+	// the instance. Only the first call stops it
+	if !atomic.CompareAndSwapUint32(&p.closed, 0, 1) {
+		return nil
+	}
 	hash := "Unknown hash"
 	if p.Dht != nil {
 		hash = p.Dht.NetworkHash
@@ -773,11 +1098,14 @@ func (p *PeerToPeer) Close() error {
 	Log(Info, "Stopping instance %s", hash)
 	p.deactivateInterface()
 	p.stopPeers()
-	p.Shutdown = true
+	close(p.done())
 	p.stopDHT()
 	p.stopSocket()
 	p.stopInterface()
+	p.stopPipelines()
 	p.ReadyToStop = true
+	p.Callbacks.stopped(hash)
+	p.publish(EventInstanceEnd, "", nil)
 	Log(Info, "Instance %s stopped", hash)
 	return nil
 }
@@ -786,13 +1114,9 @@ func (p *PeerToPeer) deactivateInterface() error {
 	if p.Interface == nil {
 		return fmt.Errorf("nil interface")
 	}
-	for i, ip := range ActiveInterfaces {
-		if ip.Equal(p.Interface.GetIP()) {
-			ActiveInterfaces = append(ActiveInterfaces[:i], ActiveInterfaces[i+1:]...)
-			return nil
-		}
+	if p.registry().Release(p.Interface.GetIP()) == nil {
+		return nil
 	}
-
 	return fmt.Errorf("Interface %s wasn't listed as active", p.Interface.GetName())
 }
 
@@ -805,6 +1129,7 @@ func (p *PeerToPeer) stopInterface() error {
 		Log(Error, "Failed to close TAP interface: %s", err)
 		return err
 	}
+	p.publish(EventIfaceDown, "", p.interfaceDetails())
 	return nil
 }
 
@@ -838,6 +1163,7 @@ func (p *PeerToPeer) stopDHT() error {
 		Log(Error, "Failed to stop DHT: %s", err)
 		return err
 	}
+	p.publish(EventDHT, "", map[string]string{"state": "disconnected"})
 	return nil
 }
 
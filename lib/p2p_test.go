@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -15,7 +16,6 @@ func TestPeerToPeer_AssignInterface(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -78,7 +78,6 @@ func TestPeerToPeer_AssignInterface(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -105,7 +104,6 @@ func TestPeerToPeer_ListenInterface(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -133,7 +131,6 @@ func TestPeerToPeer_ListenInterface(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -160,7 +157,6 @@ func TestPeerToPeer_GenerateDeviceName(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -192,7 +188,6 @@ func TestPeerToPeer_GenerateDeviceName(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -219,7 +214,6 @@ func TestPeerToPeer_IsIPv4(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -251,7 +245,6 @@ func TestPeerToPeer_IsIPv4(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -306,7 +299,6 @@ func TestPeerToPeer_ReadDHT(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -334,7 +326,6 @@ func TestPeerToPeer_ReadDHT(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -361,7 +352,6 @@ func TestPeerToPeer_waitForRemotePort(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -397,7 +387,6 @@ func TestPeerToPeer_waitForRemotePort(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -424,7 +413,6 @@ func TestPeerToPeer_PrepareInterfaces(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -466,7 +454,6 @@ func TestPeerToPeer_PrepareInterfaces(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -493,7 +480,6 @@ func TestPeerToPeer_attemptPortForward(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -527,7 +513,6 @@ func TestPeerToPeer_attemptPortForward(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -554,7 +539,6 @@ func TestPeerToPeer_Init(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -583,7 +567,6 @@ func TestPeerToPeer_Init(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -610,7 +593,6 @@ func TestPeerToPeer_validateMac(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -647,7 +629,6 @@ func TestPeerToPeer_validateMac(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -674,7 +655,6 @@ func TestPeerToPeer_validateInterfaceName(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -708,7 +688,6 @@ func TestPeerToPeer_validateInterfaceName(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -740,7 +719,6 @@ func TestPeerToPeer_setupHandlers(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -769,7 +747,6 @@ func TestPeerToPeer_setupHandlers(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -796,7 +773,6 @@ func TestPeerToPeer_RequestIP(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -832,7 +808,6 @@ func TestPeerToPeer_RequestIP(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -867,7 +842,6 @@ func TestPeerToPeer_ReportIP(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -904,7 +878,6 @@ func TestPeerToPeer_ReportIP(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -939,7 +912,6 @@ func TestPeerToPeer_Run(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -972,7 +944,6 @@ func TestPeerToPeer_Run(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -999,7 +970,6 @@ func TestPeerToPeer_checkLastDHTUpdate(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1046,7 +1016,6 @@ func TestPeerToPeer_checkLastDHTUpdate(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1073,7 +1042,6 @@ func TestPeerToPeer_removeStoppedPeers(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1121,7 +1089,6 @@ func TestPeerToPeer_removeStoppedPeers(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1148,7 +1115,6 @@ func TestPeerToPeer_checkProxies(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1197,7 +1163,6 @@ func TestPeerToPeer_checkProxies(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1224,7 +1189,6 @@ func TestPeerToPeer_checkPeers(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1277,7 +1241,6 @@ func TestPeerToPeer_checkPeers(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1304,7 +1267,6 @@ func TestPeerToPeer_PrepareIntroductionMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1345,7 +1307,6 @@ func TestPeerToPeer_PrepareIntroductionMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1377,7 +1338,6 @@ func TestPeerToPeer_WriteToDevice(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1420,7 +1380,6 @@ func TestPeerToPeer_WriteToDevice(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1447,7 +1406,6 @@ func TestPeerToPeer_SendTo(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1504,7 +1462,6 @@ func TestPeerToPeer_SendTo(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1536,7 +1493,6 @@ func TestPeerToPeer_Close(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1566,7 +1522,6 @@ func TestPeerToPeer_Close(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1587,13 +1542,31 @@ func TestPeerToPeer_Close(t *testing.T) {
 	}
 }
 
+func TestPeerToPeer_CloseOnce(t *testing.T) {
+	var stopped int32
+	p := &PeerToPeer{Callbacks: Callbacks{Stopped: func(hash string) {
+		atomic.AddInt32(&stopped, 1)
+	}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Close()
+		}()
+	}
+	wg.Wait()
+	if stopped != 1 || !p.IsShutdown() {
+		t.Errorf("Instance stopped %d times", stopped)
+	}
+}
+
 func TestPeerToPeer_deactivateInterface(t *testing.T) {
 	type fields struct {
 		UDPSocket       *Network
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1609,7 +1582,7 @@ func TestPeerToPeer_deactivateInterface(t *testing.T) {
 	}
 	inf0, _ := newTAP("ip", "192.168.0.1", "00:11:22:33:44:55", "255.255.255.0", 1500, false)
 	inf1, _ := newTAP("ip", "192.168.0.2", "00:11:22:33:44:55", "255.255.255.0", 1500, false)
-	ActiveInterfaces = append(ActiveInterfaces, net.ParseIP("192.168.0.2"))
+	ActiveInterfaces.Reserve(net.ParseIP("192.168.0.2"))
 	tests := []struct {
 		name    string
 		fields  fields
@@ -1626,7 +1599,6 @@ func TestPeerToPeer_deactivateInterface(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1653,7 +1625,6 @@ func TestPeerToPeer_stopInterface(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1687,7 +1658,6 @@ func TestPeerToPeer_stopInterface(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1714,7 +1684,6 @@ func TestPeerToPeer_stopPeers(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1750,7 +1719,6 @@ func TestPeerToPeer_stopPeers(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1777,7 +1745,6 @@ func TestPeerToPeer_stopDHT(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1806,7 +1773,6 @@ func TestPeerToPeer_stopDHT(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -1833,7 +1799,6 @@ func TestPeerToPeer_stopSocket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1862,7 +1827,6 @@ func TestPeerToPeer_stopSocket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -116,7 +116,7 @@ func (p *PeerToPeer) handlePacket(contents []byte, proto int) error {
 	if exists {
 		return callback(contents, proto)
 	}
-	Log(Warning, "Captured undefined packet: %d", PacketType(proto))
+	p.logger(SubsystemPacket).Limited(Warning, "Captured undefined packet: %d", PacketType(proto))
 	return fmt.Errorf("Captured undefined packet: %d", PacketType(proto))
 }
 
@@ -130,10 +130,17 @@ func (p *PeerToPeer) handlePacketIPv4(contents []byte, proto int) error {
 	if f.EtherType != ethernet.EtherTypeIPv4 {
 		return fmt.Errorf("Wrong packet type in IPv4 handler. Got %d. Expecting %d", f.EtherType, ethernet.EtherTypeIPv4)
 	}
+	var peer *NetworkPeer
+	if p.Swarm != nil {
+		peer = p.Swarm.GetPeerByMac(f.Destination.String())
+	}
+	if peer != nil && p.Interface != nil && p.Interface.IsPMTUEnabled() && p.clampFrame(contents, peer) {
+		return nil
+	}
 
-	msg, err := p.CreateMessage(MsgTypeNenc, contents, uint16(proto), true)
+	msg, err := p.createFrameMessage(peer, contents, uint16(proto))
 	if err == nil && msg != nil {
-		_, err = p.SendTo(f.Destination, msg)
+		_, err = p.sendFlow(f.Destination, flowHash(contents), p.latencyCritical(contents), msg)
 		return err
 	}
 	return err
@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,10 +20,12 @@ func (p *PeerToPeer) HandleP2PMessage(count int, srcAddr *net.UDPAddr, err error
 		Log(Error, "P2P Message Handle: %v", err)
 		return err
 	}
-	buf := make([]byte, count)
-	copy(buf[:], rcvBytes[:])
-
-	msg, desErr := P2PMessageFromBytes(buf)
+	if count > len(rcvBytes) {
+		count = len(rcvBytes)
+	}
+	// Message data is copied by P2PMessageFromBytes, so buffer may be
+	// reused after return
+	msg, desErr := P2PMessageFromBytes(rcvBytes[:count])
 	if desErr != nil {
 		Log(Error, "P2PMessageFromBytes error: %v", desErr)
 		return fmt.Errorf("Failed to unmarshal message: %s", desErr.Error())
@@ -32,10 +35,11 @@ func (p *PeerToPeer) HandleP2PMessage(count int, srcAddr *net.UDPAddr, err error
 		return fmt.Errorf("Broken P2P message")
 	}
 	// Decrypt message if crypter is active
-	if p.Crypter.Active && (msg.Header.Type == MsgTypeIntro || msg.Header.Type == MsgTypeNenc || msg.Header.Type == MsgTypeIntroReq || msg.Header.Type == MsgTypeTest || msg.Header.Type == MsgTypeXpeerPing || msg.Header.Type == MsgTypeComm) {
+	if p.Crypter.Active && (msg.Header.Type == MsgTypeIntro || msg.Header.Type == MsgTypeNenc || msg.Header.Type == MsgTypeIntroReq || msg.Header.Type == MsgTypeTest || msg.Header.Type == MsgTypeXpeerPing || msg.Header.Type == MsgTypeComm || msg.Header.Type == MsgTypeGSO || msg.Header.Type == MsgTypeCompressed) {
 		var decErr error
 		msg.Data, decErr = p.Crypter.decrypt(p.Crypter.ActiveKey.Key, msg.Data)
 		if decErr != nil {
+			atomic.AddUint64(&p.decryptFailures, 1)
 			Log(Error, "Failed to decrypt message: %s", decErr)
 			return fmt.Errorf("Failed to decrypt message: %s", decErr)
 		}
@@ -62,10 +66,24 @@ func (p *PeerToPeer) HandleNotEncryptedMessage(msg *P2PMessage, srcAddr *net.UDP
 		return fmt.Errorf("nil source addr")
 	}
 	Log(Trace, "Data: %s, From: %s", msg.Data, srcAddr.String())
+	p.countRx(msg, srcAddr)
 	p.WriteToDevice(msg.Data, msg.Header.NetProto, false)
 	return nil
 }
 
+// countRx accounts received frame on the peer identified by source
+// hardware address of the frame
+func (p *PeerToPeer) countRx(msg *P2PMessage, srcAddr *net.UDPAddr) {
+	if p.Swarm == nil || len(msg.Data) < 12 {
+		return
+	}
+	peer := p.Swarm.GetPeerByMac(net.HardwareAddr(msg.Data[6:12]).String())
+	if peer == nil {
+		return
+	}
+	peer.Traffic.countRx(peer.pathType(srcAddr), srcAddr, int(msg.Header.Length))
+}
+
 // HandlePingMessage is a PING message from a proxy handler
 func (p *PeerToPeer) HandlePingMessage(msg *P2PMessage, srcAddr *net.UDPAddr) error {
 	if msg == nil {
@@ -127,8 +145,9 @@ func (p *PeerToPeer) HandleXpeerPingMessage(msg *P2PMessage, srcAddr *net.UDPAdd
 			return fmt.Errorf("payload length is too small for xpeer ping query")
 		}
 		id := string(msg.Data)[1:37]
-		endpoint := string(msg.Data)[37:]
+		endpoint, seq := splitPing(msg.Data[37:])
 		response := append([]byte("r"), []byte(endpoint)...)
+		response = append(response, seq...)
 
 		msg, err := p.CreateMessage(MsgTypeXpeerPing, response, 0, true)
 		if err != nil {
@@ -165,14 +184,17 @@ func (p *PeerToPeer) HandleXpeerPingMessage(msg *P2PMessage, srcAddr *net.UDPAdd
 		Log(Debug, "Received ping from unknown endpoint: %s [%s ID: %s]", srcAddr.String(), endpoint, id)
 		return fmt.Errorf("Received ping from unknown endpoint: %s [%s ID: %s]", srcAddr.String(), endpoint, id)
 	} else if query == "r" {
-		endpoint := msg.Data[1:]
+		endpoint, seq := splitPing(msg.Data[1:])
 		for _, peer := range p.Swarm.Get() {
 			if peer == nil {
 				continue
 			}
 			for i, ep := range peer.EndpointsHeap {
-				if ep.Addr.String() == string(endpoint) {
+				if ep.Addr.String() == endpoint {
 					peer.EndpointsHeap[i].LastContact = time.Now()
+					if seq != nil {
+						ep.quality.received(binary.BigEndian.Uint32(seq[1:5]), time.Now())
+					}
 					return nil
 				}
 			}
@@ -275,29 +297,39 @@ func (p *PeerToPeer) HandleIntroRequestMessage(msg *P2PMessage, srcAddr *net.UDP
 		Log(Error, "Failed to prepare intro message: %s", err.Error())
 		return fmt.Errorf("Failed to prepare introduction message: %s", err.Error())
 	}
-	eps := []*net.UDPAddr{}
-	eps = append(eps, peer.KnownIPs...)
-	eps = append(eps, peer.Proxies...)
 	Log(Debug, "Sending handshake response")
 
-	srcFound := false
-	for _, ep := range eps {
-		if ep.String() == srcAddr.String() {
-			srcFound = true
+	// Source of the request is answered right away. Other endpoints are
+	// answered with a pause between them outside of packet worker, so
+	// handshakes don't delay traffic of other peers
+	_, err = p.UDPSocket.SendMessage(response, srcAddr)
+	if err != nil {
+		Log(Error, "Failed to respond to introduction request: %s", err.Error())
+		return fmt.Errorf("Failed to response to introduction reuqest: %s", err.Error())
+	}
+	eps := []*net.UDPAddr{}
+	for _, ep := range append(append([]*net.UDPAddr{}, peer.KnownIPs...), peer.Proxies...) {
+		if ep.String() != srcAddr.String() {
+			eps = append(eps, ep)
 		}
 	}
-	if !srcFound {
-		eps = append(eps, srcAddr)
+	if len(eps) == 0 {
+		return nil
 	}
-
-	for _, ep := range eps {
-		time.Sleep(time.Millisecond * 10)
-		_, err := p.UDPSocket.SendMessage(response, ep)
-		if err != nil {
-			Log(Error, "Failed to respond to introduction request: %s", err.Error())
-			return fmt.Errorf("Failed to response to introduction reuqest: %s", err.Error())
+	p.spawn(func() {
+		for _, ep := range eps {
+			select {
+			case <-p.done():
+				return
+			case <-time.After(time.Millisecond * 10):
+			}
+			_, err := p.UDPSocket.SendMessage(response, ep)
+			if err != nil {
+				Log(Error, "Failed to respond to introduction request: %s", err.Error())
+				return
+			}
 		}
-	}
+	})
 	return nil
 }
 
@@ -323,6 +355,11 @@ func (p *PeerToPeer) HandleProxyMessage(msg *P2PMessage, srcAddr *net.UDPAddr) e
 	rc := p.ProxyManager.activate(srcAddr.String(), ep)
 	if rc {
 		Log(Debug, "This peer is now available over %s", ep.String())
+		p.publish(EventProxy, "", map[string]string{
+			"action":   "activated",
+			"proxy":    srcAddr.String(),
+			"endpoint": ep.String(),
+		})
 		return nil
 	}
 	return fmt.Errorf("Failed to activate proxy %s", ep.String())
@@ -357,8 +394,7 @@ func (p *PeerToPeer) HandleLatency(msg *P2PMessage, srcAddr *net.UDPAddr) error
 	if bytes.Equal(msg.Data[:4], LatencyProxyHeader) {
 		// This is a response from proxy
 
-		ts := time.Time{}
-		err := ts.UnmarshalBinary(msg.Data[4:])
+		ts, seq, hasSeq, err := latencyTimestamp(msg.Data[4:])
 		if err != nil {
 			Log(Error, "Failed to unmarshal latency packet from %s: %s", srcAddr.String(), err.Error())
 			return fmt.Errorf("Failed to unmarshal latency from %s: %s", srcAddr.String(), err.Error())
@@ -369,6 +405,9 @@ func (p *PeerToPeer) HandleLatency(msg *P2PMessage, srcAddr *net.UDPAddr) error
 			Log(Error, "Couldn't set latency for proxy: %s", srcAddr)
 			return fmt.Errorf("Failed to set latency for proxy %s", srcAddr.String())
 		}
+		if hasSeq {
+			p.ProxyManager.latencyReceived(srcAddr, seq)
+		}
 		return nil
 	} else if bytes.Equal(msg.Data[:4], LatencyRequestHeader) {
 		// This is a request of latency from endpoint
@@ -418,8 +457,7 @@ func (p *PeerToPeer) HandleLatency(msg *P2PMessage, srcAddr *net.UDPAddr) error
 			return fmt.Errorf("malformed latency packet: broken address")
 		}
 
-		ts := time.Time{}
-		err = ts.UnmarshalBinary(msg.Data[46:])
+		ts, seq, hasSeq, err := latencyTimestamp(msg.Data[46:])
 		if err != nil {
 			Log(Error, "Failed to unmarshal latency packet from %s: %s", srcAddr.String(), err.Error())
 			return fmt.Errorf("failed to unmarshal latency packet from %s: %s", srcAddr.String(), err.Error())
@@ -430,6 +468,9 @@ func (p *PeerToPeer) HandleLatency(msg *P2PMessage, srcAddr *net.UDPAddr) error
 			for i, ep := range peer.EndpointsHeap {
 				if ep.Addr.String() == addr.String() {
 					peer.EndpointsHeap[i].Latency = latency
+					if hasSeq {
+						ep.quality.received(seq, time.Now())
+					}
 					return nil
 				}
 			}
@@ -491,6 +532,11 @@ func (p *PeerToPeer) HandleComm(msg *P2PMessage, srcAddr *net.UDPAddr) error {
 		if err != nil {
 			return err
 		}
+	case CommCapabilities:
+		response, err = commCapabilitiesHandler(data, p)
+		if err != nil {
+			return err
+		}
 	default:
 		Log(Error, "Unknown communication packet: %d", commType)
 		return fmt.Errorf("unknown comm type")
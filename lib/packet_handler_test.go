@@ -14,7 +14,6 @@ func TestPeerToPeer_HandleP2PMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -90,7 +89,6 @@ func TestPeerToPeer_HandleP2PMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -117,7 +115,6 @@ func TestPeerToPeer_HandleNotEncryptedMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -157,7 +154,6 @@ func TestPeerToPeer_HandleNotEncryptedMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -184,7 +180,6 @@ func TestPeerToPeer_HandlePingMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -253,7 +248,6 @@ func TestPeerToPeer_HandlePingMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -280,7 +274,6 @@ func TestPeerToPeer_HandleXpeerPingMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -381,7 +374,6 @@ func TestPeerToPeer_HandleXpeerPingMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -408,7 +400,6 @@ func TestPeerToPeer_HandleIntroMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -488,7 +479,6 @@ func TestPeerToPeer_HandleIntroMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -515,7 +505,6 @@ func TestPeerToPeer_HandleIntroRequestMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -588,7 +577,6 @@ func TestPeerToPeer_HandleIntroRequestMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -615,7 +603,6 @@ func TestPeerToPeer_HandleProxyMessage(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -671,7 +658,6 @@ func TestPeerToPeer_HandleProxyMessage(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -698,7 +684,6 @@ func TestPeerToPeer_HandleBadTun(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -731,7 +716,6 @@ func TestPeerToPeer_HandleBadTun(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -758,7 +742,6 @@ func TestPeerToPeer_HandleLatency(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -900,7 +883,6 @@ func TestPeerToPeer_HandleLatency(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -927,7 +909,6 @@ func TestPeerToPeer_HandleComm(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -1020,7 +1001,6 @@ func TestPeerToPeer_HandleComm(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
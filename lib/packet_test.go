@@ -18,7 +18,6 @@ func TestPeerToPeer_handlePacket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -56,7 +55,6 @@ func TestPeerToPeer_handlePacket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -83,7 +81,6 @@ func TestPeerToPeer_handlePacketIPv4(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -133,7 +130,6 @@ func TestPeerToPeer_handlePacketIPv4(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -160,7 +156,6 @@ func TestPeerToPeer_handlePacketIPv6(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -193,7 +188,6 @@ func TestPeerToPeer_handlePacketIPv6(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -220,7 +214,6 @@ func TestPeerToPeer_handlePARCUniversalPacket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -253,7 +246,6 @@ func TestPeerToPeer_handlePARCUniversalPacket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -280,7 +272,6 @@ func TestPeerToPeer_handleRARPPacket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -313,7 +304,6 @@ func TestPeerToPeer_handleRARPPacket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -340,7 +330,6 @@ func TestPeerToPeer_handle8021qPacket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -373,7 +362,6 @@ func TestPeerToPeer_handle8021qPacket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -400,7 +388,6 @@ func TestPeerToPeer_handlePPPoEDiscoveryPacket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -433,7 +420,6 @@ func TestPeerToPeer_handlePPPoEDiscoveryPacket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -460,7 +446,6 @@ func TestPeerToPeer_handlePPPoESessionPacket(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -493,7 +478,6 @@ func TestPeerToPeer_handlePPPoESessionPacket(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -520,7 +504,6 @@ func TestPeerToPeer_handlePacketARP(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -598,7 +581,6 @@ func TestPeerToPeer_handlePacketARP(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -625,7 +607,6 @@ func TestPeerToPeer_handlePacketLLDP(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -658,7 +639,6 @@ func TestPeerToPeer_handlePacketLLDP(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
@@ -0,0 +1,216 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Endpoints of a peer are ranked by score: smoothed round-trip time of
+// pings and latency requests plus penalties for jitter, loss and kind of
+// path. Lower is better. Active endpoint is replaced only by a noticeably
+// better one, and not more often than scoreHoldTime, unless it stops
+// answering
+
+// Path scoring
+const (
+	scoreJitterFactor = 2                      // Jitter is counted twice
+	scoreLossPenalty  = time.Millisecond * 500 // Added for 100% loss
+	scoreUnknownRTT   = time.Millisecond * 250 // RTT of endpoints not measured yet
+	scoreSwitchRatio  = 0.8                    // Better endpoint must have at most this share of active one's score
+	scoreSwitchMin    = time.Millisecond * 5   // and be better at least by this value
+	scoreHoldTime     = time.Second * 10       // Minimal time between switches to a better endpoint
+	fastPingInterval  = time.Millisecond * 200 // Ping interval of active endpoint while traffic flows
+	fastPingTraffic   = time.Second            // Traffic flows when frames were sent this recently
+	pathDeadTimeout   = time.Second            // Active endpoint without replies for this long is dead
+	pingLossWeight    = 0.125                  // Weight of a single ping in smoothed loss
+)
+
+// pathCost is a penalty of a kind of path. Direct paths are preferred
+// over proxies and local ones over internet
+func pathCost(path PathType) time.Duration {
+	switch path {
+	case PathLAN:
+		return 0
+	case PathInternet:
+		return time.Millisecond * 10
+	}
+	return time.Millisecond * 100
+}
+
+// PathQuality is a snapshot of smoothed ping statistics of an endpoint
+type PathQuality struct {
+	RTT     time.Duration
+	Jitter  time.Duration
+	Loss    float64
+	Samples int
+}
+
+// Quality returns ping statistics of the endpoint
+func (e *Endpoint) Quality() PathQuality {
+	return e.quality.quality()
+}
+
+// Stats returns round-trip time, jitter and loss of recent pings and
+// latency requests sent to the endpoint
+func (e *Endpoint) Stats() LatencyStats {
+	return e.quality.stats(time.Now())
+}
+
+// score ranks endpoint reached over the path. Lower is better. Latency
+// measurement is used until pings or latency requests are answered
+func (e *Endpoint) score(path PathType) time.Duration {
+	q := e.Quality()
+	rtt := q.RTT
+	if q.Samples == 0 {
+		rtt = e.Latency
+		if rtt <= 0 {
+			rtt = scoreUnknownRTT
+		}
+	}
+	return rtt + scoreJitterFactor*q.Jitter + time.Duration(q.Loss*float64(scoreLossPenalty)) + pathCost(path)
+}
+
+// EndpointScore returns score of the peer's endpoint. Lower is better
+func (np *NetworkPeer) EndpointScore(ep *Endpoint) time.Duration {
+	return ep.score(np.pathType(ep.Addr))
+}
+
+// alive returns whether endpoint may be used
+func (e *Endpoint) alive(now time.Time) bool {
+	return now.Sub(e.LastContact) <= EndpointTimeout && !e.failedAt.After(e.LastContact)
+}
+
+// pingPayload appends sequence number to ping payload. Peers without
+// sequence numbers get payload of the old format
+func pingPayload(payload []byte, seq uint32) []byte {
+	trailer := make([]byte, 5)
+	binary.BigEndian.PutUint32(trailer[1:5], seq)
+	return append(payload, trailer...)
+}
+
+// splitPing splits endpoint address of a ping from the sequence number
+// trailer, if any
+func splitPing(data []byte) (string, []byte) {
+	if len(data) >= 5 && data[len(data)-5] == 0 {
+		return string(data[:len(data)-5]), data[len(data)-5:]
+	}
+	return string(data), nil
+}
+
+// rankEndpoints orders endpoints by score, best first
+func (np *NetworkPeer) rankEndpoints(endpoints []*Endpoint) {
+	scores := make(map[*Endpoint]time.Duration, len(endpoints))
+	for _, ep := range endpoints {
+		scores[ep] = ep.score(np.pathType(ep.Addr))
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return scores[endpoints[i]] < scores[endpoints[j]]
+	})
+}
+
+// selectEndpoint makes the best endpoint active, if it's better enough
+// than the current one or the current one is unusable. Returns previous
+// and new active endpoints
+func (np *NetworkPeer) selectEndpoint(now time.Time) (*Endpoint, *Endpoint) {
+	np.Lock.Lock()
+	defer np.Lock.Unlock()
+	var current, best *Endpoint
+	var currentScore, bestScore time.Duration
+	for _, ep := range np.EndpointsHeap {
+		if ep == nil || ep.Addr == nil || !ep.alive(now) {
+			continue
+		}
+		score := ep.score(np.pathType(ep.Addr))
+		if np.Endpoint != nil && ep.Addr.String() == np.Endpoint.String() {
+			current, currentScore = ep, score
+		}
+		if best == nil || score < bestScore {
+			best, bestScore = ep, score
+		}
+	}
+	if best == nil || best == current {
+		return current, current
+	}
+	if current != nil {
+		if now.Sub(np.routeChanged) < scoreHoldTime {
+			return current, current
+		}
+		if float64(bestScore) > float64(currentScore)*scoreSwitchRatio || currentScore-bestScore < scoreSwitchMin {
+			return current, current
+		}
+	}
+	np.Endpoint = best.Addr
+	np.routeChanged = now
+	return current, best
+}
+
+// checkActivePath pings active endpoint often while frames are sent to
+// the peer. When it stops answering, traffic is moved to another endpoint
+// right away instead of waiting for EndpointTimeout
+func (np *NetworkPeer) checkActivePath(ptpc *PeerToPeer, now time.Time) {
+	if np.State != PeerStateConnected || ptpc.Dht == nil || ptpc.UDPSocket == nil {
+		return
+	}
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&np.lastSent))) > fastPingTraffic {
+		np.probed = nil
+		return
+	}
+	ep := np.activeEndpoint()
+	if ep == nil {
+		return
+	}
+	if np.probed != ep {
+		np.probed, np.probedSince = ep, now
+	}
+	if now.Sub(ep.LastPing) >= fastPingInterval {
+		ep.ping(ptpc, ptpc.Dht.ID, np.Caps()&CapPingSeq != 0)
+	}
+	alive := ep.LastContact
+	if np.probedSince.After(alive) {
+		alive = np.probedSince
+	}
+	if now.Sub(alive) <= pathDeadTimeout {
+		return
+	}
+	np.Lock.Lock()
+	ep.failedAt = now
+	np.Lock.Unlock()
+	previous, active := np.selectEndpoint(now)
+	if active == nil || active == previous {
+		np.logger(ptpc).Log(Debug, "Endpoint %s stopped answering, no other endpoint to use", ep.Addr)
+		return
+	}
+	np.logger(ptpc).Log(Info, "Endpoint %s stopped answering, switching to %s", ep.Addr, active.Addr)
+	np.publishRoute(ptpc, ep.Addr, active.Addr)
+}
+
+// activeEndpoint returns active endpoint of the peer. It runs on instance
+// goroutine, so active endpoint is read under the lock
+func (np *NetworkPeer) activeEndpoint() *Endpoint {
+	np.Lock.RLock()
+	defer np.Lock.RUnlock()
+	if np.Endpoint == nil {
+		return nil
+	}
+	for _, ep := range np.EndpointsHeap {
+		if ep != nil && ep.Addr != nil && ep.Addr.String() == np.Endpoint.String() {
+			return ep
+		}
+	}
+	return nil
+}
+
+// getEndpoint returns endpoint with the address
+func (np *NetworkPeer) getEndpoint(addr *net.UDPAddr) *Endpoint {
+	np.Lock.RLock()
+	defer np.Lock.RUnlock()
+	for _, ep := range np.EndpointsHeap {
+		if ep != nil && ep.Addr != nil && ep.Addr.String() == addr.String() {
+			return ep
+		}
+	}
+	return nil
+}
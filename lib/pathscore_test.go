@@ -0,0 +1,104 @@
+package ptp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPingPayload(t *testing.T) {
+	addr, seq := splitPing(pingPayload([]byte("192.168.0.1:1234"), 7))
+	if addr != "192.168.0.1:1234" || len(seq) != 5 || seq[4] != 7 {
+		t.Errorf("splitPing() = %q, %v", addr, seq)
+	}
+	addr, seq = splitPing([]byte("192.168.0.1:1234"))
+	if addr != "192.168.0.1:1234" || seq != nil {
+		t.Errorf("splitPing() of old ping = %q, %v", addr, seq)
+	}
+}
+
+// measuredEndpoint returns endpoint with the RTT measured by pings
+func measuredEndpoint(addr string, rtt time.Duration) *Endpoint {
+	ep := &Endpoint{LastContact: time.Now()}
+	ep.Addr, _ = net.ResolveUDPAddr("udp4", addr)
+	if rtt > 0 {
+		now := time.Now()
+		for i := 0; i < 8; i++ {
+			ep.quality.received(ep.quality.sent(now), now.Add(rtt))
+		}
+	}
+	return ep
+}
+
+func TestNetworkPeer_selectEndpoint(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		endpoints []*Endpoint
+		active    int
+		changed   time.Time
+		want      int
+	}{
+		{"unmeasured lan first", []*Endpoint{measuredEndpoint("8.8.8.8:1", 0), measuredEndpoint("192.168.0.1:1", 0)}, -1, time.Time{}, 1},
+		{"measured internet over unmeasured lan", []*Endpoint{measuredEndpoint("192.168.0.1:1", 0), measuredEndpoint("8.8.8.8:1", 30*time.Millisecond)}, -1, time.Time{}, 1},
+		{"slightly better", []*Endpoint{measuredEndpoint("8.8.8.8:1", 40*time.Millisecond), measuredEndpoint("8.8.4.4:1", 36*time.Millisecond)}, 0, time.Time{}, 0},
+		{"much better", []*Endpoint{measuredEndpoint("8.8.8.8:1", 80*time.Millisecond), measuredEndpoint("8.8.4.4:1", 20*time.Millisecond)}, 0, time.Time{}, 1},
+		{"hold time", []*Endpoint{measuredEndpoint("8.8.8.8:1", 80*time.Millisecond), measuredEndpoint("8.8.4.4:1", 20*time.Millisecond)}, 0, now, 0},
+		{"failed active", []*Endpoint{{LastContact: now.Add(-time.Second), failedAt: now}, measuredEndpoint("8.8.4.4:1", 200*time.Millisecond)}, 0, now, 1},
+	}
+	tests[5].endpoints[0].Addr, _ = net.ResolveUDPAddr("udp4", "192.168.0.1:1")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			np := &NetworkPeer{EndpointsHeap: tt.endpoints, routeChanged: tt.changed}
+			if tt.active >= 0 {
+				np.Endpoint = tt.endpoints[tt.active].Addr
+			}
+			np.selectEndpoint(now)
+			if np.Endpoint != tt.endpoints[tt.want].Addr {
+				t.Errorf("Active endpoint = %v, want %v", np.Endpoint, tt.endpoints[tt.want].Addr)
+			}
+		})
+	}
+}
+
+func TestNetworkPeer_checkActivePath(t *testing.T) {
+	sender, receiver := loopbackPair(t)
+	defer sender.Close()
+	defer receiver.Close()
+
+	p := &PeerToPeer{UDPSocket: sender, Dht: &DHTClient{ID: "123e4567-e89b-12d3-a456-426655440000"}}
+	active := measuredEndpoint("192.168.0.1:1", 10*time.Millisecond)
+	active.Addr = loopbackAddr(receiver)
+	backup := measuredEndpoint("8.8.8.8:1", 50*time.Millisecond)
+	np := &NetworkPeer{State: PeerStateConnected, Endpoint: active.Addr, EndpointsHeap: []*Endpoint{active, backup}}
+	np.setCaps(CapPingSeq)
+
+	// No traffic, no fast pings
+	now := time.Now()
+	np.checkActivePath(p, now)
+	if !active.LastPing.IsZero() {
+		t.Errorf("Endpoint was pinged without traffic")
+	}
+
+	np.lastSent = now.UnixNano()
+	np.checkActivePath(p, now)
+	if active.LastPing.IsZero() {
+		t.Fatalf("Endpoint wasn't pinged while traffic flows")
+	}
+	np.checkActivePath(p, now.Add(pathDeadTimeout/2))
+	if np.Endpoint != active.Addr {
+		t.Fatalf("Switched before endpoint was considered dead")
+	}
+	np.lastSent = now.Add(pathDeadTimeout).UnixNano()
+	np.checkActivePath(p, now.Add(pathDeadTimeout+time.Millisecond))
+	if np.Endpoint != backup.Addr {
+		t.Errorf("Active endpoint = %v after it stopped answering", np.Endpoint)
+	}
+	if active.alive(now.Add(pathDeadTimeout + time.Millisecond)) {
+		t.Errorf("Failed endpoint is alive")
+	}
+	active.LastContact = now.Add(2 * pathDeadTimeout)
+	if !active.alive(active.LastContact) {
+		t.Errorf("Endpoint is not alive after reply")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,6 +14,8 @@ type StateHandlerCallback func(ptpc *PeerToPeer) error
 
 // NetworkPeer represents a peer
 type NetworkPeer struct {
+	compression        CompressionStats                   // Must be first to keep 64-bit alignment
+	lastSent           int64                              // Unix time of the last message sent, in nanoseconds
 	ID                 string                             // ID of a peer
 	Endpoint           *net.UDPAddr                       // Endpoint address of a peer. TODO: Make this net.UDPAddr
 	KnownIPs           []*net.UDPAddr                     // List of IP addresses that accepts connection on peer
@@ -34,6 +37,26 @@ type NetworkPeer struct {
 	LastPunch          time.Time                          // Last time we run hole punch
 	Stat               PeerStats                          // Peer statistics
 	RoutingRequired    bool                               // Whether or not routing is required
+	Traffic            PeerTraffic                        // Data plane traffic counters
+	caps               uint32                             // Capabilities received from the peer
+	codec              uint32                             // Compression codec accepted by the peer
+	capsRequests       uint8                              // Number of capability requests sent
+	capsRequested      time.Time                          // Last time capabilities were sent
+	pathMTU            uint32                             // Smallest path MTU of endpoints in use
+	paths              atomic.Value                       // Endpoints used in multipath mode
+	duplicateSeq       uint32                             // Sequence number of the last duplicated message
+	routeChanged       time.Time                          // Last time active endpoint was changed
+	probed             *Endpoint                          // Active endpoint pinged fast while traffic flows
+	probedSince        time.Time                          // Time when fast pings of probed endpoint started
+}
+
+// logger returns a logger with fields of the peer
+func (np *NetworkPeer) logger(ptpc *PeerToPeer) *Logger {
+	l := ptpc.logger(SubsystemPeer).With(FieldPeer, np.ID)
+	if np.Endpoint != nil {
+		l = l.With(FieldEndpoint, np.Endpoint.String())
+	}
+	return l
 }
 
 func (np *NetworkPeer) reportState(ptpc *PeerToPeer) error {
@@ -41,7 +64,7 @@ func (np *NetworkPeer) reportState(ptpc *PeerToPeer) error {
 		return fmt.Errorf("reportState: nil ptp")
 	}
 	stateStr := strconv.Itoa(int(np.State))
-	Log(Trace, "Reporting state %s to %s", StringifyState(np.State), np.ID)
+	np.logger(ptpc).Log(Trace, "Reporting state %s to %s", StringifyState(np.State), np.ID)
 	ptpc.Dht.sendState(np.ID, stateStr)
 	return nil
 }
@@ -54,14 +77,54 @@ func (np *NetworkPeer) SetState(state PeerState, ptpc *PeerToPeer) error {
 	if ptpc.Dht == nil {
 		return fmt.Errorf("nil dht")
 	}
+	previous := np.State
 	if state != np.State {
-		Log(Debug, "Peer %s changed state from %s to %s", np.ID, StringifyState(np.State), StringifyState(state))
+		np.logger(ptpc).Log(Debug, "Peer %s changed state from %s to %s", np.ID, StringifyState(np.State), StringifyState(state))
 	}
 	np.State = state
+	if state != previous {
+		ptpc.Callbacks.peerStateChanged(np.ID, previous, state)
+		ptpc.publish(EventPeerState, np.ID, map[string]string{
+			"from":     StringifyState(previous),
+			"to":       StringifyState(state),
+			"ip":       ipToString(np.PeerLocalIP),
+			"mac":      np.PeerHW.String(),
+			"endpoint": addrToString(np.Endpoint),
+			"path":     np.EndpointPath(),
+		})
+	}
 	np.reportState(ptpc)
 	return nil
 }
 
+// EndpointPath returns "proxy" when active endpoint belongs to a proxy
+// server, "direct" for any other endpoint and an empty string when peer
+// has no active endpoint
+func (np *NetworkPeer) EndpointPath() string {
+	if np.Endpoint == nil {
+		return ""
+	}
+	for _, proxy := range np.Proxies {
+		if proxy != nil && proxy.String() == np.Endpoint.String() {
+			return "proxy"
+		}
+	}
+	return "direct"
+}
+
+// pathType returns kind of path that leads to the peer over specified endpoint
+func (np *NetworkPeer) pathType(endpoint *net.UDPAddr) PathType {
+	for _, proxy := range np.Proxies {
+		if proxy != nil && proxy.String() == endpoint.String() {
+			return PathProxy
+		}
+	}
+	if rc, err := isPrivateIP(endpoint.IP); err == nil && rc {
+		return PathLAN
+	}
+	return PathInternet
+}
+
 // NetworkPeerState represents a state for remote peers
 type NetworkPeerState struct {
 	ID    string // Peer's ID
@@ -98,7 +161,7 @@ func (np *NetworkPeer) Run(ptpc *PeerToPeer) error {
 
 	for {
 		if np.State == PeerStateStop {
-			Log(Debug, "Stopping peer %s", np.ID)
+			np.logger(ptpc).Log(Debug, "Stopping peer %s", np.ID)
 			break
 		}
 		if ptpc.Dht.ID == "" {
@@ -112,17 +175,17 @@ func (np *NetworkPeer) Run(ptpc *PeerToPeer) error {
 
 		callback, exists := np.handlers[np.State]
 		if !exists {
-			Log(Error, "Peer %s is in unknown state: %d", np.ID, int(np.State))
+			np.logger(ptpc).Log(Error, "Peer %s is in unknown state: %d", np.ID, int(np.State))
 			time.Sleep(1 * time.Second)
 			continue
 		}
 		err := callback(ptpc)
 		if err != nil {
-			Log(Warning, "Peer %s: %v", np.ID, err)
+			np.logger(ptpc).Log(Warning, "Peer %s: %v", np.ID, err)
 		}
 		time.Sleep(time.Millisecond * 500)
 	}
-	Log(Info, "Peer %s has been stopped", np.ID)
+	np.logger(ptpc).Log(Info, "Peer %s has been stopped", np.ID)
 	return nil
 }
 
@@ -135,7 +198,7 @@ func (np *NetworkPeer) stateInit(ptpc *PeerToPeer) error {
 		return fmt.Errorf("nil ptp")
 	}
 	// Send request about IPs of a peer
-	Log(Debug, "Initializing new peer: %s", np.ID)
+	np.logger(ptpc).Log(Debug, "Initializing new peer: %s", np.ID)
 	ptpc.Dht.sendNode(np.ID, []net.IP{})
 	np.Endpoint = nil
 	np.PeerHW = nil
@@ -164,13 +227,13 @@ func (np *NetworkPeer) stateRequestedIP(ptpc *PeerToPeer) error {
 	if ptpc.Dht == nil {
 		return fmt.Errorf("nil dht")
 	}
-	Log(Debug, "Waiting network addresses for peer: %s", np.ID)
+	np.logger(ptpc).Log(Debug, "Waiting network addresses for peer: %s", np.ID)
 	requestSentAt := time.Now()
 	updateInterval := time.Duration(time.Millisecond * 1000)
 	attempts := 0
 	for {
 		if time.Since(requestSentAt) > updateInterval {
-			Log(Warning, "Didn't got network addresses for peer. Requesting again")
+			np.logger(ptpc).Log(Warning, "Didn't got network addresses for peer. Requesting again")
 			requestSentAt = time.Now()
 			err := ptpc.Dht.sendNode(np.ID, []net.IP{})
 			if err != nil {
@@ -197,7 +260,7 @@ func (np *NetworkPeer) stateDisconnect(ptpc *PeerToPeer) error {
 	if ptpc == nil {
 		return fmt.Errorf("nil ptp")
 	}
-	Log(Debug, "Disconnecting %s", np.ID)
+	np.logger(ptpc).Log(Debug, "Disconnecting %s", np.ID)
 	np.SetState(PeerStateStop, ptpc)
 	// TODO: Send stop to DHT
 	return nil
@@ -208,7 +271,7 @@ func (np *NetworkPeer) stateStop(ptpc *PeerToPeer) error {
 	if ptpc == nil {
 		return fmt.Errorf("nil ptp")
 	}
-	Log(Debug, "Peer %s has been stopped", np.ID)
+	np.logger(ptpc).Log(Debug, "Peer %s has been stopped", np.ID)
 	return nil
 }
 
@@ -231,7 +294,7 @@ func (np *NetworkPeer) stateConnecting(ptpc *PeerToPeer) error {
 	if ptpc == nil {
 		return fmt.Errorf("nil ptp")
 	}
-	Log(Debug, "Connecting to %s", np.ID)
+	np.logger(ptpc).Log(Debug, "Connecting to %s", np.ID)
 
 	started := time.Now()
 	np.punchUDPHole(ptpc)
@@ -248,7 +311,7 @@ func (np *NetworkPeer) stateConnecting(ptpc *PeerToPeer) error {
 		}
 		time.Sleep(time.Millisecond * 100)
 	}
-	Log(Debug, "Couldn't connect to the peer in any way")
+	np.logger(ptpc).Log(Debug, "Couldn't connect to the peer in any way")
 	np.SetState(PeerStateDisconnect, ptpc)
 	return nil
 }
@@ -271,7 +334,7 @@ func (np *NetworkPeer) punchUDPHole(ptpc *PeerToPeer) error {
 	eps := []*net.UDPAddr{}
 	eps = append(eps, np.Proxies...)
 	eps = append(eps, np.KnownIPs...)
-	Log(Debug, "Hole punching %s", np.ID)
+	np.logger(ptpc).Log(Debug, "Hole punching %s", np.ID)
 
 	np.punchingInProgress = true
 	np.RoutingRequired = true
@@ -282,20 +345,20 @@ func (np *NetworkPeer) punchUDPHole(ptpc *PeerToPeer) error {
 		if isPrivate {
 			maxRounds = 1
 		}
-		for round < maxRounds {
+		for round < maxRounds && !ptpc.IsShutdown() {
 			active, err := np.isEndpointActive(ep)
 			if err != nil || active {
-				continue
+				break
 			}
 			payload := []byte(ptpc.Dht.ID + ep.String())
 			msg, err := ptpc.CreateMessage(MsgTypeIntroReq, payload, 0, true)
 			if err != nil {
-				Log(Error, "Couldn't create an intro message: %s", err)
+				np.logger(ptpc).Log(Error, "Couldn't create an intro message: %s", err)
 				continue
 			}
 			_, err = ptpc.UDPSocket.SendMessage(msg, ep)
 			if err != nil {
-				Log(Error, "Failed to send message to %s: %s", ep.String(), err)
+				np.logger(ptpc).Log(Error, "Failed to send message to %s: %s", ep.String(), err)
 				continue
 			}
 			time.Sleep(time.Millisecond * 50)
@@ -349,14 +412,14 @@ func (np *NetworkPeer) stateWaitingToConnect(ptpc *PeerToPeer) error {
 	if ptpc == nil {
 		return fmt.Errorf("nil ptp")
 	}
-	Log(Debug, "Waiting for peer [%s] to join connection state", np.ID)
+	np.logger(ptpc).Log(Debug, "Waiting for peer [%s] to join connection state", np.ID)
 	started := time.Now()
 	timeout := time.Duration(30000 * time.Millisecond)
 	recheck := time.Now()
 	recheckTimeout := time.Duration(5000 * time.Millisecond)
 	for {
 		if np.RemoteState == PeerStateWaitingToConnect || np.RemoteState == PeerStateConnecting || np.RemoteState == PeerStateConnected {
-			Log(Debug, "Peer [%s] have joined required state: %s", np.ID, StringifyState(np.RemoteState))
+			np.logger(ptpc).Log(Debug, "Peer [%s] have joined required state: %s", np.ID, StringifyState(np.RemoteState))
 			np.SetState(PeerStateConnecting, ptpc)
 			break
 		}
@@ -367,7 +430,7 @@ func (np *NetworkPeer) stateWaitingToConnect(ptpc *PeerToPeer) error {
 			return fmt.Errorf("Wait for connection failed: Peer doesn't responded in a timely manner")
 		}
 		if time.Since(recheck) > recheckTimeout && int(np.RemoteState) != 0 {
-			Log(Debug, "Peer %s is in %s state", np.ID, StringifyState(np.RemoteState))
+			np.logger(ptpc).Log(Debug, "Peer %s is in %s state", np.ID, StringifyState(np.RemoteState))
 			recheck = time.Now()
 			np.reportState(ptpc)
 		}
@@ -462,6 +525,7 @@ func (np *NetworkPeer) route(ptpc *PeerToPeer) error {
 		np.EndpointsHeap = append(np.EndpointsHeap, locals...)
 		np.EndpointsHeap = append(np.EndpointsHeap, internet...)
 		np.EndpointsHeap = append(np.EndpointsHeap, proxies...)
+		np.rankEndpoints(np.EndpointsHeap)
 		np.Lock.Unlock()
 
 		stat.localNum = len(locals)
@@ -469,12 +533,23 @@ func (np *NetworkPeer) route(ptpc *PeerToPeer) error {
 		stat.proxyNum = len(proxies)
 		np.Stat = stat
 
+		previous := np.Endpoint
 		if len(np.EndpointsHeap) > 0 {
-			np.Endpoint = np.EndpointsHeap[0].Addr
+			if _, active := np.selectEndpoint(time.Now()); active == nil {
+				// All endpoints stopped answering fast pings
+				np.Lock.Lock()
+				np.Endpoint = np.EndpointsHeap[0].Addr
+				np.Lock.Unlock()
+			}
 			np.ConnectionAttempts = 0
 		} else {
-			Log(Debug, "No active endpoints. Disconnecting peer %s", np.ID)
+			np.logger(ptpc).Log(Debug, "No active endpoints. Disconnecting peer %s", np.ID)
+			np.Lock.Lock()
 			np.Endpoint = nil
+			np.Lock.Unlock()
+		}
+		np.publishRoute(ptpc, previous, np.Endpoint)
+		if np.Endpoint == nil {
 			np.SetState(PeerStateDisconnect, ptpc)
 		}
 		return nil
@@ -485,6 +560,11 @@ func (np *NetworkPeer) route(ptpc *PeerToPeer) error {
 		return nil
 	}
 
+	// Switch to a better endpoint, if any
+	if previous, active := np.selectEndpoint(time.Now()); previous != nil && active != previous {
+		np.publishRoute(ptpc, previous.Addr, active.Addr)
+	}
+
 	// If current active endpoint is a proxy we will force routing
 	for _, proxy := range proxies {
 		if proxy.Addr.String() == np.Endpoint.String() {
@@ -495,6 +575,17 @@ func (np *NetworkPeer) route(ptpc *PeerToPeer) error {
 	return nil
 }
 
+// publishRoute notifies about change of active endpoint
+func (np *NetworkPeer) publishRoute(ptpc *PeerToPeer, from, to *net.UDPAddr) {
+	if addrToString(from) == addrToString(to) {
+		return
+	}
+	ptpc.publish(EventPeerRoute, np.ID, map[string]string{
+		"from": addrToString(from),
+		"to":   addrToString(to),
+	})
+}
+
 // stateConnected is executed when connection was established and peer is operating normally
 func (np *NetworkPeer) stateConnected(ptpc *PeerToPeer) error {
 	if ptpc == nil {
@@ -509,11 +600,14 @@ func (np *NetworkPeer) stateConnected(ptpc *PeerToPeer) error {
 
 	if time.Since(np.LastPunch) > time.Duration(time.Millisecond*30000) && np.Stat.localNum < 1 && np.Stat.internetNum < 1 {
 		np.Stat.reconnect()
-		Log(Info, "New hole punch activity: Local %d Internet %d", np.Stat.localNum, np.Stat.internetNum)
-		go np.punchUDPHole(ptpc)
+		np.logger(ptpc).Log(Info, "New hole punch activity: Local %d Internet %d", np.Stat.localNum, np.Stat.internetNum)
+		ptpc.spawn(func() { np.punchUDPHole(ptpc) })
 	}
 
 	np.pingEndpoints(ptpc)
+	np.negotiateCaps(ptpc)
+	np.discoverMTU(ptpc)
+	np.updatePaths(ptpc.Multipath())
 	np.syncWithRemoteState(ptpc)
 
 	// if time.Since(np.LastFind) > time.Duration(time.Second*90) {
@@ -528,7 +622,7 @@ func (np *NetworkPeer) stateCooldown(ptpc *PeerToPeer) error {
 	if ptpc == nil {
 		return fmt.Errorf("nil ptp")
 	}
-	Log(Debug, "Peer %s in cooldown", np.ID)
+	np.logger(ptpc).Log(Debug, "Peer %s in cooldown", np.ID)
 	started := time.Now()
 	for time.Since(started) < time.Duration(time.Second*20) {
 		time.Sleep(time.Millisecond * 100)
@@ -575,7 +669,7 @@ func (np *NetworkPeer) pingEndpoints(ptpc *PeerToPeer) error {
 	np.Lock.RLock()
 	for _, ep := range np.EndpointsHeap {
 		if time.Since(ep.LastPing) > EndpointPingInterval {
-			ep.ping(ptpc, ptpc.Dht.ID)
+			ep.ping(ptpc, ptpc.Dht.ID, np.Caps()&CapPingSeq != 0)
 			time.Sleep(time.Millisecond * 50)
 		}
 	}
@@ -592,17 +686,17 @@ func (np *NetworkPeer) syncWithRemoteState(ptpc *PeerToPeer) error {
 		return fmt.Errorf("nil ptp")
 	}
 	if np.RemoteState == PeerStateDisconnect {
-		Log(Debug, "Peer %s disconnecting", np.ID)
+		np.logger(ptpc).Log(Debug, "Peer %s disconnecting", np.ID)
 		np.SetState(PeerStateDisconnect, ptpc)
 	} else if np.RemoteState == PeerStateStop {
-		Log(Debug, "Peer %s has been stopped", np.ID)
+		np.logger(ptpc).Log(Debug, "Peer %s has been stopped", np.ID)
 		np.SetState(PeerStateDisconnect, ptpc)
 	} else if np.RemoteState == PeerStateInit {
-		Log(Debug, "Remote peer %s decided to reconnect", np.ID)
+		np.logger(ptpc).Log(Debug, "Remote peer %s decided to reconnect", np.ID)
 		// TODO: Consider moving to Disconnect state here
 		np.SetState(PeerStateInit, ptpc)
 	} else if np.RemoteState == PeerStateWaitingToConnect {
-		Log(Debug, "Peer %s is waiting for us to connect", np.ID)
+		np.logger(ptpc).Log(Debug, "Peer %s is waiting for us to connect", np.ID)
 		np.SetState(PeerStateWaitingToConnect, ptpc)
 	}
 	return nil
@@ -0,0 +1,161 @@
+package ptp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PathType is a kind of path used to reach the peer
+type PathType uint8
+
+// Path types
+const (
+	PathLAN      PathType = 0 // Direct connection inside local network
+	PathInternet PathType = 1 // Direct connection over internet
+	PathProxy    PathType = 2 // Connection through proxy server
+)
+
+// PathTypes lists all path types in output order
+var PathTypes = []PathType{PathLAN, PathInternet, PathProxy}
+
+func (t PathType) String() string {
+	switch t {
+	case PathLAN:
+		return "lan"
+	case PathInternet:
+		return "internet"
+	case PathProxy:
+		return "proxy"
+	}
+	return "unknown"
+}
+
+// TrafficCounters holds bytes and packets sent and received
+type TrafficCounters struct {
+	TxBytes   uint64 `json:"tx_bytes" yaml:"tx_bytes"`
+	RxBytes   uint64 `json:"rx_bytes" yaml:"rx_bytes"`
+	TxPackets uint64 `json:"tx_packets" yaml:"tx_packets"`
+	RxPackets uint64 `json:"rx_packets" yaml:"rx_packets"`
+}
+
+func (c *TrafficCounters) tx(n int) {
+	atomic.AddUint64(&c.TxBytes, uint64(n))
+	atomic.AddUint64(&c.TxPackets, 1)
+}
+
+func (c *TrafficCounters) rx(n int) {
+	atomic.AddUint64(&c.RxBytes, uint64(n))
+	atomic.AddUint64(&c.RxPackets, 1)
+}
+
+func (c *TrafficCounters) load() TrafficCounters {
+	return TrafficCounters{
+		TxBytes:   atomic.LoadUint64(&c.TxBytes),
+		RxBytes:   atomic.LoadUint64(&c.RxBytes),
+		TxPackets: atomic.LoadUint64(&c.TxPackets),
+		RxPackets: atomic.LoadUint64(&c.RxPackets),
+	}
+}
+
+func (c *TrafficCounters) add(o TrafficCounters) {
+	c.TxBytes += o.TxBytes
+	c.RxBytes += o.RxBytes
+	c.TxPackets += o.TxPackets
+	c.RxPackets += o.RxPackets
+}
+
+// PeerTraffic accounts data plane traffic of a single peer by path type
+// and by endpoint. Counters are allocated separately to keep them 64-bit
+// aligned for atomic operations
+type PeerTraffic struct {
+	paths     map[PathType]*TrafficCounters
+	endpoints map[string]*TrafficCounters
+	resetAt   time.Time
+	lock      sync.RWMutex
+}
+
+// counters returns path and endpoint counters, creating them on first use
+func (t *PeerTraffic) counters(path PathType, endpoint string) (*TrafficCounters, *TrafficCounters) {
+	t.lock.RLock()
+	pc, pexists := t.paths[path]
+	ec, eexists := t.endpoints[endpoint]
+	t.lock.RUnlock()
+	if pexists && eexists {
+		return pc, ec
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.paths == nil {
+		t.paths = make(map[PathType]*TrafficCounters)
+		t.endpoints = make(map[string]*TrafficCounters)
+		t.resetAt = time.Now()
+	}
+	if _, exists := t.paths[path]; !exists {
+		t.paths[path] = new(TrafficCounters)
+	}
+	if _, exists := t.endpoints[endpoint]; !exists {
+		t.endpoints[endpoint] = new(TrafficCounters)
+	}
+	return t.paths[path], t.endpoints[endpoint]
+}
+
+func (t *PeerTraffic) countTx(path PathType, endpoint *net.UDPAddr, n int) {
+	pc, ec := t.counters(path, addrToString(endpoint))
+	pc.tx(n)
+	ec.tx(n)
+}
+
+func (t *PeerTraffic) countRx(path PathType, endpoint *net.UDPAddr, n int) {
+	pc, ec := t.counters(path, addrToString(endpoint))
+	pc.rx(n)
+	ec.rx(n)
+}
+
+// Path returns counters of the specified path type
+func (t *PeerTraffic) Path(path PathType) TrafficCounters {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	c, exists := t.paths[path]
+	if !exists {
+		return TrafficCounters{}
+	}
+	return c.load()
+}
+
+// Total returns counters summarized over all path types
+func (t *PeerTraffic) Total() TrafficCounters {
+	total := TrafficCounters{}
+	for _, path := range PathTypes {
+		total.add(t.Path(path))
+	}
+	return total
+}
+
+// Endpoints returns counters of every endpoint used by the peer
+func (t *PeerTraffic) Endpoints() map[string]TrafficCounters {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	result := make(map[string]TrafficCounters)
+	for ep, c := range t.endpoints {
+		result[ep] = c.load()
+	}
+	return result
+}
+
+// ResetAt returns time of the last counters reset
+func (t *PeerTraffic) ResetAt() time.Time {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.resetAt
+}
+
+// Reset sets all counters to zero
+func (t *PeerTraffic) Reset() {
+	t.lock.Lock()
+	t.paths = make(map[PathType]*TrafficCounters)
+	t.endpoints = make(map[string]*TrafficCounters)
+	t.resetAt = time.Now()
+	t.lock.Unlock()
+}
@@ -0,0 +1,92 @@
+package ptp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPeerTraffic(t *testing.T) {
+	lan, _ := net.ResolveUDPAddr("udp4", "192.168.1.2:1234")
+	inet, _ := net.ResolveUDPAddr("udp4", "8.8.8.8:1234")
+
+	tr := new(PeerTraffic)
+	if tr.Total() != (TrafficCounters{}) {
+		t.Fatalf("PeerTraffic.Total() on empty counters = %+v", tr.Total())
+	}
+	tr.countTx(PathLAN, lan, 100)
+	tr.countTx(PathLAN, lan, 50)
+	tr.countRx(PathInternet, inet, 10)
+	tr.countRx(PathProxy, inet, 20)
+
+	tests := []struct {
+		name string
+		got  TrafficCounters
+		want TrafficCounters
+	}{
+		{"lan", tr.Path(PathLAN), TrafficCounters{TxBytes: 150, TxPackets: 2}},
+		{"internet", tr.Path(PathInternet), TrafficCounters{RxBytes: 10, RxPackets: 1}},
+		{"proxy", tr.Path(PathProxy), TrafficCounters{RxBytes: 20, RxPackets: 1}},
+		{"total", tr.Total(), TrafficCounters{TxBytes: 150, TxPackets: 2, RxBytes: 30, RxPackets: 2}},
+		{"lan endpoint", tr.Endpoints()[lan.String()], TrafficCounters{TxBytes: 150, TxPackets: 2}},
+		{"internet endpoint", tr.Endpoints()[inet.String()], TrafficCounters{RxBytes: 30, RxPackets: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("counters = %+v, want %+v", tt.got, tt.want)
+			}
+		})
+	}
+
+	tr.Reset()
+	if tr.Total() != (TrafficCounters{}) || len(tr.Endpoints()) != 0 {
+		t.Errorf("PeerTraffic.Reset() didn't reset counters")
+	}
+	if tr.ResetAt().IsZero() {
+		t.Errorf("PeerTraffic.Reset() didn't set reset time")
+	}
+}
+
+func TestNetworkPeer_pathType(t *testing.T) {
+	lan, _ := net.ResolveUDPAddr("udp4", "192.168.1.2:1234")
+	inet, _ := net.ResolveUDPAddr("udp4", "8.8.8.8:1234")
+	proxy, _ := net.ResolveUDPAddr("udp4", "8.8.4.4:1234")
+	np := &NetworkPeer{Proxies: []*net.UDPAddr{proxy}}
+	tests := []struct {
+		name string
+		addr *net.UDPAddr
+		want PathType
+	}{
+		{"lan", lan, PathLAN},
+		{"internet", inet, PathInternet},
+		{"proxy", proxy, PathProxy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := np.pathType(tt.addr); got != tt.want {
+				t.Errorf("NetworkPeer.pathType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeerToPeer_countRx(t *testing.T) {
+	src, _ := net.ResolveUDPAddr("udp4", "8.8.8.8:1234")
+	mac := net.HardwareAddr{0x06, 0x05, 0x04, 0x03, 0x02, 0x01}
+	swarm := new(Swarm)
+	swarm.Init()
+	peer := &NetworkPeer{ID: "p0", PeerHW: mac}
+	swarm.Update("p0", peer)
+	p := &PeerToPeer{Swarm: swarm}
+
+	frame := make([]byte, 60)
+	copy(frame[6:12], mac)
+	msg := &P2PMessage{Header: &P2PMessageHeader{Length: 60}, Data: frame}
+	p.countRx(msg, src)
+	p.countRx(&P2PMessage{Header: &P2PMessageHeader{}, Data: []byte{1}}, src)
+
+	want := TrafficCounters{RxBytes: 60, RxPackets: 1}
+	if got := peer.Traffic.Path(PathInternet); got != want {
+		t.Errorf("PeerToPeer.countRx() counters = %+v, want %+v", got, want)
+	}
+}
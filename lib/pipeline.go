@@ -0,0 +1,377 @@
+package ptp
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/subutai-io/p2p/protocol"
+)
+
+// Defaults of packet processing pipeline
+const (
+	PipelineQueueSize = 512  // Jobs queued per worker
+	packetBufferSize  = 4096 // Size of pooled packet buffers. Matches UDP read buffer
+)
+
+// PipelineWorkers is a number of workers processing packets of an
+// instance. Instances created after a change use the new value
+var PipelineWorkers = runtime.NumCPU()
+
+// DHTWorkers is a number of workers processing packets received from
+// bootstrap node, so a slow handler doesn't stall peer discovery
+var DHTWorkers = 4
+
+// Kinds of pipeline jobs
+const (
+	jobFrame   uint8 = iota // Frame read from TAP interface
+	jobMessage              // Datagram received from UDP socket
+	jobDHT                  // Packet received from bootstrap node
+)
+
+// pipelineJob is a unit of work. Jobs are passed by value, so queueing
+// a job doesn't allocate
+type pipelineJob struct {
+	kind  uint8
+	proto int
+	data  []byte
+	buf   *packetBuffer
+	addr  *net.UDPAddr
+	dht   *protocol.DHTPacket
+	gso   VNetHeader // Header of super-packet read from TAP with offload
+}
+
+// PipelineStats holds counters of a pipeline
+type PipelineStats struct {
+	Queued    uint64 `json:"queued"`
+	Processed uint64 `json:"processed"`
+	Dropped   uint64 `json:"dropped"`
+}
+
+// pipeline processes jobs with a fixed pool of workers. Every worker has
+// its own bounded queue and jobs with the same key always go to the same
+// worker, so they're processed in order. Jobs are dropped when queue is
+// full, unless submitter waits for free space
+type pipeline struct {
+	stats    PipelineStats // Must be first to keep 64-bit alignment
+	queues   []chan pipelineJob
+	dispatch func(*pipelineJob)
+	idle     func()        // Called by a worker when its queue becomes empty
+	done     chan struct{} // Closed when pipeline stops
+	stop     sync.Once
+	lock     sync.RWMutex
+	closed   bool
+}
+
+// newPipeline starts workers with spawn and returns the pipeline. idle may
+// be nil
+func newPipeline(workers, queueSize int, dispatch func(*pipelineJob), idle func(), spawn func(func())) *pipeline {
+	if workers < 1 {
+		workers = 1
+	}
+	pl := &pipeline{
+		dispatch: dispatch,
+		idle:     idle,
+		queues:   make([]chan pipelineJob, workers),
+		done:     make(chan struct{}),
+	}
+	for i := range pl.queues {
+		queue := make(chan pipelineJob, queueSize)
+		pl.queues[i] = queue
+		spawn(func() { pl.work(queue) })
+	}
+	return pl
+}
+
+func (pl *pipeline) work(queue chan pipelineJob) {
+	for {
+		var job pipelineJob
+		var ok bool
+		select {
+		case job, ok = <-queue:
+		default:
+			if pl.idle != nil {
+				pl.idle()
+			}
+			job, ok = <-queue
+		}
+		if !ok {
+			return
+		}
+		select {
+		case <-pl.done:
+			// Jobs left after close are not processed
+			pl.drop(&job)
+			continue
+		default:
+		}
+		pl.dispatch(&job)
+		if job.buf != nil {
+			putPacketBuffer(job.buf)
+		}
+		atomic.AddUint64(&pl.stats.Processed, 1)
+	}
+}
+
+// submit queues a job. When queue is full, job is dropped and false is
+// returned, unless wait is set. Then submit blocks until there is a free
+// space or pipeline is closed
+func (pl *pipeline) submit(key uint32, job pipelineJob, wait bool) bool {
+	pl.lock.RLock()
+	defer pl.lock.RUnlock()
+	if pl.closed {
+		pl.drop(&job)
+		return false
+	}
+	queue := pl.queues[key%uint32(len(pl.queues))]
+	select {
+	case queue <- job:
+		atomic.AddUint64(&pl.stats.Queued, 1)
+		return true
+	default:
+	}
+	if wait {
+		select {
+		case queue <- job:
+			atomic.AddUint64(&pl.stats.Queued, 1)
+			return true
+		case <-pl.done:
+		}
+	}
+	pl.drop(&job)
+	return false
+}
+
+func (pl *pipeline) drop(job *pipelineJob) {
+	if job.buf != nil {
+		putPacketBuffer(job.buf)
+	}
+	atomic.AddUint64(&pl.stats.Dropped, 1)
+}
+
+// close stops the pipeline without waiting for workers, so it's safe to
+// call from a job. Queued jobs are dropped
+func (pl *pipeline) close() {
+	if pl == nil {
+		return
+	}
+	pl.stop.Do(func() { close(pl.done) })
+	// Submitters blocked on full queues are released by done, so the
+	// lock can be taken now
+	pl.lock.Lock()
+	defer pl.lock.Unlock()
+	if pl.closed {
+		return
+	}
+	pl.closed = true
+	for _, queue := range pl.queues {
+		close(queue)
+	}
+}
+
+// Stats returns a snapshot of pipeline counters
+func (pl *pipeline) Stats() PipelineStats {
+	if pl == nil {
+		return PipelineStats{}
+	}
+	return PipelineStats{
+		Queued:    atomic.LoadUint64(&pl.stats.Queued),
+		Processed: atomic.LoadUint64(&pl.stats.Processed),
+		Dropped:   atomic.LoadUint64(&pl.stats.Dropped),
+	}
+}
+
+// dhtKey returns a key of DHT packet. Packets describing a peer carry
+// its ID in Data. Other packets are handled by the first worker
+func dhtKey(packet *protocol.DHTPacket) uint32 {
+	switch packet.Type {
+	case protocol.DHTPacketType_Find, protocol.DHTPacketType_Node, protocol.DHTPacketType_RequestProxy, protocol.DHTPacketType_State:
+		return hashKey([]byte(packet.Data))
+	}
+	return 0
+}
+
+// hashKey is a FNV-1a hash used to pick a worker
+func hashKey(b []byte) uint32 {
+	h := uint32(2166136261)
+	for _, c := range b {
+		h ^= uint32(c)
+		h *= 16777619
+	}
+	return h
+}
+
+// frameKey keeps frames to the same destination MAC in order
+func frameKey(frame []byte) uint32 {
+	if len(frame) < 6 {
+		return 0
+	}
+	return hashKey(frame[:6])
+}
+
+// addrKey keeps datagrams from the same endpoint in order
+func addrKey(addr *net.UDPAddr) uint32 {
+	if addr == nil {
+		return 0
+	}
+	return (hashKey(addr.IP.To16()) ^ uint32(addr.Port)) * 16777619
+}
+
+// packetBuffer is a pooled buffer for received datagrams
+type packetBuffer struct {
+	data []byte
+	pool *bufferPool // Pool the buffer is returned to. Nil when not pooled
+}
+
+// bufferPool keeps buffers of the same size
+type bufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+func newBufferPool(size int) *bufferPool {
+	bp := &bufferPool{size: size}
+	bp.pool.New = func() interface{} {
+		return &packetBuffer{data: make([]byte, size), pool: bp}
+	}
+	return bp
+}
+
+// get returns a buffer of length n. Buffer larger than the pool size is
+// allocated and is not returned to the pool
+func (bp *bufferPool) get(n int) *packetBuffer {
+	if n > bp.size {
+		return &packetBuffer{data: make([]byte, n)}
+	}
+	b := bp.pool.Get().(*packetBuffer)
+	b.data = b.data[:n]
+	return b
+}
+
+var (
+	packetBuffers = newBufferPool(packetBufferSize)
+	// offloadBuffers hold super-packets of vnet and datagrams of peers
+	// with offload. Only instances with offload enabled use them
+	offloadBuffers = newBufferPool(offloadBufferSize)
+	// frameBuffers are pools of interfaces with large MTU. Instances with
+	// the same MTU share a pool
+	frameBuffers     = make(map[int]*bufferPool)
+	frameBuffersLock sync.Mutex
+)
+
+// bufferPoolOf returns a pool of buffers of given size. Sizes up to
+// packetBufferSize share a single pool
+func bufferPoolOf(size int) *bufferPool {
+	switch {
+	case size <= packetBufferSize:
+		return packetBuffers
+	case size == offloadBufferSize:
+		return offloadBuffers
+	}
+	frameBuffersLock.Lock()
+	defer frameBuffersLock.Unlock()
+	bp, exists := frameBuffers[size]
+	if !exists {
+		bp = newBufferPool(size)
+		frameBuffers[size] = bp
+	}
+	return bp
+}
+
+// getPacketBuffer returns a buffer of length n from a pool of buffers of
+// size n
+func getPacketBuffer(n int) *packetBuffer {
+	return bufferPoolOf(n).get(n)
+}
+
+func putPacketBuffer(b *packetBuffer) {
+	if b.pool != nil {
+		b.pool.pool.Put(b)
+	}
+}
+
+// startPipelines creates workers processing packets of the instance
+func (p *PeerToPeer) startPipelines() {
+	// Frames coalesced for TAP are written once there is nothing to merge
+	// them with
+	p.packets = newPipeline(PipelineWorkers, PipelineQueueSize, p.processJob, p.flushInterface, p.spawn)
+	// DHT packets about the same peer go to the same worker to keep them
+	// in order
+	p.dhtPackets = newPipeline(DHTWorkers, PipelineQueueSize, p.processJob, nil, p.spawn)
+}
+
+func (p *PeerToPeer) stopPipelines() {
+	p.packets.close()
+	p.dhtPackets.close()
+}
+
+// processJob runs a handler for a single job. Errors are logged by
+// handlers themselves
+func (p *PeerToPeer) processJob(job *pipelineJob) {
+	switch job.kind {
+	case jobFrame:
+		if job.gso.IsGSO() {
+			p.handleOffloadFrame(job.data, job.proto, job.gso)
+			return
+		}
+		p.handlePacket(job.data, job.proto)
+	case jobMessage:
+		p.HandleP2PMessage(len(job.data), job.addr, nil, job.data)
+	case jobDHT:
+		p.handleDHTPacket(job.dht)
+	}
+}
+
+// receiveFrame queues a frame read from TAP interface. Frame is dropped
+// when queue of its worker is full. buf holding the frame, if any, is
+// returned to the pool after frame is processed
+func (p *PeerToPeer) receiveFrame(packet *Packet, buf *packetBuffer) {
+	job := pipelineJob{kind: jobFrame, data: packet.Packet, proto: packet.Protocol, buf: buf, gso: packet.GSO}
+	if p.packets == nil {
+		p.processJob(&job)
+		if buf != nil {
+			putPacketBuffer(buf)
+		}
+		return
+	}
+	p.packets.submit(frameKey(packet.Packet), job, false)
+}
+
+// receiveMessage is a callback of UDP listener. Listener reuses its
+// buffer, so datagram is copied into a pooled one before it's queued
+func (p *PeerToPeer) receiveMessage(count int, srcAddr *net.UDPAddr, err error, rcvBytes []byte) error {
+	if err != nil || p.packets == nil {
+		err = p.HandleP2PMessage(count, srcAddr, err, rcvBytes)
+		p.flushInterface()
+		return err
+	}
+	if count > len(rcvBytes) {
+		count = len(rcvBytes)
+	}
+	pool := p.messageBuffers
+	if pool == nil || count <= packetBufferSize {
+		pool = packetBuffers
+	}
+	buf := pool.get(count)
+	copy(buf.data, rcvBytes[:count])
+	job := pipelineJob{kind: jobMessage, data: buf.data, buf: buf, addr: srcAddr}
+	if !p.packets.submit(addrKey(srcAddr), job, false) {
+		return fmt.Errorf("Packet queue is full")
+	}
+	return nil
+}
+
+// receiveBatch is a callback of batch UDP listener
+func (p *PeerToPeer) receiveBatch(batch []UDPDatagram) {
+	for _, d := range batch {
+		p.receiveMessage(len(d.Data), d.Addr, nil, d.Data)
+	}
+}
+
+// PipelineStats returns counters of packet processing. DHT packets are
+// not included
+func (p *PeerToPeer) PipelineStats() PipelineStats {
+	return p.packets.Stats()
+}
@@ -0,0 +1,292 @@
+package ptp
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/subutai-io/p2p/protocol"
+)
+
+func spawnTracked(wg *sync.WaitGroup) func(func()) {
+	return func(fn func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+}
+
+func TestPipeline_order(t *testing.T) {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	got := map[int][]int{}
+	pl := newPipeline(4, 16, func(job *pipelineJob) {
+		lock.Lock()
+		got[job.proto] = append(got[job.proto], int(job.data[0]))
+		lock.Unlock()
+	}, nil, spawnTracked(&wg))
+
+	for i := 0; i < 100; i++ {
+		for key := 0; key < 8; key++ {
+			pl.submit(uint32(key), pipelineJob{proto: key, data: []byte{byte(i)}}, true)
+		}
+	}
+	for pl.Stats().Processed < 800 {
+		time.Sleep(time.Millisecond)
+	}
+	pl.close()
+	wg.Wait()
+
+	for key := 0; key < 8; key++ {
+		if len(got[key]) != 100 {
+			t.Fatalf("Key %d: processed %d jobs, want 100", key, len(got[key]))
+		}
+		for i, v := range got[key] {
+			if v != i {
+				t.Fatalf("Key %d: job %d processed at position %d", key, v, i)
+			}
+		}
+	}
+	if s := pl.Stats(); s.Queued != 800 || s.Dropped != 0 {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+}
+
+func TestPipeline_drop(t *testing.T) {
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	pl := newPipeline(1, 2, func(job *pipelineJob) {
+		<-release
+	}, nil, spawnTracked(&wg))
+
+	// First job blocks the worker, next two fill the queue
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		if pl.submit(0, pipelineJob{}, false) {
+			accepted++
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for pl.Stats().Processed < uint64(accepted) {
+		time.Sleep(time.Millisecond)
+	}
+	pl.close()
+	wg.Wait()
+
+	s := pl.Stats()
+	if accepted < 2 || accepted > 3 {
+		t.Errorf("Accepted %d jobs with queue of 2", accepted)
+	}
+	if s.Queued != uint64(accepted) || s.Dropped != uint64(10-accepted) || s.Processed != uint64(accepted) {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+	if pl.submit(0, pipelineJob{}, false) {
+		t.Errorf("Closed pipeline accepted a job")
+	}
+}
+
+func TestPipeline_closeReleasesWaiting(t *testing.T) {
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	var pl *pipeline
+	pl = newPipeline(1, 1, func(job *pipelineJob) {
+		<-release
+	}, nil, spawnTracked(&wg))
+
+	pl.submit(0, pipelineJob{}, true)
+	pl.submit(0, pipelineJob{}, true)
+	result := make(chan bool)
+	go func() {
+		// Worker is busy and queue is full, so this blocks
+		result <- pl.submit(0, pipelineJob{}, true)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pl.close()
+	select {
+	case ok := <-result:
+		if ok {
+			t.Errorf("Job was accepted by closed pipeline")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Close didn't release blocked submitter")
+	}
+	close(release)
+	wg.Wait()
+	// Job left in the queue is dropped by worker after close
+	if s := pl.Stats(); s.Processed+s.Dropped != 3 || s.Processed != 1 {
+		t.Errorf("Wrong stats: %+v", s)
+	}
+}
+
+func TestPeerToPeer_receiveMessage(t *testing.T) {
+	var wg sync.WaitGroup
+	received := make(chan []byte, 1)
+	p := new(PeerToPeer)
+	p.MessageHandlers = map[uint16]MessageHandler{
+		uint16(MsgTypeNenc): func(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+			received <- msg.Data
+			return nil
+		},
+	}
+	p.packets = newPipeline(2, 4, p.processJob, nil, spawnTracked(&wg))
+
+	msg, err := p.CreateMessage(MsgTypeNenc, []byte("payload"), 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, packetBufferSize)
+	n := copy(buf, msg.Serialize())
+	p.receiveMessage(n, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 6881}, nil, buf)
+	// Listener reuses its buffer for the next datagram
+	for i := range buf {
+		buf[i] = 0
+	}
+
+	select {
+	case data := <-received:
+		if string(data) != "payload" {
+			t.Errorf("Received %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Message wasn't handled")
+	}
+	p.stopPipelines()
+	wg.Wait()
+}
+
+func TestGetPacketBuffer(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		size int
+	}{
+		{"datagram", HeaderSize + 1400, packetBufferSize},
+		{"standard MTU", frameBufferSize(1500), packetBufferSize},
+		{"jumbo MTU", frameBufferSize(9000), frameBufferSize(9000)},
+		{"jumbo MTU datagram", frameBufferSize(9000) + messageOverhead, frameBufferSize(9000) + messageOverhead},
+		{"offload", offloadBufferSize, offloadBufferSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := getPacketBuffer(tt.n)
+			if len(b.data) != tt.n || cap(b.data) != tt.size || b.pool.size != tt.size {
+				t.Errorf("getPacketBuffer(%d) len = %d, cap = %d, want cap %d", tt.n, len(b.data), cap(b.data), tt.size)
+			}
+			putPacketBuffer(b)
+		})
+	}
+	if bufferPoolOf(frameBufferSize(9000)) != bufferPoolOf(frameBufferSize(9000)) {
+		t.Errorf("Interfaces with the same MTU got different pools")
+	}
+	// Datagram larger than pool of the instance is not pooled
+	if b := packetBuffers.get(packetBufferSize + 1); b.pool != nil || len(b.data) != packetBufferSize+1 {
+		t.Errorf("Oversized buffer was pooled")
+	}
+}
+
+func TestFrameKey(t *testing.T) {
+	a := []byte{0x06, 0, 0, 0, 0, 1, 0xff}
+	b := []byte{0x06, 0, 0, 0, 0, 1, 0xee}
+	c := []byte{0x06, 0, 0, 0, 0, 2, 0xff}
+	if frameKey(a) != frameKey(b) {
+		t.Errorf("Frames to the same MAC got different keys")
+	}
+	if frameKey(a) == frameKey(c) {
+		t.Errorf("Frames to different MACs got the same key")
+	}
+	if frameKey([]byte{1, 2}) != 0 {
+		t.Errorf("Short frame got non-zero key")
+	}
+}
+
+func TestDHTKey(t *testing.T) {
+	peerA := "123e4567-e89b-12d3-a456-426655440000"
+	peerB := "123e4567-e89b-12d3-a456-426655440001"
+	find := &protocol.DHTPacket{Type: protocol.DHTPacketType_Find, Data: peerA}
+	node := &protocol.DHTPacket{Type: protocol.DHTPacketType_Node, Data: peerA}
+	other := &protocol.DHTPacket{Type: protocol.DHTPacketType_State, Data: peerB}
+	if dhtKey(find) != dhtKey(node) {
+		t.Errorf("Packets about the same peer got different keys")
+	}
+	if dhtKey(find) == dhtKey(other) {
+		t.Errorf("Packets about different peers got the same key")
+	}
+	if dhtKey(&protocol.DHTPacket{Type: protocol.DHTPacketType_Connect, Data: peerA}) != 0 {
+		t.Errorf("Packet without peer got non-zero key")
+	}
+}
+
+// benchmarkMessage returns a PeerToPeer with a no-op handler and
+// a serialized message
+func benchmarkMessage(b *testing.B, handled *uint64) (*PeerToPeer, []byte) {
+	p := new(PeerToPeer)
+	p.MessageHandlers = map[uint16]MessageHandler{
+		uint16(MsgTypeNenc): func(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+			atomic.AddUint64(handled, 1)
+			return nil
+		},
+	}
+	msg, err := p.CreateMessage(MsgTypeNenc, make([]byte, 1400), 0, false)
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, packetBufferSize)
+	copy(buf, msg.Serialize())
+	return p, buf
+}
+
+func BenchmarkReceiveMessage_pipeline(b *testing.B) {
+	var handled uint64
+	var wg sync.WaitGroup
+	p, buf := benchmarkMessage(b, &handled)
+	p.packets = newPipeline(PipelineWorkers, PipelineQueueSize, p.processJob, nil, spawnTracked(&wg))
+	n := HeaderSize + 1400
+	addrs := make([]*net.UDPAddr, 16)
+	for i := range addrs {
+		addrs[i] = &net.UDPAddr{IP: net.IPv4(10, 0, 0, byte(i)), Port: 6881}
+	}
+	b.SetBytes(int64(n))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Same as receiveMessage, but waits for a free space to measure
+		// throughput rather than drops
+		pb := getPacketBuffer(n)
+		copy(pb.data, buf[:n])
+		addr := addrs[i%len(addrs)]
+		p.packets.submit(addrKey(addr), pipelineJob{kind: jobMessage, data: pb.data, buf: pb, addr: addr}, true)
+	}
+	for atomic.LoadUint64(&handled) < uint64(b.N) {
+		time.Sleep(time.Microsecond)
+	}
+	b.StopTimer()
+	p.stopPipelines()
+	wg.Wait()
+}
+
+func BenchmarkReceiveMessage_goroutine(b *testing.B) {
+	var handled uint64
+	var wg sync.WaitGroup
+	p, buf := benchmarkMessage(b, &handled)
+	n := HeaderSize + 1400
+	addr := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 6881}
+	b.SetBytes(int64(n))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// Goroutine per datagram, as it was before the pipeline
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.HandleP2PMessage(n, addr, nil, data)
+		}()
+	}
+	wg.Wait()
+}
@@ -3,9 +3,11 @@
 package ptp
 
 import (
+	"context"
 	"fmt"
 	"log/syslog"
 	"os"
+	"os/exec"
 )
 
 const (
@@ -44,3 +46,8 @@ func Syslog(level LogLevel, format string, v ...interface{}) {
 func SetupPlatform(remove bool) {
 	// Not used on POSIX
 }
+
+// hookCommand returns command that executes hook in a shell
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
@@ -3,6 +3,7 @@
 package ptp
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -284,3 +285,8 @@ func exePath() (string, error) {
 	}
 	return "", err
 }
+
+// hookCommand returns command that executes hook in a shell
+func hookCommand(ctx context.Context, command string) *exec.Cmd {
+	return exec.CommandContext(ctx, "cmd", "/C", command)
+}
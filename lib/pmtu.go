@@ -33,87 +33,83 @@ func checksum(bytes []byte) uint16 {
 	return ^uint16(csum)
 }
 
-func pmtu(data []byte, tap TAP) (bool, error) {
-	protocol := int(binary.BigEndian.Uint16(data[12:14]))
-	length := len(data)
-
-	if protocol == int(PacketIPv4) && length > GlobalMTU-150 {
-		header, err := ipv4.ParseHeader(data[14:])
-		if err != nil {
-			Log(Error, "Failed to parse IPv4 packet: %s", err.Error())
-			return false, nil
-		}
-
-		// Don't fragment flag is set. We need to respond with ICMP Destination Unreachable
-		if header.Flags == ipv4.DontFragment {
-			// Extract packet contents as an ethernet frame for later re-use
-			f := new(ethernet.Frame)
-			if err := f.UnmarshalBinary(data); err != nil {
-				Log(Error, "Failed to Unmarshal IPv4")
-				return false, nil
-			}
-
-			// Build "Fragmentation needed" ICMP message
-			packetICMP := &icmp.Message{
-				Type: ipv4.ICMPTypeDestinationUnreachable,
-				Code: 4,
-				Body: &icmp.PacketTooBig{
-					MTU:  GlobalMTU - 200,    // Next-hop MTU
-					Data: data[14 : 14+20+8], // Original header and 64-bits of datagram
-				},
-			}
-			payloadICMP, err := packetICMP.Marshal(nil)
-			if err != nil {
-				Log(Error, "Failed to marshal ICMP: %s", err.Error())
-				return false, errICMPMarshalFailed
-			}
+// fragmentationNeeded builds ICMP "fragmentation needed" reply to IPv4
+// frame with Don't Fragment flag. mtu is the next-hop MTU reported to the
+// sender. Returns nil when frame can be fragmented
+func fragmentationNeeded(data []byte, mtu int) ([]byte, error) {
+	if len(data) < 14 || int(binary.BigEndian.Uint16(data[12:14])) != int(PacketIPv4) {
+		return nil, fmt.Errorf("Unsupported protocol for PMTU")
+	}
+	header, err := ipv4.ParseHeader(data[14:])
+	if err != nil {
+		Log(Error, "Failed to parse IPv4 packet: %s", err.Error())
+		return nil, nil
+	}
 
-			// Build IPv4 Header
-			iph := &ipv4.Header{
-				Version:  4,
-				Len:      20, // Precalculated header length
-				TOS:      0,
-				TotalLen: len(payloadICMP) + 20,
-				ID:       25,
-				TTL:      64,
-				Protocol: 1,
-				Dst:      header.Src,
-				Src:      header.Dst,
-				Checksum: 0,
-			}
-			ipHeader, err := iph.Marshal()
-			if err != nil {
-				Log(Error, "Failed to marshal header: %s", err.Error())
-				return false, nil
-			}
+	// Don't fragment flag is set. We need to respond with ICMP Destination Unreachable
+	if header.Flags&ipv4.DontFragment == 0 || len(data) < 14+header.Len+8 {
+		return nil, nil
+	}
+	// Extract packet contents as an ethernet frame for later re-use
+	f := new(ethernet.Frame)
+	if err := f.UnmarshalBinary(data); err != nil {
+		Log(Error, "Failed to Unmarshal IPv4")
+		return nil, nil
+	}
 
-			// Calculate IPv4 header checksum
-			hcsum := checksum(ipHeader)
-			binary.BigEndian.PutUint16(ipHeader[10:], hcsum)
+	// Build "Fragmentation needed" ICMP message
+	packetICMP := &icmp.Message{
+		Type: ipv4.ICMPTypeDestinationUnreachable,
+		Code: 4,
+		Body: &icmp.PacketTooBig{
+			MTU:  mtu,                        // Next-hop MTU
+			Data: data[14 : 14+header.Len+8], // Original header and 64-bits of datagram
+		},
+	}
+	payloadICMP, err := packetICMP.Marshal(nil)
+	if err != nil {
+		Log(Error, "Failed to marshal ICMP: %s", err.Error())
+		return nil, errICMPMarshalFailed
+	}
 
-			// Build new ethernet frame. Swap dst/src
-			pl := append(ipHeader, payloadICMP...)
-			nf := new(ethernet.Frame)
-			nf.Destination = f.Source
-			nf.Source = f.Destination
-			nf.EtherType = ethernet.EtherTypeIPv4
-			nf.Payload = pl
-			rpacket, err := nf.MarshalBinary()
-			if err != nil {
-				Log(Error, "Failed to marshal ethernet")
-				return false, nil
-			}
+	// Build IPv4 Header
+	iph := &ipv4.Header{
+		Version:  4,
+		Len:      20, // Precalculated header length
+		TOS:      0,
+		TotalLen: len(payloadICMP) + 20,
+		ID:       25,
+		TTL:      64,
+		Protocol: 1,
+		Dst:      header.Src,
+		Src:      header.Dst,
+		Checksum: 0,
+	}
+	ipHeader, err := iph.Marshal()
+	if err != nil {
+		Log(Error, "Failed to marshal header: %s", err.Error())
+		return nil, nil
+	}
 
-			// Calculate CRC32 checksum for ethernet frame
-			crc := make([]byte, 4)
-			binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(rpacket))
-			rpacket = append(rpacket, crc...)
+	// Calculate IPv4 header checksum
+	hcsum := checksum(ipHeader)
+	binary.BigEndian.PutUint16(ipHeader[10:], hcsum)
 
-			// Send frame to the interface
-			// P2P will drop packet afterwards
-			tap.WritePacket(&Packet{int(PacketIPv4), rpacket})
-			return true, nil
-		}
+	// Build new ethernet frame. Swap dst/src
+	pl := append(ipHeader, payloadICMP...)
+	nf := new(ethernet.Frame)
+	nf.Destination = f.Source
+	nf.Source = f.Destination
+	nf.EtherType = ethernet.EtherTypeIPv4
+	nf.Payload = pl
+	rpacket, err := nf.MarshalBinary()
+	if err != nil {
+		Log(Error, "Failed to marshal ethernet")
+		return nil, nil
 	}
-	return false, fmt.Errorf("Unsupported protocol for PMTU")
+
+	// Calculate CRC32 checksum for ethernet frame
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(rpacket))
+	return append(rpacket, crc...), nil
 }
@@ -0,0 +1,307 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Path MTU of endpoints every peer is sent to is searched with padded
+// probes, as in DPLPMTUD (RFC 8899). Probe is a MsgTypeTest message with
+// the following payload:
+// type[1] token[4] padding[?]
+// type is 'q' for probes and 'r' for replies. Replies are not padded
+
+// Path MTU discovery timings
+const (
+	pmtuProbeTimeout      = time.Second      // Probe without reply is considered lost
+	pmtuProbeAttempts     = 3                // Lost probes before size is considered too large
+	pmtuSearchInterval    = time.Minute * 10 // Path MTU is searched again after this time
+	pmtuSearchGranularity = 32               // Search stops when bounds are this close
+)
+
+// Path MTU probe types
+const (
+	pmtuProbeRequest byte = 'q'
+	pmtuProbeReply   byte = 'r'
+)
+
+// mtuSearch is a state of path MTU search. It's used by the goroutine of
+// the peer only
+type mtuSearch struct {
+	low      int       // Largest size known to pass
+	high     int       // Largest size not known to be lost. 0 when search isn't running
+	first    bool      // Whether largest size wasn't probed yet
+	size     int       // Size of the probe in flight. 0 when there is none
+	token    uint32    // Token of the probe in flight
+	sent     time.Time // When the probe was sent
+	attempts int       // Number of probes of this size
+	finished time.Time // When last search was finished
+}
+
+// MTU returns discovered path MTU of the endpoint, or 0 when it's unknown
+func (e *Endpoint) MTU() int {
+	return int(atomic.LoadUint32(&e.mtu))
+}
+
+// probeMTU advances path MTU search. Search starts with the largest size,
+// max, and continues with binary search between MinUnderlayMTU and max
+func (e *Endpoint) probeMTU(ptpc *PeerToPeer, max int, now time.Time) error {
+	s := &e.search
+	if s.size != 0 {
+		switch {
+		case atomic.LoadUint32(&e.probeAck) == s.token:
+			if s.size > s.low {
+				s.low = s.size
+			}
+			s.size = 0
+		case now.Sub(s.sent) < pmtuProbeTimeout:
+			return nil
+		case s.attempts < pmtuProbeAttempts:
+			return e.sendProbe(ptpc, s.size, now)
+		default:
+			s.high = s.size - 1
+			s.size = 0
+		}
+	} else if s.high == 0 {
+		if now.Sub(s.finished) < pmtuSearchInterval {
+			return nil
+		}
+		s.low, s.high, s.first = MinUnderlayMTU, max, true
+	}
+	if s.high-s.low < pmtuSearchGranularity {
+		if old := e.MTU(); old != s.low {
+			Log(Debug, "Path MTU of %s: %d -> %d", e.Addr, old, s.low)
+		}
+		atomic.StoreUint32(&e.mtu, uint32(s.low))
+		s.high = 0
+		s.finished = now
+		return nil
+	}
+	// Most paths carry the largest size, so it's probed first
+	size := (s.low + s.high + 1) / 2
+	if s.first {
+		size = s.high
+		s.first = false
+	}
+	s.attempts = 0
+	return e.sendProbe(ptpc, size, now)
+}
+
+// sendProbe sends a probe that makes a datagram of at most size bytes,
+// including IP and UDP headers
+func (e *Endpoint) sendProbe(ptpc *PeerToPeer, size int, now time.Time) error {
+	if ptpc.UDPSocket == nil {
+		return fmt.Errorf("nil socket")
+	}
+	if e.Addr == nil {
+		return fmt.Errorf("nil addr")
+	}
+	token := atomic.AddUint32(&ptpc.probeToken, 1)
+	payload := make([]byte, size-underlayOverhead-HeaderSize)
+	payload[0] = pmtuProbeRequest
+	binary.BigEndian.PutUint32(payload[1:5], token)
+	var msg *P2PMessage
+	for {
+		var err error
+		msg, err = ptpc.CreateMessage(MsgTypeTest, payload, 0, true)
+		if err != nil {
+			return err
+		}
+		// Encryption adds IV and padding
+		over := underlayOverhead + HeaderSize + len(msg.Data) - size
+		if over <= 0 {
+			break
+		}
+		payload = payload[:len(payload)-over]
+	}
+
+	s := &e.search
+	s.size = underlayOverhead + HeaderSize + len(msg.Data)
+	s.token = token
+	s.sent = now
+	s.attempts++
+	_, err := ptpc.UDPSocket.sendProbe(msg, e.Addr)
+	if err != nil {
+		// Probe larger than MTU of local interface is rejected by the
+		// kernel. It's considered lost without waiting
+		s.attempts = pmtuProbeAttempts
+		s.sent = time.Time{}
+	}
+	return err
+}
+
+// discoverMTU searches path MTU of the active endpoint of the peer and of
+// other endpoints used in multipath mode. Any of them may carry a frame,
+// so the smallest discovered MTU is used for the peer. Endpoints with
+// unknown MTU are limited by underlay MTU, which is never smaller
+func (np *NetworkPeer) discoverMTU(ptpc *PeerToPeer) error {
+	if np.Caps()&CapPMTU == 0 || np.Endpoint == nil || ptpc.UDPSocket == nil {
+		return nil
+	}
+	used := map[string]bool{np.Endpoint.String(): true}
+	if set, _ := np.paths.Load().(*pathSet); set != nil && ptpc.Multipath() != MultipathOff {
+		for _, addr := range set.endpoints {
+			used[addr.String()] = true
+		}
+	}
+	probed := []*Endpoint{}
+	np.Lock.RLock()
+	for _, ep := range np.EndpointsHeap {
+		if ep.Addr != nil && used[ep.Addr.String()] {
+			probed = append(probed, ep)
+		}
+	}
+	np.Lock.RUnlock()
+
+	var err error
+	mtu := 0
+	now := time.Now()
+	for _, ep := range probed {
+		if e := ep.probeMTU(ptpc, ptpc.pathMTU(nil), now); e != nil {
+			err = e
+		}
+		if m := ep.MTU(); m != 0 && (mtu == 0 || m < mtu) {
+			mtu = m
+		}
+	}
+	atomic.StoreUint32(&np.pathMTU, uint32(mtu))
+	return err
+}
+
+// PathMTU returns the smallest path MTU discovered for endpoints frames
+// are sent to, or 0 when it's unknown
+func (np *NetworkPeer) PathMTU() int {
+	return int(atomic.LoadUint32(&np.pathMTU))
+}
+
+// pathMTU returns MTU of the path to the peer: discovered one, if it's
+// known, or configured underlay MTU
+func (p *PeerToPeer) pathMTU(peer *NetworkPeer) int {
+	mtu := p.underlayMTU
+	if mtu == 0 {
+		mtu = DefaultUnderlayMTU
+	}
+	if peer != nil {
+		if path := peer.PathMTU(); path != 0 && path < mtu {
+			mtu = path
+		}
+	}
+	return mtu
+}
+
+// HandleTestMessage answers path MTU probes and acknowledges probes
+// answered by peers
+func (p *PeerToPeer) HandleTestMessage(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+	if msg == nil {
+		return fmt.Errorf("nil message")
+	}
+	if srcAddr == nil {
+		return fmt.Errorf("nil source addr")
+	}
+	if p.UDPSocket == nil {
+		return fmt.Errorf("nil socket")
+	}
+	if p.Swarm == nil {
+		return fmt.Errorf("nil peer list")
+	}
+	if len(msg.Data) < 5 {
+		return fmt.Errorf("payload is too small")
+	}
+	token := binary.BigEndian.Uint32(msg.Data[1:5])
+	switch msg.Data[0] {
+	case pmtuProbeRequest:
+		payload := make([]byte, 5)
+		payload[0] = pmtuProbeReply
+		binary.BigEndian.PutUint32(payload[1:5], token)
+		reply, err := p.CreateMessage(MsgTypeTest, payload, 0, true)
+		if err != nil {
+			return err
+		}
+		_, err = p.UDPSocket.SendMessage(reply, srcAddr)
+		return err
+	case pmtuProbeReply:
+		found := false
+		for _, peer := range p.Swarm.Get() {
+			peer.Lock.RLock()
+			// Peers behind the same proxy share an endpoint address.
+			// Tokens are unique, so each of them is acknowledged
+			for _, ep := range peer.EndpointsHeap {
+				if ep.Addr != nil && ep.Addr.String() == srcAddr.String() {
+					atomic.StoreUint32(&ep.probeAck, token)
+					found = true
+				}
+			}
+			peer.Lock.RUnlock()
+		}
+		if !found {
+			return fmt.Errorf("Probe reply from unknown endpoint %s", srcAddr)
+		}
+		return nil
+	}
+	return fmt.Errorf("Unknown test message from %s", srcAddr)
+}
+
+// clampFrame fits IPv4 frame read from the interface into the path to the
+// peer. MSS of TCP SYN is lowered, so connection never sends segments
+// larger than a datagram. Larger frames with Don't Fragment flag are
+// answered with ICMP "fragmentation needed", unless the peer reassembles
+// fragments. Returns true when frame must be dropped
+func (p *PeerToPeer) clampFrame(frame []byte, peer *NetworkPeer) bool {
+	mtu := p.maxDatagram(peer) - messageOverhead - ethernetHeaderLength
+	clampMSS(frame, mtu-40)
+	if len(frame)-ethernetHeaderLength <= mtu || peer.Caps()&CapFragment != 0 {
+		return false
+	}
+	reply, err := fragmentationNeeded(frame, mtu)
+	if err != nil || reply == nil {
+		return false
+	}
+	p.Interface.WritePacket(&Packet{Protocol: int(PacketIPv4), Packet: reply})
+	return true
+}
+
+// clampMSS lowers MSS option of TCP SYN in IPv4 frame. TCP checksum is
+// updated incrementally (RFC 1624). Returns true when frame was changed
+func clampMSS(frame []byte, mss int) bool {
+	if len(frame) < ethernetHeaderLength+40 || binary.BigEndian.Uint16(frame[12:14]) != uint16(PacketIPv4) {
+		return false
+	}
+	ip := frame[ethernetHeaderLength:]
+	ihl := int(ip[0]&0x0f) * 4
+	if ip[9] != 6 || ihl < 20 || binary.BigEndian.Uint16(ip[6:8])&0x1fff != 0 || len(ip) < ihl+20 {
+		return false
+	}
+	tcp := ip[ihl:]
+	offset := int(tcp[12]>>4) * 4
+	if tcp[13]&tcpFlagSYN == 0 || offset < 20 || offset > len(tcp) {
+		return false
+	}
+	options := tcp[20:offset]
+	for i := 0; i < len(options); {
+		switch options[i] {
+		case 0: // End of options
+			return false
+		case 1: // No operation
+			i++
+			continue
+		}
+		if i+1 >= len(options) || options[i+1] < 2 || i+int(options[i+1]) > len(options) {
+			return false
+		}
+		if options[i] == 2 && options[i+1] == 4 {
+			old := binary.BigEndian.Uint16(options[i+2 : i+4])
+			if int(old) <= mss {
+				return false
+			}
+			binary.BigEndian.PutUint16(options[i+2:i+4], uint16(mss))
+			sum := uint32(^binary.BigEndian.Uint16(tcp[16:18])) + uint32(^old) + uint32(uint16(mss))
+			binary.BigEndian.PutUint16(tcp[16:18], ^checksumFold(sum))
+			return true
+		}
+		i += int(options[i+1])
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package ptp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendProbe sends path MTU probe with Don't Fragment flag. Socket is
+// switched to IP_PMTUDISC_PROBE for a single write, so the probe is
+// neither fragmented locally nor limited by path MTU cached by the kernel.
+// Other writes wait until the socket is switched back, so data datagrams
+// never get the flag, and probes sent at the same time don't overlap
+func (uc *Network) sendProbe(msg *P2PMessage, dstAddr *net.UDPAddr) (int, error) {
+	if uc.conn == nil {
+		return -1, fmt.Errorf("Nil connection")
+	}
+	if msg == nil {
+		return 0, fmt.Errorf("Nil message")
+	}
+	raw, err := uc.conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	uc.sendLock.Lock()
+	defer uc.sendLock.Unlock()
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		// Mode is read once, before any probe, so it's always the one
+		// socket was opened with
+		if !uc.pmtuSaved {
+			uc.pmtuMode, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+			if sockErr != nil {
+				return
+			}
+			uc.pmtuSaved = true
+		}
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE)
+	})
+	if err == nil {
+		err = sockErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	n, err := uc.writeTo(msg.Serialize(), dstAddr)
+	restoreErr := raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, uc.pmtuMode)
+	})
+	if restoreErr == nil {
+		restoreErr = sockErr
+	}
+	if restoreErr != nil {
+		Log(Error, "Failed to restore path MTU discovery mode: %s", restoreErr)
+	}
+	return n, err
+}
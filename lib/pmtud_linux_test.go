@@ -0,0 +1,59 @@
+// +build linux
+
+package ptp
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNetwork_sendProbe(t *testing.T) {
+	uc := new(Network)
+	if err := uc.Init("", 0); err != nil {
+		t.Fatalf("Init() failed: %s", err)
+	}
+	defer uc.Close()
+	raw, err := uc.conn.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mode := func() int {
+		var m int
+		raw.Control(func(fd uintptr) {
+			m, _ = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+		})
+		return m
+	}
+	raw.Control(func(fd uintptr) {
+		unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DONT)
+	})
+
+	dst := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: uc.GetPort()}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		probe, _ := CreateMessageStatic(MsgTypeTest, []byte("probe"))
+		data := probe.Serialize()
+		go func() {
+			defer wg.Done()
+			if _, err := uc.sendProbe(probe, dst); err != nil {
+				t.Errorf("sendProbe() failed: %s", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			uc.SendRawBytes(data, dst)
+		}()
+	}
+	wg.Wait()
+	// Overlapping probes must not leave the socket in probe mode
+	if m := mode(); m != unix.IP_PMTUDISC_DONT {
+		t.Errorf("IP_MTU_DISCOVER = %d after probes, want %d", m, unix.IP_PMTUDISC_DONT)
+	}
+	if _, err := uc.sendProbe(nil, dst); err == nil {
+		t.Errorf("sendProbe() of nil message succeeded")
+	}
+}
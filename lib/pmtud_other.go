@@ -0,0 +1,12 @@
+// +build !linux
+
+package ptp
+
+import "net"
+
+// sendProbe sends path MTU probe. Don't Fragment flag can't be controlled
+// on this platform, so probes may be fragmented on the way and discovered
+// MTU stays at the configured one
+func (uc *Network) sendProbe(msg *P2PMessage, dstAddr *net.UDPAddr) (int, error) {
+	return uc.SendMessage(msg, dstAddr)
+}
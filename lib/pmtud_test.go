@@ -0,0 +1,176 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tcpSYN returns TCP SYN frame with the given options
+func tcpSYN(options []byte) []byte {
+	frame := tcpSegment(1000, 1, tcpFlagSYN, options)
+	frame[14+20+12] = byte(5+len(options)/4) << 4
+	finishTCPFrame(frame, tcpFrame{ipLen: 20, tcpLen: 20 + len(options)}, false)
+	return frame
+}
+
+func TestClampMSS(t *testing.T) {
+	mss := func(value uint16) []byte {
+		return []byte{1, 1, 2, 4, byte(value >> 8), byte(value)}
+	}
+	tests := []struct {
+		name    string
+		frame   []byte
+		want    bool
+		wantMSS uint16
+	}{
+		{"larger mss", tcpSYN(append(mss(1460), 1, 1)), true, 1300},
+		{"smaller mss", tcpSYN(append(mss(1200), 1, 1)), false, 1200},
+		{"no mss", tcpSYN([]byte{1, 1, 1, 0}), false, 0},
+		{"broken options", tcpSYN([]byte{1, 3, 9, 0}), false, 0},
+		{"not syn", tcpSegment(1000, 1, tcpFlagACK, make([]byte, 20)), false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clampMSS(tt.frame, 1300); got != tt.want {
+				t.Errorf("clampMSS() = %v, want %v", got, tt.want)
+			}
+			checkSegment(t, tt.frame)
+			if tt.wantMSS != 0 {
+				if got := binary.BigEndian.Uint16(tt.frame[14+20+24:]); got != tt.wantMSS {
+					t.Errorf("MSS = %d, want %d", got, tt.wantMSS)
+				}
+			}
+		})
+	}
+}
+
+func TestFragmentationNeeded(t *testing.T) {
+	frame := tcpSegment(1000, 1, tcpFlagACK, make([]byte, 1400))
+	reply, err := fragmentationNeeded(frame, 1200)
+	if err != nil || reply == nil {
+		t.Fatalf("No reply to frame with Don't Fragment flag: %v", err)
+	}
+	icmp := reply[14+20:]
+	if icmp[0] != 3 || icmp[1] != 4 || binary.BigEndian.Uint16(icmp[6:8]) != 1200 {
+		t.Errorf("Wrong ICMP message: %v", icmp[:8])
+	}
+	if string(reply[0:6]) != string(frame[6:12]) {
+		t.Errorf("Reply is sent to %v", net.HardwareAddr(reply[0:6]))
+	}
+
+	frame[14+6] = 0
+	if reply, _ := fragmentationNeeded(frame, 1200); reply != nil {
+		t.Errorf("Reply to frame without Don't Fragment flag")
+	}
+}
+
+func TestEndpoint_probeMTU(t *testing.T) {
+	sender, receiver := loopbackPair(t)
+	defer sender.Close()
+	defer receiver.Close()
+
+	// Receiver drops datagrams larger than limit
+	limit := 1200
+	var seen, answered uint32 // Number of probes and token of the last answered one
+	r := &PeerToPeer{UDPSocket: receiver, Swarm: new(Swarm)}
+	r.Swarm.Init()
+	r.MessageHandlers = map[uint16]MessageHandler{
+		MsgTypeTest: func(msg *P2PMessage, srcAddr *net.UDPAddr) error {
+			defer atomic.AddUint32(&seen, 1)
+			if underlayOverhead+HeaderSize+len(msg.Data) > limit {
+				return nil
+			}
+			atomic.StoreUint32(&answered, binary.BigEndian.Uint32(msg.Data[1:5]))
+			return r.HandleTestMessage(msg, srcAddr)
+		},
+	}
+	go receiver.ListenBatch(func(batch []UDPDatagram) {
+		for _, d := range batch {
+			r.HandleP2PMessage(len(d.Data), d.Addr, nil, d.Data)
+		}
+	})
+
+	p := &PeerToPeer{UDPSocket: sender, Swarm: new(Swarm)}
+	p.Swarm.Init()
+	ep := &Endpoint{Addr: loopbackAddr(receiver)}
+	p.Swarm.Update("peer", &NetworkPeer{ID: "peer", EndpointsHeap: []*Endpoint{ep}})
+	p.setupHandlers()
+	go sender.ListenBatch(func(batch []UDPDatagram) {
+		for _, d := range batch {
+			p.HandleP2PMessage(len(d.Data), d.Addr, nil, d.Data)
+		}
+	})
+
+	// wait returns when the last probe was handled by receiver and its
+	// reply, if any, was received
+	wait := func() {
+		sent := atomic.LoadUint32(&p.probeToken)
+		for i := 0; i < 100 && atomic.LoadUint32(&seen) < sent; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if atomic.LoadUint32(&answered) != ep.search.token {
+			return
+		}
+		for i := 0; i < 100 && atomic.LoadUint32(&ep.probeAck) != ep.search.token; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	now := time.Now()
+	for i := 0; i < 100 && ep.MTU() == 0; i++ {
+		if err := ep.probeMTU(p, DefaultUnderlayMTU, now); err != nil {
+			t.Fatal(err)
+		}
+		wait()
+		now = now.Add(pmtuProbeTimeout)
+	}
+	if ep.MTU() > limit || ep.MTU() <= limit-pmtuSearchGranularity {
+		t.Fatalf("Discovered MTU %d, want up to %d", ep.MTU(), limit)
+	}
+
+	// Search is repeated after interval
+	sent := atomic.LoadUint32(&p.probeToken)
+	ep.probeMTU(p, DefaultUnderlayMTU, now)
+	if atomic.LoadUint32(&p.probeToken) != sent {
+		t.Errorf("Probe sent before search interval")
+	}
+	ep.probeMTU(p, DefaultUnderlayMTU, now.Add(pmtuSearchInterval))
+	if atomic.LoadUint32(&p.probeToken) != sent+1 || ep.search.size <= limit {
+		t.Errorf("Search wasn't repeated with the largest size")
+	}
+}
+
+func TestNetworkPeer_discoverMTU(t *testing.T) {
+	p := &PeerToPeer{UDPSocket: new(Network)}
+	np := multipathPeer(10*time.Millisecond, 20*time.Millisecond, 30*time.Millisecond)
+	np.setCaps(CapPMTU)
+	// Search of every endpoint is finished, so nothing is probed
+	for i, mtu := range []uint32{1400, 1300, 1200} {
+		np.EndpointsHeap[i].mtu = mtu
+		np.EndpointsHeap[i].search.finished = time.Now()
+	}
+
+	np.updatePaths(p.Multipath())
+	np.discoverMTU(p)
+	if np.PathMTU() != 1400 {
+		t.Errorf("PathMTU() of active endpoint = %d, want 1400", np.PathMTU())
+	}
+
+	// Frames of any flow may go over the slowest path
+	p.SetMultipath(MultipathRoundRobin)
+	np.updatePaths(p.Multipath())
+	np.discoverMTU(p)
+	if np.PathMTU() != 1200 || p.maxDatagram(np) != 1200-underlayOverhead {
+		t.Errorf("PathMTU() of path set = %d, want 1200", np.PathMTU())
+	}
+
+	// Endpoint with unknown MTU is limited by underlay MTU
+	np.EndpointsHeap[2].mtu = 0
+	np.discoverMTU(p)
+	if np.PathMTU() != 1300 {
+		t.Errorf("PathMTU() with unknown endpoint = %d, want 1300", np.PathMTU())
+	}
+}
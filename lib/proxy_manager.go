@@ -72,7 +72,9 @@ func (p *ProxyManager) new(endpoint *net.UDPAddr) error {
 	return nil
 }
 
-func (p *ProxyManager) check() {
+// check closes inactive proxies and returns list of removed ones
+func (p *ProxyManager) check() []string {
+	removed := []string{}
 	proxies := p.get()
 	for id, proxy := range proxies {
 		if proxy.Status == proxyConnecting && time.Since(proxy.Created) > time.Duration(10*time.Second) {
@@ -93,8 +95,10 @@ func (p *ProxyManager) check() {
 			Log(Debug, "Removing proxy %s", id)
 			p.operate(OperateDelete, id, nil)
 			p.hasChanges = true
+			removed = append(removed, id)
 		}
 	}
+	return removed
 }
 
 func (p *ProxyManager) touch(id string) bool {
@@ -140,7 +144,18 @@ func (p *ProxyManager) setLatency(l time.Duration, addr *net.UDPAddr) error {
 	return fmt.Errorf("latency set failed: proxy not found: %s", addr.String())
 }
 
-// getBestProxy will return best proxy server based on latency
+// latencyReceived accounts response to the latency request sent to proxy
+func (p *ProxyManager) latencyReceived(addr *net.UDPAddr, seq uint32) {
+	for _, proxy := range p.get() {
+		if proxy.Addr != nil && proxy.Addr.String() == addr.String() {
+			proxy.window.received(seq, time.Now())
+			return
+		}
+	}
+}
+
+// getBestProxy will return best proxy server based on latency, jitter and
+// loss
 func (p *ProxyManager) getBestProxy() *proxyServer {
 	var bp *proxyServer
 	var min int64 = 0
@@ -148,14 +163,15 @@ func (p *ProxyManager) getBestProxy() *proxyServer {
 		if proxy.Status != proxyActive {
 			continue
 		}
+		score := proxy.score().Nanoseconds()
 		if min == 0 {
-			min = proxy.Latency.Nanoseconds()
+			min = score
 			bp = proxy
 			continue
 		}
-		if min > proxy.Latency.Nanoseconds() {
+		if min > score {
 			bp = proxy
-			min = proxy.Latency.Nanoseconds()
+			min = score
 			continue
 		}
 	}
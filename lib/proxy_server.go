@@ -14,6 +14,7 @@ type proxyServer struct {
 	Latency           time.Duration // Measured latency
 	LastLatencyQuery  time.Time     // Last latency request
 	MeasureInProgress bool          // Whether or not this proxy is measuring latency currently
+	window            latencyWindow // Results of recent latency requests
 }
 
 // Init will initialize Proxy Server
@@ -26,6 +27,11 @@ func (p *proxyServer) Init(addr *net.UDPAddr) error {
 	return nil
 }
 
+// IsActive returns true when proxy is ready to forward traffic
+func (p *proxyServer) IsActive() bool {
+	return p.Status == proxyActive
+}
+
 // Close will stop proxy
 func (p *proxyServer) Close() error {
 	Log(Info, "Stopping proxy %s, Endpoint: %s", p.Addr.String(), p.Endpoint.String())
@@ -46,13 +52,12 @@ func (p *proxyServer) Measure(n *Network) {
 		return
 	}
 
-	if p.MeasureInProgress {
-		return
-	}
-
+	// Request is sent again when response to the previous one was lost
 	p.MeasureInProgress = true
-	ts, _ := time.Now().MarshalBinary()
-	msg, err := CreateMessageStatic(MsgTypeLatency, append(LatencyProxyHeader, ts...))
+	p.LastLatencyQuery = time.Now()
+	ts, _ := p.LastLatencyQuery.MarshalBinary()
+	payload := append(append([]byte{}, LatencyProxyHeader...), ts...)
+	msg, err := CreateMessageStatic(MsgTypeLatency, appendSeq(payload, p.window.sent(p.LastLatencyQuery)))
 	if err != nil {
 		Log(Error, "Failed to create latency measurement packet for proxy: %s", err.Error())
 		p.LastLatencyQuery = time.Now()
@@ -62,3 +67,18 @@ func (p *proxyServer) Measure(n *Network) {
 	Log(Trace, "Measuring latency with proxy %s", p.Addr.String())
 	n.SendMessage(msg, p.Addr)
 }
+
+// Stats returns round-trip time, jitter and loss of recent latency
+// requests sent to the proxy
+func (p *proxyServer) Stats() LatencyStats {
+	return p.window.stats(time.Now())
+}
+
+// score ranks proxy by latency, jitter and loss. Lower is better
+func (p *proxyServer) score() time.Duration {
+	q := p.window.quality()
+	if q.Samples == 0 {
+		return p.Latency
+	}
+	return q.RTT + scoreJitterFactor*q.Jitter + time.Duration(q.Loss*float64(scoreLossPenalty))
+}
@@ -136,6 +136,17 @@ func (l *Swarm) GetEndpoint(mac string) (*net.UDPAddr, error) {
 	return nil, fmt.Errorf("Specified hardware address was not found in table")
 }
 
+// GetPeerByMac returns peer by hardware address of its interface
+func (l *Swarm) GetPeerByMac(mac string) *NetworkPeer {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	id, exists := l.tableMacID[mac]
+	if !exists {
+		return nil
+	}
+	return l.peers[id]
+}
+
 // GetID returns ID by specified IP
 func (l *Swarm) GetID(ip string) (string, error) {
 	l.lock.RLock()
@@ -158,8 +169,9 @@ func (l *Swarm) RunPeer(id string, p *PeerToPeer) {
 	Log(Info, "Running peer %s", id)
 	l.lock.RLock()
 	defer l.lock.RUnlock()
-	if !l.peers[id].IsRunning() {
-		go l.peers[id].Run(p)
+	peer := l.peers[id]
+	if !peer.IsRunning() {
+		p.spawn(func() { peer.Run(p) })
 	} else {
 		Log(Info, "Peer %s is already running", id)
 	}
@@ -1,19 +1,25 @@
 package ptp
 
 import (
+	"encoding/binary"
 	"errors"
 	"net"
 )
 
 const (
-	flagMF      = 0x10
-	flagDF      = 0x1
-	iffTun      = 0x1
-	iffTap      = 0x2
-	iffOneQueue = 0x2000
-	iffnopi     = 0x1000
+	flagMF        = 0x10
+	flagDF        = 0x1
+	iffTun        = 0x1
+	iffTap        = 0x2
+	iffOneQueue   = 0x2000
+	iffnopi       = 0x1000
+	iffMultiQueue = 0x0100
+	iffVNetHdr    = 0x4000
 )
 
+// MaxTAPQueues is a maximum number of TAP queues supported by Linux
+const MaxTAPQueues = 256
+
 var (
 	errPacketTooBig      = errors.New("Packet exceeds MTU")
 	errICMPMarshalFailed = errors.New("Failed to marshal ICMP")
@@ -30,6 +36,7 @@ type ifReq struct {
 type Packet struct {
 	Protocol int
 	Packet   []byte
+	GSO      VNetHeader // Set for super-packets read from interface with offload
 }
 
 // InterfaceStatus holds Status of the network Interface
@@ -54,11 +61,13 @@ type TAP interface {
 	GetSubnet() net.IP
 	GetMask() net.IPMask
 	GetBasename() string
+	GetMTU() int
 	SetName(string)
 	SetHardwareAddress(net.HardwareAddr)
 	SetIP(net.IP)
 	SetSubnet(net.IP)
 	SetMask(net.IPMask)
+	SetMTU(int)
 	Init(string) error
 	Open() error
 	Close() error
@@ -77,3 +86,51 @@ type TAP interface {
 	IsAuto() bool
 	GetStatus() InterfaceStatus
 }
+
+// frameBufferSize returns size of a buffer that holds any frame of an
+// interface with the given MTU
+func frameBufferSize(mtu int) int {
+	size := mtu + ethernetHeaderLength + 4 // 802.1Q tag
+	if size < packetBufferSize {
+		return packetBufferSize
+	}
+	return size
+}
+
+// MultiQueueTAP is implemented by TAP devices that can be opened with
+// several queues. Every queue is read by its own goroutine
+type MultiQueueTAP interface {
+	SetQueues(int)
+	QueueCount() int
+	ReadQueue(queue int, buf []byte) (*Packet, error)
+}
+
+// OffloadTAP is implemented by TAP devices that can exchange frames with
+// virtio-net header. With offload enabled, interface reads and writes TCP
+// super-packets up to 64KB and frames with partial checksums
+type OffloadTAP interface {
+	SetOffload(bool)
+	OffloadEnabled() bool
+	WriteOffload(h VNetHeader, frame []byte) error
+	Flush() error
+}
+
+// flowHash returns the same value for all frames of a TCP or UDP flow in
+// both directions. Other frames are hashed by their addresses
+func flowHash(frame []byte) uint32 {
+	if len(frame) < 14 {
+		return 0
+	}
+	if PacketType(binary.BigEndian.Uint16(frame[12:14])) != PacketIPv4 || len(frame) < 34 {
+		return hashKey(frame[0:6]) ^ hashKey(frame[6:12])
+	}
+	ip := frame[14:]
+	ihl := int(ip[0]&0x0f) * 4
+	proto := ip[9]
+	h := hashKey(ip[12:16]) ^ hashKey(ip[16:20]) ^ uint32(proto)
+	if (proto == 6 || proto == 17) && len(ip) >= ihl+4 && binary.BigEndian.Uint16(ip[6:8])&0x1fff == 0 {
+		ports := ip[ihl : ihl+4]
+		h ^= uint32(binary.BigEndian.Uint16(ports[0:2])) ^ uint32(binary.BigEndian.Uint16(ports[2:4]))
+	}
+	return h * 16777619
+}
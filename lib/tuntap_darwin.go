@@ -28,6 +28,9 @@ func GetConfigurationTool() string {
 
 func newTAP(tool, ip, mac, mask string, mtu int, pmtu bool) (*TAPDarwin, error) {
 	Log(Info, "Acquiring TAP interface [Darwin]")
+	if mtu == 0 {
+		mtu = DefaultMTU
+	}
 	nip := net.ParseIP(ip)
 	if nip == nil {
 		return nil, fmt.Errorf("Failed to parse IP during TAP creation")
@@ -41,7 +44,7 @@ func newTAP(tool, ip, mac, mask string, mtu int, pmtu bool) (*TAPDarwin, error)
 		IP:   nip,
 		Mac:  nmac,
 		Mask: net.IPv4Mask(255, 255, 255, 0), // Unused yet
-		MTU:  DefaultMTU,
+		MTU:  mtu,
 		PMTU: pmtu,
 	}, nil
 }
@@ -95,6 +98,11 @@ func (t *TAPDarwin) GetBasename() string {
 	return "tap"
 }
 
+// GetMTU returns MTU of the interface
+func (t *TAPDarwin) GetMTU() int {
+	return t.MTU
+}
+
 // SetName will set interface name
 func (t *TAPDarwin) SetName(name string) {
 	t.Name = name
@@ -119,6 +127,11 @@ func (t *TAPDarwin) SetMask(mask net.IPMask) {
 	t.Mask = mask
 }
 
+// SetMTU will set MTU. Takes effect on next configuration
+func (t *TAPDarwin) SetMTU(mtu int) {
+	t.MTU = mtu
+}
+
 // Init will initialize TAP interface creation process
 func (t *TAPDarwin) Init(name string) error {
 	if name == "" {
@@ -199,18 +212,6 @@ func (t *TAPDarwin) ReadPacket() (*Packet, error) {
 	var pkt *Packet
 	pkt = &Packet{Packet: buf[0:n]}
 	pkt.Protocol = int(binary.BigEndian.Uint16(buf[12:14]))
-
-	if !t.IsPMTUEnabled() {
-		return pkt, nil
-	}
-
-	if pkt.Protocol == int(PacketIPv4) {
-		// Return packet
-		skip, err := pmtu(buf, t)
-		if skip {
-			return nil, err
-		}
-	}
 	return pkt, nil
 }
 
@@ -272,11 +273,6 @@ func FilterInterface(infName, infIP string) bool {
 	if len(infIP) > 4 && infIP[0:3] == "172" {
 		return true
 	}
-	for _, ip := range ActiveInterfaces {
-		if ip.String() == infIP {
-			return true
-		}
-	}
 	Log(Trace, "ping -t 1 -c 1 -S %s ptest.subutai.io", infIP)
 	ping := exec.Command("ping", "-t", "1", "-c", "1", "-S", infIP, "ptest.subutai.io")
 	if ping.Run() != nil {
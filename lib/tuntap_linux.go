@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"sync"
 	"syscall"
 	"unsafe"
 )
@@ -32,6 +33,9 @@ func GetConfigurationTool() string {
 
 func newTAP(tool, ip, mac, mask string, mtu int, pmtu bool) (*TAPLinux, error) {
 	Log(Debug, "Acquiring TAP interface [Linux]")
+	if mtu == 0 {
+		mtu = DefaultMTU
+	}
 	nip := net.ParseIP(ip)
 	if nip == nil {
 		return nil, fmt.Errorf("Failed to parse IP during TAP creation")
@@ -45,7 +49,7 @@ func newTAP(tool, ip, mac, mask string, mtu int, pmtu bool) (*TAPLinux, error) {
 		IP:   nip,
 		Mac:  nmac,
 		Mask: net.IPv4Mask(255, 255, 255, 0), // Unused yet
-		MTU:  GlobalMTU,
+		MTU:  mtu,
 		PMTU: pmtu,
 	}, nil
 }
@@ -68,7 +72,14 @@ type TAPLinux struct {
 	PMTU       bool             // Enables/Disbles PMTU
 	Auto       bool
 	Status     InterfaceStatus
-	file       *os.File // Interface descriptor
+	Queues     int        // Number of queues requested. Single queue is opened when less than 2
+	file       *os.File   // Interface descriptor
+	queues     []*os.File // Descriptors of every queue. First one is file
+	fds        []int      // Raw descriptors of queues, used for vectored writes
+	Offload    bool       // Whether virtio-net header offload is requested
+	vnet       bool       // Whether interface was opened with virtio-net header
+	gro        *groTable  // Coalesces written segments when vnet is set
+	groLock    sync.Mutex
 	//file       unix.FileHandle  // TAP Interface File Handle
 }
 
@@ -101,6 +112,11 @@ func (tap *TAPLinux) GetBasename() string {
 	return "vptp"
 }
 
+// GetMTU returns MTU of the interface
+func (tap *TAPLinux) GetMTU() int {
+	return tap.MTU
+}
+
 // SetName will set interface name
 func (tap *TAPLinux) SetName(name string) {
 	tap.Name = name
@@ -125,6 +141,11 @@ func (tap *TAPLinux) SetMask(mask net.IPMask) {
 	tap.Mask = mask
 }
 
+// SetMTU will set MTU. Takes effect on next configuration
+func (tap *TAPLinux) SetMTU(mtu int) {
+	tap.MTU = mtu
+}
+
 // Init will initialize TAP interface creation process
 func (tap *TAPLinux) Init(name string) error {
 	if name == "" {
@@ -134,20 +155,86 @@ func (tap *TAPLinux) Init(name string) error {
 	return nil
 }
 
-// Open will open a file descriptor for a new interface
+// Open will open a file descriptor for a new interface. When more than
+// one queue is requested, interface is created with IFF_MULTI_QUEUE and
+// a descriptor is opened for every queue. Kernels without multi-queue
+// support get a single queue
 func (tap *TAPLinux) Open() error {
-	var err error
 	if tap.file != nil {
 		return fmt.Errorf("TAP device is already acquired")
 	}
-	tap.fd, err = unix.Open("/dev/net/tun", os.O_RDWR, 0)
+	count := tap.Queues
+	if count < 1 {
+		count = 1
+	}
+	var flags uint16 = iffTap | iffnopi
+	if count > 1 {
+		flags |= iffMultiQueue
+	}
+	if tap.Offload {
+		flags |= iffVNetHdr
+	}
+	queues, fds, err := tap.openQueues(count, flags)
+	if err == unix.EINVAL && count > 1 {
+		Log(Warning, "Kernel rejected %d queues for %s: %s. Using a single queue", count, tap.Name, err)
+		count = 1
+		queues, fds, err = tap.openQueues(count, flags&^iffMultiQueue)
+	}
 	if err != nil {
 		return err
 	}
-	tap.file = os.NewFile(uintptr(tap.fd), "/dev/net/tun")
-	err = tap.createInterface()
+	tap.fd = fds[0]
+	tap.queues = queues
+	tap.fds = fds
+	tap.file = queues[0]
+	if count > 1 {
+		Log(Info, "Opened %d queues of %s", count, tap.Name)
+	}
+	if tap.Offload {
+		tap.vnet = true
+		tap.gro = newGROTable(tap.writeFrame)
+		Log(Info, "Enabled offload on %s", tap.Name)
+	}
+	return nil
+}
+
+// openQueues opens count descriptors of the interface. Error of the
+// first queue is returned as is
+func (tap *TAPLinux) openQueues(count int, flags uint16) ([]*os.File, []int, error) {
+	queues := []*os.File{}
+	fds := []int{}
+	for i := 0; i < count; i++ {
+		fd, err := unix.Open("/dev/net/tun", os.O_RDWR, 0)
+		if err == nil {
+			err = tap.createQueue(fd, flags)
+			if err == nil && tap.Offload {
+				err = setOffload(fd)
+			}
+			if err != nil {
+				unix.Close(fd)
+			}
+		}
+		if err != nil {
+			for _, f := range queues {
+				f.Close()
+			}
+			if i > 0 {
+				return nil, nil, fmt.Errorf("Failed to open TAP queue %d: %s", i, err)
+			}
+			return nil, nil, err
+		}
+		queues = append(queues, os.NewFile(uintptr(fd), "/dev/net/tun"))
+		fds = append(fds, fd)
+	}
+	return queues, fds, nil
+}
+
+// setOffload enables checksum and TCPv4 segmentation offload. IPv6 frames
+// are not forwarded by p2p, so TSO6 is left disabled
+func setOffload(fd int) error {
+	err := unix.IoctlSetInt(fd, unix.TUNSETOFFLOAD, unix.TUN_F_CSUM|unix.TUN_F_TSO4)
 	if err != nil {
-		return err
+		return fmt.Errorf("Failed to enable offload: %s", err)
 	}
 	return nil
 }
@@ -158,6 +245,11 @@ func (tap *TAPLinux) Close() error {
 		return fmt.Errorf("nil interface file descriptor")
 	}
 	Log(Info, "Closing network interface %s", tap.GetName())
+	if len(tap.queues) > 1 {
+		for _, f := range tap.queues[1:] {
+			f.Close()
+		}
+	}
 	err := tap.file.Close()
 	if err != nil {
 		return fmt.Errorf("Failed to close network interface: %s", err)
@@ -166,6 +258,32 @@ func (tap *TAPLinux) Close() error {
 	return nil
 }
 
+// SetQueues sets number of queues opened by Open
+func (tap *TAPLinux) SetQueues(n int) {
+	if n > MaxTAPQueues {
+		n = MaxTAPQueues
+	}
+	tap.Queues = n
+}
+
+// SetOffload sets whether Open enables virtio-net header offload
+func (tap *TAPLinux) SetOffload(offload bool) {
+	tap.Offload = offload
+}
+
+// OffloadEnabled returns whether interface was opened with offload
+func (tap *TAPLinux) OffloadEnabled() bool {
+	return tap.vnet
+}
+
+// QueueCount returns number of opened queues
+func (tap *TAPLinux) QueueCount() int {
+	if len(tap.queues) == 0 {
+		return 1
+	}
+	return len(tap.queues)
+}
+
 // Configure will configure interface using system calls to commands
 func (tap *TAPLinux) Configure(lazy bool) error {
 	tap.Status = InterfaceConfiguring
@@ -217,17 +335,51 @@ func (tap *TAPLinux) Deconfigure() error {
 
 // ReadPacket will read single packet from network interface
 func (tap *TAPLinux) ReadPacket() (*Packet, error) {
-	buf := make([]byte, 4096)
+	size := frameBufferSize(tap.MTU)
+	if tap.vnet {
+		size = offloadBufferSize
+	}
+	return tap.ReadQueue(0, make([]byte, size))
+}
 
-	n, err := tap.file.Read(buf)
+// ReadQueue reads a single packet from a queue into buf
+func (tap *TAPLinux) ReadQueue(queue int, buf []byte) (*Packet, error) {
+	file := tap.file
+	if queue > 0 {
+		file = tap.queues[queue]
+	}
+	n, err := file.Read(buf)
 	if err != nil {
 		Log(Error, "Failed to read packet: %+v", err)
 		return nil, err
 	}
-
+	if tap.vnet {
+		return tap.handleOffloadPacket(buf[0:n])
+	}
 	return tap.handlePacket(buf[0:n])
 }
 
+// handleOffloadPacket strips virtio-net header. Super-packets are returned
+// with the header, checksums of other frames are completed here, since
+// peers expect frames with valid checksums. Broken frames are skipped
+func (tap *TAPLinux) handleOffloadPacket(data []byte) (*Packet, error) {
+	h, err := ParseVNetHeader(data)
+	if err != nil || len(data) < VNetHeaderSize+14 {
+		return nil, nil
+	}
+	frame := data[VNetHeaderSize:]
+	if h.IsGSO() {
+		// Super-packets are larger than MTU by design, so PMTU is not checked
+		return &Packet{Protocol: int(binary.BigEndian.Uint16(frame[12:14])), Packet: frame, GSO: h}, nil
+	}
+	err = completeChecksum(frame, h)
+	if err != nil {
+		Log(Warning, "Skipping frame read from %s: %s", tap.Name, err)
+		return nil, nil
+	}
+	return tap.handlePacket(frame)
+}
+
 func (tap *TAPLinux) handlePacket(data []byte) (*Packet, error) {
 	length := len(data)
 	if length < 14 {
@@ -235,28 +387,75 @@ func (tap *TAPLinux) handlePacket(data []byte) (*Packet, error) {
 	}
 	pkt := &Packet{Packet: data[0:length]}
 	pkt.Protocol = int(binary.BigEndian.Uint16(data[12:14]))
+	return pkt, nil
+}
 
-	if !tap.IsPMTUEnabled() {
-		return pkt, nil
+// WritePacket will write a single packet to interface. With several
+// queues, packets of a single flow are written to the same queue to keep
+// them in order. There are no dedicated writers: packet workers write
+// concurrently, each to the queue of its flow, and a write to TAP
+// doesn't block, so the number of writers follows PipelineWorkers
+func (tap *TAPLinux) WritePacket(packet *Packet) error {
+	if tap.vnet {
+		// Segments are kept until the flow is interrupted or Flush is
+		// called, and written as a single super-packet
+		tap.groLock.Lock()
+		defer tap.groLock.Unlock()
+		return tap.gro.add(packet.Packet)
+	}
+	file := tap.file
+	if len(tap.queues) > 1 {
+		file = tap.queues[flowHash(packet.Packet)%uint32(len(tap.queues))]
+	}
+	n, err := file.Write(packet.Packet)
+	if err != nil {
+		return err
 	}
+	if n != len(packet.Packet) {
+		return io.ErrShortWrite
+	}
+	return nil
+}
 
-	if pkt.Protocol == int(PacketIPv4) {
-		// Return packet
-		skip, err := pmtu(data, tap)
-		if skip {
-			return nil, err
-		}
+// WriteOffload writes a frame with virtio-net header. Frames kept for
+// coalescing are written first to keep them in order
+func (tap *TAPLinux) WriteOffload(h VNetHeader, frame []byte) error {
+	if !tap.vnet {
+		return fmt.Errorf("Offload is not enabled on %s", tap.Name)
 	}
-	return pkt, nil
+	tap.groLock.Lock()
+	defer tap.groLock.Unlock()
+	err := tap.gro.flushAll()
+	if err != nil {
+		return err
+	}
+	return tap.writeFrame(h, frame)
 }
 
-// WritePacket will write a single packet to interface
-func (tap *TAPLinux) WritePacket(packet *Packet) error {
-	n, err := tap.file.Write(packet.Packet)
+// Flush writes frames kept for coalescing
+func (tap *TAPLinux) Flush() error {
+	if !tap.vnet {
+		return nil
+	}
+	tap.groLock.Lock()
+	defer tap.groLock.Unlock()
+	return tap.gro.flushAll()
+}
+
+// writeFrame writes header and frame with a single system call to the
+// queue of the flow
+func (tap *TAPLinux) writeFrame(h VNetHeader, frame []byte) error {
+	fd := tap.fds[0]
+	if len(tap.fds) > 1 {
+		fd = tap.fds[flowHash(frame)%uint32(len(tap.fds))]
+	}
+	var hdr [VNetHeaderSize]byte
+	h.Encode(hdr[:])
+	n, err := unix.Writev(fd, [][]byte{hdr[:], frame})
 	if err != nil {
 		return err
 	}
-	if n != len(packet.Packet) {
+	if n != len(hdr)+len(frame) {
 		return io.ErrShortWrite
 	}
 	return nil
@@ -268,12 +467,14 @@ func (tap *TAPLinux) Run() {
 }
 
 func (tap *TAPLinux) createInterface() error {
+	return tap.createQueue(tap.fd, iffTap|iffnopi)
+}
+
+func (tap *TAPLinux) createQueue(fd int, flags uint16) error {
 	var req ifReq
-	req.Flags = 0
 	copy(req.Name[:15], tap.Name)
-	req.Flags |= iffTap
-	req.Flags |= iffnopi
-	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, uintptr(tap.fd), uintptr(syscall.TUNSETIFF), uintptr(unsafe.Pointer(&req)))
+	req.Flags = flags
+	_, _, err := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), uintptr(syscall.TUNSETIFF), uintptr(unsafe.Pointer(&req)))
 	if err != 0 {
 		return err
 	}
@@ -374,11 +575,6 @@ func FilterInterface(infName, infIP string) bool {
 	if len(infIP) > 4 && infIP[0:3] == "172" {
 		return true
 	}
-	for _, ip := range ActiveInterfaces {
-		if ip.String() == infIP {
-			return true
-		}
-	}
 	Log(Trace, "ping -4 -w 1 -c 1 -I %s ptest.subutai.io", infName)
 	ping := exec.Command("ping", "-4", "-w", "1", "-c", "1", "-I", infName, "ptest.subutai.io")
 	if ping.Run() != nil {
@@ -1748,3 +1748,57 @@ func TestFilterInterface(t *testing.T) {
 		})
 	}
 }
+
+func TestTAPLinux_multiQueue(t *testing.T) {
+	tap := &TAPLinux{Name: "vptpmq0"}
+	tap.SetQueues(4)
+	if err := tap.Open(); err != nil {
+		t.Skipf("Can't create TAP interface: %s", err)
+	}
+	defer tap.Close()
+	if tap.QueueCount() != 4 {
+		t.Errorf("Opened %d queues, want 4", tap.QueueCount())
+	}
+
+	single := &TAPLinux{Name: "vptpmq0"}
+	if err := single.Open(); err == nil {
+		single.Close()
+		t.Errorf("Opened single queue on multi-queue interface")
+	}
+}
+
+func TestTAPLinux_offload(t *testing.T) {
+	tap := &TAPLinux{Name: "vptpoff0"}
+	tap.SetOffload(true)
+	if err := tap.Open(); err != nil {
+		t.Skipf("Can't create TAP interface: %s", err)
+	}
+	defer tap.Close()
+	if !tap.OffloadEnabled() {
+		t.Fatalf("Offload wasn't enabled")
+	}
+	// Kernel rejects frames written to interface that is down
+	tap.Tool = GetConfigurationTool()
+	if err := tap.linkUp(); err != nil {
+		t.Skipf("Can't bring interface up: %s", err)
+	}
+
+	payload := payloadOf(3000)
+	super := tcpSegment(40000, 1, tcpFlagACK, payload)
+	f, _ := parseTCPFrame(super)
+	finishTCPFrame(super, f, true)
+	if err := tap.WriteOffload(partialHeader(f, 1000, len(payload)), super); err != nil {
+		t.Errorf("Failed to write super-packet: %s", err)
+	}
+	if err := tap.WritePacket(&Packet{Packet: tcpSegment(40001, 1, tcpFlagACK, payload[:1000])}); err != nil {
+		t.Errorf("Failed to write frame: %s", err)
+	}
+	if err := tap.Flush(); err != nil {
+		t.Errorf("Failed to flush: %s", err)
+	}
+
+	plain := &TAPLinux{Name: "vptpoff1"}
+	if err := plain.WriteOffload(VNetHeader{}, super); err == nil {
+		t.Errorf("Super-packet was written without offload")
+	}
+}
@@ -0,0 +1,45 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func ipv4Frame(proto byte, src, dst [4]byte, sport, dport uint16) []byte {
+	frame := make([]byte, 14+20+8)
+	binary.BigEndian.PutUint16(frame[12:14], uint16(PacketIPv4))
+	ip := frame[14:]
+	ip[0] = 0x45
+	ip[9] = proto
+	copy(ip[12:16], src[:])
+	copy(ip[16:20], dst[:])
+	binary.BigEndian.PutUint16(ip[20:22], sport)
+	binary.BigEndian.PutUint16(ip[22:24], dport)
+	return frame
+}
+
+func TestFlowHash(t *testing.T) {
+	a, b := [4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}
+	flow := flowHash(ipv4Frame(6, a, b, 40000, 80))
+	tests := []struct {
+		name  string
+		frame []byte
+		same  bool
+	}{
+		{"same flow", ipv4Frame(6, a, b, 40000, 80), true},
+		{"reply", ipv4Frame(6, b, a, 80, 40000), true},
+		{"other port", ipv4Frame(6, a, b, 40001, 80), false},
+		{"other protocol", ipv4Frame(17, a, b, 40000, 80), false},
+		{"other host", ipv4Frame(6, a, [4]byte{10, 0, 0, 3}, 40000, 80), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flowHash(tt.frame) == flow; got != tt.same {
+				t.Errorf("flowHash() same = %v, want %v", got, tt.same)
+			}
+		})
+	}
+	if flowHash([]byte{1, 2, 3}) != 0 {
+		t.Errorf("flowHash() of short frame is not 0")
+	}
+}
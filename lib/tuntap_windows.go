@@ -60,6 +60,9 @@ func GetConfigurationTool() string {
 
 func newTAP(tool, ip, mac, mask string, mtu int, pmtu bool) (*TAPWindows, error) {
 	Log(Debug, "Acquiring TAP interface [Windows]")
+	if mtu == 0 {
+		mtu = DefaultMTU
+	}
 	nip := net.ParseIP(ip)
 	if nip == nil {
 		return nil, fmt.Errorf("Failed to parse IP during TAP creation")
@@ -73,7 +76,7 @@ func newTAP(tool, ip, mac, mask string, mtu int, pmtu bool) (*TAPWindows, error)
 		IP:        nip,
 		Mac:       nmac,
 		Mask:      net.IPv4Mask(255, 255, 255, 0), // Unused yet
-		MTU:       DefaultMTU,
+		MTU:       mtu,
 		MacNotSet: true,
 		PMTU:      pmtu,
 		Status:    InterfaceWaiting,
@@ -167,6 +170,11 @@ func (t *TAPWindows) GetBasename() string {
 	return "vptp"
 }
 
+// GetMTU returns MTU of the interface
+func (t *TAPWindows) GetMTU() int {
+	return t.MTU
+}
+
 // SetName will set interface name
 func (t *TAPWindows) SetName(name string) {
 	t.Name = name
@@ -191,6 +199,11 @@ func (t *TAPWindows) SetMask(mask net.IPMask) {
 	t.Mask = mask
 }
 
+// SetMTU will set MTU. Takes effect on next configuration
+func (t *TAPWindows) SetMTU(mtu int) {
+	t.MTU = mtu
+}
+
 // Init will initialize TAP interface creation process
 func (t *TAPWindows) Init(name string) error {
 	if name == "" {
@@ -323,19 +336,6 @@ func (t *TAPWindows) ReadPacket() (*Packet, error) {
 	pkt.Protocol = int(binary.BigEndian.Uint16(buf[12:14]))
 
 	return pkt, nil
-
-	// if !t.IsPMTUEnabled() {
-	// 	return pkt, nil
-	// }
-
-	// if pkt.Protocol == int(PacketIPv4) {
-	// 	// Return packet
-	// 	skip, err := pmtu(buf, t)
-	// 	if skip {
-	// 		return nil, err
-	// 	}
-	// }
-	// return pkt, nil
 }
 
 func (t *TAPWindows) WritePacket(pkt *Packet) error {
@@ -579,12 +579,6 @@ func FilterInterface(infName, infIP string) bool {
 	if len(infIP) > 4 && infIP[0:3] == "172" {
 		return true
 	}
-	for _, ip := range ActiveInterfaces {
-		if ip.String() == infIP {
-			return true
-		}
-	}
-
 	Log(Trace, "ping -4 -w 1000 -n 1 -S %s ptest.subutai.io", infIP)
 	ping := exec.Command("ping", "-4", "-w", "1000", "-n", "1", "-S", infIP, "ptest.subutai.io")
 	if ping.Run() != nil {
@@ -91,12 +91,7 @@ func StringifyState(state PeerState) string {
 // IsInterfaceLocal will return true if specified IP is in list of
 // local network interfaces
 func IsInterfaceLocal(ip net.IP) bool {
-	for _, localIP := range ActiveInterfaces {
-		if localIP.Equal(ip) {
-			return true
-		}
-	}
-	return false
+	return ActiveInterfaces.Contains(ip)
 }
 
 // FindNetworkAddresses method lists interfaces available in the system and retrieves their
@@ -140,7 +135,7 @@ func (p *PeerToPeer) ParseInterfaces(interfaces []net.Interface) []net.IP {
 			}
 
 			if ip.IsGlobalUnicast() && p.IsIPv4(ip.String()) {
-				if !FilterInterface(i.Name, ip.String()) {
+				if !p.registry().Contains(ip) && !FilterInterface(i.Name, ip.String()) {
 					ips = append(ips, ip)
 				} else {
 					reserve = append(reserve, ip)
@@ -107,7 +107,8 @@ func TestIsInterfaceLocal(t *testing.T) {
 	type args struct {
 		ip net.IP
 	}
-	ActiveInterfaces = []net.IP{net.ParseIP("10.10.10.1")}
+	ActiveInterfaces = NewIPRegistry()
+	ActiveInterfaces.Reserve(net.ParseIP("10.10.10.1"))
 	tests := []struct {
 		name string
 		args args
@@ -187,7 +188,6 @@ func TestPeerToPeer_FindNetworkAddresses(t *testing.T) {
 		LocalIPs        []net.IP
 		Dht             *DHTClient
 		Crypter         Crypto
-		Shutdown        bool
 		ForwardMode     bool
 		ReadyToStop     bool
 		MessageHandlers map[uint16]MessageHandler
@@ -215,7 +215,6 @@ func TestPeerToPeer_FindNetworkAddresses(t *testing.T) {
 				LocalIPs:        tt.fields.LocalIPs,
 				Dht:             tt.fields.Dht,
 				Crypter:         tt.fields.Crypter,
-				Shutdown:        tt.fields.Shutdown,
 				ForwardMode:     tt.fields.ForwardMode,
 				ReadyToStop:     tt.fields.ReadyToStop,
 				MessageHandlers: tt.fields.MessageHandlers,
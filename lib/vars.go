@@ -18,19 +18,23 @@ type MsgType uint16
 
 // Internal network packet type
 const (
-	MsgTypeString    MsgType = 0  // String
-	MsgTypeIntro             = 1  // Introduction packet
-	MsgTypeIntroReq          = 2  // Request for introduction packet
-	MsgTypeNenc              = 3  // Not encrypted message
-	MsgTypeEnc               = 4  // Encrypted message
-	MsgTypePing              = 5  // Internal ping message for Proxies
-	MsgTypeXpeerPing         = 6  // Crosspeer ping message
-	MsgTypeTest              = 7  // Packet tests established connection
-	MsgTypeProxy             = 8  // Information about proxy (forwarder)
-	MsgTypeBadTun            = 9  // Notifies about dead tunnel
-	MsgTypeConf              = 10 // Confirmation
-	MsgTypeLatency           = 11 // Latency measurement
-	MsgTypeComm              = 12 // Internal cross peer communication
+	MsgTypeString     MsgType = 0  // String
+	MsgTypeIntro              = 1  // Introduction packet
+	MsgTypeIntroReq           = 2  // Request for introduction packet
+	MsgTypeNenc               = 3  // Not encrypted message
+	MsgTypeEnc                = 4  // Encrypted message
+	MsgTypePing               = 5  // Internal ping message for Proxies
+	MsgTypeXpeerPing          = 6  // Crosspeer ping message
+	MsgTypeTest               = 7  // Packet tests established connection
+	MsgTypeProxy              = 8  // Information about proxy (forwarder)
+	MsgTypeBadTun             = 9  // Notifies about dead tunnel
+	MsgTypeConf               = 10 // Confirmation
+	MsgTypeLatency            = 11 // Latency measurement
+	MsgTypeComm               = 12 // Internal cross peer communication
+	MsgTypeGSO                = 13 // TCP super-packet with virtio-net header
+	MsgTypeFragment           = 14 // Fragment of a message larger than underlay MTU
+	MsgTypeCompressed         = 15 // Compressed frame
+	MsgTypeDuplicate          = 16 // Frame sent over several paths
 )
 
 // Common communication packet types
@@ -60,6 +64,11 @@ const (
 	CommDiscoveryUnsupported        = 27 // Unsupported packet version
 )
 
+// Capability negotiation packets
+const (
+	CommCapabilities uint16 = 30 // Request/response with supported features
+)
+
 // Network Constants
 const (
 	MagicCookie uint16 = 0xabcd
@@ -0,0 +1,433 @@
+package ptp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Offload is described by virtio-net header that Linux TAP puts in front of
+// every frame when interface is opened with IFF_VNET_HDR. Frames may be
+// GSO super-packets up to 64KB with partial checksums
+
+// Sizes of offloaded frames
+const (
+	VNetHeaderSize       = 10
+	offloadBufferSize    = VNetHeaderSize + 14 + 65535 // Largest frame read from TAP with offload
+	offloadMaxPayload    = 60000                       // Largest super-packet sent to a peer that reassembles fragments
+	groMaxFlows          = 8
+	ethernetHeaderLength = 14
+)
+
+// Flags and GSO types of virtio-net header
+const (
+	VNetFlagNeedsCsum uint8 = 1
+	VNetGSONone       uint8 = 0
+	VNetGSOTCPv4      uint8 = 1
+	VNetGSOTCPv6      uint8 = 4
+)
+
+// TCP flags
+const (
+	tcpFlagFIN = 0x01
+	tcpFlagSYN = 0x02
+	tcpFlagRST = 0x04
+	tcpFlagPSH = 0x08
+	tcpFlagACK = 0x10
+	tcpFlagURG = 0x20
+	tcpFlagECE = 0x40
+	tcpFlagCWR = 0x80
+)
+
+// VNetHeader is a virtio-net header. TAP uses byte order of the host,
+// which is little endian on all supported platforms
+type VNetHeader struct {
+	Flags      uint8
+	GSOType    uint8
+	HdrLen     uint16
+	GSOSize    uint16
+	CsumStart  uint16
+	CsumOffset uint16
+}
+
+// ParseVNetHeader decodes virtio-net header
+func ParseVNetHeader(b []byte) (VNetHeader, error) {
+	if len(b) < VNetHeaderSize {
+		return VNetHeader{}, fmt.Errorf("virtio-net header is too short")
+	}
+	return VNetHeader{
+		Flags:      b[0],
+		GSOType:    b[1],
+		HdrLen:     binary.LittleEndian.Uint16(b[2:4]),
+		GSOSize:    binary.LittleEndian.Uint16(b[4:6]),
+		CsumStart:  binary.LittleEndian.Uint16(b[6:8]),
+		CsumOffset: binary.LittleEndian.Uint16(b[8:10]),
+	}, nil
+}
+
+// Encode writes header into b, which must hold VNetHeaderSize bytes
+func (h VNetHeader) Encode(b []byte) {
+	b[0] = h.Flags
+	b[1] = h.GSOType
+	binary.LittleEndian.PutUint16(b[2:4], h.HdrLen)
+	binary.LittleEndian.PutUint16(b[4:6], h.GSOSize)
+	binary.LittleEndian.PutUint16(b[6:8], h.CsumStart)
+	binary.LittleEndian.PutUint16(b[8:10], h.CsumOffset)
+}
+
+// IsGSO returns whether header describes a super-packet
+func (h VNetHeader) IsGSO() bool {
+	return h.GSOType != VNetGSONone
+}
+
+// checksumAdd adds data to one's complement sum
+func checksumAdd(sum uint32, data []byte) uint32 {
+	n := len(data)
+	for i := 0; i+1 < n; i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if n%2 == 1 {
+		sum += uint32(data[n-1]) << 8
+	}
+	return sum
+}
+
+func checksumFold(sum uint32) uint16 {
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return uint16(sum)
+}
+
+// pseudoHeaderSum returns sum of TCP/UDP pseudo header
+func pseudoHeaderSum(src, dst []byte, proto uint8, length int) uint32 {
+	sum := checksumAdd(0, src)
+	sum = checksumAdd(sum, dst)
+	sum += uint32(proto)
+	sum += uint32(length)
+	return sum
+}
+
+// completeChecksum calculates checksum that was left partial by the
+// kernel. Partial checksum field already holds sum of pseudo header
+func completeChecksum(frame []byte, h VNetHeader) error {
+	if h.Flags&VNetFlagNeedsCsum == 0 {
+		return nil
+	}
+	start, field := int(h.CsumStart), int(h.CsumStart)+int(h.CsumOffset)
+	if start >= len(frame) || field+2 > len(frame) {
+		return fmt.Errorf("Checksum offset is out of frame")
+	}
+	binary.BigEndian.PutUint16(frame[field:], ^checksumFold(checksumAdd(0, frame[start:])))
+	return nil
+}
+
+// tcpFrame holds offsets of headers of a TCP frame
+type tcpFrame struct {
+	ipLen  int // Length of IP header
+	tcpLen int // Length of TCP header
+}
+
+func (f tcpFrame) hdrLen() int {
+	return ethernetHeaderLength + f.ipLen + f.tcpLen
+}
+
+// parseTCPFrame checks that frame is an unfragmented TCP segment over
+// IPv4. IPv6 frames are not forwarded by p2p, so offload handles only
+// TCPv4, like the TAP interface configured by setOffload
+func parseTCPFrame(frame []byte) (tcpFrame, error) {
+	var f tcpFrame
+	if len(frame) < ethernetHeaderLength+20 {
+		return f, fmt.Errorf("Frame is too short")
+	}
+	ip := frame[ethernetHeaderLength:]
+	if PacketType(binary.BigEndian.Uint16(frame[12:14])) != PacketIPv4 {
+		return f, fmt.Errorf("Not an IPv4 packet")
+	}
+	f.ipLen = int(ip[0]&0x0f) * 4
+	if ip[9] != 6 || f.ipLen < 20 || binary.BigEndian.Uint16(ip[6:8])&0x3fff != 0 {
+		return f, fmt.Errorf("Not a TCP segment")
+	}
+	if len(ip) < f.ipLen+20 {
+		return f, fmt.Errorf("Frame is too short")
+	}
+	f.tcpLen = int(ip[f.ipLen+12]>>4) * 4
+	if f.tcpLen < 20 || len(ip) < f.ipLen+f.tcpLen {
+		return f, fmt.Errorf("Bad TCP header")
+	}
+	return f, nil
+}
+
+// finishTCPFrame sets lengths and checksums of a TCP frame built from
+// headers of another one. With partial set, TCP checksum holds only the
+// sum of pseudo header, as expected by the kernel from frames with
+// VNetFlagNeedsCsum
+func finishTCPFrame(frame []byte, f tcpFrame, partial bool) {
+	ip := frame[ethernetHeaderLength:]
+	tcp := ip[f.ipLen:]
+	length := len(tcp)
+	binary.BigEndian.PutUint16(ip[2:4], uint16(f.ipLen+length))
+	ip[10], ip[11] = 0, 0
+	binary.BigEndian.PutUint16(ip[10:12], ^checksumFold(checksumAdd(0, ip[:f.ipLen])))
+	sum := pseudoHeaderSum(ip[12:16], ip[16:20], 6, length)
+	tcp[16], tcp[17] = 0, 0
+	if partial {
+		binary.BigEndian.PutUint16(tcp[16:18], checksumFold(sum))
+		return
+	}
+	binary.BigEndian.PutUint16(tcp[16:18], ^checksumFold(checksumAdd(sum, tcp)))
+}
+
+// ipEnd returns length of the frame without Ethernet padding, or -1 when
+// IP length doesn't fit into the frame
+func ipEnd(frame []byte, f tcpFrame) int {
+	ip := frame[ethernetHeaderLength:]
+	end := ethernetHeaderLength + int(binary.BigEndian.Uint16(ip[2:4]))
+	if end < f.hdrLen() || end > len(frame) {
+		return -1
+	}
+	return end
+}
+
+// tcpChecksumValid verifies TCP checksum of the frame. Segments are merged
+// only when they're intact, since super-packet gets a new checksum
+func tcpChecksumValid(frame []byte, f tcpFrame) bool {
+	ip := frame[ethernetHeaderLength:]
+	tcp := ip[f.ipLen:]
+	sum := pseudoHeaderSum(ip[12:16], ip[16:20], 6, len(tcp))
+	return checksumFold(checksumAdd(sum, tcp)) == 0xffff
+}
+
+// partialHeader returns virtio-net header for a TCP frame finished with
+// partial checksum
+func partialHeader(f tcpFrame, mss int, payload int) VNetHeader {
+	h := VNetHeader{
+		Flags:      VNetFlagNeedsCsum,
+		HdrLen:     uint16(f.hdrLen()),
+		CsumStart:  uint16(ethernetHeaderLength + f.ipLen),
+		CsumOffset: 16,
+	}
+	if payload > mss {
+		h.GSOType = VNetGSOTCPv4
+		h.GSOSize = uint16(mss)
+	}
+	return h
+}
+
+// segmentTCP splits TCP super-packet into frames carrying up to
+// chunk bytes of payload. Chunks larger than GSO size of the super-packet
+// are super-packets themselves and are finished with partial checksums.
+// Otherwise every frame gets full checksums, so it can be delivered to
+// peers and interfaces without offload. Frames share a buffer, so seg is
+// valid only until out returns
+func segmentTCP(frame []byte, h VNetHeader, chunk int, out func(seg []byte, h VNetHeader) error) error {
+	f, err := parseTCPFrame(frame)
+	if err != nil {
+		return err
+	}
+	mss := int(h.GSOSize)
+	if mss == 0 {
+		return fmt.Errorf("Zero GSO size")
+	}
+	if chunk < mss {
+		chunk = mss
+	}
+	chunk -= chunk % mss
+	hdrLen := f.hdrLen()
+	payload := frame[hdrLen:]
+	tcpOff := ethernetHeaderLength + f.ipLen
+	seq := binary.BigEndian.Uint32(frame[tcpOff+4:])
+	flags := frame[tcpOff+13]
+	id := binary.BigEndian.Uint16(frame[ethernetHeaderLength+4:])
+	partial := chunk > mss
+	size := chunk
+	if size > len(payload) {
+		size = len(payload)
+	}
+	buf := make([]byte, hdrLen+size)
+	for offset := 0; offset < len(payload) || offset == 0; offset += chunk {
+		end := offset + chunk
+		if end > len(payload) {
+			end = len(payload)
+		}
+		seg := buf[:hdrLen+end-offset]
+		copy(seg, frame[:hdrLen])
+		copy(seg[hdrLen:], payload[offset:end])
+		binary.BigEndian.PutUint32(seg[tcpOff+4:], seq+uint32(offset))
+		segFlags := flags
+		if offset > 0 {
+			segFlags &^= tcpFlagCWR
+		}
+		if end < len(payload) {
+			segFlags &^= tcpFlagFIN | tcpFlagPSH
+		}
+		seg[tcpOff+13] = segFlags
+		binary.BigEndian.PutUint16(seg[ethernetHeaderLength+4:], id+uint16(offset/mss))
+		finishTCPFrame(seg, f, partial)
+		segHdr := VNetHeader{}
+		if partial {
+			segHdr = partialHeader(f, mss, end-offset)
+		}
+		if err := out(seg, segHdr); err != nil {
+			return err
+		}
+		if end == len(payload) {
+			break
+		}
+	}
+	return nil
+}
+
+// groFlow is a super-packet being assembled from segments of a flow
+type groFlow struct {
+	key      [12]byte
+	frame    []byte
+	tcp      tcpFrame
+	mss      int
+	nextSeq  uint32
+	segments int
+	closed   bool // Last segment was shorter than mss
+}
+
+// groTable coalesces consecutive TCP segments of the same flow into
+// super-packets before they are written to TAP
+type groTable struct {
+	flows []*groFlow
+	write func(h VNetHeader, frame []byte) error
+}
+
+func newGROTable(write func(h VNetHeader, frame []byte) error) *groTable {
+	return &groTable{write: write}
+}
+
+// groKey identifies a flow by addresses and ports
+func groKey(frame []byte, f tcpFrame) (key [12]byte) {
+	ip := frame[ethernetHeaderLength:]
+	copy(key[:], ip[12:20])
+	copy(key[8:], ip[f.ipLen:f.ipLen+4])
+	return
+}
+
+// add writes frame or keeps it to merge with following segments
+func (g *groTable) add(frame []byte) error {
+	f, err := parseTCPFrame(frame)
+	if err != nil {
+		// Not TCP: nothing to coalesce
+		return g.write(VNetHeader{}, frame)
+	}
+	// Short frames are padded up to minimal Ethernet frame size
+	end := ipEnd(frame, f)
+	if end < 0 {
+		return g.write(VNetHeader{}, frame)
+	}
+	frame = frame[:end]
+	key := groKey(frame, f)
+	flow := g.find(key)
+	if flow != nil && g.merge(flow, frame, f) {
+		if flow.closed || frame[ethernetHeaderLength+f.ipLen+13]&tcpFlagPSH != 0 {
+			return g.flush(flow)
+		}
+		return nil
+	}
+	if flow != nil {
+		if err := g.flush(flow); err != nil {
+			return err
+		}
+	}
+	if !groCandidate(frame, f) || !tcpChecksumValid(frame, f) {
+		return g.write(VNetHeader{}, frame)
+	}
+	if len(g.flows) >= groMaxFlows {
+		if err := g.flush(g.flows[0]); err != nil {
+			return err
+		}
+	}
+	flow = &groFlow{key: key, tcp: f, mss: len(frame) - f.hdrLen(), segments: 1}
+	flow.frame = make([]byte, len(frame), ethernetHeaderLength+65535)
+	copy(flow.frame, frame)
+	flow.nextSeq = binary.BigEndian.Uint32(frame[ethernetHeaderLength+f.ipLen+4:]) + uint32(flow.mss)
+	g.flows = append(g.flows, flow)
+	return nil
+}
+
+// groCandidate returns whether segment may start or continue a
+// super-packet: plain ACK with payload and without IP options
+func groCandidate(frame []byte, f tcpFrame) bool {
+	if f.ipLen != 20 {
+		return false
+	}
+	flags := frame[ethernetHeaderLength+f.ipLen+13]
+	if flags&^(tcpFlagACK|tcpFlagPSH) != 0 || flags&tcpFlagACK == 0 {
+		return false
+	}
+	return len(frame) > f.hdrLen()
+}
+
+func (g *groTable) find(key [12]byte) *groFlow {
+	for _, flow := range g.flows {
+		if flow.key == key {
+			return flow
+		}
+	}
+	return nil
+}
+
+// merge appends payload of frame to the flow if it continues it
+func (g *groTable) merge(flow *groFlow, frame []byte, f tcpFrame) bool {
+	if flow.closed || f != flow.tcp || !groCandidate(frame, f) || !tcpChecksumValid(frame, f) {
+		return false
+	}
+	payload := len(frame) - f.hdrLen()
+	if payload > flow.mss || len(flow.frame)+payload > cap(flow.frame) {
+		return false
+	}
+	ipOff := ethernetHeaderLength
+	tcpOff := ipOff + f.ipLen
+	if binary.BigEndian.Uint32(frame[tcpOff+4:]) != flow.nextSeq {
+		return false
+	}
+	// Headers must match except for lengths, IDs, checksums and sequence
+	// numbers. PSH of the last segment is kept
+	if frame[ipOff+1] != flow.frame[ipOff+1] || frame[ipOff+6]&0x40 != flow.frame[ipOff+6]&0x40 || frame[ipOff+8] != flow.frame[ipOff+8] {
+		return false
+	}
+	if binary.BigEndian.Uint32(frame[tcpOff+8:]) != binary.BigEndian.Uint32(flow.frame[tcpOff+8:]) ||
+		binary.BigEndian.Uint16(frame[tcpOff+14:]) != binary.BigEndian.Uint16(flow.frame[tcpOff+14:]) ||
+		string(frame[tcpOff+20:f.hdrLen()]) != string(flow.frame[tcpOff+20:f.hdrLen()]) {
+		return false
+	}
+	flow.frame = append(flow.frame, frame[f.hdrLen():]...)
+	flow.frame[tcpOff+13] |= frame[tcpOff+13] & tcpFlagPSH
+	flow.nextSeq += uint32(payload)
+	flow.segments++
+	if payload < flow.mss {
+		flow.closed = true
+	}
+	return true
+}
+
+// flush writes super-packet of a flow and forgets the flow
+func (g *groTable) flush(flow *groFlow) error {
+	for i, fl := range g.flows {
+		if fl == flow {
+			g.flows = append(g.flows[:i], g.flows[i+1:]...)
+			break
+		}
+	}
+	if flow.segments == 1 {
+		// Single segment still has its original checksums
+		return g.write(VNetHeader{}, flow.frame)
+	}
+	finishTCPFrame(flow.frame, flow.tcp, true)
+	return g.write(partialHeader(flow.tcp, flow.mss, len(flow.frame)-flow.tcp.hdrLen()), flow.frame)
+}
+
+// flushAll writes all pending super-packets
+func (g *groTable) flushAll() error {
+	var err error
+	for len(g.flows) > 0 {
+		if ferr := g.flush(g.flows[0]); ferr != nil {
+			err = ferr
+		}
+	}
+	return err
+}
@@ -0,0 +1,262 @@
+package ptp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// tcpSegment returns IPv4 TCP frame with valid checksums
+func tcpSegment(sport uint16, seq uint32, flags byte, payload []byte) []byte {
+	frame := make([]byte, 14+20+20+len(payload))
+	copy(frame[0:6], []byte{0x06, 0, 0, 0, 0, 2})
+	copy(frame[6:12], []byte{0x06, 0, 0, 0, 0, 1})
+	binary.BigEndian.PutUint16(frame[12:14], uint16(PacketIPv4))
+	ip := frame[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[4:6], 100)
+	ip[6] = 0x40
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:16], []byte{10, 0, 0, 1})
+	copy(ip[16:20], []byte{10, 0, 0, 2})
+	tcp := ip[20:]
+	binary.BigEndian.PutUint16(tcp[0:2], sport)
+	binary.BigEndian.PutUint16(tcp[2:4], 80)
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	binary.BigEndian.PutUint32(tcp[8:12], 7)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:16], 512)
+	copy(tcp[20:], payload)
+	finishTCPFrame(frame, tcpFrame{ipLen: 20, tcpLen: 20}, false)
+	return frame
+}
+
+// checkSegment verifies lengths and checksums of a frame
+func checkSegment(t *testing.T, frame []byte) {
+	t.Helper()
+	f, err := parseTCPFrame(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ip := frame[14:]
+	if int(binary.BigEndian.Uint16(ip[2:4])) != len(ip) {
+		t.Errorf("Wrong IP length %d for %d bytes", binary.BigEndian.Uint16(ip[2:4]), len(ip))
+	}
+	if checksumFold(checksumAdd(0, ip[:20])) != 0xffff {
+		t.Errorf("Bad IP checksum")
+	}
+	if !tcpChecksumValid(frame, f) {
+		t.Errorf("Bad TCP checksum")
+	}
+}
+
+func payloadOf(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestVNetHeader(t *testing.T) {
+	h := VNetHeader{Flags: VNetFlagNeedsCsum, GSOType: VNetGSOTCPv4, HdrLen: 54, GSOSize: 1448, CsumStart: 34, CsumOffset: 16}
+	b := make([]byte, VNetHeaderSize)
+	h.Encode(b)
+	got, err := ParseVNetHeader(b)
+	if err != nil || got != h {
+		t.Errorf("Got %+v, %v", got, err)
+	}
+	if _, err := ParseVNetHeader(b[:9]); err == nil {
+		t.Errorf("Short header was parsed")
+	}
+}
+
+func TestCompleteChecksum(t *testing.T) {
+	frame := tcpSegment(40000, 1, tcpFlagACK|tcpFlagPSH, payloadOf(301))
+	want := append([]byte{}, frame...)
+	f, _ := parseTCPFrame(frame)
+	finishTCPFrame(frame, f, true)
+	h := partialHeader(f, 1448, 301)
+	if h.IsGSO() {
+		t.Errorf("Single segment got GSO header")
+	}
+	if err := completeChecksum(frame, h); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, want) {
+		t.Errorf("Completed checksum %x, want %x", frame[50:52], want[50:52])
+	}
+	if err := completeChecksum(frame[:40], h); err == nil {
+		t.Errorf("Checksum out of frame was accepted")
+	}
+}
+
+func TestSegmentTCP(t *testing.T) {
+	payload := payloadOf(3500)
+	super := tcpSegment(40000, 1000, tcpFlagACK|tcpFlagPSH|tcpFlagCWR, payload)
+	h := VNetHeader{GSOType: VNetGSOTCPv4, GSOSize: 1000, HdrLen: 54}
+
+	tests := []struct {
+		name    string
+		chunk   int
+		lengths []int
+		gso     bool
+	}{
+		{"segments", 1000, []int{1000, 1000, 1000, 500}, false},
+		{"chunk rounded to mss", 2500, []int{2000, 1500}, true},
+		{"whole", offloadMaxPayload, []int{3500}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []byte{}
+			i := 0
+			err := segmentTCP(super, h, tt.chunk, func(seg []byte, sh VNetHeader) error {
+				if i >= len(tt.lengths) || len(seg)-54 != tt.lengths[i] {
+					t.Fatalf("Segment %d has %d bytes of payload", i, len(seg)-54)
+				}
+				if sh.IsGSO() != tt.gso {
+					t.Errorf("Segment %d: got header %+v", i, sh)
+				}
+				seg = append([]byte{}, seg...)
+				if sh.Flags&VNetFlagNeedsCsum != 0 {
+					completeChecksum(seg, sh)
+				}
+				checkSegment(t, seg)
+				tcp := seg[34:]
+				if seq := binary.BigEndian.Uint32(tcp[4:8]); seq != uint32(1000+len(got)) {
+					t.Errorf("Segment %d: sequence %d", i, seq)
+				}
+				last := i == len(tt.lengths)-1
+				if (tcp[13]&tcpFlagPSH != 0) != last || (tcp[13]&tcpFlagCWR != 0) != (i == 0) {
+					t.Errorf("Segment %d: flags %#x", i, tcp[13])
+				}
+				got = append(got, seg[54:]...)
+				i++
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("Payload wasn't preserved")
+			}
+		})
+	}
+
+	if err := segmentTCP(super[:30], h, 1000, nil); err == nil {
+		t.Errorf("Broken frame was segmented")
+	}
+}
+
+type groWrite struct {
+	h     VNetHeader
+	frame []byte
+}
+
+func TestGROTable(t *testing.T) {
+	writes := []groWrite{}
+	g := newGROTable(func(h VNetHeader, frame []byte) error {
+		writes = append(writes, groWrite{h, append([]byte{}, frame...)})
+		return nil
+	})
+	payload := payloadOf(4000)
+	seg := func(sport uint16, offset, n int, flags byte) []byte {
+		return tcpSegment(sport, uint32(offset), flags, payload[offset:offset+n])
+	}
+
+	// Two flows interleaved, second one has a gap
+	g.add(seg(1, 0, 1000, tcpFlagACK))
+	g.add(seg(2, 0, 1000, tcpFlagACK))
+	g.add(seg(1, 1000, 1000, tcpFlagACK))
+	g.add(seg(2, 2000, 1000, tcpFlagACK))
+	g.add(seg(1, 2000, 1000, tcpFlagACK))
+	if len(writes) != 1 {
+		t.Fatalf("Gap didn't flush the flow: %d writes", len(writes))
+	}
+	g.add(seg(1, 3000, 500, tcpFlagACK|tcpFlagPSH))
+	// Not mergeable
+	g.add(seg(3, 0, 100, tcpFlagSYN))
+	g.flushAll()
+
+	want := []struct {
+		sport   uint16
+		payload []byte
+		gso     bool
+	}{
+		{2, payload[0:1000], false},
+		{1, payload[0:3500], true},
+		{3, payload[0:100], false},
+		{2, payload[2000:3000], false},
+	}
+	if len(writes) != len(want) {
+		t.Fatalf("Got %d writes, want %d", len(writes), len(want))
+	}
+	for i, w := range want {
+		frame := writes[i].frame
+		if sport := binary.BigEndian.Uint16(frame[34:36]); sport != w.sport {
+			t.Errorf("Write %d: flow %d, want %d", i, sport, w.sport)
+			continue
+		}
+		if !bytes.Equal(frame[54:], w.payload) {
+			t.Errorf("Write %d: wrong payload of %d bytes", i, len(frame)-54)
+		}
+		h := writes[i].h
+		if h.IsGSO() != w.gso {
+			t.Errorf("Write %d: header %+v", i, h)
+		}
+		if h.IsGSO() {
+			if h.GSOSize != 1000 || frame[47]&tcpFlagPSH == 0 {
+				t.Errorf("Write %d: GSO size %d, flags %#x", i, h.GSOSize, frame[47])
+			}
+			completeChecksum(frame, h)
+		}
+		checkSegment(t, frame)
+	}
+}
+
+func TestGROTable_corrupted(t *testing.T) {
+	writes := 0
+	g := newGROTable(func(h VNetHeader, frame []byte) error {
+		writes++
+		return nil
+	})
+	payload := payloadOf(2000)
+	g.add(tcpSegment(1, 0, tcpFlagACK, payload[:1000]))
+	bad := tcpSegment(1, 1000, tcpFlagACK, payload[1000:])
+	bad[100] ^= 0xff
+	g.add(bad)
+	if writes != 2 {
+		t.Errorf("Corrupted segment was merged")
+	}
+}
+
+func TestParseTCPFrame_IPv6(t *testing.T) {
+	frame := tcpSegment(1000, 1, tcpFlagACK, payloadOf(100))
+	binary.BigEndian.PutUint16(frame[12:14], uint16(PacketIPv6))
+	if _, err := parseTCPFrame(frame); err == nil {
+		t.Errorf("parseTCPFrame() accepted IPv6 frame")
+	}
+}
+
+func TestPeerToPeer_offloadChunk(t *testing.T) {
+	p := &PeerToPeer{underlayMTU: 1400}
+	frame := tcpSegment(1000, 1, tcpFlagACK, payloadOf(100))
+
+	peer := new(NetworkPeer)
+	peer.setCaps(CapOffload | CapFragment)
+	if chunk, err := p.offloadChunk(peer, frame); err != nil || chunk != offloadMaxPayload {
+		t.Errorf("offloadChunk() for peer with fragments = %d, %v", chunk, err)
+	}
+
+	peer.setCaps(CapOffload)
+	chunk, err := p.offloadChunk(peer, frame)
+	if err != nil {
+		t.Fatalf("offloadChunk() failed: %s", err)
+	}
+	// Whole encrypted message carrying the piece must fit into the path
+	if size := underlayOverhead + messageOverhead + VNetHeaderSize + 54 + chunk; size != 1400 {
+		t.Errorf("offloadChunk() = %d, datagram of %d bytes", chunk, size)
+	}
+}
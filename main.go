@@ -8,7 +8,7 @@ import (
 	"time"
 
 	ptp "github.com/subutai-io/p2p/lib"
-	"github.com/urfave/cli"
+	"github.com/urfave/cli/v2"
 )
 
 // These variables must be customized at build time
@@ -92,6 +92,11 @@ func main() {
 		PMTU           bool   // Whether or not PMTU capabilities should be used
 		SRVEntry       string // SRV Entry for service lookup
 		ConfigFile     string // Path to configuration YAML file
+		EventTypes     string // Comma-separated list of event types
+		ShowTraffic    bool   // Show traffic counters in status output
+		ResetTraffic   bool   // Reset traffic counters of peers
+		Format         string // Output format of client commands
+		DryRun         bool   // Display apply plan without executing it
 	)
 
 	app := cli.NewApp()
@@ -107,6 +112,13 @@ func main() {
 	app.Usage = "Subutai P2P daemon/client application"
 	app.Copyright = "Copyright 2018 Subutai.io"
 
+	formatFlag := &cli.StringFlag{
+		Name:        "format",
+		Usage:       "Output format: table, json or yaml",
+		Value:       FormatTable,
+		Destination: &Format,
+	}
+
 	app.Commands = []*cli.Command{
 		{
 			Name:  "daemon",
@@ -150,7 +162,7 @@ func main() {
 				},
 				&cli.StringFlag{
 					Name:        "log",
-					Usage:       "Log level. Available levels: trace, debug, info, warning, error",
+					Usage:       "Log level. Available levels: trace, debug, info, warning, error. Subsystem levels are set as subsystem=level, e.g. info,dht=debug",
 					Value:       "",
 					Destination: &LogLevel,
 				},
@@ -276,9 +288,10 @@ func main() {
 					Usage:       "Force proxy servers usage",
 					Destination: &UseForwarders,
 				},
+				formatFlag,
 			},
 			Action: func(c *cli.Context) error {
-				CommandStart(RPCPort, IP, Infohash, Mac, InterfaceName, Keyfile, Key, Until, UseForwarders, UDPPort)
+				CommandStart(RPCPort, IP, Infohash, Mac, InterfaceName, Keyfile, Key, Until, UseForwarders, UDPPort, Format)
 				return nil
 			},
 		},
@@ -304,9 +317,10 @@ func main() {
 					Value:       "",
 					Destination: &InterfaceName,
 				},
+				formatFlag,
 			},
 			Action: func(c *cli.Context) error {
-				CommandStop(RPCPort, Infohash, InterfaceName)
+				CommandStop(RPCPort, Infohash, InterfaceName, Format)
 				return nil
 			},
 		},
@@ -352,9 +366,10 @@ func main() {
 					Usage:       "Display current MTU value in P2P",
 					Destination: &ShowMTU,
 				},
+				formatFlag,
 			},
 			Action: func(c *cli.Context) error {
-				CommandShow(RPCPort, Infohash, IP, ShowInterfaces, ShowAll, ShowBind, ShowMTU)
+				CommandShow(RPCPort, Infohash, IP, ShowInterfaces, ShowAll, ShowBind, ShowMTU, Format)
 				return nil
 			},
 		},
@@ -370,7 +385,7 @@ func main() {
 				},
 				&cli.StringFlag{
 					Name:        "log",
-					Usage:       "Log level. Available levels: trace, debug, info, warning, error",
+					Usage:       "Log level. Available levels: trace, debug, info, warning, error. Subsystem levels are set as subsystem=level, e.g. info,dht=debug",
 					Value:       "",
 					Destination: &LogLevel,
 				},
@@ -398,9 +413,15 @@ func main() {
 					Value:       "",
 					Destination: &IP,
 				},
+				&cli.BoolFlag{
+					Name:        "reset-traffic",
+					Usage:       "Reset traffic counters of peers. Limited to instance when used with -hash",
+					Destination: &ResetTraffic,
+				},
+				formatFlag,
 			},
 			Action: func(c *cli.Context) error {
-				CommandSet(RPCPort, LogLevel, Infohash, "", Key, Until, IP)
+				CommandSet(RPCPort, LogLevel, Infohash, "", Key, Until, IP, ResetTraffic, Format)
 				return nil
 			},
 		},
@@ -414,9 +435,10 @@ func main() {
 					Value:       52523,
 					Destination: &RPCPort,
 				},
+				formatFlag,
 			},
 			Action: func(c *cli.Context) error {
-				CommandDebug(RPCPort)
+				CommandDebug(RPCPort, Format)
 				return nil
 			},
 		},
@@ -436,9 +458,87 @@ func main() {
 					Value:       "",
 					Destination: &Infohash,
 				},
+				&cli.BoolFlag{
+					Name:        "traffic",
+					Usage:       "Display traffic counters of peers",
+					Destination: &ShowTraffic,
+				},
+				formatFlag,
+			},
+			Action: func(c *cli.Context) error {
+				CommandStatus(RPCPort, Infohash, ShowTraffic, Format)
+				return nil
+			},
+		},
+		{
+			Name:  "events",
+			Usage: "Stream instance lifecycle events",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:        "rpc-port",
+					Usage:       "RPC port",
+					Value:       52523,
+					Destination: &RPCPort,
+				},
+				&cli.StringFlag{
+					Name:        "hash",
+					Usage:       "Limit events to specified instance",
+					Value:       "",
+					Destination: &Infohash,
+				},
+				&cli.StringFlag{
+					Name:        "type",
+					Usage:       "Comma-separated list of event types",
+					Value:       "",
+					Destination: &EventTypes,
+				},
+				formatFlag,
+			},
+			Action: func(c *cli.Context) error {
+				CommandEvents(RPCPort, Infohash, EventTypes, Format)
+				return nil
+			},
+		},
+		{
+			Name:  "apply",
+			Usage: "Converge instances to declarative configuration",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:        "rpc-port",
+					Usage:       "RPC port",
+					Value:       52523,
+					Destination: &RPCPort,
+				},
+				&cli.StringSliceFlag{
+					Name:  "file",
+					Usage: "YAML file or directory with instance declarations. Defaults to " + DefaultInstancesDir,
+				},
+				&cli.BoolFlag{
+					Name:        "dry-run",
+					Usage:       "Display plan without applying it",
+					Destination: &DryRun,
+				},
+				formatFlag,
+			},
+			Action: func(c *cli.Context) error {
+				CommandApply(RPCPort, c.StringSlice("file"), DryRun, Format)
+				return nil
+			},
+		},
+		{
+			Name:  "reload",
+			Usage: "Re-read daemon configuration file",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:        "rpc-port",
+					Usage:       "RPC port",
+					Value:       52523,
+					Destination: &RPCPort,
+				},
+				formatFlag,
 			},
 			Action: func(c *cli.Context) error {
-				CommandStatus(RPCPort, Infohash)
+				CommandReload(RPCPort, Format)
 				return nil
 			},
 		},
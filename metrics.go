@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+// metricsWriter produces output in Prometheus text exposition format
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes HELP and TYPE lines of the metric
+func (m *metricsWriter) family(name, help, kind string) {
+	fmt.Fprintf(&m.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(&m.buf, "# TYPE %s %s\n", name, kind)
+}
+
+// sample writes single value of the metric. Labels are passed as
+// name/value pairs
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	m.buf.WriteString(name)
+	if len(labels) > 1 {
+		pairs := []string{}
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], escapeLabel(labels[i+1])))
+		}
+		m.buf.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	fmt.Fprintf(&m.buf, " %v\n", value)
+}
+
+func escapeLabel(value string) string {
+	value = strings.Replace(value, "\\", "\\\\", -1)
+	value = strings.Replace(value, "\"", "\\\"", -1)
+	return strings.Replace(value, "\n", "\\n", -1)
+}
+
+func boolToFloat(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+func (d *Daemon) execRESTMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(d.metrics())
+}
+
+// metrics collects daemon, instance and peer metrics
+func (d *Daemon) metrics() []byte {
+	m := new(metricsWriter)
+
+	m.family("p2p_uptime_seconds", "Time since daemon start", "gauge")
+	m.sample("p2p_uptime_seconds", time.Since(StartTime).Seconds())
+
+	m.family("p2p_bootstrap_connected", "Whether connection with bootstrap node is established", "gauge")
+	for _, node := range bootstrap.getRouters() {
+		if node != nil {
+			m.sample("p2p_bootstrap_connected", boolToFloat(node.running && node.handshaked), "node", node.router)
+		}
+	}
+	m.family("p2p_bootstrap_rx_bytes_total", "Bytes received from bootstrap node", "counter")
+	for _, node := range bootstrap.getRouters() {
+		if node != nil {
+			m.sample("p2p_bootstrap_rx_bytes_total", float64(node.rx), "node", node.router)
+		}
+	}
+	m.family("p2p_bootstrap_tx_bytes_total", "Bytes sent to bootstrap node", "counter")
+	for _, node := range bootstrap.getRouters() {
+		if node != nil {
+			m.sample("p2p_bootstrap_tx_bytes_total", float64(node.tx), "node", node.router)
+		}
+	}
+
+	instances := []*P2PInstance{}
+	if d.Instances != nil {
+		for _, inst := range d.Instances.get() {
+			if inst != nil && inst.PTP != nil && inst.PTP.Swarm != nil {
+				instances = append(instances, inst)
+			}
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	m.family("p2p_instances", "Number of running instances", "gauge")
+	m.sample("p2p_instances", float64(len(instances)))
+
+	m.family("p2p_instance_dht_connected", "Whether instance is registered on bootstrap node", "gauge")
+	for _, inst := range instances {
+		m.sample("p2p_instance_dht_connected", boolToFloat(inst.PTP.Dht != nil && inst.PTP.Dht.Connected), "hash", inst.ID)
+	}
+	m.family("p2p_instance_peers", "Number of known peers", "gauge")
+	for _, inst := range instances {
+		m.sample("p2p_instance_peers", float64(inst.PTP.Swarm.Length()), "hash", inst.ID)
+	}
+	m.family("p2p_instance_rx_bytes_total", "Bytes received by instance socket", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_rx_bytes_total", float64(inst.PTP.UDPSocket.Stats().RxBytes), "hash", inst.ID)
+	}
+	m.family("p2p_instance_tx_bytes_total", "Bytes sent by instance socket", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_tx_bytes_total", float64(inst.PTP.UDPSocket.Stats().TxBytes), "hash", inst.ID)
+	}
+	m.family("p2p_instance_rx_packets_total", "Datagrams received by instance socket", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_rx_packets_total", float64(inst.PTP.UDPSocket.Stats().RxPackets), "hash", inst.ID)
+	}
+	m.family("p2p_instance_tx_packets_total", "Datagrams sent by instance socket", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_tx_packets_total", float64(inst.PTP.UDPSocket.Stats().TxPackets), "hash", inst.ID)
+	}
+	m.family("p2p_instance_decrypt_failures_total", "Messages failed to decrypt", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_decrypt_failures_total", float64(inst.PTP.DecryptFailures()), "hash", inst.ID)
+	}
+	m.family("p2p_instance_duplicates_dropped_total", "Copies of frames sent over several paths dropped", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_duplicates_dropped_total", float64(inst.PTP.DuplicatesDropped()), "hash", inst.ID)
+	}
+	m.family("p2p_instance_pipeline_processed_total", "Packets processed by instance workers", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_pipeline_processed_total", float64(inst.PTP.PipelineStats().Processed), "hash", inst.ID)
+	}
+	m.family("p2p_instance_pipeline_dropped_total", "Packets dropped because worker queue was full", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_pipeline_dropped_total", float64(inst.PTP.PipelineStats().Dropped), "hash", inst.ID)
+	}
+	m.family("p2p_instance_fragments_sent_total", "Fragments of messages larger than underlay MTU", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_fragments_sent_total", float64(inst.PTP.FragmentStats().Sent), "hash", inst.ID)
+	}
+	m.family("p2p_instance_fragments_reassembled_total", "Messages reassembled from fragments", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_fragments_reassembled_total", float64(inst.PTP.FragmentStats().Reassembled), "hash", inst.ID)
+	}
+	m.family("p2p_instance_fragments_dropped_total", "Fragments dropped as invalid, expired or over memory limit", "counter")
+	for _, inst := range instances {
+		m.sample("p2p_instance_fragments_dropped_total", float64(inst.PTP.FragmentStats().Dropped), "hash", inst.ID)
+	}
+
+	m.family("p2p_proxy_active", "Whether proxy server is active", "gauge")
+	for _, inst := range instances {
+		if inst.PTP.ProxyManager == nil {
+			continue
+		}
+		for _, proxy := range inst.PTP.ProxyManager.GetList() {
+			if proxy.Addr != nil {
+				m.sample("p2p_proxy_active", boolToFloat(proxy.IsActive()), "hash", inst.ID, "proxy", proxy.Addr.String())
+			}
+		}
+	}
+	m.family("p2p_proxy_latency_seconds", "Measured latency of proxy server", "gauge")
+	for _, inst := range instances {
+		if inst.PTP.ProxyManager == nil {
+			continue
+		}
+		for _, proxy := range inst.PTP.ProxyManager.GetList() {
+			if proxy.Addr != nil && proxy.IsActive() {
+				m.sample("p2p_proxy_latency_seconds", proxy.Latency.Seconds(), "hash", inst.ID, "proxy", proxy.Addr.String())
+			}
+		}
+	}
+	proxyStats := []struct {
+		name, help string
+		value      func(ptp.LatencyStats) float64
+	}{
+		{"p2p_proxy_loss_ratio", "Share of recent latency requests to proxy server without response", func(s ptp.LatencyStats) float64 { return s.Loss() / 100 }},
+		{"p2p_proxy_jitter_seconds", "Jitter of recent latency requests to proxy server", func(s ptp.LatencyStats) float64 { return s.Jitter.Seconds() }},
+	}
+	for _, stat := range proxyStats {
+		m.family(stat.name, stat.help, "gauge")
+		for _, inst := range instances {
+			if inst.PTP.ProxyManager == nil {
+				continue
+			}
+			for _, proxy := range inst.PTP.ProxyManager.GetList() {
+				if proxy.Addr != nil && proxy.IsActive() {
+					m.sample(stat.name, stat.value(proxy.Stats()), "hash", inst.ID, "proxy", proxy.Addr.String())
+				}
+			}
+		}
+	}
+
+	peers := map[string][]*ptp.NetworkPeer{}
+	for _, inst := range instances {
+		list := []*ptp.NetworkPeer{}
+		for _, peer := range inst.PTP.Swarm.Get() {
+			list = append(list, peer)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		peers[inst.ID] = list
+	}
+
+	m.family("p2p_peer_state", "Current state of the peer", "gauge")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			m.sample("p2p_peer_state", 1, "hash", inst.ID, "peer", peer.ID, "state", ptp.StringifyState(peer.State))
+		}
+	}
+	m.family("p2p_peer_proxied", "Whether active endpoint of the peer is a proxy", "gauge")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			m.sample("p2p_peer_proxied", boolToFloat(peer.EndpointPath() == "proxy"), "hash", inst.ID, "peer", peer.ID)
+		}
+	}
+	m.family("p2p_peer_endpoint_latency_seconds", "Measured latency of peer endpoint", "gauge")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			peer.Lock.RLock()
+			for _, ep := range peer.EndpointsHeap {
+				if ep != nil && ep.Addr != nil {
+					m.sample("p2p_peer_endpoint_latency_seconds", ep.Latency.Seconds(), "hash", inst.ID, "peer", peer.ID, "endpoint", ep.Addr.String())
+				}
+			}
+			peer.Lock.RUnlock()
+		}
+	}
+	endpointStats := []struct {
+		name, help string
+		value      func(ptp.LatencyStats) float64
+	}{
+		{"p2p_peer_endpoint_loss_ratio", "Share of recent latency requests to peer endpoint without response", func(s ptp.LatencyStats) float64 { return s.Loss() / 100 }},
+		{"p2p_peer_endpoint_jitter_seconds", "Jitter of recent latency requests to peer endpoint", func(s ptp.LatencyStats) float64 { return s.Jitter.Seconds() }},
+		{"p2p_peer_endpoint_rtt_p95_seconds", "95th percentile of recent round-trip times to peer endpoint", func(s ptp.LatencyStats) float64 { return s.P95.Seconds() }},
+	}
+	for _, stat := range endpointStats {
+		m.family(stat.name, stat.help, "gauge")
+		for _, inst := range instances {
+			for _, peer := range peers[inst.ID] {
+				peer.Lock.RLock()
+				for _, ep := range peer.EndpointsHeap {
+					if ep != nil && ep.Addr != nil {
+						m.sample(stat.name, stat.value(ep.Stats()), "hash", inst.ID, "peer", peer.ID, "endpoint", ep.Addr.String())
+					}
+				}
+				peer.Lock.RUnlock()
+			}
+		}
+	}
+	m.family("p2p_peer_endpoint_mtu_bytes", "Discovered path MTU of peer endpoint", "gauge")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			peer.Lock.RLock()
+			for _, ep := range peer.EndpointsHeap {
+				if ep != nil && ep.Addr != nil && ep.MTU() != 0 {
+					m.sample("p2p_peer_endpoint_mtu_bytes", float64(ep.MTU()), "hash", inst.ID, "peer", peer.ID, "endpoint", ep.Addr.String())
+				}
+			}
+			peer.Lock.RUnlock()
+		}
+	}
+	peerTraffic := []struct {
+		name, help string
+		value      func(c ptp.TrafficCounters) uint64
+	}{
+		{"p2p_peer_tx_bytes_total", "Bytes of frames sent to the peer", func(c ptp.TrafficCounters) uint64 { return c.TxBytes }},
+		{"p2p_peer_rx_bytes_total", "Bytes of frames received from the peer", func(c ptp.TrafficCounters) uint64 { return c.RxBytes }},
+		{"p2p_peer_tx_packets_total", "Frames sent to the peer", func(c ptp.TrafficCounters) uint64 { return c.TxPackets }},
+		{"p2p_peer_rx_packets_total", "Frames received from the peer", func(c ptp.TrafficCounters) uint64 { return c.RxPackets }},
+	}
+	for _, metric := range peerTraffic {
+		m.family(metric.name, metric.help, "counter")
+		for _, inst := range instances {
+			for _, peer := range peers[inst.ID] {
+				for _, path := range ptp.PathTypes {
+					m.sample(metric.name, float64(metric.value(peer.Traffic.Path(path))), "hash", inst.ID, "peer", peer.ID, "path", path.String())
+				}
+			}
+		}
+	}
+	peerCompression := []struct {
+		name, help string
+		value      func(c ptp.CompressionStats) uint64
+	}{
+		{"p2p_peer_compressed_frames_total", "Frames sent to the peer compressed", func(c ptp.CompressionStats) uint64 { return c.Frames }},
+		{"p2p_peer_compression_skipped_total", "Frames sent to the peer as is, since they didn't shrink", func(c ptp.CompressionStats) uint64 { return c.Skipped }},
+		{"p2p_peer_compression_input_bytes_total", "Bytes of compressed frames before compression", func(c ptp.CompressionStats) uint64 { return c.Bytes }},
+		{"p2p_peer_compression_output_bytes_total", "Bytes of compressed frames after compression", func(c ptp.CompressionStats) uint64 { return c.Compressed }},
+	}
+	for _, metric := range peerCompression {
+		m.family(metric.name, metric.help, "counter")
+		for _, inst := range instances {
+			for _, peer := range peers[inst.ID] {
+				m.sample(metric.name, float64(metric.value(peer.CompressionStats())), "hash", inst.ID, "peer", peer.ID)
+			}
+		}
+	}
+	m.family("p2p_peer_connection_attempts_total", "Connection attempts during first connection cycle", "counter")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			m.sample("p2p_peer_connection_attempts_total", float64(peer.Stat.GetConnectionsNum()), "hash", inst.ID, "peer", peer.ID)
+		}
+	}
+	m.family("p2p_peer_reconnects_total", "Reconnection cycles", "counter")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			m.sample("p2p_peer_reconnects_total", float64(peer.Stat.GetReconnectsNum()), "hash", inst.ID, "peer", peer.ID)
+		}
+	}
+	m.family("p2p_peer_hole_punch_attempts_total", "UDP hole punching attempts", "counter")
+	for _, inst := range instances {
+		for _, peer := range peers[inst.ID] {
+			m.sample("p2p_peer_hole_punch_attempts_total", float64(peer.Stat.GetHolePunchNum()), "hash", inst.ID, "peer", peer.ID)
+		}
+	}
+	return m.buf.Bytes()
+}
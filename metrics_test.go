@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+func TestMetricsWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		labels []string
+		want   string
+	}{
+		{"no labels", 1, nil, "p2p_test 1\n"},
+		{"single label", 0.25, []string{"hash", "abc"}, "p2p_test{hash=\"abc\"} 0.25\n"},
+		{"many labels", 3, []string{"hash", "abc", "peer", "def"}, "p2p_test{hash=\"abc\",peer=\"def\"} 3\n"},
+		{"escaped label", 1, []string{"hash", "a\"b\\c\nd"}, "p2p_test{hash=\"a\\\"b\\\\c\\nd\"} 1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := new(metricsWriter)
+			m.sample("p2p_test", tt.value, tt.labels...)
+			if got := m.buf.String(); got != tt.want {
+				t.Errorf("metricsWriter.sample() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaemon_metrics(t *testing.T) {
+	d := new(Daemon)
+	d.Instances = new(InstanceList)
+	d.Instances.init()
+	out := string(d.metrics())
+	for _, name := range []string{"# TYPE p2p_uptime_seconds gauge", "p2p_instances 0"} {
+		if !strings.Contains(out, name) {
+			t.Errorf("Daemon.metrics() output doesn't contain %q", name)
+		}
+	}
+}
+
+func TestDaemon_metrics_peers(t *testing.T) {
+	swarm := new(ptp.Swarm)
+	swarm.Init()
+	swarm.Update("peer", &ptp.NetworkPeer{ID: "peer", State: ptp.PeerStateConnected})
+	d := new(Daemon)
+	d.Instances = new(InstanceList)
+	d.Instances.init()
+	d.Instances.update("hash", &P2PInstance{ID: "hash", PTP: &ptp.PeerToPeer{Swarm: swarm}})
+	out := string(d.metrics())
+
+	for _, sample := range []string{
+		`p2p_instance_rx_bytes_total{hash="hash"} 0`,
+		`p2p_instance_tx_packets_total{hash="hash"} 0`,
+		`p2p_peer_state{hash="hash",peer="peer",state="CONNECTED"} 1`,
+		`p2p_peer_tx_bytes_total{hash="hash",peer="peer",path="proxy"} 0`,
+		`p2p_peer_rx_bytes_total{hash="hash",peer="peer",path="lan"} 0`,
+		`p2p_peer_tx_packets_total{hash="hash",peer="peer",path="internet"} 0`,
+		`p2p_peer_rx_packets_total{hash="hash",peer="peer",path="internet"} 0`,
+		`p2p_peer_hole_punch_attempts_total{hash="hash",peer="peer"} 0`,
+		`p2p_peer_reconnects_total{hash="hash",peer="peer"} 0`,
+	} {
+		if !strings.Contains(out, sample+"\n") {
+			t.Errorf("Daemon.metrics() output doesn't contain %q", sample)
+		}
+	}
+
+	// Samples of a family must follow its header
+	family := ""
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.HasPrefix(line, "# TYPE ") {
+			family = strings.Fields(line)[2]
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name := strings.FieldsFunc(line, func(r rune) bool { return r == '{' || r == ' ' })[0]; name != family {
+			t.Errorf("Sample %q follows header of %s", line, family)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is a version of v2 API reported in OpenAPI document
+const OpenAPIVersion = "2.0.0"
+
+// openAPIDocument generates OpenAPI 3 document describing routes
+func openAPIDocument(routes []apiRoute) map[string]interface{} {
+	g := &openAPIGenerator{schemas: make(map[string]interface{})}
+	paths := make(map[string]interface{})
+	tags := []string{}
+	for _, route := range routes {
+		item, exists := paths[route.Path].(map[string]interface{})
+		if !exists {
+			item = make(map[string]interface{})
+			paths[route.Path] = item
+		}
+		item[strings.ToLower(route.Method)] = g.operation(route)
+		found := false
+		for _, t := range tags {
+			found = found || t == route.Tag
+		}
+		if !found {
+			tags = append(tags, route.Tag)
+		}
+	}
+	tagList := []interface{}{}
+	for _, t := range tags {
+		tagList = append(tagList, map[string]interface{}{"name": t})
+	}
+	g.schemas["APIError"] = g.structSchema(reflect.TypeOf(APIError{}))
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Subutai P2P",
+			"version": OpenAPIVersion,
+			"license": map[string]interface{}{
+				"name": "GPLv3",
+				"url":  "https://www.gnu.org/licenses/gpl-3.0.en.html",
+			},
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": "http://localhost:52523" + RESTv2Prefix},
+		},
+		"tags":  tagList,
+		"paths": paths,
+		// Requests over Unix socket or from verified client
+		// certificates don't need a token
+		"security": []interface{}{
+			map[string]interface{}{"bearerAuth": []interface{}{}},
+			map[string]interface{}{},
+		},
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+		},
+	}
+}
+
+type openAPIGenerator struct {
+	schemas map[string]interface{}
+}
+
+func (g *openAPIGenerator) operation(route apiRoute) map[string]interface{} {
+	op := map[string]interface{}{
+		"tags":        []interface{}{route.Tag},
+		"summary":     route.Summary,
+		"operationId": operationID(route),
+	}
+	params := []interface{}{}
+	for _, part := range strings.Split(route.Path, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params = append(params, map[string]interface{}{
+				"name":     part[1 : len(part)-1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if route.Request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  jsonContent(g.schema(reflect.TypeOf(route.Request))),
+		}
+	}
+
+	responses := make(map[string]interface{})
+	success := map[string]interface{}{"description": http.StatusText(route.Status)}
+	if route.Response != nil {
+		success["content"] = jsonContent(g.schema(reflect.TypeOf(route.Response)))
+	} else if route.Status != http.StatusNoContent {
+		success["content"] = jsonContent(map[string]interface{}{"type": "object"})
+	}
+	responses[fmt.Sprintf("%d", route.Status)] = success
+	errors := append([]int{}, route.Errors...)
+	if route.Request != nil && !containsInt(errors, http.StatusBadRequest) {
+		errors = append(errors, http.StatusBadRequest)
+	}
+	errors = append(errors, http.StatusUnauthorized)
+	if route.Method != http.MethodGet {
+		errors = append(errors, http.StatusForbidden)
+	}
+	for _, code := range errors {
+		responses[fmt.Sprintf("%d", code)] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content":     jsonContent(schemaRef("APIError")),
+		}
+	}
+	op["responses"] = responses
+	return op
+}
+
+// operationID builds an identifier like getInstancesHashPeers
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.Split(route.Path, "/") {
+		part = strings.Trim(part, "{}")
+		part = strings.Replace(part, ".", "", -1)
+		if part == "" {
+			continue
+		}
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func containsInt(list []int, v int) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonContent(schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// schema returns schema of type t. Named structs are added to components
+// and referenced
+func (g *openAPIGenerator) schema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		return g.schema(t.Elem())
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, exists := g.schemas[t.Name()]; !exists {
+			// Placeholder protects from recursive types
+			g.schemas[t.Name()] = nil
+			g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return schemaRef(t.Name())
+	}
+	return map[string]interface{}{}
+}
+
+func (g *openAPIGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+		omit := false
+		for _, opt := range tag[1:] {
+			omit = omit || opt == "omitempty"
+		}
+		if !omit && f.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+	s := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
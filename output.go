@@ -0,0 +1,418 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	ptp "github.com/subutai-io/p2p/lib"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// OutputVersion is a version of structured responses. It must be
+// increased every time fields are removed or change their meaning
+const OutputVersion = 1
+
+// Output formats supported by client commands
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatYAML  = "yaml"
+)
+
+// validateFormat terminates client when unknown output format was specified
+func validateFormat(format string) {
+	switch format {
+	case "", FormatTable, FormatJSON, FormatYAML:
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Unknown output format %s. Supported formats: table, json, yaml\n", format)
+	os.Exit(1)
+}
+
+// isStructured returns true when format requires versioned response
+func isStructured(format string) bool {
+	return format == FormatJSON || format == FormatYAML
+}
+
+// printStructured prints response in JSON or YAML format
+func printStructured(format string, v interface{}) error {
+	var data []byte
+	var err error
+	if format == FormatYAML {
+		data, err = yaml.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to marshal output: %s", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
+// MessageOutput is a structured representation of a plain text response
+type MessageOutput struct {
+	Version int    `json:"version" yaml:"version"`
+	Code    int    `json:"code" yaml:"code"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// printMessage outputs response of a command that returns plain text
+// and terminates client with response code
+func printMessage(format string, out *RESTResponse) {
+	if isStructured(format) {
+		printStructured(format, &MessageOutput{Version: OutputVersion, Code: out.Code, Message: out.Message})
+	} else if out.Code > 0 {
+		fmt.Fprintln(os.Stderr, out.Message)
+	} else {
+		fmt.Println(out.Message)
+	}
+	os.Exit(out.Code)
+}
+
+// EndpointOutput describes a single endpoint of a peer
+type EndpointOutput struct {
+	Addr        string     `json:"addr" yaml:"addr"`
+	Latency     float64    `json:"latency_ms" yaml:"latency_ms"`
+	Score       float64    `json:"score_ms" yaml:"score_ms"`
+	MTU         int        `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+	LastContact string     `json:"last_contact" yaml:"last_contact"`
+	RTT         *RTTOutput `json:"rtt,omitempty" yaml:"rtt,omitempty"`
+}
+
+// RTTOutput holds round-trip time, jitter and loss of recent latency
+// requests
+type RTTOutput struct {
+	Samples int     `json:"samples" yaml:"samples"`
+	Lost    int     `json:"lost" yaml:"lost"`
+	Loss    float64 `json:"loss_percent" yaml:"loss_percent"`
+	Min     float64 `json:"min_ms" yaml:"min_ms"`
+	Avg     float64 `json:"avg_ms" yaml:"avg_ms"`
+	Max     float64 `json:"max_ms" yaml:"max_ms"`
+	P95     float64 `json:"p95_ms" yaml:"p95_ms"`
+	Jitter  float64 `json:"jitter_ms" yaml:"jitter_ms"`
+}
+
+// newRTTOutput returns nil until any request was answered or lost
+func newRTTOutput(stats ptp.LatencyStats) *RTTOutput {
+	if stats.Samples+stats.Lost == 0 {
+		return nil
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d.Nanoseconds()) / float64(time.Millisecond)
+	}
+	return &RTTOutput{
+		Samples: stats.Samples,
+		Lost:    stats.Lost,
+		Loss:    stats.Loss(),
+		Min:     ms(stats.Min),
+		Avg:     ms(stats.Avg),
+		Max:     ms(stats.Max),
+		P95:     ms(stats.P95),
+		Jitter:  ms(stats.Jitter),
+	}
+}
+
+// PeerStatsOutput holds connection statistics of a peer
+type PeerStatsOutput struct {
+	ConnectionAttempts int    `json:"connection_attempts" yaml:"connection_attempts"`
+	Reconnects         int    `json:"reconnects" yaml:"reconnects"`
+	HolePunches        int    `json:"hole_punches" yaml:"hole_punches"`
+	StartedAt          string `json:"started_at,omitempty" yaml:"started_at,omitempty"`
+	ConnectedAt        string `json:"connected_at,omitempty" yaml:"connected_at,omitempty"`
+	ConnectionLostAt   string `json:"connection_lost_at,omitempty" yaml:"connection_lost_at,omitempty"`
+	ReconnectedAt      string `json:"reconnected_at,omitempty" yaml:"reconnected_at,omitempty"`
+}
+
+// TrafficOutput holds traffic counters of a peer
+type TrafficOutput struct {
+	Total     ptp.TrafficCounters            `json:"total" yaml:"total"`
+	Paths     map[string]ptp.TrafficCounters `json:"paths" yaml:"paths"`
+	Endpoints map[string]ptp.TrafficCounters `json:"endpoints" yaml:"endpoints"`
+	Since     string                         `json:"since,omitempty" yaml:"since,omitempty"`
+}
+
+// PeerOutput is a full description of a peer
+type PeerOutput struct {
+	ID          string             `json:"id" yaml:"id"`
+	IP          string             `json:"ip" yaml:"ip"`
+	Mac         string             `json:"mac" yaml:"mac"`
+	State       string             `json:"state" yaml:"state"`
+	RemoteState string             `json:"remote_state" yaml:"remote_state"`
+	LastError   string             `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+	Endpoint    string             `json:"endpoint" yaml:"endpoint"`
+	Path        string             `json:"path" yaml:"path"`
+	Paths       []string           `json:"paths,omitempty" yaml:"paths,omitempty"`
+	LastContact string             `json:"last_contact" yaml:"last_contact"`
+	Endpoints   []EndpointOutput   `json:"endpoints" yaml:"endpoints"`
+	KnownIPs    []string           `json:"known_ips" yaml:"known_ips"`
+	Proxies     []string           `json:"proxies" yaml:"proxies"`
+	Stats       PeerStatsOutput    `json:"stats" yaml:"stats"`
+	Traffic     TrafficOutput      `json:"traffic" yaml:"traffic"`
+	Compression *CompressionOutput `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+// CompressionOutput holds compression counters of frames sent to a peer
+type CompressionOutput struct {
+	Frames     uint64  `json:"frames" yaml:"frames"`
+	Skipped    uint64  `json:"skipped" yaml:"skipped"`
+	Bytes      uint64  `json:"bytes" yaml:"bytes"`
+	Compressed uint64  `json:"compressed_bytes" yaml:"compressed_bytes"`
+	Ratio      float64 `json:"ratio" yaml:"ratio"`
+}
+
+// ProxyOutput describes a proxy server used by instance
+type ProxyOutput struct {
+	Addr     string     `json:"addr" yaml:"addr"`
+	Endpoint string     `json:"endpoint" yaml:"endpoint"`
+	Active   bool       `json:"active" yaml:"active"`
+	Latency  float64    `json:"latency_ms" yaml:"latency_ms"`
+	RTT      *RTTOutput `json:"rtt,omitempty" yaml:"rtt,omitempty"`
+}
+
+// InstanceOutput is a full description of an instance
+type InstanceOutput struct {
+	Hash         string        `json:"hash" yaml:"hash"`
+	ID           string        `json:"id" yaml:"id"`
+	IP           string        `json:"ip" yaml:"ip"`
+	Mac          string        `json:"mac" yaml:"mac"`
+	Interface    string        `json:"interface" yaml:"interface"`
+	Port         int           `json:"port" yaml:"port"`
+	DHTConnected bool          `json:"dht_connected" yaml:"dht_connected"`
+	LocalIPs     []string      `json:"local_ips" yaml:"local_ips"`
+	Proxies      []ProxyOutput `json:"proxies" yaml:"proxies"`
+	Peers        []PeerOutput  `json:"peers,omitempty" yaml:"peers,omitempty"`
+}
+
+// InterfaceOutput describes p2p interface
+type InterfaceOutput struct {
+	Name string `json:"name" yaml:"name"`
+	Hash string `json:"hash,omitempty" yaml:"hash,omitempty"`
+}
+
+// ShowResponse is a versioned response of `show` command
+type ShowResponse struct {
+	Version    int               `json:"version" yaml:"version"`
+	Code       int               `json:"code" yaml:"code"`
+	Error      string            `json:"error,omitempty" yaml:"error,omitempty"`
+	Text       string            `json:"text,omitempty" yaml:"text,omitempty"`
+	Instances  []InstanceOutput  `json:"instances,omitempty" yaml:"instances,omitempty"`
+	Peers      []PeerOutput      `json:"peers,omitempty" yaml:"peers,omitempty"`
+	Interfaces []InterfaceOutput `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`
+	MTU        int               `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+}
+
+// StatusResponse is a versioned response of `status` command
+type StatusResponse struct {
+	Version   int              `json:"version" yaml:"version"`
+	Code      int              `json:"code" yaml:"code"`
+	Error     string           `json:"error,omitempty" yaml:"error,omitempty"`
+	Instances []InstanceOutput `json:"instances" yaml:"instances"`
+}
+
+// BootstrapOutput describes connection to a bootstrap node
+type BootstrapOutput struct {
+	Addr          string `json:"addr" yaml:"addr"`
+	Connected     bool   `json:"connected" yaml:"connected"`
+	Rx            uint64 `json:"rx" yaml:"rx"`
+	Tx            uint64 `json:"tx" yaml:"tx"`
+	Version       string `json:"version" yaml:"version"`
+	PacketVersion string `json:"packet_version" yaml:"packet_version"`
+}
+
+// DebugResponse is a versioned response of `debug` command
+type DebugResponse struct {
+	Version    int               `json:"version" yaml:"version"`
+	Code       int               `json:"code" yaml:"code"`
+	Error      string            `json:"error,omitempty" yaml:"error,omitempty"`
+	AppVersion string            `json:"app_version" yaml:"app_version"`
+	Build      string            `json:"build" yaml:"build"`
+	Uptime     int64             `json:"uptime" yaml:"uptime"`
+	Goroutines int               `json:"goroutines" yaml:"goroutines"`
+	PMTU       bool              `json:"pmtu" yaml:"pmtu"`
+	Bootstrap  []BootstrapOutput `json:"bootstrap" yaml:"bootstrap"`
+	Instances  []InstanceOutput  `json:"instances" yaml:"instances"`
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func newPeerOutput(peer *ptp.NetworkPeer) PeerOutput {
+	out := PeerOutput{
+		ID:          peer.ID,
+		State:       ptp.StringifyState(peer.State),
+		RemoteState: ptp.StringifyState(peer.RemoteState),
+		LastError:   peer.LastError,
+		Path:        peer.EndpointPath(),
+		Paths:       peer.Paths(),
+		LastContact: formatTime(peer.LastContact),
+		Endpoints:   []EndpointOutput{},
+		KnownIPs:    []string{},
+		Proxies:     []string{},
+		Stats: PeerStatsOutput{
+			ConnectionAttempts: peer.Stat.GetConnectionsNum(),
+			Reconnects:         peer.Stat.GetReconnectsNum(),
+			HolePunches:        peer.Stat.GetHolePunchNum(),
+			StartedAt:          formatTime(peer.Stat.GetStartedAt()),
+			ConnectedAt:        formatTime(peer.Stat.GetConnectedAt()),
+			ConnectionLostAt:   formatTime(peer.Stat.GetConnectionLostAt()),
+			ReconnectedAt:      formatTime(peer.Stat.GetReconnectedAt()),
+		},
+		Traffic: *newTrafficOutput(peer),
+	}
+	if c := peer.CompressionStats(); c.Frames+c.Skipped != 0 {
+		out.Compression = &CompressionOutput{
+			Frames:     c.Frames,
+			Skipped:    c.Skipped,
+			Bytes:      c.Bytes,
+			Compressed: c.Compressed,
+			Ratio:      c.Ratio(),
+		}
+	}
+	if peer.PeerLocalIP != nil {
+		out.IP = peer.PeerLocalIP.String()
+	}
+	if peer.PeerHW != nil {
+		out.Mac = peer.PeerHW.String()
+	}
+	if peer.Endpoint != nil {
+		out.Endpoint = peer.Endpoint.String()
+	}
+	peer.Lock.RLock()
+	for _, ep := range peer.EndpointsHeap {
+		if ep == nil || ep.Addr == nil {
+			continue
+		}
+		out.Endpoints = append(out.Endpoints, EndpointOutput{
+			Addr:        ep.Addr.String(),
+			Latency:     float64(ep.Latency.Nanoseconds()) / float64(time.Millisecond),
+			Score:       float64(peer.EndpointScore(ep).Nanoseconds()) / float64(time.Millisecond),
+			MTU:         ep.MTU(),
+			LastContact: formatTime(ep.LastContact),
+			RTT:         newRTTOutput(ep.Stats()),
+		})
+	}
+	peer.Lock.RUnlock()
+	for _, ip := range peer.KnownIPs {
+		if ip != nil {
+			out.KnownIPs = append(out.KnownIPs, ip.String())
+		}
+	}
+	for _, proxy := range peer.Proxies {
+		if proxy != nil {
+			out.Proxies = append(out.Proxies, proxy.String())
+		}
+	}
+	return out
+}
+
+func newTrafficOutput(peer *ptp.NetworkPeer) *TrafficOutput {
+	out := &TrafficOutput{
+		Total:     peer.Traffic.Total(),
+		Paths:     make(map[string]ptp.TrafficCounters),
+		Endpoints: peer.Traffic.Endpoints(),
+		Since:     formatTime(peer.Traffic.ResetAt()),
+	}
+	for _, path := range ptp.PathTypes {
+		out.Paths[path.String()] = peer.Traffic.Path(path)
+	}
+	return out
+}
+
+func newInstanceOutput(inst *P2PInstance, withPeers bool) InstanceOutput {
+	out := InstanceOutput{
+		Hash:     inst.ID,
+		LocalIPs: []string{},
+		Proxies:  []ProxyOutput{},
+	}
+	p := inst.PTP
+	if p == nil {
+		return out
+	}
+	if p.Dht != nil {
+		out.ID = p.Dht.ID
+		out.DHTConnected = p.Dht.Connected
+	}
+	if p.UDPSocket != nil {
+		out.Port = p.UDPSocket.GetPort()
+	}
+	if p.Interface != nil {
+		if p.Interface.GetIP() != nil {
+			out.IP = p.Interface.GetIP().String()
+		}
+		if p.Interface.GetHardwareAddress() != nil {
+			out.Mac = p.Interface.GetHardwareAddress().String()
+		}
+		out.Interface = p.Interface.GetName()
+	}
+	for _, ip := range p.LocalIPs {
+		out.LocalIPs = append(out.LocalIPs, ip.String())
+	}
+	if p.ProxyManager != nil {
+		for _, proxy := range p.ProxyManager.GetList() {
+			if proxy.Addr == nil {
+				continue
+			}
+			po := ProxyOutput{
+				Addr:    proxy.Addr.String(),
+				Active:  proxy.IsActive(),
+				Latency: float64(proxy.Latency.Nanoseconds()) / float64(time.Millisecond),
+				RTT:     newRTTOutput(proxy.Stats()),
+			}
+			if proxy.Endpoint != nil {
+				po.Endpoint = proxy.Endpoint.String()
+			}
+			out.Proxies = append(out.Proxies, po)
+		}
+	}
+	if withPeers && p.Swarm != nil {
+		out.Peers = newPeersOutput(p.Swarm.Get())
+	}
+	return out
+}
+
+func newPeersOutput(peers map[string]*ptp.NetworkPeer) []PeerOutput {
+	out := []PeerOutput{}
+	for _, peer := range peers {
+		out = append(out, newPeerOutput(peer))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// newInstancesOutput returns description of instances sorted by hash.
+// When hash is not empty only that instance is returned
+func (d *Daemon) newInstancesOutput(hash string, withPeers bool) []InstanceOutput {
+	out := []InstanceOutput{}
+	for _, inst := range d.Instances.get() {
+		if hash != "" && inst.ID != hash {
+			continue
+		}
+		out = append(out, newInstanceOutput(inst, withPeers))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hash < out[j].Hash })
+	return out
+}
+
+// daemonState returns error code and message when daemon is not able to
+// serve requests yet
+func daemonState() (int, string) {
+	if !ReadyToServe {
+		return 105, "P2P Daemon is in initialization state"
+	}
+	if !bootstrap.isActive {
+		return 106, "Not connected to DHT nodes"
+	}
+	if bootstrap.ip == "" {
+		return 107, "Didn't received outbound IP yet"
+	}
+	return 0, ""
+}
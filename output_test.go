@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+func TestIsStructured(t *testing.T) {
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{"", false},
+		{FormatTable, false},
+		{FormatJSON, true},
+		{FormatYAML, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if got := isStructured(tt.format); got != tt.want {
+				t.Errorf("isStructured() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPeerOutput(t *testing.T) {
+	ep, _ := net.ResolveUDPAddr("udp4", "8.8.8.8:1234")
+	proxy, _ := net.ResolveUDPAddr("udp4", "8.8.4.4:1234")
+	peer := &ptp.NetworkPeer{
+		ID:            "peer",
+		PeerLocalIP:   net.ParseIP("10.10.10.1"),
+		PeerHW:        net.HardwareAddr{0x06, 0x05, 0x04, 0x03, 0x02, 0x01},
+		Endpoint:      proxy,
+		State:         ptp.PeerStateConnected,
+		KnownIPs:      []*net.UDPAddr{ep},
+		Proxies:       []*net.UDPAddr{proxy},
+		EndpointsHeap: []*ptp.Endpoint{{Addr: proxy}},
+	}
+	out := newPeerOutput(peer)
+	if out.ID != "peer" || out.IP != "10.10.10.1" || out.Mac != "06:05:04:03:02:01" {
+		t.Errorf("newPeerOutput() wrong identity: %+v", out)
+	}
+	if out.Path != "proxy" || out.Endpoint != proxy.String() {
+		t.Errorf("newPeerOutput() wrong endpoint: %s %s", out.Endpoint, out.Path)
+	}
+	if len(out.Endpoints) != 1 || len(out.KnownIPs) != 1 || len(out.Proxies) != 1 {
+		t.Errorf("newPeerOutput() wrong endpoints: %+v", out)
+	}
+	if len(out.Traffic.Paths) != len(ptp.PathTypes) {
+		t.Errorf("newPeerOutput() wrong traffic paths: %+v", out.Traffic.Paths)
+	}
+}
+
+func TestDaemon_showStructured(t *testing.T) {
+	ReadyToServe = false
+	d := new(Daemon)
+	d.Instances = new(InstanceList)
+	d.Instances.init()
+	out := d.showStructured(&request{})
+	if out.Version != OutputVersion || out.Code != 105 {
+		t.Errorf("Daemon.showStructured() = %+v", out)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+// daemonSettings holds values passed on the command line and the
+// configuration applied at start or by the last reload. Command line
+// values take precedence over configuration file
+type daemonSettings struct {
+	configFile string
+	targetURL  string
+	logLevel   string
+	syslog     string
+	mtu        int
+	pmtu       bool
+	conf       *ptp.Conf
+	hooks      *ptp.HookRunner
+	logFile    io.Closer
+	lock       sync.Mutex
+	globals    sync.RWMutex // Guards daemon-wide ptp variables changed by reload
+}
+
+// instanceConfig fills daemon-wide parameters of new instance. Daemon MTU
+// is used when MTU is 0
+func (s *daemonSettings) instanceConfig(cfg ptp.Config) ptp.Config {
+	if s != nil {
+		s.globals.RLock()
+		defer s.globals.RUnlock()
+	}
+	if cfg.MTU == 0 {
+		cfg.MTU = ptp.GlobalMTU
+	}
+	cfg.PMTU = ptp.UsePMTU
+	cfg.UnderlayMTU = ptp.UnderlayMTU
+	cfg.Queues = ptp.TAPQueues
+	cfg.Offload = ptp.UseOffload
+	cfg.Compression = ptp.UseCompression
+	cfg.Multipath = ptp.GetMultipath()
+	cfg.DuplicatePorts = ptp.GetDuplicatePorts()
+	return cfg
+}
+
+// setGlobals changes daemon-wide ptp variables read by instanceConfig
+func (s *daemonSettings) setGlobals(set func()) {
+	s.globals.Lock()
+	defer s.globals.Unlock()
+	set()
+}
+
+// ReloadOutput describes result of configuration reload
+type ReloadOutput struct {
+	Applied         []string `json:"applied" yaml:"applied"`
+	RestartRequired []string `json:"restart_required" yaml:"restart_required"`
+	Failed          []string `json:"failed" yaml:"failed"`
+}
+
+// ReloadResponse is a versioned response of `reload` command
+type ReloadResponse struct {
+	Version      int    `json:"version" yaml:"version"`
+	Code         int    `json:"code" yaml:"code"`
+	Error        string `json:"error,omitempty" yaml:"error,omitempty"`
+	ReloadOutput `yaml:",inline"`
+}
+
+// CommandReload asks daemon to re-read configuration file
+func CommandReload(restPort int, format string) {
+	validateFormat(format)
+	data, err := sendRequestRaw(restPort, "reload", &request{Format: format})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	out := &ReloadResponse{}
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to unmarshal response: %s\n", err)
+		os.Exit(1)
+	}
+	if isStructured(format) {
+		printStructured(format, out)
+		os.Exit(out.Code)
+	}
+	if out.Code != 0 {
+		fmt.Fprintln(os.Stderr, out.Error)
+		os.Exit(out.Code)
+	}
+	fmt.Print(formatReload(&out.ReloadOutput))
+}
+
+func formatReload(out *ReloadOutput) string {
+	if len(out.Applied)+len(out.RestartRequired)+len(out.Failed) == 0 {
+		return "Configuration reloaded, no changes found\n"
+	}
+	result := "Configuration reloaded\n"
+	sections := []struct {
+		title string
+		lines []string
+	}{
+		{"Applied", out.Applied},
+		{"Restart required", out.RestartRequired},
+		{"Failed", out.Failed},
+	}
+	for _, s := range sections {
+		if len(s.lines) == 0 {
+			continue
+		}
+		result += s.title + ":\n"
+		for _, l := range s.lines {
+			result += "  " + l + "\n"
+		}
+	}
+	return result
+}
+
+func (d *Daemon) execRESTReload(w http.ResponseWriter, r *http.Request) {
+	response := &ReloadResponse{Version: OutputVersion}
+	out, err := d.reload()
+	if err != nil {
+		response.Code = 1
+		response.Error = err.Error()
+	} else {
+		response.ReloadOutput = *out
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		ptp.Log(ptp.Error, "Failed to marshal reload response: %s", err)
+		return
+	}
+	w.Write(data)
+}
+
+// reload re-reads configuration file and applies changes that are safe
+// to apply live. Changes that require restart are reported only
+func (d *Daemon) reload() (*ReloadOutput, error) {
+	s := d.settings
+	if s == nil {
+		return nil, fmt.Errorf("Reload is not available")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	conf, err := processConfigFile(s.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load config file %s: %s", s.configFile, err)
+	}
+	prev := s.conf
+	if prev == nil {
+		prev = new(ptp.Conf)
+		prev.SetDefaults()
+	}
+	out := &ReloadOutput{Applied: []string{}, RestartRequired: []string{}, Failed: []string{}}
+	applied := func(format string, a ...interface{}) {
+		out.Applied = append(out.Applied, fmt.Sprintf(format, a...))
+	}
+	restart := func(format string, a ...interface{}) {
+		out.RestartRequired = append(out.RestartRequired, fmt.Sprintf(format, a...))
+	}
+
+	oldLevel, newLevel := prev.GetLogLevel(s.logLevel), conf.GetLogLevel(s.logLevel)
+	if newLevel == "" {
+		newLevel = DefaultLog
+	}
+	if oldLevel == "" {
+		oldLevel = DefaultLog
+	}
+	if oldLevel != newLevel {
+		levels := ptp.LogLevels()
+		ptp.ClearSubsystemLogLevels()
+		if err := ptp.SetLogLevels(newLevel); err != nil {
+			ptp.SetLogLevels(levels)
+			out.Failed = append(out.Failed, fmt.Sprintf("log_level: %s", err))
+		} else {
+			applied("log_level: %s -> %s", oldLevel, newLevel)
+		}
+	}
+
+	if prev.GetLogFormat() != conf.GetLogFormat() {
+		if err := ptp.SetLogFormat(conf.GetLogFormat()); err != nil {
+			out.Failed = append(out.Failed, fmt.Sprintf("log_format: %s", err))
+		} else {
+			applied("log_format: %s -> %s", prev.GetLogFormat(), conf.GetLogFormat())
+		}
+	}
+
+	if !reflect.DeepEqual(prev.GetLogOutput(), conf.GetLogOutput()) {
+		file, err := configureLogOutput(conf)
+		if err != nil {
+			out.Failed = append(out.Failed, fmt.Sprintf("log_output: %s", err))
+			conf.LogOutput = prev.LogOutput
+		} else {
+			if s.logFile != nil {
+				s.logFile.Close()
+			}
+			s.logFile = file
+			applied("log_output: reopened")
+		}
+	}
+
+	oldSyslog, newSyslog := prev.GetSyslog(s.syslog), conf.GetSyslog(s.syslog)
+	if oldSyslog != newSyslog {
+		ptp.SetSyslogSocket(newSyslog)
+		applied("syslog: %s -> %s", oldSyslog, newSyslog)
+	}
+
+	newPMTU := s.pmtu || conf.GetPMTU()
+	if newPMTU != ptp.UsePMTU {
+		applied("pmtu: %t -> %t", ptp.UsePMTU, newPMTU)
+		s.setGlobals(func() { ptp.UsePMTU = newPMTU })
+		d.setPMTU(newPMTU)
+	}
+
+	if prev.GetMultipath() != conf.GetMultipath() {
+		var err error
+		s.setGlobals(func() { err = ptp.SetMultipath(conf.GetMultipath()) })
+		if err != nil {
+			out.Failed = append(out.Failed, fmt.Sprintf("multipath: %s", err))
+		} else {
+			d.setMultipath(ptp.GetMultipath())
+			applied("multipath: %s -> %s", prev.GetMultipath(), conf.GetMultipath())
+		}
+	}
+
+	if !reflect.DeepEqual(prev.GetDuplicatePorts(), conf.GetDuplicatePorts()) {
+		var err error
+		s.setGlobals(func() { err = ptp.SetDuplicatePorts(conf.GetDuplicatePorts()) })
+		if err != nil {
+			out.Failed = append(out.Failed, fmt.Sprintf("duplicate_ports: %s", err))
+		} else {
+			d.setDuplicatePorts(conf.GetDuplicatePorts())
+			applied("duplicate_ports: %v -> %v", prev.GetDuplicatePorts(), conf.GetDuplicatePorts())
+		}
+	}
+
+	if !reflect.DeepEqual(prev.GetHooks(), conf.GetHooks()) {
+		if s.hooks != nil {
+			s.hooks.Stop()
+		}
+		s.hooks = configureHooks(conf)
+		applied("hooks: reloaded")
+	}
+
+	if !reflect.DeepEqual(prev.GetBootstrap(), conf.GetBootstrap()) {
+		nodes, err := lookupRouters(s.targetURL, conf.GetBootstrap())
+		list := []string{}
+		for _, n := range nodes {
+			list = append(list, n)
+		}
+		if err == nil {
+			added, removed, uerr := bootstrap.updateRouters(list)
+			err = uerr
+			for _, r := range added {
+				applied("bootstrap: connected to %s", r)
+			}
+			for _, r := range removed {
+				applied("bootstrap: disconnected from %s", r)
+			}
+		}
+		if err != nil {
+			out.Failed = append(out.Failed, fmt.Sprintf("bootstrap: %s", err))
+			// Keep previous list, so next reload will try again
+			conf.Bootstrap = prev.Bootstrap
+		}
+	}
+
+	oldMTU, newMTU := prev.GetMTU(s.mtu), conf.GetMTU(s.mtu)
+	if oldMTU != newMTU {
+		// New instances will use new value right away
+		s.setGlobals(func() { ptp.GlobalMTU = newMTU })
+		restart("mtu: %d -> %d, restart running instances to apply", oldMTU, newMTU)
+	}
+	if prev.GetTAPQueues() != conf.GetTAPQueues() {
+		s.setGlobals(func() { ptp.TAPQueues = conf.GetTAPQueues() })
+		restart("tap_queues: %d -> %d, restart running instances to apply", prev.GetTAPQueues(), conf.GetTAPQueues())
+	}
+	if prev.GetOffload() != conf.GetOffload() {
+		s.setGlobals(func() { ptp.UseOffload = conf.GetOffload() })
+		restart("offload: %t -> %t, restart running instances to apply", prev.GetOffload(), conf.GetOffload())
+	}
+	if prev.GetUnderlayMTU() != conf.GetUnderlayMTU() {
+		s.setGlobals(func() { ptp.UnderlayMTU = conf.GetUnderlayMTU() })
+		restart("underlay_mtu: %d -> %d, restart running instances to apply", prev.GetUnderlayMTU(), conf.GetUnderlayMTU())
+	}
+	if prev.GetCompression() != conf.GetCompression() {
+		s.setGlobals(func() { ptp.UseCompression = conf.GetCompression() })
+		restart("compression: %t -> %t, restart running instances to apply", prev.GetCompression(), conf.GetCompression())
+	}
+	if prev.IPTool != conf.IPTool || prev.TAPTool != conf.TAPTool || prev.INFFile != conf.INFFile {
+		restart("iptool, taptool, inf_file: restart daemon to apply")
+	}
+	if !reflect.DeepEqual(prev.GetAPI(), conf.GetAPI()) {
+		restart("api: restart daemon to apply")
+	}
+	if prev.GetMasterKeyFile() != conf.GetMasterKeyFile() {
+		restart("master_key_file: restart daemon to apply")
+	}
+
+	s.conf = conf
+	for _, l := range out.Applied {
+		ptp.Log(ptp.Info, "Reload: %s", l)
+	}
+	for _, l := range out.RestartRequired {
+		ptp.Log(ptp.Warning, "Reload: %s", l)
+	}
+	for _, l := range out.Failed {
+		ptp.Log(ptp.Error, "Reload: %s", l)
+	}
+	return out, nil
+}
+
+// setPMTU switches PMTU on interfaces of running instances
+func (d *Daemon) setPMTU(enabled bool) {
+	if d.Instances == nil {
+		return
+	}
+	for _, inst := range d.Instances.get() {
+		if inst == nil || inst.PTP == nil || inst.PTP.Interface == nil {
+			continue
+		}
+		if enabled {
+			inst.PTP.Interface.EnablePMTU()
+		} else {
+			inst.PTP.Interface.DisablePMTU()
+		}
+	}
+}
+
+// setMultipath changes multipath mode of running instances
+func (d *Daemon) setMultipath(mode ptp.MultipathMode) {
+	if d.Instances == nil {
+		return
+	}
+	for _, inst := range d.Instances.get() {
+		if inst == nil || inst.PTP == nil {
+			continue
+		}
+		inst.PTP.SetMultipath(mode)
+	}
+}
+
+// setDuplicatePorts changes duplicate ports of running instances. Ports
+// were validated already
+func (d *Daemon) setDuplicatePorts(ports []int) {
+	if d.Instances == nil {
+		return
+	}
+	for _, inst := range d.Instances.get() {
+		if inst == nil || inst.PTP == nil {
+			continue
+		}
+		inst.PTP.SetDuplicatePorts(ports)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+func TestDaemon_reload(t *testing.T) {
+	level, mtu, pmtu, queues, offload := ptp.MinLogLevel(), ptp.GlobalMTU, ptp.UsePMTU, ptp.TAPQueues, ptp.UseOffload
+	underlay, compression, multipath := ptp.UnderlayMTU, ptp.UseCompression, ptp.GetMultipath()
+	defer func() {
+		ptp.SetMinLogLevel(level)
+		ptp.GlobalMTU, ptp.UsePMTU, ptp.TAPQueues, ptp.UseOffload = mtu, pmtu, queues, offload
+		ptp.UnderlayMTU, ptp.UseCompression = underlay, compression
+		ptp.SetMultipath(multipath.String())
+		ptp.SetDuplicatePorts(nil)
+	}()
+
+	f, err := ioutil.TempFile("", "p2p-reload")
+	if err != nil {
+		t.Fatalf("Failed to create config: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.Close()
+
+	d := new(Daemon)
+	if _, err := d.reload(); err == nil {
+		t.Errorf("Daemon.reload() without settings didn't fail")
+	}
+
+	conf := new(ptp.Conf)
+	conf.SetDefaults()
+	conf.LogLevel = "info"
+	d.settings = &daemonSettings{configFile: f.Name(), conf: conf}
+	ptp.UsePMTU = false
+
+	ioutil.WriteFile(f.Name(), []byte("log_level: debug\npmtu: true\nmtu: 1400\ntap_queues: 4\noffload: true\nunderlay_mtu: 1280\ncompression: true\nmultipath: hash\nduplicate_ports: [5060]\napi:\n  socket: /run/p2p.sock\n"), 0600)
+	out, err := d.reload()
+	if err != nil {
+		t.Fatalf("Daemon.reload() failed: %s", err)
+	}
+	if len(out.Applied) != 4 || len(out.RestartRequired) != 6 || len(out.Failed) != 0 {
+		t.Errorf("Daemon.reload() = %+v", out)
+	}
+	if ptp.MinLogLevel() != ptp.Debug || !ptp.UsePMTU || ptp.GlobalMTU != 1400 || ptp.TAPQueues != 4 || !ptp.UseOffload || ptp.UnderlayMTU != 1280 || !ptp.UseCompression || ptp.GetMultipath() != ptp.MultipathHash {
+		t.Errorf("Daemon.reload() didn't apply changes")
+	}
+	cfg := d.settings.instanceConfig(ptp.Config{})
+	if cfg.MTU != 1400 || !cfg.PMTU || cfg.Queues != 4 || !cfg.Offload || cfg.UnderlayMTU != 1280 || !cfg.Compression || cfg.Multipath != ptp.MultipathHash || len(cfg.DuplicatePorts) != 1 {
+		t.Errorf("daemonSettings.instanceConfig() = %+v", cfg)
+	}
+	if cfg := d.settings.instanceConfig(ptp.Config{MTU: 1300}); cfg.MTU != 1300 {
+		t.Errorf("daemonSettings.instanceConfig() replaced MTU of instance with %d", cfg.MTU)
+	}
+
+	out, err = d.reload()
+	if err != nil || len(out.Applied)+len(out.RestartRequired)+len(out.Failed) != 0 {
+		t.Errorf("Daemon.reload() of unchanged config = %+v, %v", out, err)
+	}
+
+	ioutil.WriteFile(f.Name(), []byte("-"), 0600)
+	if _, err := d.reload(); err == nil {
+		t.Errorf("Daemon.reload() of broken config didn't fail")
+	}
+	if d.settings.conf.MTU != 1400 {
+		t.Errorf("Daemon.reload() replaced config with a broken one")
+	}
+}
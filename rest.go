@@ -2,15 +2,20 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 
 	ptp "github.com/subutai-io/p2p/lib"
+	"github.com/subutai-io/p2p/protocol/control"
 )
 
 var (
@@ -34,6 +39,7 @@ type request struct {
 	All        bool   `json:"all"`        // Used for show request
 	Bind       bool   `json:"bind"`       // Used for show request
 	MTU        bool   `json:"mtu"`        // Used for MTU show request
+	Format     string `json:"format"`     // Output format requested by client
 }
 
 type RESTResponse struct {
@@ -47,16 +53,67 @@ type ErrorOutput struct {
 	Code  int    `json:"code"`
 }
 
-func setupRESTHandlers(port int, d *Daemon) {
-	http.HandleFunc("/rest/v1/start", d.execRESTStart)
-	http.HandleFunc("/rest/v1/stop", d.execRESTStop)
-	http.HandleFunc("/rest/v1/show", d.execRESTShow)
-	http.HandleFunc("/rest/v1/status", d.execRESTStatus)
-	http.HandleFunc("/rest/v1/debug", d.execRESTDebug)
-	http.HandleFunc("/rest/v1/set", d.execRESTSet)
+func setupRESTHandlers(port int, d *Daemon, conf ptp.APIConf) {
+	auth, err := newAPIAuth(conf)
+	if err != nil {
+		ptp.Log(ptp.Error, "Failed to configure control API: %s", err)
+		os.Exit(98)
+	}
+	http.HandleFunc("/rest/v1/start", auth.require(RoleAdmin, d.execRESTStart))
+	http.HandleFunc("/rest/v1/stop", auth.require(RoleAdmin, d.execRESTStop))
+	http.HandleFunc("/rest/v1/show", auth.require(RoleRead, d.execRESTShow))
+	http.HandleFunc("/rest/v1/status", auth.require(RoleRead, d.execRESTStatus))
+	http.HandleFunc("/rest/v1/debug", auth.require(RoleRead, d.execRESTDebug))
+	http.HandleFunc("/rest/v1/set", auth.require(RoleAdmin, d.execRESTSet))
+	http.HandleFunc("/rest/v1/events", auth.require(RoleRead, d.execRESTEvents))
+	http.HandleFunc("/rest/v1/reload", auth.require(RoleAdmin, d.execRESTReload))
+	// Dry run is allowed for read role, handler checks role otherwise
+	http.HandleFunc("/rest/v1/apply", auth.require(RoleRead, d.execRESTApply))
+	http.HandleFunc(RESTv2Prefix+"/", auth.require(RoleRead, d.execRESTv2))
+	http.HandleFunc("/metrics", auth.require(RoleRead, d.execRESTMetrics))
+	http.HandleFunc("/"+control.Control_ServiceDesc.ServiceName+"/", auth.grpc(newGRPCServer(d)))
+
+	tlsConfig, err := apiTLSConfig(conf)
+	if err != nil {
+		ptp.Log(ptp.Error, "Failed to configure control API: %s", err)
+		os.Exit(98)
+	}
+
+	if conf.Socket != "" {
+		l, err := listenSocket(conf)
+		if err != nil {
+			ptp.Log(ptp.Error, "Failed to listen on control socket %s: %s", conf.Socket, err)
+			os.Exit(98)
+		}
+		ptp.Log(ptp.Info, "Control API is listening on %s", conf.Socket)
+		go func() {
+			server := &http.Server{ConnContext: auth.connContext, Protocols: apiProtocols()}
+			err := server.Serve(l)
+			if err != nil {
+				fmt.Printf("Failed to start HTTP listener: %s", err)
+				os.Exit(98)
+			}
+		}()
+	}
 
+	if conf.DisableTCP {
+		return
+	}
+	if !auth.enabled {
+		ptp.Log(ptp.Warning, "Control API on port %d doesn't require authentication", port)
+	}
 	go func() {
-		err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
+		server := &http.Server{
+			Addr:      fmt.Sprintf(":%d", port),
+			TLSConfig: tlsConfig,
+			Protocols: apiProtocols(),
+		}
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
 		if err != nil {
 			fmt.Printf("Failed to start HTTP listener: %s", err)
 			os.Exit(98)
@@ -64,18 +121,95 @@ func setupRESTHandlers(port int, d *Daemon) {
 	}()
 }
 
+// apiProtocols returns protocols served by control API listeners.
+// Unencrypted HTTP/2 is required by gRPC clients on the socket and on
+// TCP port without TLS. Needs Go 1.24 or newer
+func apiProtocols() *http.Protocols {
+	protocols := &http.Protocols{}
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return protocols
+}
+
+// Environment variables used by client commands to reach control API
+const (
+	EnvAPIToken  = "P2P_API_TOKEN"  // Bearer token
+	EnvAPISocket = "P2P_API_SOCKET" // Unix socket used instead of TCP port
+	EnvAPICA     = "P2P_API_CA"     // CA of daemon certificate. Enables HTTPS
+	EnvAPICert   = "P2P_API_CERT"   // Client certificate for mTLS
+	EnvAPIKey    = "P2P_API_KEY"    // Client private key for mTLS
+	EnvAPIGRPC   = "P2P_API_GRPC"   // Use gRPC control service when set
+)
+
+// clientTLSConfig returns TLS configuration of control API client or nil
+// when daemon certificate CA is not specified
+func clientTLSConfig() (*tls.Config, error) {
+	ca := os.Getenv(EnvAPICA)
+	if ca == "" {
+		return nil, nil
+	}
+	pem, err := ioutil.ReadFile(ca)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CA: %s", err)
+	}
+	config := &tls.Config{RootCAs: x509.NewCertPool()}
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates found in %s", ca)
+	}
+	if os.Getenv(EnvAPICert) != "" {
+		cert, err := tls.LoadX509KeyPair(os.Getenv(EnvAPICert), os.Getenv(EnvAPIKey))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// controlRequest creates a request to daemon control API and a client to
+// execute it. Transport and credentials are taken from environment
+func controlRequest(port int, method, path string, body io.Reader) (*http.Client, *http.Request, error) {
+	client := &http.Client{}
+	url := fmt.Sprintf("http://localhost:%d%s", port, path)
+	if socket := os.Getenv(EnvAPISocket); socket != "" {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+		url = "http://p2p" + path
+	} else if config, err := clientTLSConfig(); err != nil {
+		return nil, nil, err
+	} else if config != nil {
+		client.Transport = &http.Transport{TLSClientConfig: config}
+		url = fmt.Sprintf("https://localhost:%d%s", port, path)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token := os.Getenv(EnvAPIToken); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client, req, nil
+}
+
 func sendRequest(port int, command string, args *DaemonArgs) (*RESTResponse, error) {
+	// Interfaces are removed only through REST API
+	if useGRPC() && (command == "start" || command == "stop" && args.Hash != "") {
+		return sendGRPCRequest(port, command, args)
+	}
 	data, err := json.Marshal(args)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to marshal request: %s", err)
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/rest/v1/%s", port, command), bytes.NewBuffer(data))
+	client, req, err := controlRequest(port, "POST", "/rest/v1/"+command, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create request: %s", err)
 	}
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't execute command. Check if p2p daemon is running.")
@@ -98,19 +232,21 @@ func sendRequestRaw(port int, command string, r *request) ([]byte, error) {
 		return nil, errorFailedToMarshal
 	}
 
-	req, err := http.NewRequest("POST", fmt.Sprintf("http://localhost:%d/rest/v1/%s", port, command), bytes.NewBuffer(data))
+	client, req, err := controlRequest(port, "POST", "/rest/v1/"+command, bytes.NewBuffer(data))
 	if err != nil {
 		ptp.Log(ptp.Error, "%s: %s", errorFailedToCreatePOSTRequest, err)
 		return nil, errorFailedToCreatePOSTRequest
 	}
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		ptp.Log(ptp.Error, "%s. Check if p2p daemon is running", errorFailedToExecuteRequest)
 		return nil, errorFailedToExecuteRequest
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("Access denied: %s", resp.Status)
+	}
 	return ioutil.ReadAll(resp.Body)
 }
 
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	ptp "github.com/subutai-io/p2p/lib"
+)
+
+// RESTv2Prefix is a path prefix of all v2 endpoints
+const RESTv2Prefix = "/rest/v2"
+
+// Error codes returned in APIError body
+const (
+	ErrCodeBadRequest   = "bad_request"
+	ErrCodeUnauthorized = "unauthorized"
+	ErrCodeForbidden    = "forbidden"
+	ErrCodeNotFound     = "not_found"
+	ErrCodeNotAllowed   = "method_not_allowed"
+	ErrCodeConflict     = "conflict"
+	ErrCodeUnavailable  = "unavailable"
+	ErrCodeInternal     = "internal"
+)
+
+// APIError is a body of every unsuccessful v2 response
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// DaemonCode is a v1 state code (105-107) when daemon is not ready
+	DaemonCode int `json:"daemon_code,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func newAPIError(status int, code, format string, a ...interface{}) *APIError {
+	return &APIError{Status: status, Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// InstanceRequest is a body of instance creation request
+type InstanceRequest struct {
+	Hash    string `json:"hash"`
+	IP      string `json:"ip,omitempty"`
+	Mac     string `json:"mac,omitempty"`
+	Dev     string `json:"dev,omitempty"`
+	Keyfile string `json:"keyfile,omitempty"`
+	Key     string `json:"key,omitempty"`
+	TTL     string `json:"ttl,omitempty"`
+	Fwd     bool   `json:"fwd,omitempty"`
+	Port    int    `json:"port,omitempty"`
+}
+
+// KeyRequest is a body of key creation request
+type KeyRequest struct {
+	Key string `json:"key"`
+	TTL string `json:"ttl,omitempty"`
+}
+
+// KeyOutput describes a crypto key of an instance. Key material
+// itself is never returned
+type KeyOutput struct {
+	Until  string `json:"until"`
+	Active bool   `json:"active"`
+}
+
+// DaemonOutput describes daemon itself
+type DaemonOutput struct {
+	AppVersion string `json:"app_version"`
+	Build      string `json:"build"`
+	Uptime     int64  `json:"uptime"`
+	Goroutines int    `json:"goroutines"`
+	Ready      bool   `json:"ready"`
+	State      int    `json:"state"`
+	Message    string `json:"message,omitempty"`
+}
+
+// apiHandler processes a v2 request. Path parameters are passed in
+// params. Returned value is marshaled as a response body with route's
+// status, unless it's nil
+type apiHandler func(d *Daemon, r *http.Request, params map[string]string) (interface{}, *APIError)
+
+// apiRoute describes a single v2 endpoint. Routes are used both to
+// dispatch requests and to generate the OpenAPI document
+type apiRoute struct {
+	Method   string
+	Path     string // Relative to RESTv2Prefix, parameters are in braces
+	Tag      string
+	Summary  string
+	Request  interface{} // Request body type, if any
+	Response interface{} // Response body type, if any
+	Status   int
+	Errors   []int
+	Ready    bool // Route requires daemon to be connected to bootstrap
+	handler  apiHandler
+}
+
+// apiRoutes returns all v2 routes in documentation order
+func apiRoutes() []apiRoute {
+	return []apiRoute{
+		{
+			Method: http.MethodGet, Path: "/daemon", Tag: "daemon",
+			Summary:  "Daemon version and state",
+			Response: DaemonOutput{}, Status: http.StatusOK,
+			handler: (*Daemon).apiGetDaemon,
+		},
+		{
+			Method: http.MethodPost, Path: "/daemon/reload", Tag: "daemon",
+			Summary:  "Reload configuration file",
+			Response: ReloadOutput{}, Status: http.StatusOK,
+			Errors:  []int{http.StatusInternalServerError},
+			handler: (*Daemon).apiReload,
+		},
+		{
+			Method: http.MethodGet, Path: "/bootstrap", Tag: "daemon",
+			Summary:  "List connections to bootstrap nodes",
+			Response: []BootstrapOutput{}, Status: http.StatusOK,
+			Errors: []int{http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiListBootstrap,
+		},
+		{
+			Method: http.MethodGet, Path: "/instances", Tag: "instances",
+			Summary:  "List instances",
+			Response: []InstanceOutput{}, Status: http.StatusOK,
+			Errors: []int{http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiListInstances,
+		},
+		{
+			Method: http.MethodPost, Path: "/instances", Tag: "instances",
+			Summary: "Create new instance",
+			Request: InstanceRequest{}, Response: InstanceOutput{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusServiceUnavailable, http.StatusInternalServerError}, Ready: true,
+			handler: (*Daemon).apiCreateInstance,
+		},
+		{
+			Method: http.MethodGet, Path: "/instances/{hash}", Tag: "instances",
+			Summary:  "Get instance with its peers",
+			Response: InstanceOutput{}, Status: http.StatusOK,
+			Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiGetInstance,
+		},
+		{
+			Method: http.MethodDelete, Path: "/instances/{hash}", Tag: "instances",
+			Summary: "Stop instance",
+			Status:  http.StatusNoContent,
+			Errors:  []int{http.StatusNotFound, http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiDeleteInstance,
+		},
+		{
+			Method: http.MethodGet, Path: "/instances/{hash}/peers", Tag: "peers",
+			Summary:  "List peers of instance",
+			Response: []PeerOutput{}, Status: http.StatusOK,
+			Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiListPeers,
+		},
+		{
+			Method: http.MethodGet, Path: "/instances/{hash}/peers/{id}", Tag: "peers",
+			Summary:  "Get single peer",
+			Response: PeerOutput{}, Status: http.StatusOK,
+			Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiGetPeer,
+		},
+		{
+			Method: http.MethodGet, Path: "/instances/{hash}/keys", Tag: "keys",
+			Summary:  "List keys of instance",
+			Response: []KeyOutput{}, Status: http.StatusOK,
+			Errors: []int{http.StatusNotFound, http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiListKeys,
+		},
+		{
+			Method: http.MethodPost, Path: "/instances/{hash}/keys", Tag: "keys",
+			Summary: "Add key to instance",
+			Request: KeyRequest{}, Response: KeyOutput{}, Status: http.StatusCreated,
+			Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusServiceUnavailable}, Ready: true,
+			handler: (*Daemon).apiAddKey,
+		},
+		{
+			Method: http.MethodGet, Path: "/openapi.json", Tag: "daemon",
+			Summary: "OpenAPI document of this API",
+			Status:  http.StatusOK,
+			handler: (*Daemon).apiGetOpenAPI,
+		},
+	}
+}
+
+// matchPath compares request path with route pattern and returns path
+// parameters on success
+func matchPath(pattern, path string) (map[string]string, bool) {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	rs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(ps) != len(rs) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i := range ps {
+		if strings.HasPrefix(ps[i], "{") && strings.HasSuffix(ps[i], "}") {
+			if rs[i] == "" {
+				return nil, false
+			}
+			params[ps[i][1:len(ps[i])-1]] = rs[i]
+			continue
+		}
+		if ps[i] != rs[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// execRESTv2 dispatches v2 requests to the route handlers
+func (d *Daemon) execRESTv2(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, RESTv2Prefix)
+	pathFound := false
+	for _, route := range apiRoutes() {
+		params, ok := matchPath(route.Path, path)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if route.Method != r.Method {
+			continue
+		}
+		if route.Method != http.MethodGet && requestRole(r) < RoleAdmin {
+			writeAPIError(w, newAPIError(http.StatusForbidden, ErrCodeForbidden, "Role %s is not allowed to perform this request", requestRole(r)))
+			return
+		}
+		if route.Ready {
+			if apiErr := apiReady(); apiErr != nil {
+				writeAPIError(w, apiErr)
+				return
+			}
+		}
+		out, apiErr := route.handler(d, r, params)
+		if apiErr != nil {
+			writeAPIError(w, apiErr)
+			return
+		}
+		writeAPIResponse(w, route.Status, out)
+		return
+	}
+	if pathFound {
+		writeAPIError(w, newAPIError(http.StatusMethodNotAllowed, ErrCodeNotAllowed, "Method %s is not allowed for %s", r.Method, path))
+		return
+	}
+	writeAPIError(w, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Unknown endpoint %s", path))
+}
+
+// apiReady returns an error when daemon is not connected to bootstrap
+// nodes yet
+func apiReady() *APIError {
+	code, msg := daemonState()
+	if code == 0 {
+		return nil
+	}
+	apiErr := newAPIError(http.StatusServiceUnavailable, ErrCodeUnavailable, "%s", msg)
+	apiErr.DaemonCode = code
+	return apiErr
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, out interface{}) {
+	if out == nil {
+		w.WriteHeader(status)
+		return
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		ptp.Log(ptp.Error, "Failed to marshal response: %s", err)
+		writeAPIError(w, newAPIError(http.StatusInternalServerError, ErrCodeInternal, "Failed to marshal response"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func writeAPIError(w http.ResponseWriter, e *APIError) {
+	data, _ := json.Marshal(e)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	w.Write(data)
+}
+
+// readBody unmarshals JSON request body into v
+func readBody(r *http.Request, v interface{}) *APIError {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "Failed to read request body: %s", err)
+	}
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "Malformed request body: %s", err)
+	}
+	return nil
+}
+
+func (d *Daemon) apiInstance(hash string) (*P2PInstance, *APIError) {
+	inst := d.Instances.getInstance(hash)
+	if inst == nil || inst.PTP == nil {
+		return nil, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Instance %s was not found", hash)
+	}
+	return inst, nil
+}
+
+func (d *Daemon) apiGetDaemon(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	return newDaemonOutput(), nil
+}
+
+func newDaemonOutput() *DaemonOutput {
+	out := &DaemonOutput{
+		AppVersion: AppVersion,
+		Build:      BuildID,
+		Uptime:     int64(time.Since(StartTime).Seconds()),
+		Goroutines: runtime.NumGoroutine(),
+	}
+	out.State, out.Message = daemonState()
+	out.Ready = out.State == 0
+	return out
+}
+
+func (d *Daemon) apiReload(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	out, err := d.reload()
+	if err != nil {
+		return nil, newAPIError(http.StatusInternalServerError, ErrCodeInternal, "%s", err)
+	}
+	return out, nil
+}
+
+func (d *Daemon) apiListBootstrap(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	return newBootstrapOutput(), nil
+}
+
+func newBootstrapOutput() []BootstrapOutput {
+	out := []BootstrapOutput{}
+	for _, node := range bootstrap.getRouters() {
+		if node == nil {
+			continue
+		}
+		out = append(out, BootstrapOutput{
+			Addr:          node.router,
+			Connected:     node.running && node.handshaked,
+			Rx:            node.rx,
+			Tx:            node.tx,
+			Version:       node.version,
+			PacketVersion: node.packetVersion,
+		})
+	}
+	return out
+}
+
+func (d *Daemon) apiListInstances(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	return d.newInstancesOutput("", false), nil
+}
+
+func (d *Daemon) apiCreateInstance(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	req := new(InstanceRequest)
+	if err := readBody(r, req); err != nil {
+		return nil, err
+	}
+	out, apiErr := d.createInstance(req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return out, nil
+}
+
+// createInstance validates request and starts new instance. Used by
+// both REST v2 and gRPC
+func (d *Daemon) createInstance(req *InstanceRequest) (*InstanceOutput, *APIError) {
+	if req.Hash == "" {
+		return nil, newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "Hash cannot be empty")
+	}
+	if strings.Contains(req.Hash, "~") || strings.Contains(req.Hash, "/") {
+		return nil, newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "Hash cannot contain ~ or /")
+	}
+	if req.Mac != "" {
+		if _, err := net.ParseMAC(req.Mac); err != nil {
+			return nil, newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "Invalid MAC address: %s", err)
+		}
+	}
+	if d.Instances.getInstance(req.Hash) != nil {
+		return nil, newAPIError(http.StatusConflict, ErrCodeConflict, "Hash %s already in use", req.Hash)
+	}
+
+	ptp.Log(ptp.Debug, "Executing v2 create instance: %+v", req)
+	response := new(Response)
+	args := &RunArgs{
+		IP:      req.IP,
+		Mac:     req.Mac,
+		Dev:     req.Dev,
+		Hash:    req.Hash,
+		Keyfile: req.Keyfile,
+		Key:     req.Key,
+		TTL:     req.TTL,
+		Fwd:     req.Fwd,
+		Port:    req.Port,
+	}
+	err := d.run(args, response)
+	switch {
+	case response.ExitCode == 1:
+		return nil, newAPIError(http.StatusConflict, ErrCodeConflict, "%s", response.Output)
+	case response.ExitCode == 119:
+		return nil, newAPIError(http.StatusConflict, ErrCodeConflict, "Hash %s already in use", req.Hash)
+	case err != nil || response.ExitCode != 0:
+		return nil, newAPIError(http.StatusInternalServerError, ErrCodeInternal, "%s", strings.TrimSpace(response.Output))
+	}
+	d.saveInstance(args)
+	inst, apiErr := d.apiInstance(req.Hash)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	out := newInstanceOutput(inst, false)
+	return &out, nil
+}
+
+func (d *Daemon) apiGetInstance(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	inst, err := d.apiInstance(params["hash"])
+	if err != nil {
+		return nil, err
+	}
+	return newInstanceOutput(inst, true), nil
+}
+
+func (d *Daemon) apiDeleteInstance(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	return nil, d.deleteInstance(params["hash"])
+}
+
+// deleteInstance stops instance. Used by both REST v2 and gRPC
+func (d *Daemon) deleteInstance(hash string) *APIError {
+	if _, err := d.apiInstance(hash); err != nil {
+		return err
+	}
+	response := new(Response)
+	d.Stop(&DaemonArgs{Hash: hash}, response)
+	if response.ExitCode != 0 {
+		return newAPIError(http.StatusInternalServerError, ErrCodeInternal, "%s", response.Output)
+	}
+	return nil
+}
+
+func (d *Daemon) apiListPeers(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	out, err := d.listPeers(params["hash"])
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (d *Daemon) listPeers(hash string) ([]PeerOutput, *APIError) {
+	inst, err := d.apiInstance(hash)
+	if err != nil {
+		return nil, err
+	}
+	if inst.PTP.Swarm == nil {
+		return []PeerOutput{}, nil
+	}
+	return newPeersOutput(inst.PTP.Swarm.Get()), nil
+}
+
+func (d *Daemon) apiGetPeer(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	out, err := d.getPeer(params["hash"], params["id"])
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (d *Daemon) getPeer(hash, id string) (*PeerOutput, *APIError) {
+	inst, err := d.apiInstance(hash)
+	if err != nil {
+		return nil, err
+	}
+	if inst.PTP.Swarm != nil {
+		if peer := inst.PTP.Swarm.GetPeer(id); peer != nil {
+			out := newPeerOutput(peer)
+			return &out, nil
+		}
+	}
+	return nil, newAPIError(http.StatusNotFound, ErrCodeNotFound, "Peer %s was not found", id)
+}
+
+func newKeyOutput(key ptp.CryptoKey, active ptp.CryptoKey) KeyOutput {
+	return KeyOutput{
+		Until:  formatTime(key.Until),
+		Active: key.Until.Equal(active.Until) && string(key.Key) == string(active.Key),
+	}
+}
+
+func (d *Daemon) apiListKeys(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	out, err := d.listKeys(params["hash"])
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (d *Daemon) listKeys(hash string) ([]KeyOutput, *APIError) {
+	inst, err := d.apiInstance(hash)
+	if err != nil {
+		return nil, err
+	}
+	out := []KeyOutput{}
+	for _, key := range inst.PTP.Crypter.Keys {
+		out = append(out, newKeyOutput(key, inst.PTP.Crypter.ActiveKey))
+	}
+	return out, nil
+}
+
+func (d *Daemon) apiAddKey(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	if _, apiErr := d.apiInstance(params["hash"]); apiErr != nil {
+		return nil, apiErr
+	}
+	req := new(KeyRequest)
+	if err := readBody(r, req); err != nil {
+		return nil, err
+	}
+	out, apiErr := d.addKey(params["hash"], req)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return out, nil
+}
+
+// addKey validates request and adds key to instance. Used by both REST
+// v2 and gRPC
+func (d *Daemon) addKey(hash string, req *KeyRequest) (*KeyOutput, *APIError) {
+	inst, apiErr := d.apiInstance(hash)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if req.Key == "" {
+		return nil, newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "Key cannot be empty")
+	}
+	if req.TTL != "" {
+		if _, err := strconv.ParseInt(req.TTL, 10, 64); err != nil {
+			return nil, newAPIError(http.StatusBadRequest, ErrCodeBadRequest, "TTL must be a unix timestamp")
+		}
+	}
+	inst.PTP.AddKey(req.Key, req.TTL)
+	keys := inst.PTP.Crypter.Keys
+	out := newKeyOutput(keys[len(keys)-1], inst.PTP.Crypter.ActiveKey)
+	return &out, nil
+}
+
+func (d *Daemon) apiGetOpenAPI(r *http.Request, params map[string]string) (interface{}, *APIError) {
+	return openAPIDocument(apiRoutes()), nil
+}
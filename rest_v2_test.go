@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var updateOpenAPI = flag.Bool("update-openapi", false, "Regenerate rest/swagger_v2.yml")
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		path    string
+		want    map[string]string
+		ok      bool
+	}{
+		{"static", "/instances", "/instances", map[string]string{}, true},
+		{"trailing slash", "/instances", "/instances/", map[string]string{}, true},
+		{"param", "/instances/{hash}", "/instances/abc", map[string]string{"hash": "abc"}, true},
+		{"two params", "/instances/{hash}/peers/{id}", "/instances/abc/peers/p1", map[string]string{"hash": "abc", "id": "p1"}, true},
+		{"too short", "/instances/{hash}", "/instances", nil, false},
+		{"empty param", "/instances/{hash}/peers", "/instances//peers", nil, false},
+		{"mismatch", "/instances/{hash}/keys", "/instances/abc/peers", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchPath(tt.pattern, tt.path)
+			if ok != tt.ok {
+				t.Fatalf("matchPath() ok = %v, want %v", ok, tt.ok)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("matchPath() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("matchPath() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDaemon_execRESTv2(t *testing.T) {
+	ReadyToServe = false
+	d := new(Daemon)
+	d.Instances = new(InstanceList)
+	d.Instances.init()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		code   string
+	}{
+		{"daemon", http.MethodGet, "/rest/v2/daemon", http.StatusOK, ""},
+		{"not ready", http.MethodGet, "/rest/v2/instances", http.StatusServiceUnavailable, ErrCodeUnavailable},
+		{"method not allowed", http.MethodPut, "/rest/v2/instances", http.StatusMethodNotAllowed, ErrCodeNotAllowed},
+		{"unknown", http.MethodGet, "/rest/v2/unknown", http.StatusNotFound, ErrCodeNotFound},
+		{"openapi", http.MethodGet, "/rest/v2/openapi.json", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			d.execRESTv2(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.status {
+				t.Fatalf("execRESTv2() status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.code == "" {
+				return
+			}
+			apiErr := new(APIError)
+			if err := json.Unmarshal(w.Body.Bytes(), apiErr); err != nil {
+				t.Fatalf("execRESTv2() returned malformed error: %s", err)
+			}
+			if apiErr.Code != tt.code || apiErr.Status != tt.status {
+				t.Errorf("execRESTv2() error = %+v, want code %s", apiErr, tt.code)
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	doc := openAPIDocument(apiRoutes())
+	paths := doc["paths"].(map[string]interface{})
+	for _, route := range apiRoutes() {
+		item, exists := paths[route.Path].(map[string]interface{})
+		if !exists {
+			t.Fatalf("Path %s is not documented", route.Path)
+		}
+		if _, exists := item[map[string]string{
+			http.MethodGet:    "get",
+			http.MethodPost:   "post",
+			http.MethodDelete: "delete",
+		}[route.Method]]; !exists {
+			t.Errorf("Operation %s %s is not documented", route.Method, route.Path)
+		}
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatalf("Failed to marshal OpenAPI document: %s", err)
+	}
+	if *updateOpenAPI {
+		if err := ioutil.WriteFile("rest/swagger_v2.yml", data, 0644); err != nil {
+			t.Fatalf("Failed to write rest/swagger_v2.yml: %s", err)
+		}
+	}
+	existing, err := ioutil.ReadFile("rest/swagger_v2.yml")
+	if err != nil {
+		t.Fatalf("Failed to read rest/swagger_v2.yml: %s", err)
+	}
+	if !bytes.Equal(existing, data) {
+		t.Errorf("rest/swagger_v2.yml is out of date. Run `go test -run TestOpenAPIDocument -update-openapi`")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
 
@@ -18,12 +19,30 @@ import (
 // This class keeps a list of so-called "save entries", which is
 // a binding for instance information
 // Restore system saves entries in a YAML-formatted save file, specified
-// as an argument on daemon launch using `--save`
+// as an argument on daemon launch using `--save`. When master key is set
+// keys of instances are sealed before they're written to disk
 type Restore struct {
-	entries  []saveEntry
-	filepath string
-	lock     sync.RWMutex
-	active   bool
+	entries   []saveEntry
+	filepath  string
+	lock      sync.RWMutex
+	active    bool
+	masterKey []byte
+	locked    bool // save file has secrets we can't open and must not be overwritten
+}
+
+// SaveFileVersion is a version of save file schema written by this daemon.
+// Files of older versions are migrated on load. Adding optional fields
+// doesn't need a new version, since daemons ignore unknown fields
+const SaveFileVersion = 1
+
+// saveBackupSuffix is appended to save file name to get a name of
+// a file with previous generation
+const saveBackupSuffix = ".bak"
+
+// saveDocument is a top-level YAML binding of save file
+type saveDocument struct {
+	Version   int         `yaml:"version"`
+	Instances []saveEntry `yaml:"instances"`
 }
 
 // saveEntry is a YAML binding for data save file
@@ -34,7 +53,11 @@ type saveEntry struct {
 	Hash        string `yaml:"hash"`
 	Keyfile     string `yaml:"keyfile"`
 	Key         string `yaml:"key"`
+	SealedKey   string `yaml:"sealed_key,omitempty"`
 	TTL         string `yaml:"ttl"`
+	Fwd         bool   `yaml:"fwd,omitempty"`
+	Port        int    `yaml:"port,omitempty"`
+	MTU         int    `yaml:"mtu,omitempty"`
 	LastSuccess string `yaml:"last_success"`
 	Enabled     bool
 }
@@ -56,54 +79,183 @@ func (r *Restore) init(filepath string) error {
 	return nil
 }
 
-// save will write dump of entries into a save file
+// setMasterKey sets a key used to seal secrets in the save file
+func (r *Restore) setMasterKey(key []byte) {
+	r.lock.Lock()
+	r.masterKey = key
+	r.lock.Unlock()
+}
+
+// save will write dump of entries into a save file. File is replaced
+// atomically, so a crash during save won't leave it truncated. Previous
+// generation is kept in a backup file
 func (r *Restore) save() error {
 	if r.filepath == "" {
 		return nil
 	}
 	r.lock.Lock()
-	file, err := os.OpenFile(r.filepath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0700)
-	if err != nil {
-		r.lock.Unlock()
-		return err
+	defer r.lock.Unlock()
+	if r.locked {
+		return fmt.Errorf("Save file %s can't be loaded by this daemon. Refusing to overwrite it", r.filepath)
 	}
 	data, err := r.encode()
 	if err != nil {
-		r.lock.Unlock()
 		return err
 	}
 	ptp.Log(ptp.Info, "Saving instances")
-	_, err = file.Write(data)
+	return writeFileAtomic(r.filepath, data, 0600, r.filepath+saveBackupSuffix)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory
+// and renames it over the target. When backup is not empty, current
+// content of the target is preserved under this name
+func writeFileAtomic(path string, data []byte, perm os.FileMode, backup string) error {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
 	if err != nil {
-		r.lock.Unlock()
 		return err
 	}
-	r.lock.Unlock()
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), perm)
+	}
+	if err == nil && backup != "" {
+		err = backupFile(path, backup)
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(path))
 	return nil
 }
 
-// load will read save file and unmarshal saved entries
+// backupFile makes backup a copy of path. Hard link is used when possible
+func backupFile(path, backup string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	os.Remove(backup)
+	if os.Link(path, backup) == nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Failed to backup %s: %s", path, err)
+	}
+	return writeFileAtomic(backup, data, 0600, "")
+}
+
+// syncDir flushes directory entry after rename. Not every platform
+// supports it, so errors are ignored
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
+
+// load will read save file and unmarshal saved entries. Files of older
+// versions or with plain text keys are rewritten in current format. When
+// save file can't be loaded, previous generation is loaded from backup
 func (r *Restore) load() error {
 	if r.filepath == "" {
 		return nil
 	}
-	r.lock.Lock()
-	data, err := ioutil.ReadFile(r.filepath)
+	migrate, err := r.loadFile(r.filepath)
 	if err != nil {
-		r.lock.Unlock()
-		return err
+		backup := r.filepath + saveBackupSuffix
+		if _, serr := os.Stat(backup); r.locked || serr != nil {
+			return err
+		}
+		ptp.Log(ptp.Error, "Failed to load save file %s: %s. Loading previous generation from %s", r.filepath, err, backup)
+		if _, berr := r.loadFile(backup); berr != nil {
+			ptp.Log(ptp.Error, "Failed to load backup: %s", berr)
+			return err
+		}
+		// Keep broken file aside, so next save won't replace the backup with it
+		os.Rename(r.filepath, r.filepath+".broken")
+		migrate = true
+	}
+	if !migrate {
+		return nil
+	}
+	ptp.Log(ptp.Info, "Rewriting save file %s with version %d", r.filepath, SaveFileVersion)
+	err = r.save()
+	if err != nil {
+		ptp.Log(ptp.Error, "Failed to rewrite save file: %s", err)
 	}
+	return nil
+}
+
+// loadFile reads entries from a save file of any known format. It
+// reports whether file should be rewritten
+func (r *Restore) loadFile(path string) (bool, error) {
+	r.lock.Lock()
+	data, err := ioutil.ReadFile(path)
 	r.lock.Unlock()
+	if err != nil {
+		return false, err
+	}
 	data = bytes.Trim(data, "\x00") // TODO: add more security to this
 	if len(data) == 0 {
-		return nil
+		return false, nil
 	}
-	if string(data[0]) == "-" || string(data[0]) == "[" {
-		r.decode(data)
-		return nil
+	file, version, err := parseSaveDocument(data)
+	if err == errUnknownSaveFormat {
+		// TODO: This code is deprecated and must be removed in version 9
+		return true, r.decodeInstances(data)
+	}
+	if err != nil {
+		if version > SaveFileVersion {
+			// File was written by a newer daemon
+			r.lock.Lock()
+			r.locked = true
+			r.lock.Unlock()
+		}
+		return false, err
 	}
-	// TODO: This code is deprecated and must be removed in version 9
-	return r.decodeInstances(data)
+	migrate, err := r.decodeFile(file)
+	return migrate || version < SaveFileVersion, err
+}
+
+// decodeFile opens sealed keys of a save file. It reports whether file
+// has plain text keys that should be sealed
+func (r *Restore) decodeFile(file *saveDocument) (bool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	migrate := false
+	for i, e := range file.Instances {
+		if e.SealedKey == "" {
+			if e.Key != "" && r.masterKey != nil {
+				migrate = true
+			}
+			continue
+		}
+		key, err := openSecret(r.masterKey, e.SealedKey)
+		if err != nil {
+			r.locked = true
+			return false, fmt.Errorf("Failed to open key of %s: %s", e.Hash, err)
+		}
+		file.Instances[i].Key = key
+		file.Instances[i].SealedKey = ""
+	}
+	r.entries = file.Instances
+	return migrate, nil
 }
 
 // addInstance will create new save file entry from instance
@@ -117,6 +269,9 @@ func (r *Restore) addInstance(inst *P2PInstance) error {
 		Keyfile:     inst.Args.Keyfile,
 		Key:         inst.Args.Key,
 		TTL:         inst.Args.TTL,
+		Fwd:         inst.Args.Fwd,
+		Port:        inst.Args.Port,
+		MTU:         inst.Args.MTU,
 		LastSuccess: string(ls),
 		Enabled:     true,
 	})
@@ -182,37 +337,31 @@ func (r *Restore) disableStaleInstances(inst *P2PInstance) error {
 	return nil
 }
 
-// encode will generate YAML
+// encode will generate YAML of enabled entries. Keys are sealed if
+// master key is set. Version is written even when there are no entries
 func (r *Restore) encode() ([]byte, error) {
-	if len(r.entries) == 0 {
-		return nil, nil
-	}
-	var data []saveEntry
+	file := saveDocument{Version: SaveFileVersion, Instances: []saveEntry{}}
 	for _, e := range r.entries {
-		if e.Enabled {
-			data = append(data, e)
+		if !e.Enabled {
+			continue
 		}
+		if e.Key != "" && r.masterKey != nil {
+			sealed, err := sealSecret(r.masterKey, e.Key)
+			if err != nil {
+				return nil, fmt.Errorf("Failed to seal key of %s: %s", e.Hash, err)
+			}
+			e.Key = ""
+			e.SealedKey = sealed
+		}
+		file.Instances = append(file.Instances, e)
 	}
-	output, err := yaml.Marshal(data)
+	output, err := yaml.Marshal(file)
 	if err != nil {
 		return nil, err
 	}
 	return output, nil
 }
 
-// decode will accept YAML and generate a slice of RunArgs
-func (r *Restore) decode(data []byte) error {
-	var saved []saveEntry
-	err := yaml.Unmarshal(data, &saved)
-	if err != nil {
-		return err
-	}
-	r.lock.Lock()
-	r.entries = saved
-	r.lock.Unlock()
-	return nil
-}
-
 // decodeInstances is an obsolet variant of instances unmarshal
 // TODO: Remove in version 10
 func (r *Restore) decodeInstances(data []byte) error {
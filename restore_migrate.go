@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	ptp "github.com/subutai-io/p2p/lib"
+	yaml "gopkg.in/yaml.v2"
+)
+
+var errUnknownSaveFormat = errors.New("Unknown save file format")
+
+// saveMigration upgrades raw save document by a single version
+type saveMigration func(doc map[interface{}]interface{}) error
+
+// saveMigrations are indexed by a version they upgrade from. Add a
+// migration here every time SaveFileVersion is bumped
+var saveMigrations = map[int]saveMigration{
+	0: migrateSaveV0,
+}
+
+// parseSaveDocument parses YAML save file of any version and migrates it
+// to SaveFileVersion. It returns version of the file as it was on disk
+func parseSaveDocument(data []byte) (*saveDocument, int, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, 0, errUnknownSaveFormat
+	}
+	doc := map[interface{}]interface{}{}
+	switch v := raw.(type) {
+	case []interface{}:
+		// Version 0 was a plain list of entries
+		doc["instances"] = v
+	case map[interface{}]interface{}:
+		if _, ok := v["version"]; !ok {
+			return nil, 0, fmt.Errorf("Save file has no version")
+		}
+		doc = v
+	default:
+		return nil, 0, errUnknownSaveFormat
+	}
+	version, err := migrateSaveDocument(doc)
+	if err != nil {
+		return nil, version, err
+	}
+	data, err = yaml.Marshal(doc)
+	if err != nil {
+		return nil, version, err
+	}
+	file := new(saveDocument)
+	err = yaml.Unmarshal(data, file)
+	if err != nil {
+		return nil, version, fmt.Errorf("Failed to parse save file: %s", err)
+	}
+	return file, version, nil
+}
+
+// migrateSaveDocument applies migrations to raw document one by one
+func migrateSaveDocument(doc map[interface{}]interface{}) (int, error) {
+	version := 0
+	if v, ok := doc["version"]; ok {
+		n, ok := v.(int)
+		if !ok || n < 1 {
+			return 0, fmt.Errorf("Bad save file version: %v", v)
+		}
+		version = n
+	}
+	if version > SaveFileVersion {
+		return version, fmt.Errorf("Save file version %d is newer than supported %d", version, SaveFileVersion)
+	}
+	for v := version; v < SaveFileVersion; v++ {
+		migrate, ok := saveMigrations[v]
+		if !ok {
+			return version, fmt.Errorf("No migration of save file from version %d", v)
+		}
+		if err := migrate(doc); err != nil {
+			return version, fmt.Errorf("Failed to migrate save file from version %d: %s", v, err)
+		}
+		doc["version"] = v + 1
+		ptp.Log(ptp.Info, "Migrated save file from version %d to %d", v, v+1)
+	}
+	return version, nil
+}
+
+// saveDocumentEntries returns instances of raw document
+func saveDocumentEntries(doc map[interface{}]interface{}) ([]interface{}, error) {
+	if doc["instances"] == nil {
+		return nil, nil
+	}
+	list, ok := doc["instances"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("instances is not a list")
+	}
+	return list, nil
+}
+
+// migrateSaveV0 drops malformed entries of unversioned list. Such entries
+// were silently ignored before
+func migrateSaveV0(doc map[interface{}]interface{}) error {
+	list, err := saveDocumentEntries(doc)
+	if err != nil {
+		return err
+	}
+	valid := []interface{}{}
+	for _, e := range list {
+		if _, ok := e.(map[interface{}]interface{}); !ok {
+			ptp.Log(ptp.Warning, "Dropping malformed save file entry: %v", e)
+			continue
+		}
+		valid = append(valid, e)
+	}
+	doc["instances"] = valid
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseSaveDocument(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantVersion int
+		wantEntries int
+		wantErr     error
+		wantAnyErr  bool
+	}{
+		{"version 0", "- hash: a\n  enabled: true\n- hash: b\n", 0, 2, nil, false},
+		{"version 0 malformed entry", "- empty\n- hash: a\n", 0, 1, nil, false},
+		{"version 1", "version: 1\ninstances:\n- hash: a\n  key: k\n", 1, 1, nil, false},
+		{"version 1 with options", "version: 1\ninstances:\n- hash: a\n  port: 5000\n  fwd: true\n", 1, 1, nil, false},
+		{"empty instances", "version: 1\n", 1, 0, nil, false},
+		{"newer version", "version: 2\ninstances: []\n", 2, 0, nil, true},
+		{"bad version", "version: x\ninstances: []\n", 0, 0, nil, true},
+		{"no version", "instances: []\n", 0, 0, nil, true},
+		{"bad instances", "version: 1\ninstances: a\n", 1, 0, nil, true},
+		{"legacy format", "10.0.0.1~~p2p1~hash~~~key~1540794362~0~0", 0, 0, errUnknownSaveFormat, true},
+		{"not yaml", "/\\", 0, 0, errUnknownSaveFormat, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, version, err := parseSaveDocument([]byte(tt.data))
+			if (err != nil) != tt.wantAnyErr || (tt.wantErr != nil && err != tt.wantErr) {
+				t.Fatalf("parseSaveDocument() error = %v, wantErr %v", err, tt.wantAnyErr)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("parseSaveDocument() version = %d, want %d", version, tt.wantVersion)
+			}
+			if err != nil {
+				return
+			}
+			if got.Version != SaveFileVersion || len(got.Instances) != tt.wantEntries {
+				t.Errorf("parseSaveDocument() = %+v", got)
+			}
+		})
+	}
+}
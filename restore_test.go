@@ -2,8 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -327,7 +330,9 @@ func TestRestore_encode(t *testing.T) {
 		active   bool
 	}
 
-	ee1 := `- ip: ""
+	ee1 := `version: 1
+instances:
+- ip: ""
   mac: ""
   dev: ""
   hash: hash
@@ -344,8 +349,8 @@ func TestRestore_encode(t *testing.T) {
 		want    []byte
 		wantErr bool
 	}{
-		{"No entries", fields{}, nil, false},
-		{"Single Disabled Entry", fields{entries: []saveEntry{{Hash: "hash"}}}, []byte{91, 93, 10}, false},
+		{"No entries", fields{}, []byte("version: 1\ninstances: []\n"), false},
+		{"Single Disabled Entry", fields{entries: []saveEntry{{Hash: "hash"}}}, []byte("version: 1\ninstances: []\n"), false},
 		{"Single Enabled Entry", fields{entries: []saveEntry{{Hash: "hash", Enabled: true}}}, []byte(ee1), false},
 	}
 	for _, tt := range tests {
@@ -368,40 +373,6 @@ func TestRestore_encode(t *testing.T) {
 	}
 }
 
-func TestRestore_decode(t *testing.T) {
-	type fields struct {
-		entries  []saveEntry
-		filepath string
-		lock     sync.RWMutex
-		active   bool
-	}
-	type args struct {
-		data []byte
-	}
-	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		wantErr bool
-	}{
-		{"Failed case", fields{}, args{[]byte("/\\")}, true},
-		{"Passing case", fields{}, args{[]byte("-")}, false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			r := &Restore{
-				entries:  tt.fields.entries,
-				filepath: tt.fields.filepath,
-				lock:     tt.fields.lock,
-				active:   tt.fields.active,
-			}
-			if err := r.decode(tt.args.data); (err != nil) != tt.wantErr {
-				t.Errorf("Restore.decode() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
 func TestRestore_decodeInstances(t *testing.T) {
 	type fields struct {
 		entries  []saveEntry
@@ -493,3 +464,93 @@ func TestRestore_isActive(t *testing.T) {
 		})
 	}
 }
+
+func TestRestore_sealedKeys(t *testing.T) {
+	f, err := ioutil.TempFile("", "p2p-save")
+	if err != nil {
+		t.Fatalf("Failed to create save file: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.Write([]byte("- hash: swarm\n  key: secret\n  enabled: true\n"))
+	f.Close()
+
+	key, _ := deriveMasterKey("master-key-of-at-least-32-characters")
+	r := &Restore{filepath: f.Name(), masterKey: key}
+	if err := r.load(); err != nil {
+		t.Fatalf("Restore.load() of plain text file failed: %s", err)
+	}
+	data, _ := ioutil.ReadFile(f.Name())
+	if !strings.HasPrefix(string(data), "version: 1\n") || strings.Contains(string(data), "secret") {
+		t.Fatalf("Restore.load() didn't migrate save file: %s", data)
+	}
+
+	r = &Restore{filepath: f.Name(), masterKey: key}
+	if err := r.load(); err != nil {
+		t.Fatalf("Restore.load() of sealed file failed: %s", err)
+	}
+	if e := r.get(); len(e) != 1 || e[0].Key != "secret" || e[0].SealedKey != "" {
+		t.Errorf("Restore.load() = %+v", e)
+	}
+
+	other, _ := deriveMasterKey("other-key-of-at-least-32-characters")
+	for _, k := range [][]byte{nil, other} {
+		r = &Restore{filepath: f.Name(), masterKey: k}
+		if err := r.load(); err == nil {
+			t.Errorf("Restore.load() with master key %v didn't fail", k)
+		}
+		if err := r.save(); err == nil {
+			t.Errorf("Restore.save() overwrote file that couldn't be opened")
+		}
+	}
+	after, _ := ioutil.ReadFile(f.Name())
+	if string(after) != string(data) {
+		t.Errorf("Save file was modified")
+	}
+}
+
+func TestRestore_backup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-save")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "save.yaml")
+
+	r := &Restore{filepath: path, entries: []saveEntry{{Hash: "first", Port: 5000, Fwd: true, MTU: 1400, Enabled: true}}}
+	if err := r.save(); err != nil {
+		t.Fatalf("Restore.save() failed: %s", err)
+	}
+	if _, err := os.Stat(path + saveBackupSuffix); !os.IsNotExist(err) {
+		t.Errorf("Restore.save() created backup of missing file")
+	}
+	r.entries = append(r.entries, saveEntry{Hash: "second", Enabled: true})
+	if err := r.save(); err != nil {
+		t.Fatalf("Restore.save() failed: %s", err)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("Restore.save() left %d files in directory", len(files))
+	}
+
+	ioutil.WriteFile(path, []byte("version: 1\ninstances: broken\n"), 0600)
+	r = &Restore{filepath: path}
+	if err := r.load(); err != nil {
+		t.Fatalf("Restore.load() didn't fall back to backup: %s", err)
+	}
+	e := r.get()
+	if len(e) != 1 || e[0].Hash != "first" || e[0].Port != 5000 || !e[0].Fwd || e[0].MTU != 1400 {
+		t.Errorf("Restore.load() from backup = %+v", e)
+	}
+	if _, err := os.Stat(path + ".broken"); err != nil {
+		t.Errorf("Broken save file wasn't kept: %s", err)
+	}
+
+	ioutil.WriteFile(path, []byte("version: 3\ninstances: []\n"), 0600)
+	r = &Restore{filepath: path}
+	if err := r.load(); err == nil {
+		t.Errorf("Restore.load() of newer version didn't fail")
+	}
+	if err := r.save(); err == nil {
+		t.Errorf("Restore.save() overwrote file of newer version")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sources of daemon master key
+const (
+	// MasterKeyEnv is an environment variable holding master key itself
+	MasterKeyEnv = "P2P_MASTER_KEY"
+	// MasterKeyCredential is a name of systemd credential with master key.
+	// Credentials are read from $CREDENTIALS_DIRECTORY
+	MasterKeyCredential = "p2p-master-key"
+)
+
+const sealedPrefix = "v1:"
+
+// MinMasterKeyLength is a minimal length of master key. Key is hashed
+// without salt or stretching, so it must be random, not a passphrase.
+// `openssl rand -base64 32` produces a suitable key
+const MinMasterKeyLength = 32
+
+// loadMasterKey returns a key used to seal secrets in the save file and
+// a name of its source. Key file from configuration is checked first,
+// then systemd credential and then environment. Nil key is returned when
+// master key is not configured. Environment variable is removed, so
+// hooks and other child processes don't inherit the key
+func loadMasterKey(keyfile string) ([]byte, string, error) {
+	envKey, envSet := os.LookupEnv(MasterKeyEnv)
+	os.Unsetenv(MasterKeyEnv)
+	if keyfile == "" {
+		if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+			path := filepath.Join(dir, MasterKeyCredential)
+			if _, err := os.Stat(path); err == nil {
+				keyfile = path
+			}
+		}
+	}
+	if keyfile != "" {
+		data, err := ioutil.ReadFile(keyfile)
+		if err != nil {
+			return nil, "", fmt.Errorf("Failed to read master key: %s", err)
+		}
+		key, err := deriveMasterKey(string(data))
+		return key, keyfile, err
+	}
+	if envSet {
+		key, err := deriveMasterKey(envKey)
+		return key, MasterKeyEnv, err
+	}
+	return nil, "", nil
+}
+
+// deriveMasterKey turns random key material into AES-256 key. Short
+// material is rejected, because a single hash doesn't protect weak keys
+// from brute force
+func deriveMasterKey(material string) ([]byte, error) {
+	material = strings.TrimSpace(material)
+	if material == "" {
+		return nil, fmt.Errorf("Master key is empty")
+	}
+	if len(material) < MinMasterKeyLength {
+		return nil, fmt.Errorf("Master key is too short: %d characters, at least %d required", len(material), MinMasterKeyLength)
+	}
+	sum := sha256.Sum256([]byte(material))
+	return sum[:], nil
+}
+
+// sealSecret encrypts value with AES-GCM. Result is a printable string
+func sealSecret(key []byte, value string) (string, error) {
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(value), nil)
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openSecret decrypts value produced by sealSecret
+func openSecret(key []byte, value string) (string, error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return "", fmt.Errorf("Unknown format of sealed secret")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("Malformed sealed secret: %s", err)
+	}
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", fmt.Errorf("Sealed secret is too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("Failed to open sealed secret: wrong master key or corrupted data")
+	}
+	return string(plain), nil
+}
+
+func newSecretCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("Master key is not configured")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
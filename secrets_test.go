@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealSecret(t *testing.T) {
+	key, _ := deriveMasterKey("master-key-of-at-least-32-characters")
+	other, _ := deriveMasterKey("other-key-of-at-least-32-characters")
+
+	sealed, err := sealSecret(key, "secret")
+	if err != nil {
+		t.Fatalf("sealSecret() failed: %s", err)
+	}
+	again, _ := sealSecret(key, "secret")
+	if sealed == again {
+		t.Errorf("sealSecret() produced same output twice")
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"valid", key, sealed, "secret", false},
+		{"wrong key", other, sealed, "", true},
+		{"no key", nil, sealed, "", true},
+		{"unknown format", key, "secret", "", true},
+		{"bad encoding", key, sealedPrefix + "%%%", "", true},
+		{"too short", key, sealedPrefix + "AAAA", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := openSecret(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("openSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("openSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMasterKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "p2p-credentials")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	keyfile := filepath.Join(dir, "keyfile")
+	ioutil.WriteFile(keyfile, []byte("from-file-0123456789abcdef0123456789\n"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, MasterKeyCredential), []byte("from-credential-0123456789abcdef0123456789"), 0600)
+	empty := filepath.Join(dir, "empty")
+	ioutil.WriteFile(empty, []byte("\n"), 0600)
+	short := filepath.Join(dir, "short")
+	ioutil.WriteFile(short, []byte("passphrase\n"), 0600)
+	fromEnv := "from-env-0123456789abcdef0123456789"
+
+	defer os.Unsetenv("CREDENTIALS_DIRECTORY")
+	defer os.Unsetenv(MasterKeyEnv)
+
+	tests := []struct {
+		name        string
+		keyfile     string
+		credentials string
+		env         string
+		want        string
+		wantErr     bool
+	}{
+		{"not configured", "", "", "", "", false},
+		{"environment", "", "", fromEnv, fromEnv, false},
+		{"credential", "", dir, fromEnv, "from-credential-0123456789abcdef0123456789", false},
+		{"keyfile", keyfile, dir, fromEnv, "from-file-0123456789abcdef0123456789", false},
+		{"missing keyfile", filepath.Join(dir, "missing"), "", "", "", true},
+		{"empty keyfile", empty, "", "", "", true},
+		{"short keyfile", short, "", "", "", true},
+		{"short environment", "", "", "passphrase", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("CREDENTIALS_DIRECTORY", tt.credentials)
+			if tt.env != "" {
+				os.Setenv(MasterKeyEnv, tt.env)
+			} else {
+				os.Unsetenv(MasterKeyEnv)
+			}
+			got, _, err := loadMasterKey(tt.keyfile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMasterKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var want []byte
+			if tt.want != "" {
+				want, _ = deriveMasterKey(tt.want)
+			}
+			if string(got) != string(want) {
+				t.Errorf("loadMasterKey() returned key of %q", tt.want)
+			}
+			if _, exists := os.LookupEnv(MasterKeyEnv); exists {
+				t.Errorf("loadMasterKey() left %s in environment", MasterKeyEnv)
+			}
+		})
+	}
+}
@@ -11,13 +11,17 @@ import (
 )
 
 // Set modifies different options of P2P daemon
-func CommandSet(rpcPort int, log, hash, keyfile, key, ttl, ip string) {
-	out, err := sendRequest(rpcPort, "set", &DaemonArgs{Log: log, Keyfile: keyfile, Key: key, TTL: ttl, IP: ip, Hash: hash})
+func CommandSet(rpcPort int, log, hash, keyfile, key, ttl, ip string, resetTraffic bool, format string) {
+	validateFormat(format)
+	out, err := sendRequest(rpcPort, "set", &DaemonArgs{Log: log, Keyfile: keyfile, Key: key, TTL: ttl, IP: ip, Hash: hash, ResetTraffic: resetTraffic})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
+	if isStructured(format) {
+		printMessage(format, out)
+	}
 	fmt.Println(out.Message)
 	os.Exit(out.Code)
 }
@@ -50,6 +54,8 @@ func (d *Daemon) execRESTSet(w http.ResponseWriter, r *http.Request) {
 			Name:  "log",
 			Value: args.Log,
 		}, response)
+	} else if args.ResetTraffic {
+		d.resetTraffic(args.Hash, response)
 	} else if args.IP != "" && args.Hash != "" {
 		// User modifying IP of the hash
 		ptp.Log(ptp.Info, "Request IP change for %s: %s", args.Hash, args.IP)
@@ -70,6 +76,29 @@ func (d *Daemon) execRESTSet(w http.ResponseWriter, r *http.Request) {
 	w.Write(resp)
 }
 
+// resetTraffic resets traffic counters of every peer of the instance or
+// of all instances when hash is empty
+func (d *Daemon) resetTraffic(hash string, resp *Response) error {
+	instances := d.Instances.get()
+	if hash != "" {
+		inst := d.Instances.getInstance(hash)
+		if inst == nil {
+			resp.ExitCode = 4
+			resp.Output = "Instance " + hash + " wasn't found"
+			return fmt.Errorf("Instance %s not found", hash)
+		}
+		instances = map[string]*P2PInstance{hash: inst}
+	}
+	for _, inst := range instances {
+		for _, peer := range inst.PTP.Swarm.Get() {
+			peer.Traffic.Reset()
+		}
+	}
+	resp.ExitCode = 0
+	resp.Output = "Traffic counters were reset"
+	return nil
+}
+
 // setIP will change IP of specified hash
 func (d *Daemon) setIP(args *NameValueArg, resp *Response) error {
 
@@ -114,13 +143,12 @@ func (d *Daemon) SetLog(args *NameValueArg, resp *Response) error {
 	ptp.Log(ptp.Info, "Setting option %s to %s", args.Name, args.Value)
 	resp.ExitCode = 0
 	if args.Name == "log" {
-		resp.Output = "Logging level has switched to " + args.Value + " level"
-		err := ptp.SetMinLogLevelString(args.Value)
+		err := ptp.SetLogLevels(args.Value)
 		if err == nil {
-			resp.Output = "Logging level has switched to " + args.Value + " level"
+			resp.Output = "Log levels have switched to " + ptp.LogLevels()
 		} else {
 			resp.ExitCode = 1
-			resp.Output = "Unknown log level was specified. Supported log levels is:\n"
+			resp.Output = err.Error() + ". Supported log levels is:\n"
 			resp.Output = resp.Output + "TRACE\n"
 			resp.Output = resp.Output + "DEBUG\n"
 			resp.Output = resp.Output + "INFO\n"
@@ -149,10 +177,7 @@ func (p *Daemon) AddKey(args *RunArgs, resp *Response) error {
 	}
 	if resp.ExitCode == 0 {
 		resp.Output = "New key added"
-		var newKey ptp.CryptoKey
-
-		newKey = inst.PTP.Crypter.EnrichKeyValues(newKey, args.Key, args.TTL)
-		inst.PTP.Crypter.Keys = append(inst.PTP.Crypter.Keys, newKey)
+		inst.PTP.AddKey(args.Key, args.TTL)
 		p.Instances.update(args.Hash, inst)
 	}
 	return nil
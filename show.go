@@ -24,8 +24,9 @@ type ShowOutput struct {
 }
 
 // Show outputs information about P2P instances and interfaces
-func CommandShow(queryPort int, hash, ip string, interfaces, all, bind, mtu bool) {
-	req := &request{}
+func CommandShow(queryPort int, hash, ip string, interfaces, all, bind, mtu bool, format string) {
+	validateFormat(format)
+	req := &request{Format: format}
 	if hash != "" {
 		req.Hash = hash
 	} else {
@@ -49,6 +50,17 @@ func CommandShow(queryPort int, hash, ip string, interfaces, all, bind, mtu bool
 		}
 		os.Exit(1)
 	}
+	if isStructured(format) {
+		response := new(ShowResponse)
+		err = json.Unmarshal(out, response)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to unmarshal JSON. Error %s\n", err)
+			os.Exit(99)
+		}
+		printStructured(format, response)
+		os.Exit(response.Code)
+	}
+
 	show := []ShowOutput{}
 	err = json.Unmarshal(out, &show)
 	if err != nil {
@@ -122,14 +134,24 @@ func (d *Daemon) execRESTShow(w http.ResponseWriter, r *http.Request) {
 	if handleMarshalError(err, w) != nil {
 		return
 	}
-	output, err := d.Show(&request{
+	req := &request{
 		Hash:       args.Hash,
 		IP:         args.IP,
 		Interfaces: args.Interfaces,
 		Bind:       args.Bind,
 		MTU:        args.MTU,
 		All:        args.All,
-	})
+	}
+	if isStructured(args.Format) {
+		output, err := json.Marshal(d.showStructured(req))
+		if err != nil {
+			ptp.Log(ptp.Error, "Failed to marshal show response: %s", err)
+			return
+		}
+		w.Write(output)
+		return
+	}
+	output, err := d.Show(req)
 	if err != nil {
 		ptp.Log(ptp.Error, "Internal error: %s", err)
 		return
@@ -284,6 +306,61 @@ func (d *Daemon) showInstances() ([]byte, error) {
 }
 
 func (d *Daemon) showMTU() ([]byte, error) {
-	ptp.Log(ptp.Trace, "Retrieving MTU value: %d", ptp.GlobalMTU)
-	return d.showOutput([]ShowOutput{{MTU: fmt.Sprintf("%d", ptp.GlobalMTU)}})
+	mtu := d.settings.instanceConfig(ptp.Config{}).MTU
+	ptp.Log(ptp.Trace, "Retrieving MTU value: %d", mtu)
+	return d.showOutput([]ShowOutput{{MTU: fmt.Sprintf("%d", mtu)}})
+}
+
+// showStructured returns versioned response for the show request
+func (d *Daemon) showStructured(args *request) *ShowResponse {
+	response := &ShowResponse{Version: OutputVersion}
+	response.Code, response.Error = daemonState()
+	if response.Code != 0 {
+		return response
+	}
+
+	if args.Hash != "" {
+		inst := d.Instances.getInstance(args.Hash)
+		if inst == nil {
+			response.Code = 15
+			response.Error = "Specified environment was not found"
+			return response
+		}
+		if args.IP == "" {
+			response.Peers = newPeersOutput(inst.PTP.Swarm.Get())
+			return response
+		}
+		for _, peer := range inst.PTP.Swarm.Get() {
+			if peer.PeerLocalIP.String() == args.IP && peer.State == ptp.PeerStateConnected {
+				response.Text = "Integrated with " + args.IP
+				return response
+			}
+		}
+		response.Code = 12
+		response.Error = "Not yet integrated with " + args.IP
+		return response
+	}
+	if args.Interfaces {
+		response.Interfaces = []InterfaceOutput{}
+		if args.All {
+			for _, inf := range InterfaceNames {
+				response.Interfaces = append(response.Interfaces, InterfaceOutput{Name: inf})
+			}
+			return response
+		}
+		for _, inst := range d.newInstancesOutput("", false) {
+			iface := InterfaceOutput{Name: inst.Interface}
+			if args.Bind {
+				iface.Hash = inst.Hash
+			}
+			response.Interfaces = append(response.Interfaces, iface)
+		}
+		return response
+	}
+	if args.MTU {
+		response.MTU = d.settings.instanceConfig(ptp.Config{}).MTU
+		return response
+	}
+	response.Instances = d.newInstancesOutput("", false)
+	return response
 }
@@ -13,7 +13,8 @@ import (
 )
 
 // CommandStart will create new P2P instance
-func CommandStart(restPort int, ip, hash, mac, dev, keyfile, key, ttl string, fwd bool, port int) {
+func CommandStart(restPort int, ip, hash, mac, dev, keyfile, key, ttl string, fwd bool, port int, format string) {
+	validateFormat(format)
 	args := &DaemonArgs{}
 	args.IP = ip
 	if hash == "" {
@@ -46,12 +47,7 @@ func CommandStart(restPort int, ip, hash, mac, dev, keyfile, key, ttl string, fw
 		os.Exit(1)
 	}
 
-	if out.Code > 0 {
-		fmt.Fprintln(os.Stderr, out.Message)
-	} else {
-		fmt.Println(out.Message)
-	}
-	os.Exit(out.Code)
+	printMessage(format, out)
 }
 
 func (d *Daemon) execRESTStart(w http.ResponseWriter, r *http.Request) {
@@ -78,7 +74,7 @@ func (d *Daemon) execRESTStart(w http.ResponseWriter, r *http.Request) {
 
 	ptp.Log(ptp.Debug, "Executing start command: %+v", args)
 	response := new(Response)
-	err = d.run(&RunArgs{
+	runArgs := &RunArgs{
 		IP:      args.IP,
 		Mac:     args.Mac,
 		Dev:     args.Dev,
@@ -89,12 +85,22 @@ func (d *Daemon) execRESTStart(w http.ResponseWriter, r *http.Request) {
 		TTL:     args.TTL,
 		Fwd:     args.Fwd,
 		Port:    args.Port,
-	}, response)
+	}
+	err = d.run(runArgs, response)
 
-	ls, _ := time.Unix(0, 0).MarshalText()
+	d.saveInstance(runArgs)
+	resp, err := getResponse(response.ExitCode, response.Output)
+	if err != nil {
+		ptp.Log(ptp.Error, "Internal error: %s", err)
+		return
+	}
+	w.Write(resp)
+}
 
-	// We add new save entry. If save entry already exists with
-	// hash specified, we will just update it's last success timestamp
+// saveInstance adds new save entry. If save entry already exists with
+// hash specified, it will just update it's last success timestamp
+func (d *Daemon) saveInstance(args *RunArgs) {
+	ls, _ := time.Unix(0, 0).MarshalText()
 	if d.Restore.addEntry(saveEntry{
 		IP:          args.IP,
 		Mac:         args.Mac,
@@ -103,21 +109,35 @@ func (d *Daemon) execRESTStart(w http.ResponseWriter, r *http.Request) {
 		Keyfile:     args.Keyfile,
 		Key:         args.Key,
 		TTL:         args.TTL,
+		Fwd:         args.Fwd,
+		Port:        args.Port,
+		MTU:         args.MTU,
 		LastSuccess: string(ls),
 		Enabled:     true,
 	}) != nil {
 		d.Restore.bumpInstance(args.Hash)
 	}
-	err = d.Restore.save()
+	err := d.Restore.save()
 	if err != nil {
 		ptp.Log(ptp.Error, "Failed to save instance information: %s", err.Error())
 	}
-	resp, err := getResponse(response.ExitCode, response.Output)
-	if err != nil {
-		ptp.Log(ptp.Error, "Internal error: %s", err)
-		return
+}
+
+// normalizeKey pads or truncates key to a valid AES key length
+func normalizeKey(key string) string {
+	if key == "" {
+		return key
 	}
-	w.Write(resp)
+	if len(key) < 16 {
+		key += "0000000000000000"[:16-len(key)]
+	} else if len(key) > 16 && len(key) < 24 {
+		key += "000000000000000000000000"[:24-len(key)]
+	} else if len(key) > 24 && len(key) < 32 {
+		key += "00000000000000000000000000000000"[:32-len(key)]
+	} else if len(key) > 32 {
+		key = key[:32]
+	}
+	return key
 }
 
 // Run starts a P2P instance
@@ -142,22 +162,23 @@ func (d *Daemon) run(args *RunArgs, resp *Response) error {
 	inst := d.Instances.getInstance(args.Hash)
 	if inst == nil {
 		resp.Output = resp.Output + "Lookup finished\n"
-		if args.Key != "" {
-			if len(args.Key) < 16 {
-				args.Key += "0000000000000000"[:16-len(args.Key)]
-			} else if len(args.Key) > 16 && len(args.Key) < 24 {
-				args.Key += "000000000000000000000000"[:24-len(args.Key)]
-			} else if len(args.Key) > 24 && len(args.Key) < 32 {
-				args.Key += "00000000000000000000000000000000"[:32-len(args.Key)]
-			} else if len(args.Key) > 32 {
-				args.Key = args.Key[:32]
-			}
-		}
+		args.Key = normalizeKey(args.Key)
 
 		newInst := new(P2PInstance)
 		newInst.ID = args.Hash
 		newInst.Args = *args
-		newInst.PTP = ptp.New(args.Mac, args.Hash, args.Keyfile, args.Key, args.TTL, TargetURL, args.Fwd, args.Port, OutboundIP)
+		newInst.PTP = ptp.NewFromConfig(d.settings.instanceConfig(ptp.Config{
+			Hash:       args.Hash,
+			Mac:        args.Mac,
+			Keyfile:    args.Keyfile,
+			Key:        args.Key,
+			TTL:        args.TTL,
+			Target:     TargetURL,
+			Forward:    args.Fwd,
+			Port:       args.Port,
+			OutboundIP: OutboundIP,
+			MTU:        args.MTU,
+		}))
 		if newInst.PTP == nil {
 			resp.Output = resp.Output + "Failed to create P2P Instance"
 			resp.ExitCode = 1
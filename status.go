@@ -21,20 +21,43 @@ type statusInstance struct {
 }
 
 type statusPeer struct {
-	ID        string `json:"id"`
-	IP        string `json:"ip"`
-	State     string `json:"state"`
-	LastError string `json:"lastError"`
+	ID        string         `json:"id"`
+	IP        string         `json:"ip"`
+	State     string         `json:"state"`
+	LastError string         `json:"lastError"`
+	Traffic   *TrafficOutput `json:"traffic,omitempty"`
 }
 
-// CommandStatus outputs connectivity status of each peer
-func CommandStatus(restPort int, hash string) {
-	out, err := sendRequestRaw(restPort, "status", &request{Hash: hash})
+// formatTraffic returns short representation of traffic counters
+func formatTraffic(c ptp.TrafficCounters) string {
+	return fmt.Sprintf("Tx:%d/%d|Rx:%d/%d", c.TxBytes, c.TxPackets, c.RxBytes, c.RxPackets)
+}
+
+// CommandStatus outputs connectivity status of each peer. When traffic
+// is set, bytes/packets counters are printed for every peer
+func CommandStatus(restPort int, hash string, traffic bool, format string) {
+	validateFormat(format)
+	if useGRPC() {
+		statusGRPC(restPort, hash, traffic, format)
+		return
+	}
+	out, err := sendRequestRaw(restPort, "status", &request{Hash: hash, Format: format})
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
+	if isStructured(format) {
+		response := new(StatusResponse)
+		err = json.Unmarshal(out, response)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to unmarshal status response: %s", err)
+			os.Exit(125)
+		}
+		printStructured(format, response)
+		os.Exit(response.Code)
+	}
+
 	response := new(statusResponse)
 	err = json.Unmarshal(out, response)
 	if err != nil {
@@ -47,6 +70,45 @@ func CommandStatus(restPort int, hash string) {
 		os.Exit(response.Code)
 	}
 
+	printStatus(response, hash, traffic)
+	os.Exit(0)
+}
+
+// statusGRPC outputs status received over gRPC control service
+func statusGRPC(restPort int, hash string, traffic bool, format string) {
+	instances, err := grpcStatus(restPort, hash)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if isStructured(format) {
+		printStructured(format, &StatusResponse{Version: OutputVersion, Instances: instances})
+		os.Exit(0)
+	}
+	response := &statusResponse{Instances: []*statusInstance{}}
+	for _, inst := range instances {
+		instance := &statusInstance{ID: inst.Hash, IP: inst.IP}
+		if hash != "" {
+			instance.ID = ""
+		}
+		for i := range inst.Peers {
+			peer := &inst.Peers[i]
+			instance.Peers = append(instance.Peers, &statusPeer{
+				ID:        peer.ID,
+				IP:        peer.IP,
+				State:     peer.State,
+				LastError: peer.LastError,
+				Traffic:   &peer.Traffic,
+			})
+		}
+		response.Instances = append(response.Instances, instance)
+	}
+	printStatus(response, hash, traffic)
+	os.Exit(0)
+}
+
+// printStatus prints status of peers in text form
+func printStatus(response *statusResponse, hash string, traffic bool) {
 	if len(hash) == 0 {
 		for _, instance := range response.Instances {
 			if len(hash) == 0 {
@@ -60,6 +122,12 @@ func CommandStatus(restPort int, hash string) {
 				if peer.LastError != "" {
 					fmt.Printf("LastError:%s", peer.LastError)
 				}
+				if traffic && peer.Traffic != nil {
+					fmt.Printf("%s|", formatTraffic(peer.Traffic.Total))
+					for _, path := range ptp.PathTypes {
+						fmt.Printf("%s:%s|", path, formatTraffic(peer.Traffic.Paths[path.String()]))
+					}
+				}
 				fmt.Printf("\n")
 			}
 		}
@@ -74,10 +142,16 @@ func CommandStatus(restPort int, hash string) {
 				fmt.Printf("\t\t\"state\": \"%s\"", peer.State)
 				if peer.LastError != "" {
 					fmt.Printf(",\n")
-					fmt.Printf("\t\t\"last_error\": \"%s\"\n", peer.IP)
-				} else {
-					fmt.Printf("\n")
+					fmt.Printf("\t\t\"last_error\": \"%s\"", peer.IP)
 				}
+				if traffic && peer.Traffic != nil {
+					fmt.Printf(",\n")
+					fmt.Printf("\t\t\"tx_bytes\": %d,\n", peer.Traffic.Total.TxBytes)
+					fmt.Printf("\t\t\"rx_bytes\": %d,\n", peer.Traffic.Total.RxBytes)
+					fmt.Printf("\t\t\"tx_packets\": %d,\n", peer.Traffic.Total.TxPackets)
+					fmt.Printf("\t\t\"rx_packets\": %d", peer.Traffic.Total.RxPackets)
+				}
+				fmt.Printf("\n")
 				fmt.Printf("\t}")
 				if i != len(instance.Peers) {
 					fmt.Printf(",")
@@ -87,10 +161,28 @@ func CommandStatus(restPort int, hash string) {
 		}
 		fmt.Printf("]\n")
 	}
-	os.Exit(0)
 }
 
 func (d *Daemon) execRESTStatus(w http.ResponseWriter, r *http.Request) {
+	args := new(DaemonArgs)
+	err := getJSON(r.Body, args)
+	if handleMarshalError(err, w) != nil {
+		return
+	}
+	if isStructured(args.Format) {
+		response := &StatusResponse{Version: OutputVersion, Instances: []InstanceOutput{}}
+		response.Code, response.Error = daemonState()
+		if response.Code == 0 {
+			response.Instances = d.newInstancesOutput(args.Hash, true)
+		}
+		output, err := json.Marshal(response)
+		if err != nil {
+			ptp.Log(ptp.Error, "Failed to marshal status response: %s", err)
+			return
+		}
+		w.Write(output)
+		return
+	}
 	if !ReadyToServe {
 		resp, _ := getResponse(105, "P2P Daemon is in initialization state")
 		w.Write(resp)
@@ -106,11 +198,6 @@ func (d *Daemon) execRESTStatus(w http.ResponseWriter, r *http.Request) {
 		w.Write(resp)
 		return
 	}
-	args := new(DaemonArgs)
-	err := getJSON(r.Body, args)
-	if handleMarshalError(err, w) != nil {
-		return
-	}
 	response, err := d.Status(args.Hash)
 	if err != nil {
 		ptp.Log(ptp.Error, "Internal error: %s", err)
@@ -152,6 +239,7 @@ func (d *Daemon) Status(hash string) (*statusResponse, error) {
 				IP:        peer.PeerLocalIP.String(),
 				State:     ptp.StringifyState(peer.State),
 				LastError: peer.LastError,
+				Traffic:   newTrafficOutput(peer),
 			})
 		}
 		response.Instances = append(response.Instances, instance)
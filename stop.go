@@ -12,7 +12,8 @@ import (
 // Function will send a request to the /stop/ REST endpoint with
 // specified hash that is needed to stop or interface name that's
 // needed to be removed from saved interfaces list
-func CommandStop(rpcPort int, hash, dev string) {
+func CommandStop(rpcPort int, hash, dev, format string) {
+	validateFormat(format)
 	args := &DaemonArgs{}
 	if hash != "" {
 		args.Hash = hash
@@ -30,6 +31,9 @@ func CommandStop(rpcPort int, hash, dev string) {
 		os.Exit(1)
 	}
 
+	if isStructured(format) {
+		printMessage(format, out)
+	}
 	fmt.Println(out.Message)
 	os.Exit(out.Code)
 }